@@ -0,0 +1,63 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"github.com/macadrich/go-bike/database"
+	"github.com/macadrich/go-bike/database/models"
+	"github.com/macadrich/go-bike/pkg/tracing"
+)
+
+// apiKeyPrefix marks go-bike API keys, so they are easy to recognise in
+// configuration files and secret scanners.
+const apiKeyPrefix = "gbk_"
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateAPIKey generates and stores a new API key. The key itself is only
+// returned here; the database holds its hash.
+func (s *service) CreateAPIKey(ctx context.Context, name string) (_ *models.APIKey, _ string, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "service.CreateAPIKey")
+	defer func() { tracing.End(span, err) }()
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", err
+	}
+	key := apiKeyPrefix + hex.EncodeToString(secret)
+
+	record := &models.APIKey{Name: name, KeyHash: hashAPIKey(key)}
+	if err := s.db.CreateAPIKey(ctx, record); err != nil {
+		return nil, "", err
+	}
+
+	return record, key, nil
+}
+
+// VerifyAPIKey reports whether key was created by CreateAPIKey.
+func (s *service) VerifyAPIKey(ctx context.Context, key string) (_ bool, err error) {
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return false, nil
+	}
+
+	ctx, span := tracing.Tracer().Start(ctx, "service.VerifyAPIKey")
+	defer func() { tracing.End(span, err) }()
+
+	_, err = s.db.UseAPIKey(ctx, hashAPIKey(key))
+	if errors.Is(err, database.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/macadrich/go-bike/config"
+	"github.com/macadrich/go-bike/database"
+	"github.com/macadrich/go-bike/database/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// apiKeysDB keeps API keys in memory, by hash.
+type apiKeysDB struct {
+	database.Database
+	keys map[string]models.APIKey
+}
+
+func (db *apiKeysDB) CreateAPIKey(ctx context.Context, key *models.APIKey) error {
+	key.Id = int64(len(db.keys) + 1)
+	db.keys[key.KeyHash] = *key
+	return nil
+}
+
+func (db *apiKeysDB) UseAPIKey(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	key, ok := db.keys[keyHash]
+	if !ok {
+		return nil, database.ErrNotFound
+	}
+	return &key, nil
+}
+
+func TestAPIKeys(t *testing.T) {
+	db := &apiKeysDB{keys: make(map[string]models.APIKey)}
+	s := &service{db: db, live: config.NewLive("", &config.Config{})}
+
+	record, key, err := s.CreateAPIKey(context.TODO(), "dashboard")
+	require.NoError(t, err)
+	assert.Equal(t, "dashboard", record.Name)
+	assert.True(t, strings.HasPrefix(key, apiKeyPrefix))
+	assert.NotContains(t, record.KeyHash, key)
+	assert.Len(t, record.KeyHash, 64)
+
+	ok, err := s.VerifyAPIKey(context.TODO(), key)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = s.VerifyAPIKey(context.TODO(), key+"0")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	ok, err = s.VerifyAPIKey(context.TODO(), "not-a-key")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
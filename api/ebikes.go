@@ -0,0 +1,101 @@
+package api
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/macadrich/go-bike/database/models"
+	"github.com/macadrich/go-bike/pkg/battery"
+	"github.com/macadrich/go-bike/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// EBikeBattery summarises the e-bike batteries in the latest snapshot,
+// system-wide and per kiosk, or for a single kiosk when kioskId is set.
+func (s *service) EBikeBattery(ctx context.Context, kioskId int) (_ *models.EBikeBattery, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "service.EBikeBattery")
+	defer func() { tracing.End(span, err) }()
+
+	at, err := s.db.LatestSnapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	bikes, err := s.db.EBikesAt(ctx, at)
+	if err != nil {
+		return nil, err
+	}
+
+	system, stations := battery.Summary(bikes)
+	if kioskId != 0 {
+		i := slices.IndexFunc(stations, func(d models.BatteryDistribution) bool { return d.KioskId == kioskId })
+		if i >= 0 {
+			stations = stations[i : i+1]
+		} else {
+			stations = []models.BatteryDistribution{{KioskId: kioskId}}
+		}
+	}
+	span.SetAttributes(attribute.Int("ebikes.count", system.Count))
+
+	return &models.EBikeBattery{
+		At:       at,
+		System:   system,
+		Stations: stations,
+	}, nil
+}
+
+// LowBatteryBikes lists the e-bikes in the latest snapshot below threshold
+// percent, or the configured EBikes.LowBattery when threshold is zero, with
+// how long their dock has held a low e-bike.
+func (s *service) LowBatteryBikes(ctx context.Context, threshold int) (_ *models.LowBatteryReport, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "service.LowBatteryBikes")
+	defer func() { tracing.End(span, err) }()
+
+	cfg := s.cfg().EBikes
+	if threshold == 0 {
+		threshold = cfg.LowBattery
+	}
+
+	at, err := s.db.LatestSnapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	bikes, err := s.db.LowBatteryBikes(ctx, at, threshold, at.Add(-cfg.LowBatteryLookback))
+	if err != nil {
+		return nil, err
+	}
+	for i := range bikes {
+		bikes[i].LowForMinutes = int(at.Sub(bikes[i].LowSince).Minutes())
+	}
+	span.SetAttributes(attribute.Int("ebikes.threshold", threshold), attribute.Int("ebikes.low", len(bikes)))
+
+	return &models.LowBatteryReport{
+		At:        at,
+		Threshold: threshold,
+		Bikes:     bikes,
+	}, nil
+}
+
+// DockBatteryHistory returns what one dock held in every snapshot between
+// from and to, for charting the battery of the e-bikes docked there.
+func (s *service) DockBatteryHistory(ctx context.Context, kioskId, dockNumber int, from, to time.Time) (_ *models.DockBatteryHistory, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "service.DockBatteryHistory")
+	defer func() { tracing.End(span, err) }()
+
+	span.SetAttributes(attribute.Int("kiosk.id", kioskId), attribute.Int("dock.number", dockNumber))
+
+	points, err := s.db.DockBatteryHistory(ctx, kioskId, dockNumber, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.DockBatteryHistory{
+		KioskId:    kioskId,
+		DockNumber: dockNumber,
+		From:       from,
+		To:         to,
+		Points:     points,
+	}, nil
+}
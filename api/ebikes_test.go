@@ -0,0 +1,84 @@
+package api
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/macadrich/go-bike/config"
+	"github.com/macadrich/go-bike/database"
+	"github.com/macadrich/go-bike/database/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ebikesDB serves one snapshot of e-bikes.
+type ebikesDB struct {
+	database.Database
+	at        time.Time
+	bikes     []models.Bike
+	threshold int
+	since     time.Time
+}
+
+func (db *ebikesDB) LatestSnapshot(ctx context.Context) (time.Time, error) {
+	return db.at, nil
+}
+
+func (db *ebikesDB) EBikesAt(ctx context.Context, at time.Time) ([]models.Bike, error) {
+	return db.bikes, nil
+}
+
+func (db *ebikesDB) LowBatteryBikes(ctx context.Context, at time.Time, threshold int, since time.Time) ([]models.LowBatteryBike, error) {
+	db.threshold, db.since = threshold, since
+	return []models.LowBatteryBike{{KioskId: 3005, DockNumber: 5, Battery: 4, LowSince: at.Add(-90 * time.Minute)}}, nil
+}
+
+func newEBikesService(db *ebikesDB) *service {
+	cfg := &config.Config{}
+	cfg.EBikes = config.EBikesConfig{LowBattery: 20, LowBatteryLookback: 24 * time.Hour}
+	return &service{db: db, live: config.NewLive("", cfg)}
+}
+
+func TestEBikeBattery(t *testing.T) {
+	at := time.Date(2024, 5, 14, 6, 48, 0, 0, time.UTC)
+	db := &ebikesDB{at: at, bikes: []models.Bike{
+		{KioskId: 3005, IsElectric: true, Battery: 30},
+		{KioskId: 3006, IsElectric: true, Battery: 90},
+	}}
+	s := newEBikesService(db)
+
+	result, err := s.EBikeBattery(context.TODO(), 0)
+	require.NoError(t, err)
+	assert.Equal(t, at, result.At)
+	assert.Equal(t, 2, result.System.Count)
+	assert.Len(t, result.Stations, 2)
+
+	result, err = s.EBikeBattery(context.TODO(), 3006)
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.System.Count)
+	require.Len(t, result.Stations, 1)
+	assert.Equal(t, 90, result.Stations[0].Max)
+
+	result, err = s.EBikeBattery(context.TODO(), 3999)
+	require.NoError(t, err)
+	assert.Equal(t, []models.BatteryDistribution{{KioskId: 3999}}, result.Stations)
+}
+
+func TestLowBatteryBikes(t *testing.T) {
+	at := time.Date(2024, 5, 14, 6, 48, 0, 0, time.UTC)
+	db := &ebikesDB{at: at}
+	s := newEBikesService(db)
+
+	report, err := s.LowBatteryBikes(context.TODO(), 0)
+	require.NoError(t, err)
+	assert.Equal(t, 20, report.Threshold)
+	assert.Equal(t, 20, db.threshold)
+	assert.Equal(t, at.Add(-24*time.Hour), db.since)
+	require.Len(t, report.Bikes, 1)
+	assert.Equal(t, 90, report.Bikes[0].LowForMinutes)
+
+	report, err = s.LowBatteryBikes(context.TODO(), 50)
+	require.NoError(t, err)
+	assert.Equal(t, 50, report.Threshold)
+}
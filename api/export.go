@@ -0,0 +1,58 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/macadrich/go-bike/pkg/export"
+	"github.com/macadrich/go-bike/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ExportRequest selects the rows of an export: the snapshots of Dataset in
+// [From, To), of one kiosk when KioskId is set, written in Format.
+type ExportRequest struct {
+	Dataset string
+	Format  string
+	From    time.Time
+	To      time.Time
+	KioskId int
+}
+
+// Filename names an export after its dataset and range, e.g.
+// stations-20240501T000000Z-20240508T000000Z.csv.
+func (req ExportRequest) Filename() string {
+	const layout = "20060102T150405Z"
+	return fmt.Sprintf("%s-%s-%s.%s", req.Dataset, req.From.UTC().Format(layout), req.To.UTC().Format(layout), req.Format)
+}
+
+// Export streams the rows of req to w as they are read from the
+// database. Rows already written stay written when it fails midway.
+func (s *service) Export(ctx context.Context, req ExportRequest, w io.Writer) (err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "service.Export")
+	defer func() { tracing.End(span, err) }()
+
+	span.SetAttributes(
+		attribute.String("export.dataset", req.Dataset),
+		attribute.String("export.format", req.Format),
+		attribute.Int("kiosk.id", req.KioskId),
+	)
+
+	columns, ok := export.Columns(req.Dataset)
+	if !ok {
+		return fmt.Errorf("unknown export dataset %q", req.Dataset)
+	}
+
+	out, err := export.NewWriter(w, req.Format, columns)
+	if err != nil {
+		return err
+	}
+
+	if err := s.db.Export(ctx, req.Dataset, req.From, req.To, req.KioskId, out.Write); err != nil {
+		return err
+	}
+
+	return out.Close()
+}
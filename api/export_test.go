@@ -0,0 +1,55 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/macadrich/go-bike/config"
+	"github.com/macadrich/go-bike/database"
+	"github.com/macadrich/go-bike/pkg/export"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// exportDB serves two weather observations.
+type exportDB struct {
+	database.Database
+	dataset string
+}
+
+func (db *exportDB) Export(ctx context.Context, dataset string, from, to time.Time, kioskId int, fn func(row []any) error) error {
+	db.dataset = dataset
+	for _, row := range [][]any{
+		{from, "Rain", "light rain", 58.3, 57.9, int64(88), 9.2, int64(100)},
+		{from.Add(time.Hour), "Clouds", nil, 60.1, 59.0, int64(70), 4.0, int64(75)},
+	} {
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestExport(t *testing.T) {
+	db := &exportDB{}
+	s := &service{db: db, live: config.NewLive("", &config.Config{})}
+	from := time.Date(2024, 5, 14, 6, 0, 0, 0, time.UTC)
+
+	var out bytes.Buffer
+	err := s.Export(context.Background(), ExportRequest{
+		Dataset: export.DatasetWeather,
+		Format:  export.FormatCSV,
+		From:    from,
+		To:      from.Add(24 * time.Hour),
+	}, &out)
+	require.NoError(t, err)
+	assert.Equal(t, export.DatasetWeather, db.dataset)
+	assert.Equal(t, "at,condition,description,temperature,feels_like,humidity,wind_speed,clouds\n"+
+		"2024-05-14T06:00:00Z,Rain,light rain,58.3,57.9,88,9.2,100\n"+
+		"2024-05-14T07:00:00Z,Clouds,,60.1,59,70,4,75\n", out.String())
+
+	err = s.Export(context.Background(), ExportRequest{Dataset: export.DatasetWeather, Format: "xlsx"}, &out)
+	assert.ErrorIs(t, err, export.ErrUnknownFormat)
+}
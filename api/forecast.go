@@ -0,0 +1,164 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/macadrich/go-bike/database/models"
+	"github.com/macadrich/go-bike/pkg/apperr"
+	"github.com/macadrich/go-bike/pkg/forecast"
+	"github.com/macadrich/go-bike/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// forecastHistory is how much hourly history a forecast is trained on.
+const forecastHistory = 8 * 7 * 24 * time.Hour
+
+// maxWeatherAge is how old the latest observation may be to count as the
+// current weather.
+const maxWeatherAge = 3 * time.Hour
+
+// minWeatherSamples is how many past hours with the current weather are
+// needed before a forecast is adjusted for it.
+const minWeatherSamples = 3
+
+// wetConditions are the OpenWeather groups in which fewer people ride.
+var wetConditions = []string{"Rain", "Drizzle", "Thunderstorm", "Snow"}
+
+// weatherKind groups conditions into wet and dry.
+func weatherKind(condition string) string {
+	if slices.Contains(wetConditions, condition) {
+		return "wet"
+	}
+	return "dry"
+}
+
+// WeatherObservation extracts what is stored of an OpenWeather response.
+func WeatherObservation(w *models.WeatherMap) *models.WeatherObservation {
+	obs := &models.WeatherObservation{
+		At:          time.Unix(int64(w.Dt), 0).UTC(),
+		Temperature: float64(w.Main.Temp),
+		FeelsLike:   float64(w.Main.FeelsLike),
+		Humidity:    w.Main.Humidity,
+		WindSpeed:   float64(w.Wind.Speed),
+		Clouds:      w.Clouds.All,
+	}
+	if len(w.Weather) > 0 {
+		obs.Condition = w.Weather[0].Main
+		obs.Description = w.Weather[0].Description
+	}
+	return obs
+}
+
+// weatherMap is the inverse of WeatherObservation, for answering with a
+// stored observation where the current weather would go.
+func weatherMap(obs *models.WeatherObservation) models.WeatherMap {
+	return models.WeatherMap{
+		Dt:      int(obs.At.Unix()),
+		Main:    models.Main{Temp: float32(obs.Temperature), FeelsLike: float32(obs.FeelsLike), Humidity: obs.Humidity},
+		Wind:    models.Wind{Speed: float32(obs.WindSpeed)},
+		Clouds:  models.Clouds{All: obs.Clouds},
+		Weather: []models.Weather{{Main: obs.Condition, Description: obs.Description}},
+	}
+}
+
+// recordWeather stores the current weather for forecasting and export.
+func (s *service) recordWeather(ctx context.Context) error {
+	weather, err := s.fetchWeather(ctx)
+	if err != nil {
+		return err
+	}
+	if weather.Dt == 0 {
+		return nil
+	}
+
+	return s.db.InsertWeather(ctx, WeatherObservation(weather))
+}
+
+// Forecast predicts the availability of a kiosk horizon after its latest
+// snapshot from its hour-of-week baseline. With weather, the baseline is
+// shifted by how the station usually differs from it in the current kind
+// of weather, when that is known.
+func (s *service) Forecast(ctx context.Context, kioskId int, horizon time.Duration, weather bool) (_ *models.StationForecast, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "service.Forecast")
+	defer func() { tracing.End(span, err) }()
+
+	span.SetAttributes(attribute.Int("kiosk.id", kioskId), attribute.String("forecast.horizon", horizon.String()))
+
+	at, err := s.db.LatestSnapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	station, err := s.db.QuerySpecificStation(ctx, kioskId, at)
+	if err != nil {
+		return nil, err
+	}
+	if station.At.IsZero() {
+		return nil, apperr.NotFound("station", fmt.Sprintf("station %d not found", kioskId))
+	}
+
+	from := station.At.Add(-forecastHistory)
+	points, err := s.db.StationHistory(ctx, kioskId, from, station.At, models.ResolutionHour)
+	if err != nil {
+		return nil, err
+	}
+
+	loc := s.cfg().ThirdpartyAPI.Location()
+	model := forecast.Train(points, loc)
+
+	in := forecast.Input{
+		At:      station.At,
+		Bikes:   station.BikesAvailable,
+		Docks:   station.DocksAvailable,
+		Horizon: horizon,
+	}
+
+	var condition string
+	if weather {
+		observations, err := s.db.WeatherHistory(ctx, from, station.At.Add(time.Minute))
+		if err != nil {
+			return nil, err
+		}
+		condition, in.Adjust = s.weatherAdjustment(model, points, observations, station.At)
+	}
+
+	result := model.Predict(in)
+	result.KioskId = kioskId
+	result.Weather = condition
+	span.SetAttributes(attribute.Float64("forecast.bikes", result.Bikes.Predicted))
+
+	return &result, nil
+}
+
+// weatherAdjustment returns the current weather condition and how many
+// bikes the station differs from its baseline on average in past hours of
+// the same kind of weather. Both are zero without recent weather or
+// enough history.
+func (s *service) weatherAdjustment(model *forecast.Model, points []models.HistoryPoint, observations []models.WeatherObservation, at time.Time) (string, float64) {
+	if len(observations) == 0 {
+		return "", 0
+	}
+	current := observations[len(observations)-1]
+	if at.Sub(current.At) > maxWeatherAge {
+		return "", 0
+	}
+
+	// The kind of weather of every hour, from its last observation.
+	kinds := make(map[int64]string)
+	for _, obs := range observations {
+		kinds[obs.At.Truncate(time.Hour).Unix()] = weatherKind(obs.Condition)
+	}
+
+	kind := weatherKind(current.Condition)
+	residual, n := model.Residual(points, func(t time.Time) bool {
+		return kinds[t.Truncate(time.Hour).Unix()] == kind
+	})
+	if n < minWeatherSamples {
+		return current.Condition, 0
+	}
+
+	return current.Condition, residual
+}
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/macadrich/go-bike/config"
+	"github.com/macadrich/go-bike/database"
+	"github.com/macadrich/go-bike/database/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// forecastDB serves four weeks of hourly history of kiosk 3005, which
+// holds 6 bikes when dry and 2 when it rains, as it did in weeks 1 and 3.
+type forecastDB struct {
+	database.Database
+	at      time.Time
+	station models.Stations
+	points  []models.HistoryPoint
+	weather []models.WeatherObservation
+}
+
+func newForecastDB(at time.Time) *forecastDB {
+	db := &forecastDB{
+		at:      at,
+		station: models.Stations{At: at, KioskId: 3005, BikesAvailable: 2, DocksAvailable: 10},
+	}
+
+	start := at.Add(-4 * 7 * 24 * time.Hour)
+	for t := start; t.Before(at); t = t.Add(time.Hour) {
+		bikes, condition := 6.0, "Clear"
+		if week := int(t.Sub(start).Hours()) / (7 * 24); week%2 == 0 {
+			bikes, condition = 2, "Rain"
+		}
+		db.points = append(db.points, models.HistoryPoint{At: t, Samples: 60, BikesAvg: bikes})
+		db.weather = append(db.weather, models.WeatherObservation{At: t.Add(10 * time.Minute), Condition: condition})
+	}
+	db.weather = append(db.weather, models.WeatherObservation{At: at.Add(-5 * time.Minute), Condition: "Rain"})
+
+	return db
+}
+
+func (db *forecastDB) LatestSnapshot(ctx context.Context) (time.Time, error) {
+	return db.at, nil
+}
+
+func (db *forecastDB) QuerySpecificStation(ctx context.Context, kioskId int, at time.Time) (*models.Stations, error) {
+	if kioskId != db.station.KioskId {
+		return &models.Stations{}, nil
+	}
+	return &db.station, nil
+}
+
+func (db *forecastDB) StationHistory(ctx context.Context, kioskId int, from, to time.Time, resolution string) ([]models.HistoryPoint, error) {
+	return db.points, nil
+}
+
+func (db *forecastDB) WeatherHistory(ctx context.Context, from, to time.Time) ([]models.WeatherObservation, error) {
+	return db.weather, nil
+}
+
+func newForecastService(db *forecastDB) *service {
+	cfg := &config.Config{}
+	cfg.ThirdpartyAPI.TimeZone = "America/New_York"
+	return &service{db: db, live: config.NewLive("", cfg)}
+}
+
+func TestForecast(t *testing.T) {
+	at := time.Date(2024, 5, 14, 6, 0, 0, 0, time.UTC)
+	s := newForecastService(newForecastDB(at))
+
+	// A day ahead the current state no longer matters: the baseline is the
+	// mean of wet and dry weeks.
+	result, err := s.Forecast(context.Background(), 3005, 24*time.Hour, false)
+	require.NoError(t, err)
+	assert.Equal(t, 3005, result.KioskId)
+	assert.Equal(t, at.Add(24*time.Hour), result.Target)
+	assert.InDelta(t, 4, result.Bikes.Predicted, 0.01)
+	assert.InDelta(t, 8, result.Docks.Predicted, 0.01)
+	assert.Less(t, result.Bikes.Low, result.Bikes.Predicted)
+	assert.Greater(t, result.Bikes.High, result.Bikes.Predicted)
+	assert.Equal(t, 4, result.Samples)
+	assert.Empty(t, result.Weather)
+
+	// It is raining, so the station is expected to stay as low as on wet
+	// days.
+	result, err = s.Forecast(context.Background(), 3005, 24*time.Hour, true)
+	require.NoError(t, err)
+	assert.Equal(t, "Rain", result.Weather)
+	assert.InDelta(t, 2, result.Bikes.Predicted, 0.01)
+
+	_, err = s.Forecast(context.Background(), 9999, time.Hour, false)
+	assert.ErrorIs(t, err, database.ErrNotFound)
+}
+
+func TestWeatherObservation(t *testing.T) {
+	w := &models.WeatherMap{
+		Dt:      1715669299,
+		Weather: []models.Weather{{Main: "Rain", Description: "light rain"}},
+	}
+	w.Main.Temp = 61.5
+	w.Main.Humidity = 80
+
+	obs := WeatherObservation(w)
+	assert.Equal(t, time.Date(2024, 5, 14, 6, 48, 19, 0, time.UTC), obs.At)
+	assert.Equal(t, "Rain", obs.Condition)
+	assert.Equal(t, "light rain", obs.Description)
+	assert.Equal(t, 61.5, obs.Temperature)
+	assert.Equal(t, 80, obs.Humidity)
+	assert.Equal(t, "wet", weatherKind(obs.Condition))
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/macadrich/go-bike/api/problem"
+	"github.com/macadrich/go-bike/api/validate"
+	"github.com/macadrich/go-bike/pkg/tracing"
+)
+
+// maxDockHistoryRange bounds dock battery charts, which are read from the
+// raw snapshots.
+const maxDockHistoryRange = 31 * 24 * time.Hour
+
+// EBikeBattery shows the distribution of e-bike batteries in the latest
+// snapshot, system-wide and per station, or for one station with kioskId.
+//
+// @Summary Show e-bike battery levels
+// @Tags ebikes
+// @Produce json
+// @Param kioskId query int false "Only this kiosk" minimum(1)
+// @Success 200 {object} models.EBikeBattery
+// @Failure 400,401,429,500 {object} problem.Problem
+// @Security BearerAuth
+// @Router /api/v1/ebikes/battery [get]
+// @Router /api/v2/ebikes/battery [get]
+func (h *Handlers) EBikeBattery(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "Handlers.EBikeBattery")
+	defer span.End()
+
+	v := validate.New(r)
+	kioskId := v.ID("kioskId")
+	if err := v.Err(); err != nil {
+		problem.Write(w, r, err)
+		return
+	}
+
+	result, err := h.svc.EBikeBattery(ctx, int(kioskId))
+	if err != nil {
+		sendError(w, r, span, err, "Unable to get e-bike batteries")
+		return
+	}
+
+	sendResponse(w, http.StatusOK, result)
+}
+
+// LowBatteryBikes lists the e-bikes below threshold percent (default
+// EBikes.LowBattery) and how long they have been low, longest first.
+//
+// @Summary List e-bikes with a low battery
+// @Tags ebikes
+// @Produce json
+// @Param threshold query int false "Battery percentage below which a bike is low" minimum(1) maximum(100)
+// @Success 200 {object} models.LowBatteryReport
+// @Failure 400,401,429,500 {object} problem.Problem
+// @Security BearerAuth
+// @Router /api/v1/ebikes/low [get]
+// @Router /api/v2/ebikes/low [get]
+func (h *Handlers) LowBatteryBikes(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "Handlers.LowBatteryBikes")
+	defer span.End()
+
+	v := validate.New(r)
+	threshold := v.Int("threshold", 0, 1, 100)
+	if err := v.Err(); err != nil {
+		problem.Write(w, r, err)
+		return
+	}
+
+	report, err := h.svc.LowBatteryBikes(ctx, threshold)
+	if err != nil {
+		sendError(w, r, span, err, "Unable to get low-battery e-bikes")
+		return
+	}
+
+	sendResponse(w, http.StatusOK, report)
+}
+
+// DockBatteryHistory returns what a dock held in every snapshot between
+// from and to (default now), with the battery of docked e-bikes.
+//
+// @Summary Get the battery history of a dock
+// @Tags ebikes
+// @Produce json
+// @Param kioskId path int true "Kiosk id" minimum(1)
+// @Param dockNumber path int true "Dock number" minimum(0)
+// @Param from query string true "Start of the range: RFC 3339, Unix seconds, now, or relative such as -24h"
+// @Param to query string false "End of the range, in the same formats as from; default now"
+// @Success 200 {object} models.DockBatteryHistory
+// @Failure 400,401,429,500 {object} problem.Problem
+// @Security BearerAuth
+// @Router /api/v1/ebikes/stations/{kioskId}/docks/{dockNumber}/history [get]
+// @Router /api/v2/ebikes/stations/{kioskId}/docks/{dockNumber}/history [get]
+func (h *Handlers) DockBatteryHistory(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "Handlers.DockBatteryHistory")
+	defer span.End()
+
+	v := validate.New(r)
+	kioskId := v.PathID("kioskId")
+	dockNumber := v.PathInt("dockNumber", 0, math.MaxInt)
+	from, to := v.TimeRange(maxDockHistoryRange)
+	if err := v.Err(); err != nil {
+		problem.Write(w, r, err)
+		return
+	}
+
+	history, err := h.svc.DockBatteryHistory(ctx, int(kioskId), dockNumber, from, to)
+	if err != nil {
+		sendError(w, r, span, err, "Unable to get dock battery history")
+		return
+	}
+
+	sendResponse(w, http.StatusOK, history)
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/macadrich/go-bike/database/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEBikeBattery(t *testing.T) {
+	tests := []struct {
+		url  string
+		want int
+	}{
+		{"/api/v1/ebikes/battery", http.StatusOK},
+		{"/api/v1/ebikes/battery?kioskId=3005", http.StatusOK},
+		{"/api/v1/ebikes/battery?kioskId=abc", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			mockDB := NewMockDB()
+			mockDB.On("EBikeBattery", 0).Return(&models.EBikeBattery{}, nil)
+			mockDB.On("EBikeBattery", 3005).Return(&models.EBikeBattery{}, nil)
+			handlers := NewHandlers(mockDB)
+
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(handlers.EBikeBattery).ServeHTTP(rr, httptest.NewRequest("GET", tt.url, nil))
+			assert.Equal(t, tt.want, rr.Code)
+		})
+	}
+}
+
+func TestLowBatteryBikes(t *testing.T) {
+	tests := []struct {
+		url  string
+		want int
+	}{
+		{"/api/v1/ebikes/low", http.StatusOK},
+		{"/api/v1/ebikes/low?threshold=30", http.StatusOK},
+		{"/api/v1/ebikes/low?threshold=0", http.StatusBadRequest},
+		{"/api/v1/ebikes/low?threshold=101", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			mockDB := NewMockDB()
+			mockDB.On("LowBatteryBikes", 0).Return(&models.LowBatteryReport{}, nil)
+			mockDB.On("LowBatteryBikes", 30).Return(&models.LowBatteryReport{}, nil)
+			handlers := NewHandlers(mockDB)
+
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(handlers.LowBatteryBikes).ServeHTTP(rr, httptest.NewRequest("GET", tt.url, nil))
+			assert.Equal(t, tt.want, rr.Code)
+		})
+	}
+}
+
+func TestDockBatteryHistory(t *testing.T) {
+	from := time.Date(2024, 5, 14, 0, 0, 0, 0, time.UTC)
+	to := from.Add(24 * time.Hour)
+
+	tests := []struct {
+		name   string
+		kiosk  string
+		dock   string
+		params string
+		want   int
+	}{
+		{"valid", "3005", "5", "from=2024-05-14T00:00:00Z&to=2024-05-15T00:00:00Z", http.StatusOK},
+		{"invalid kiosk", "abc", "5", "from=2024-05-14T00:00:00Z&to=2024-05-15T00:00:00Z", http.StatusBadRequest},
+		{"invalid dock", "3005", "x", "from=2024-05-14T00:00:00Z&to=2024-05-15T00:00:00Z", http.StatusBadRequest},
+		{"missing from", "3005", "5", "", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := NewMockDB()
+			mockDB.On("DockBatteryHistory", 3005, 5, from, to).Return(&models.DockBatteryHistory{}, nil)
+			handlers := NewHandlers(mockDB)
+
+			router := chi.NewRouter()
+			router.Get("/ebikes/stations/{kioskId}/docks/{dockNumber}/history", handlers.DockBatteryHistory)
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest("GET", "/ebikes/stations/"+tt.kiosk+"/docks/"+tt.dock+"/history?"+tt.params, nil))
+			assert.Equal(t, tt.want, rr.Code)
+		})
+	}
+}
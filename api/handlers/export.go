@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/macadrich/go-bike/api"
+	"github.com/macadrich/go-bike/api/problem"
+	"github.com/macadrich/go-bike/api/validate"
+	"github.com/macadrich/go-bike/pkg/export"
+	"github.com/macadrich/go-bike/pkg/tracing"
+)
+
+// maxExportRange bounds a single export. Rows are streamed, so the limit
+// only guards against accidental exports of the whole history.
+const maxExportRange = 366 * 24 * time.Hour
+
+// writeTracker records whether anything has been written to a response.
+type writeTracker struct {
+	http.ResponseWriter
+	written bool
+}
+
+func (w *writeTracker) Write(b []byte) (int, error) {
+	w.written = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Export streams the snapshots of a dataset (stations, bikes or weather;
+// default stations) between from and to (default now) as csv (default),
+// ndjson or parquet, optionally of one kiosk.
+//
+// @Summary Export snapshots
+// @Tags export
+// @Produce text/csv,application/x-ndjson,application/vnd.apache.parquet
+// @Param dataset query string false "Dataset" Enums(stations, bikes, weather) default(stations)
+// @Param format query string false "File format" Enums(csv, ndjson, parquet) default(csv)
+// @Param from query string true "Start of the range: RFC 3339, Unix seconds, now, or relative such as -24h"
+// @Param to query string false "End of the range, in the same formats as from; default now"
+// @Param kioskId query int false "Only this kiosk; not for weather" minimum(1)
+// @Success 200 {file} file
+// @Failure 400,401,429,500 {object} problem.Problem
+// @Security BearerAuth
+// @Router /api/v1/export [get]
+// @Router /api/v2/export [get]
+func (h *Handlers) Export(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "Handlers.Export")
+	defer span.End()
+
+	v := validate.New(r)
+	req := api.ExportRequest{
+		Dataset: v.Enum("dataset", export.DatasetStations, export.DatasetStations, export.DatasetBikes, export.DatasetWeather),
+		Format:  v.Enum("format", export.FormatCSV, export.FormatCSV, export.FormatNDJSON, export.FormatParquet),
+		KioskId: int(v.ID("kioskId")),
+	}
+	req.From, req.To = v.TimeRange(maxExportRange)
+	if req.KioskId != 0 && req.Dataset == export.DatasetWeather {
+		v.Invalid("kioskId", validate.InQuery, "does not apply to weather")
+	}
+	if err := v.Err(); err != nil {
+		problem.Write(w, r, err)
+		return
+	}
+
+	// Large exports outlive the server's write timeout.
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", export.ContentType(req.Format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", req.Filename()))
+
+	out := &writeTracker{ResponseWriter: w}
+	if err := h.svc.Export(ctx, req, out); err != nil {
+		if !out.written {
+			w.Header().Del("Content-Disposition")
+			sendError(w, r, span, err, "Unable to export "+req.Dataset)
+			return
+		}
+		span.RecordError(err)
+		// The status is already sent; the truncated body is all the
+		// client gets.
+		log.Println("error exporting", req.Dataset+":", err)
+	}
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/macadrich/go-bike/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExport(t *testing.T) {
+	from := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 5, 8, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		url  string
+		want int
+	}{
+		{"default csv", "/api/v1/export?from=2024-05-01T00:00:00Z&to=2024-05-08T00:00:00Z", http.StatusOK},
+		{"bikes of a kiosk", "/api/v1/export?from=2024-05-01T00:00:00Z&to=2024-05-08T00:00:00Z&dataset=bikes&format=ndjson&kioskId=3005", http.StatusOK},
+		{"failed before writing", "/api/v1/export?from=2024-05-01T00:00:00Z&to=2024-05-08T00:00:00Z&dataset=weather&format=parquet", http.StatusInternalServerError},
+		{"unknown dataset", "/api/v1/export?from=2024-05-01T00:00:00Z&dataset=trips", http.StatusBadRequest},
+		{"unknown format", "/api/v1/export?from=2024-05-01T00:00:00Z&format=xlsx", http.StatusBadRequest},
+		{"missing from", "/api/v1/export", http.StatusBadRequest},
+		{"invalid kiosk", "/api/v1/export?from=2024-05-01T00:00:00Z&kioskId=abc", http.StatusBadRequest},
+		{"weather of a kiosk", "/api/v1/export?from=2024-05-01T00:00:00Z&dataset=weather&kioskId=3005", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := NewMockDB()
+			mockDB.On("Export", api.ExportRequest{Dataset: "stations", Format: "csv", From: from, To: to}).Return(nil)
+			mockDB.On("Export", api.ExportRequest{Dataset: "bikes", Format: "ndjson", From: from, To: to, KioskId: 3005}).Return(nil)
+			mockDB.On("Export", mock.MatchedBy(func(req api.ExportRequest) bool { return req.Dataset == "weather" })).
+				Return(errors.New("connection refused"))
+			handlers := NewHandlers(mockDB)
+
+			req, err := http.NewRequest("GET", tt.url, nil)
+			require.NoError(t, err)
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(handlers.Export).ServeHTTP(rr, req)
+			assert.Equal(t, tt.want, rr.Code)
+		})
+	}
+}
+
+func TestExportHeaders(t *testing.T) {
+	mockDB := NewMockDB()
+	mockDB.On("Export", mock.Anything).Return(nil)
+	handlers := NewHandlers(mockDB)
+
+	req, err := http.NewRequest("GET", "/api/v1/export?from=2024-05-01T00:00:00Z&to=2024-05-08T00:00:00Z&format=ndjson", nil)
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(handlers.Export).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/x-ndjson", rr.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="stations-20240501T000000Z-20240508T000000Z.ndjson"`, rr.Header().Get("Content-Disposition"))
+	assert.Equal(t, "row\n", rr.Body.String())
+}
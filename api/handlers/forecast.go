@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/macadrich/go-bike/api/problem"
+	"github.com/macadrich/go-bike/api/validate"
+	"github.com/macadrich/go-bike/pkg/tracing"
+)
+
+const (
+	defaultForecastHorizon = 30 * time.Minute
+	minForecastHorizon     = 5 * time.Minute
+	maxForecastHorizon     = 24 * time.Hour
+)
+
+// StationForecast predicts the bikes and docks of a station horizon
+// (default 30m) after its latest snapshot, with a confidence band. With
+// weather=true the prediction is adjusted for the current weather.
+//
+// @Summary Forecast the availability of a station
+// @Tags stations
+// @Produce json
+// @Param kioskId path int true "Kiosk id" minimum(1)
+// @Param horizon query string false "How far ahead, a duration between 5m and 24h" default(30m)
+// @Param weather query bool false "Adjust the prediction for the current weather" default(false)
+// @Success 200 {object} models.StationForecast
+// @Failure 400,401,404,429,500 {object} problem.Problem
+// @Security BearerAuth
+// @Router /api/v1/stations/{kioskId}/forecast [get]
+// @Router /api/v2/stations/{kioskId}/forecast [get]
+func (h *Handlers) StationForecast(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "Handlers.StationForecast")
+	defer span.End()
+
+	v := validate.New(r)
+	kioskId := v.PathID("kioskId")
+	horizon := v.Duration("horizon", defaultForecastHorizon, minForecastHorizon, maxForecastHorizon)
+	weather := v.Bool("weather", false)
+	if err := v.Err(); err != nil {
+		problem.Write(w, r, err)
+		return
+	}
+
+	result, err := h.svc.Forecast(ctx, int(kioskId), horizon, weather)
+	if err != nil {
+		sendError(w, r, span, err, "Unable to forecast station")
+		return
+	}
+
+	sendResponse(w, http.StatusOK, result)
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/macadrich/go-bike/database"
+	"github.com/macadrich/go-bike/database/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStationForecast(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		want int
+	}{
+		{"default horizon", "/stations/3005/forecast", http.StatusOK},
+		{"with weather", "/stations/3005/forecast?horizon=2h&weather=true", http.StatusOK},
+		{"unknown kiosk", "/stations/9999/forecast", http.StatusNotFound},
+		{"invalid kiosk", "/stations/abc/forecast", http.StatusBadRequest},
+		{"horizon too short", "/stations/3005/forecast?horizon=1m", http.StatusBadRequest},
+		{"horizon too long", "/stations/3005/forecast?horizon=48h", http.StatusBadRequest},
+		{"invalid weather", "/stations/3005/forecast?weather=maybe", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := NewMockDB()
+			mockDB.On("Forecast", 3005, 30*time.Minute, false).Return(&models.StationForecast{KioskId: 3005}, nil)
+			mockDB.On("Forecast", 3005, 2*time.Hour, true).Return(&models.StationForecast{KioskId: 3005, Weather: "Rain"}, nil)
+			mockDB.On("Forecast", 9999, 30*time.Minute, false).Return(nil, database.ErrNotFound)
+			handlers := NewHandlers(mockDB)
+
+			router := chi.NewRouter()
+			router.Get("/stations/{kioskId}/forecast", handlers.StationForecast)
+
+			req, err := http.NewRequest("GET", tt.url, nil)
+			require.NoError(t, err)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			assert.Equal(t, tt.want, rr.Code)
+		})
+	}
+}
@@ -1,14 +1,17 @@
 package handlers
 
 import (
+	"context"
 	"fmt"
 	"net/http"
-	"strconv"
 
-	"github.com/go-chi/chi/v5"
 	"github.com/macadrich/go-bike/api"
+	"github.com/macadrich/go-bike/api/problem"
+	"github.com/macadrich/go-bike/api/validate"
+	"github.com/macadrich/go-bike/database/models"
 	_ "github.com/macadrich/go-bike/docs"
-	"github.com/macadrich/go-bike/pkg/utils"
+	"github.com/macadrich/go-bike/pkg/health"
+	"github.com/macadrich/go-bike/pkg/tracing"
 )
 
 type Handlers struct {
@@ -19,23 +22,69 @@ func NewHandlers(svc api.IService) *Handlers {
 	return &Handlers{svc}
 }
 
+// VerifyAPIKey reports whether key is a valid API key. It backs the
+// authorization middleware.
+func (h *Handlers) VerifyAPIKey(ctx context.Context, key string) (bool, error) {
+	return h.svc.VerifyAPIKey(ctx, key)
+}
+
 // HealthCheck godoc
 // @Summary Show if api is running
 // @Description get string health check
-// @Tags example
-// @Accept  json
-// @Produce  json
-// @Success 200
+// @Tags health
+// @Produce plain
+// @Success 200 {string} string "health check ok!"
 // @Router /healthcheck [get]
 func (h *Handlers) HealthCheck(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprint(w, "health check ok!")
 }
 
+// Livez reports that the process is running. It never checks dependencies,
+// so a failing database does not cause Kubernetes to restart the pod.
+//
+// @Summary Liveness probe
+// @Tags health
+// @Produce json
+// @Success 200 {object} health.Report
+// @Router /livez [get]
+func (h *Handlers) Livez(w http.ResponseWriter, r *http.Request) {
+	sendResponse(w, http.StatusOK, health.Report{Status: health.StatusUp})
+}
+
+// Readyz reports whether the service can serve traffic, with a breakdown
+// per dependency. It answers 503 when any check fails.
+//
+// @Summary Readiness probe
+// @Tags health
+// @Produce json
+// @Success 200 {object} health.Report
+// @Failure 503 {object} health.Report
+// @Router /readyz [get]
+func (h *Handlers) Readyz(w http.ResponseWriter, r *http.Request) {
+	report := h.svc.CheckReadiness(r.Context())
+	if !report.Healthy() {
+		sendResponse(w, http.StatusServiceUnavailable, report)
+		return
+	}
+
+	sendResponse(w, http.StatusOK, report)
+}
+
+// InsertStation godoc
+// @Summary Ingest the current feed
+// @Description Fetches the Indego station feed and the weather and stores a snapshot.
+// @Tags stations
+// @Produce json
+// @Success 200 {object} ResponseMessage
+// @Failure 401,429,500,503 {object} problem.Problem
+// @Security BearerAuth
+// @Router /api/v1/indego-data-fetch-and-store-it-db [post]
 func (h *Handlers) InsertStation(w http.ResponseWriter, r *http.Request) {
-	if err := h.svc.InsertStation(r.Context()); err != nil {
-		sendResponse(w, http.StatusServiceUnavailable, ErrorMessage{
-			Message: "Unable to update stations",
-		})
+	ctx, span := tracing.Tracer().Start(r.Context(), "Handlers.InsertStation")
+	defer span.End()
+
+	if err := h.svc.InsertStation(ctx); err != nil {
+		sendError(w, r, span, err, "Unable to update stations")
 		return
 	}
 
@@ -44,51 +93,166 @@ func (h *Handlers) InsertStation(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// QueryAllStation godoc
+// @Summary List stations
+// @Description Returns every station and the weather of the snapshot at the given time.
+// @Tags stations
+// @Produce json
+// @Param at query string true "Snapshot time: RFC 3339, Unix seconds, now, or relative such as -1h"
+// @Success 200 {object} models.StationsResponse
+// @Failure 400,401,429,500 {object} problem.Problem
+// @Security BearerAuth
+// @Router /api/v1/stations [get]
 func (h *Handlers) QueryAllStation(w http.ResponseWriter, r *http.Request) {
-	status := r.URL.Query().Get("at")
+	ctx, span := tracing.Tracer().Start(r.Context(), "Handlers.QueryAllStation")
+	defer span.End()
 
-	if !utils.ValidateTimestamp(status) {
-		sendResponse(w, http.StatusBadRequest, ErrorMessage{
-			Message: "condition is invalid",
-		})
+	v := validate.New(r)
+	at := v.Time("at")
+	if err := v.Err(); err != nil {
+		problem.Write(w, r, err)
 		return
 	}
 
-	result, err := h.svc.QueryAllStation(r.Context(), status)
+	result, err := h.svc.QueryAllStation(ctx, at)
 	if err != nil {
-		sendResponse(w, http.StatusInternalServerError, ErrorMessage{
-			Message: "unable to get stations",
-		})
+		sendError(w, r, span, err, "unable to get stations")
 		return
 	}
 
 	sendResponse(w, http.StatusOK, result)
 }
 
+// QuerySpecificStation godoc
+// @Summary Get a station
+// @Description Returns one station in the snapshot at the given time.
+// @Tags stations
+// @Produce json
+// @Param kioskId path int true "Kiosk id" minimum(1)
+// @Param at query string true "Snapshot time: RFC 3339, Unix seconds, now, or relative such as -1h"
+// @Success 200 {object} models.Stations
+// @Failure 400,401,404,429,500 {object} problem.Problem
+// @Security BearerAuth
+// @Router /api/v1/stations/{kioskId} [get]
 func (h *Handlers) QuerySpecificStation(w http.ResponseWriter, r *http.Request) {
-	id := chi.URLParam(r, "kioskId")
-	status := r.URL.Query().Get("at")
+	ctx, span := tracing.Tracer().Start(r.Context(), "Handlers.QuerySpecificStation")
+	defer span.End()
 
-	if !utils.ValidateTimestamp(status) {
-		sendResponse(w, http.StatusBadRequest, ErrorMessage{
-			Message: "condition is invalid",
-		})
+	v := validate.New(r)
+	kioskId := v.PathID("kioskId")
+	at := v.Time("at")
+	if err := v.Err(); err != nil {
+		problem.Write(w, r, err)
 		return
 	}
 
-	kioskId, err := strconv.Atoi(id)
+	station, err := h.svc.QuerySpecificStation(ctx, int(kioskId), at)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		sendError(w, r, span, err, "Unable to get station")
+		return
+	}
+
+	sendResponse(w, http.StatusOK, station)
+}
+
+// StationHistory returns the availability of a kiosk between from and to
+// (default now). With resolution auto, the default, short ranges are read
+// from the raw snapshots and longer ones from the hourly or daily rollups.
+//
+// @Summary Get the history of a station
+// @Tags stations
+// @Produce json
+// @Param kioskId path int true "Kiosk id" minimum(1)
+// @Param from query string true "Start of the range: RFC 3339, Unix seconds, now, or relative such as -24h"
+// @Param to query string false "End of the range, in the same formats as from; default now"
+// @Param resolution query string false "Aggregation of the points" Enums(auto, raw, hour, day) default(auto)
+// @Success 200 {object} models.StationHistory
+// @Failure 400,401,429,500 {object} problem.Problem
+// @Security BearerAuth
+// @Router /api/v1/stations/{kioskId}/history [get]
+// @Router /api/v2/stations/{kioskId}/history [get]
+func (h *Handlers) StationHistory(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "Handlers.StationHistory")
+	defer span.End()
+
+	v := validate.New(r)
+	kioskId := v.PathID("kioskId")
+	from, to := v.TimeRange(0)
+	resolution := v.Enum("resolution", "", api.ResolutionAuto, models.ResolutionRaw, models.ResolutionHour, models.ResolutionDay)
+	if err := v.Err(); err != nil {
+		problem.Write(w, r, err)
 		return
 	}
 
-	station, err := h.svc.QuerySpecificStation(kioskId, status)
+	history, err := h.svc.StationHistory(ctx, int(kioskId), from, to, resolution)
 	if err != nil {
-		sendResponse(w, http.StatusInternalServerError, ErrorMessage{
-			Message: "Unable to get station",
-		})
+		sendError(w, r, span, err, "Unable to get station history")
 		return
 	}
 
-	sendResponse(w, http.StatusOK, station)
+	sendResponse(w, http.StatusOK, history)
+}
+
+// QueryEvents lists the station events detected since the given time,
+// optionally for a single kiosk. With a limit, the listing continues from
+// the last event of a page with since set to its time and afterId to its
+// id.
+//
+// @Summary List station events
+// @Tags events
+// @Produce json
+// @Param since query string true "Only events at or after this time: RFC 3339, Unix seconds, now, or relative such as -1h"
+// @Param kioskId query int false "Only this kiosk" minimum(1)
+// @Param afterId query int false "Resume after the event with this id, at since" minimum(1)
+// @Param limit query int false "Page size; all events when omitted" minimum(1) maximum(1000)
+// @Success 200 {object} EventsResponse
+// @Failure 400,401,429,500 {object} problem.Problem
+// @Security BearerAuth
+// @Router /api/v1/events [get]
+// @Router /api/v2/events [get]
+func (h *Handlers) QueryEvents(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "Handlers.QueryEvents")
+	defer span.End()
+
+	v := validate.New(r)
+	since := v.Time("since")
+	kioskId := v.ID("kioskId")
+	afterId := v.ID("afterId")
+	limit := v.Int("limit", 0, 1, maxPageSize)
+	if err := v.Err(); err != nil {
+		problem.Write(w, r, err)
+		return
+	}
+
+	events, err := h.svc.QueryEvents(ctx, since, int(kioskId), afterId, limit)
+	if err != nil {
+		sendError(w, r, span, err, "Unable to get events")
+		return
+	}
+
+	sendResponse(w, http.StatusOK, EventsResponse{
+		Since:  since,
+		Events: events,
+	})
+}
+
+// ActiveConfig shows the version of the configuration in effect and its
+// reloadable sections.
+//
+// @Summary Show the active configuration
+// @Tags admin
+// @Produce json
+// @Success 200 {object} ConfigResponse
+// @Failure 401,429 {object} problem.Problem
+// @Security BearerAuth
+// @Router /api/v1/admin/config [get]
+// @Router /api/v2/admin/config [get]
+func (h *Handlers) ActiveConfig(w http.ResponseWriter, r *http.Request) {
+	snap := h.svc.ActiveConfig()
+
+	sendResponse(w, http.StatusOK, ConfigResponse{
+		Version:  snap.Version,
+		LoadedAt: snap.LoadedAt,
+		Runtime:  snap.Config.RuntimeConfig,
+	})
 }
@@ -2,12 +2,27 @@ package handlers
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"testing"
+	"time"
 
+	"github.com/go-chi/chi/v5"
+	"github.com/macadrich/go-bike/api"
+	"github.com/macadrich/go-bike/api/problem"
+	"github.com/macadrich/go-bike/config"
 	"github.com/macadrich/go-bike/database/models"
+	"github.com/macadrich/go-bike/pkg/apperr"
+	"github.com/macadrich/go-bike/pkg/health"
+	"github.com/macadrich/go-bike/pkg/stream"
 	"github.com/stretchr/testify/mock"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 type MockDB struct {
@@ -26,23 +41,161 @@ func (m *MockDB) InsertStation(ctx context.Context) error {
 	return args.Error(0)
 }
 
-func (m *MockDB) QueryAllStation(ctx context.Context, lastUpdate string) (*models.StationsResponse, error) {
+func (m *MockDB) Ingest(ctx context.Context) (*models.Ingestion, error) {
+	args := m.Called()
+	ingestion, _ := args.Get(0).(*models.Ingestion)
+	return ingestion, args.Error(1)
+}
 
-	return nil, nil
+func (m *MockDB) Ingestions(ctx context.Context, before time.Time, limit int) ([]models.Ingestion, error) {
+	args := m.Called(before, limit)
+	return args.Get(0).([]models.Ingestion), args.Error(1)
 }
 
-func (m *MockDB) QuerySpecificStation(kioskId int, lastUpdate string) (*models.Stations, error) {
+func (m *MockDB) Snapshot(ctx context.Context, at time.Time, weather bool) (*models.StationsResponse, error) {
+	args := m.Called(at, weather)
+	snapshot, _ := args.Get(0).(*models.StationsResponse)
+	return snapshot, args.Error(1)
+}
+
+func (m *MockDB) Import(ctx context.Context, opts api.ImportOptions) (*api.ImportProgress, error) {
+	args := m.Called(opts.Path)
+	progress, _ := args.Get(0).(*api.ImportProgress)
+	return progress, args.Error(1)
+}
+
+func (m *MockDB) QueryAllStation(ctx context.Context, lastUpdate time.Time) (*models.StationsResponse, error) {
 
 	return nil, nil
 }
 
+func (m *MockDB) QuerySpecificStation(ctx context.Context, kioskId int, lastUpdate time.Time) (*models.Stations, error) {
+	args := m.Called(kioskId, lastUpdate)
+	station, _ := args.Get(0).(*models.Stations)
+	return station, args.Error(1)
+}
+
+func (m *MockDB) QueryEvents(ctx context.Context, since time.Time, kioskId int, afterId int64, limit int) ([]models.StationEvent, error) {
+	args := m.Called(since, kioskId, afterId, limit)
+	return args.Get(0).([]models.StationEvent), args.Error(1)
+}
+
+func (m *MockDB) CreateWebhook(ctx context.Context, sub *models.WebhookSubscription) (*models.WebhookSubscription, error) {
+	args := m.Called(sub)
+	return args.Get(0).(*models.WebhookSubscription), args.Error(1)
+}
+
+func (m *MockDB) ListWebhooks(ctx context.Context) ([]models.WebhookSubscription, error) {
+	args := m.Called()
+	return args.Get(0).([]models.WebhookSubscription), args.Error(1)
+}
+
+func (m *MockDB) DeleteWebhook(ctx context.Context, id int64) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockDB) ListDeliveries(ctx context.Context, subscriptionId int64, status string, beforeId int64, limit int) ([]models.WebhookDelivery, error) {
+	args := m.Called(subscriptionId, status, beforeId, limit)
+	return args.Get(0).([]models.WebhookDelivery), args.Error(1)
+}
+
+func (m *MockDB) ReplayDelivery(ctx context.Context, id int64) (*models.WebhookDelivery, error) {
+	args := m.Called(id)
+	delivery, _ := args.Get(0).(*models.WebhookDelivery)
+	return delivery, args.Error(1)
+}
+
+func (m *MockDB) StationHistory(ctx context.Context, kioskId int, from, to time.Time, resolution string) (*models.StationHistory, error) {
+	args := m.Called(kioskId, from, to, resolution)
+	return args.Get(0).(*models.StationHistory), args.Error(1)
+}
+
+func (m *MockDB) TripFlows(ctx context.Context, from, to time.Time, interval time.Duration, kioskId int) (*models.TripFlows, error) {
+	args := m.Called(from, to, interval, kioskId)
+	return args.Get(0).(*models.TripFlows), args.Error(1)
+}
+
+func (m *MockDB) TripOD(ctx context.Context, from, to time.Time, limit int) (*models.ODMatrix, error) {
+	args := m.Called(from, to, limit)
+	return args.Get(0).(*models.ODMatrix), args.Error(1)
+}
+
+func (m *MockDB) EBikeBattery(ctx context.Context, kioskId int) (*models.EBikeBattery, error) {
+	args := m.Called(kioskId)
+	return args.Get(0).(*models.EBikeBattery), args.Error(1)
+}
+
+func (m *MockDB) LowBatteryBikes(ctx context.Context, threshold int) (*models.LowBatteryReport, error) {
+	args := m.Called(threshold)
+	return args.Get(0).(*models.LowBatteryReport), args.Error(1)
+}
+
+func (m *MockDB) DockBatteryHistory(ctx context.Context, kioskId, dockNumber int, from, to time.Time) (*models.DockBatteryHistory, error) {
+	args := m.Called(kioskId, dockNumber, from, to)
+	return args.Get(0).(*models.DockBatteryHistory), args.Error(1)
+}
+
+func (m *MockDB) RebalancingPlan(ctx context.Context, opts api.RebalancingOptions) (*api.RebalancingPlan, error) {
+	args := m.Called(opts)
+	return args.Get(0).(*api.RebalancingPlan), args.Error(1)
+}
+
+func (m *MockDB) Forecast(ctx context.Context, kioskId int, horizon time.Duration, weather bool) (*models.StationForecast, error) {
+	args := m.Called(kioskId, horizon, weather)
+	forecast, _ := args.Get(0).(*models.StationForecast)
+	return forecast, args.Error(1)
+}
+
+func (m *MockDB) Export(ctx context.Context, req api.ExportRequest, w io.Writer) error {
+	args := m.Called(req)
+	if args.Error(0) == nil {
+		io.WriteString(w, "row\n")
+	}
+	return args.Error(0)
+}
+
+func (m *MockDB) CreateAPIKey(ctx context.Context, name string) (*models.APIKey, string, error) {
+	args := m.Called(name)
+	key, _ := args.Get(0).(*models.APIKey)
+	return key, args.String(1), args.Error(2)
+}
+
+func (m *MockDB) VerifyAPIKey(ctx context.Context, key string) (bool, error) {
+	args := m.Called(key)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockDB) CheckReadiness(ctx context.Context) *health.Report {
+	args := m.Called()
+	return args.Get(0).(*health.Report)
+}
+
+func (m *MockDB) MaintainPartitions(ctx context.Context) error {
+	return nil
+}
+
+func (m *MockDB) Stream() *stream.Broker {
+	args := m.Called()
+	return args.Get(0).(*stream.Broker)
+}
+
+func (m *MockDB) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+func (m *MockDB) ActiveConfig() *config.Snapshot {
+	args := m.Called()
+	return args.Get(0).(*config.Snapshot)
+}
+
 func TestInsertStation(t *testing.T) {
 	mockDB := NewMockDB()
 
 	mockDB.mockData["lastUpdated"] = []models.Stations{
 		{
 			Id: 3005,
-			At: "2024-05-14T06:48:19.588Z",
+			At: time.Date(2024, 5, 14, 6, 48, 19, 588000000, time.UTC),
 		},
 	}
 
@@ -82,3 +235,338 @@ func TestQueryAllStations(t *testing.T) {
 			status, http.StatusOK)
 	}
 }
+
+func TestQueryAllStationsSpan(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	handlers := NewHandlers(NewMockDB())
+	req, err := http.NewRequest("GET", "/api/v1/stations?at=2024-05-14T06:48:19.588Z", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(handlers.QueryAllStation).ServeHTTP(rr, req)
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v",
+			status, http.StatusOK)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 || spans[0].Name() != "Handlers.QueryAllStation" {
+		t.Errorf("handler recorded wrong spans: got %v", spans)
+	}
+}
+
+func TestQuerySpecificStation(t *testing.T) {
+	at := time.Date(2024, 5, 14, 6, 48, 19, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		url  string
+		want int
+		code string
+	}{
+		{"ok", "/stations/3005?at=2024-05-14T06:48:19Z", http.StatusOK, ""},
+		{"invalid kiosk", "/stations/abc?at=2024-05-14T06:48:19Z", http.StatusBadRequest, "invalid_argument"},
+		{"invalid at", "/stations/3005?at=yesterday", http.StatusBadRequest, "invalid_argument"},
+		{"unknown kiosk", "/stations/3999?at=2024-05-14T06:48:19Z", http.StatusNotFound, "station_not_found"},
+		{"database down", "/stations/3006?at=2024-05-14T06:48:19Z", http.StatusInternalServerError, "internal"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := NewMockDB()
+			mockDB.On("QuerySpecificStation", 3005, at).Return(&models.Stations{KioskId: 3005}, nil)
+			mockDB.On("QuerySpecificStation", 3999, at).Return(nil, apperr.NotFound("station", "station 3999 not found"))
+			mockDB.On("QuerySpecificStation", 3006, at).Return(nil, errors.New("connection refused"))
+			handlers := NewHandlers(mockDB)
+
+			router := chi.NewRouter()
+			router.Get("/stations/{kioskId}", handlers.QuerySpecificStation)
+
+			req, err := http.NewRequest("GET", tt.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			if status := rr.Code; status != tt.want {
+				t.Errorf("handler returned wrong status code: got %v want %v",
+					status, tt.want)
+			}
+			if tt.code == "" {
+				return
+			}
+
+			if ct := rr.Header().Get("Content-Type"); ct != problem.ContentType {
+				t.Errorf("handler returned wrong content type: got %v want %v", ct, problem.ContentType)
+			}
+			var p problem.Problem
+			if err := json.NewDecoder(rr.Body).Decode(&p); err != nil {
+				t.Fatal(err)
+			}
+			if p.Code != tt.code || p.Status != tt.want {
+				t.Errorf("handler returned wrong problem: got %+v", p)
+			}
+			if tt.code == "internal" && p.Detail == "connection refused" {
+				t.Errorf("handler leaked an internal error: %q", p.Detail)
+			}
+		})
+	}
+}
+
+func TestInvalidParams(t *testing.T) {
+	handlers := NewHandlers(NewMockDB())
+
+	router := chi.NewRouter()
+	router.Get("/stations/{kioskId}", handlers.QuerySpecificStation)
+
+	req, err := http.NewRequest("GET", "/stations/abc?at=yesterday", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v",
+			status, http.StatusBadRequest)
+	}
+
+	var p problem.Problem
+	if err := json.NewDecoder(rr.Body).Decode(&p); err != nil {
+		t.Fatal(err)
+	}
+	want := []apperr.FieldError{
+		{Name: "kioskId", In: "path", Reason: "must be a positive number"},
+		{Name: "at", In: "query", Reason: "must be an RFC 3339 timestamp, Unix seconds, now, or a duration from now such as -1h"},
+	}
+	if !reflect.DeepEqual(p.InvalidParams, want) {
+		t.Errorf("handler returned wrong invalid params: got %+v want %+v", p.InvalidParams, want)
+	}
+}
+
+func TestCreateIngestion(t *testing.T) {
+	at := time.Date(2024, 5, 14, 6, 48, 19, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		ingestion *models.Ingestion
+		want      int
+		location  string
+	}{
+		{"stored", &models.Ingestion{At: at, Source: "indego", Stations: 2}, http.StatusCreated, "/api/v2/snapshots/2024-05-14T06:48:19Z"},
+		{"already stored", nil, http.StatusNoContent, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := NewMockDB()
+			mockDB.On("Ingest").Return(tt.ingestion, nil)
+			handlers := NewHandlers(mockDB)
+
+			req, err := http.NewRequest("POST", "/api/v2/ingestions", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(handlers.CreateIngestion).ServeHTTP(rr, req)
+			if status := rr.Code; status != tt.want {
+				t.Errorf("handler returned wrong status code: got %v want %v",
+					status, tt.want)
+			}
+			if location := rr.Header().Get("Location"); location != tt.location {
+				t.Errorf("handler returned wrong location: got %q want %q", location, tt.location)
+			}
+		})
+	}
+}
+
+func TestGetStation(t *testing.T) {
+	at := time.Date(2024, 5, 14, 6, 48, 19, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		url  string
+		want int
+	}{
+		{"latest", "/stations/3005", http.StatusOK},
+		{"at", "/stations/3005?at=2024-05-14T06:48:19Z", http.StatusOK},
+		{"unknown kiosk", "/stations/3999", http.StatusNotFound},
+		{"invalid kiosk", "/stations/0", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opens := time.Date(0, 1, 1, 6, 0, 0, 0, time.UTC)
+			snapshot := &models.StationsResponse{At: at, Stations: []models.Stations{{KioskId: 3005, At: at, KioskStatus: "FullService", OpenTime: &opens}}}
+			mockDB := NewMockDB()
+			mockDB.On("Snapshot", time.Time{}, false).Return(snapshot, nil)
+			mockDB.On("Snapshot", at, false).Return(snapshot, nil)
+			handlers := NewHandlers(mockDB)
+
+			router := chi.NewRouter()
+			router.Get("/stations/{kioskId}", handlers.GetStation)
+
+			req, err := http.NewRequest("GET", tt.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			if status := rr.Code; status != tt.want {
+				t.Errorf("handler returned wrong status code: got %v want %v",
+					status, tt.want)
+			}
+			if tt.want != http.StatusOK {
+				return
+			}
+
+			var station map[string]any
+			if err := json.NewDecoder(rr.Body).Decode(&station); err != nil {
+				t.Fatal(err)
+			}
+			if station["kioskStatus"] != "FullService" {
+				t.Errorf("handler returned wrong station: got %v", station)
+			}
+			if station["openTime"] != "06:00:00" || station["closeTime"] != nil {
+				t.Errorf("handler returned wrong opening hours: got %v to %v", station["openTime"], station["closeTime"])
+			}
+		})
+	}
+}
+
+func TestLivez(t *testing.T) {
+	handlers := NewHandlers(NewMockDB())
+	req, err := http.NewRequest("GET", "/livez", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(handlers.Livez).ServeHTTP(rr, req)
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v",
+			status, http.StatusOK)
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	tests := []struct {
+		name   string
+		report *health.Report
+		want   int
+	}{
+		{
+			name: "ready",
+			report: &health.Report{Status: health.StatusUp, Checks: map[string]health.CheckResult{
+				"database": {Status: health.StatusUp},
+			}},
+			want: http.StatusOK,
+		},
+		{
+			name: "database down",
+			report: &health.Report{Status: health.StatusDown, Checks: map[string]health.CheckResult{
+				"database": {Status: health.StatusDown, Error: "connection refused"},
+			}},
+			want: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := NewMockDB()
+			mockDB.On("CheckReadiness").Return(tt.report)
+			handlers := NewHandlers(mockDB)
+			req, err := http.NewRequest("GET", "/readyz", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(handlers.Readyz).ServeHTTP(rr, req)
+			if status := rr.Code; status != tt.want {
+				t.Errorf("handler returned wrong status code: got %v want %v",
+					status, tt.want)
+			}
+		})
+	}
+}
+
+func TestStationHistory(t *testing.T) {
+	from := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 5, 8, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		url  string
+		want int
+	}{
+		{"ok", "/stations/3005/history?from=2024-05-01T00:00:00Z&to=2024-05-08T00:00:00Z", http.StatusOK},
+		{"invalid kiosk", "/stations/abc/history?from=2024-05-01T00:00:00Z", http.StatusBadRequest},
+		{"missing from", "/stations/3005/history", http.StatusBadRequest},
+		{"reversed range", "/stations/3005/history?from=2024-05-08T00:00:00Z&to=2024-05-01T00:00:00Z", http.StatusBadRequest},
+		{"invalid resolution", "/stations/3005/history?from=2024-05-01T00:00:00Z&resolution=week", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := NewMockDB()
+			mockDB.On("StationHistory", 3005, from, to, "").Return(&models.StationHistory{KioskId: 3005}, nil)
+			handlers := NewHandlers(mockDB)
+
+			router := chi.NewRouter()
+			router.Get("/stations/{kioskId}/history", handlers.StationHistory)
+
+			req, err := http.NewRequest("GET", tt.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			if status := rr.Code; status != tt.want {
+				t.Errorf("handler returned wrong status code: got %v want %v",
+					status, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryEvents(t *testing.T) {
+	since := time.Date(2024, 5, 14, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		url  string
+		want int
+	}{
+		{"all kiosks", "/api/v1/events?since=2024-05-14T00:00:00Z", http.StatusOK},
+		{"one kiosk", "/api/v1/events?since=2024-05-14T00:00:00Z&kioskId=3005", http.StatusOK},
+		{"missing since", "/api/v1/events", http.StatusBadRequest},
+		{"invalid kiosk", "/api/v1/events?since=2024-05-14T00:00:00Z&kioskId=abc", http.StatusBadRequest},
+		{"next page", "/api/v1/events?since=2024-05-14T00:00:00Z&afterId=41&limit=100", http.StatusOK},
+		{"invalid cursor", "/api/v1/events?since=2024-05-14T00:00:00Z&afterId=-1", http.StatusBadRequest},
+		{"limit too large", "/api/v1/events?since=2024-05-14T00:00:00Z&limit=1001", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := NewMockDB()
+			mockDB.On("QueryEvents", since, 0, int64(0), 0).Return([]models.StationEvent{}, nil)
+			mockDB.On("QueryEvents", since, 0, int64(41), 100).Return([]models.StationEvent{}, nil)
+			mockDB.On("QueryEvents", since, 3005, int64(0), 0).Return([]models.StationEvent{{KioskId: 3005, Type: models.EventBecameEmpty}}, nil)
+			handlers := NewHandlers(mockDB)
+
+			req, err := http.NewRequest("GET", tt.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(handlers.QueryEvents).ServeHTTP(rr, req)
+			if status := rr.Code; status != tt.want {
+				t.Errorf("handler returned wrong status code: got %v want %v",
+					status, tt.want)
+			}
+		})
+	}
+}
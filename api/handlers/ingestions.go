@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/macadrich/go-bike/api/problem"
+	"github.com/macadrich/go-bike/api/validate"
+	"github.com/macadrich/go-bike/pkg/tracing"
+)
+
+const defaultIngestionLimit = 100
+
+// CreateIngestion fetches the live feed and stores its snapshot. It
+// answers 201 with the ingestion, located at its snapshot, or 204 when the
+// feed has not changed since the last one.
+//
+// @Summary Ingest the current feed
+// @Tags ingestions
+// @Produce json
+// @Success 201 {object} models.Ingestion
+// @Header 201 {string} Location "Path of the stored snapshot"
+// @Success 204
+// @Failure 401,429,500,503 {object} problem.Problem
+// @Security BearerAuth
+// @Router /api/v2/ingestions [post]
+func (h *Handlers) CreateIngestion(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "Handlers.CreateIngestion")
+	defer span.End()
+
+	ingestion, err := h.svc.Ingest(ctx)
+	if err != nil {
+		sendError(w, r, span, err, "Unable to ingest the feed")
+		return
+	}
+	if ingestion == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Location", snapshotPath(ingestion.At))
+	sendResponse(w, http.StatusCreated, ingestion)
+}
+
+// ListIngestions lists the stored snapshots, newest first. Pass the time of
+// the last ingestion of a page as before to get the next one.
+//
+// @Summary List ingestions
+// @Tags ingestions
+// @Produce json
+// @Param before query string false "Only ingestions of snapshots before this time: RFC 3339, Unix seconds, now, or relative such as -1h; default now"
+// @Param limit query int false "Page size" minimum(1) maximum(1000) default(100)
+// @Success 200 {array} models.Ingestion
+// @Failure 400,401,429,500 {object} problem.Problem
+// @Security BearerAuth
+// @Router /api/v2/ingestions [get]
+func (h *Handlers) ListIngestions(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "Handlers.ListIngestions")
+	defer span.End()
+
+	v := validate.New(r)
+	before := v.OptionalTime("before", v.Now())
+	limit := v.Int("limit", defaultIngestionLimit, 1, maxPageSize)
+	if err := v.Err(); err != nil {
+		problem.Write(w, r, err)
+		return
+	}
+
+	ingestions, err := h.svc.Ingestions(ctx, before, limit)
+	if err != nil {
+		sendError(w, r, span, err, "Unable to list ingestions")
+		return
+	}
+
+	sendResponse(w, http.StatusOK, ingestions)
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+
+	"github.com/macadrich/go-bike/api"
+	"github.com/macadrich/go-bike/api/problem"
+	"github.com/macadrich/go-bike/api/validate"
+	"github.com/macadrich/go-bike/pkg/tracing"
+)
+
+// options applies the defaults of req and records every invalid field in v.
+func (req *RebalancingRequest) options(v *validate.Params) api.RebalancingOptions {
+	opts := api.RebalancingOptions{
+		Vans:        req.Vans,
+		VanCapacity: req.VanCapacity,
+		MaxStops:    req.MaxStops,
+		TargetFill:  0.5,
+		Tolerance:   0.1,
+		Targets:     req.Targets,
+		Depot:       req.Depot,
+		KioskIds:    req.KioskIds,
+	}
+	if opts.Vans == 0 {
+		opts.Vans = 1
+	}
+	if opts.VanCapacity == 0 {
+		opts.VanCapacity = 20
+	}
+	if opts.MaxStops == 0 {
+		opts.MaxStops = 15
+	}
+	if req.TargetFill != nil {
+		opts.TargetFill = *req.TargetFill
+	}
+	if req.Tolerance != nil {
+		opts.Tolerance = *req.Tolerance
+	}
+	if opts.Targets == "" {
+		opts.Targets = api.TargetsFixed
+	}
+
+	if opts.Vans < 1 || opts.Vans > 50 {
+		v.Invalid("vans", validate.InBody, "must be between 1 and 50")
+	}
+	if opts.VanCapacity < 1 || opts.VanCapacity > 200 {
+		v.Invalid("vanCapacity", validate.InBody, "must be between 1 and 200")
+	}
+	if opts.MaxStops < 1 || opts.MaxStops > 100 {
+		v.Invalid("maxStops", validate.InBody, "must be between 1 and 100")
+	}
+	if opts.TargetFill < 0 || opts.TargetFill > 1 {
+		v.Invalid("targetFill", validate.InBody, "must be between 0 and 1")
+	}
+	if opts.Tolerance < 0 || opts.Tolerance > 0.5 {
+		v.Invalid("tolerance", validate.InBody, "must be between 0 and 0.5")
+	}
+	if opts.Targets != api.TargetsFixed && opts.Targets != api.TargetsHistorical {
+		v.Invalid("targets", validate.InBody, "must be fixed or historical")
+	}
+	if opts.Depot != nil && (opts.Depot.Lat < -90 || opts.Depot.Lat > 90 || opts.Depot.Long < -180 || opts.Depot.Long > 180) {
+		v.Invalid("depot", validate.InBody, "must be a valid long/lat")
+	}
+	if slices.ContainsFunc(opts.KioskIds, func(id int) bool { return id <= 0 }) {
+		v.Invalid("kioskIds", validate.InBody, "must be positive")
+	}
+
+	return opts
+}
+
+// RebalancingPlan recommends van routes that move bikes from stations above
+// their target fill ratio to stations below it, for the latest snapshot.
+// The plan is deterministic: the same snapshot and request give the same
+// routes.
+//
+// @Summary Plan rebalancing routes
+// @Tags rebalancing
+// @Accept json
+// @Produce json
+// @Param request body RebalancingRequest false "Plan options; omitted fields take their defaults"
+// @Success 200 {object} api.RebalancingPlan
+// @Failure 400,401,429,500 {object} problem.Problem
+// @Security BearerAuth
+// @Router /api/v1/rebalancing/plan [post]
+// @Router /api/v2/rebalancing/plan [post]
+func (h *Handlers) RebalancingPlan(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "Handlers.RebalancingPlan")
+	defer span.End()
+
+	var req RebalancingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		badRequest(w, r, "request body must be a JSON object")
+		return
+	}
+
+	v := validate.New(r)
+	opts := req.options(v)
+	if err := v.Err(); err != nil {
+		problem.Write(w, r, err)
+		return
+	}
+
+	plan, err := h.svc.RebalancingPlan(ctx, opts)
+	if err != nil {
+		sendError(w, r, span, err, "Unable to plan rebalancing")
+		return
+	}
+
+	sendResponse(w, http.StatusOK, plan)
+}
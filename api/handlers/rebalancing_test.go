@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/macadrich/go-bike/api"
+	"github.com/macadrich/go-bike/api/problem"
+	"github.com/macadrich/go-bike/pkg/apperr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRebalancingPlan(t *testing.T) {
+	defaults := api.RebalancingOptions{Vans: 1, VanCapacity: 20, MaxStops: 15, TargetFill: 0.5, Tolerance: 0.1, Targets: api.TargetsFixed}
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"defaults", "", http.StatusOK},
+		{"empty object", "{}", http.StatusOK},
+		{"historical", `{"vans": 2, "targets": "historical", "depot": {"long": -75.16, "lat": 39.95}}`, http.StatusOK},
+		{"not json", "vans=2", http.StatusBadRequest},
+		{"too many vans", `{"vans": 100}`, http.StatusBadRequest},
+		{"target fill", `{"targetFill": 1.5}`, http.StatusBadRequest},
+		{"targets", `{"targets": "magic"}`, http.StatusBadRequest},
+		{"kiosk ids", `{"kioskIds": [0]}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := NewMockDB()
+			mockDB.On("RebalancingPlan", defaults).Return(&api.RebalancingPlan{}, nil)
+			mockDB.On("RebalancingPlan", mock.MatchedBy(func(opts api.RebalancingOptions) bool {
+				return opts.Vans == 2 && opts.Targets == api.TargetsHistorical && opts.Depot != nil
+			})).Return(&api.RebalancingPlan{}, nil)
+			handlers := NewHandlers(mockDB)
+
+			req := httptest.NewRequest("POST", "/api/v1/rebalancing/plan", strings.NewReader(tt.body))
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(handlers.RebalancingPlan).ServeHTTP(rr, req)
+			assert.Equal(t, tt.want, rr.Code)
+		})
+	}
+}
+
+func TestRebalancingPlanInvalidBody(t *testing.T) {
+	handlers := NewHandlers(NewMockDB())
+
+	req := httptest.NewRequest("POST", "/api/v1/rebalancing/plan", strings.NewReader(`{"vans": 100, "targets": "magic", "kioskIds": [0]}`))
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(handlers.RebalancingPlan).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	var p problem.Problem
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&p))
+	assert.Equal(t, []apperr.FieldError{
+		{Name: "vans", In: "body", Reason: "must be between 1 and 50"},
+		{Name: "targets", In: "body", Reason: "must be fixed or historical"},
+		{Name: "kioskIds", In: "body", Reason: "must be positive"},
+	}, p.InvalidParams)
+}
@@ -3,26 +3,199 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+	"time"
 
+	"github.com/macadrich/go-bike/api/problem"
+	"github.com/macadrich/go-bike/config"
 	"github.com/macadrich/go-bike/database/models"
+	"github.com/macadrich/go-bike/pkg/apperr"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type ResponseMessage struct {
 	Message string `json:"message"`
 }
 
-type ErrorMessage struct {
-	Message string `json:"message"`
-}
-
 type StationsResponse struct {
 	At       string            `json:"at"`
 	Stations []models.Stations `json:"stations,omitempty"`
 	Weather  models.WeatherMap `json:"weather,omitempty"`
 }
 
+type EventsResponse struct {
+	Since  time.Time             `json:"since"`
+	Events []models.StationEvent `json:"events"`
+}
+
+// WebhookRequest subscribes URL to station events. Empty EventTypes or
+// KioskIds match everything; Secret is generated when empty.
+type WebhookRequest struct {
+	URL        string   `json:"url"`
+	Secret     string   `json:"secret"`
+	EventTypes []string `json:"eventTypes"`
+	KioskIds   []int64  `json:"kioskIds"`
+}
+
+// RebalancingRequest asks for a rebalancing plan. Omitted fields take their
+// defaults: one van of 20 bikes making up to 15 stops, fixed targets of 0.5
+// with a tolerance of 0.1, and every station.
+type RebalancingRequest struct {
+	Vans        int                `json:"vans"`
+	VanCapacity int                `json:"vanCapacity"`
+	MaxStops    int                `json:"maxStops"`
+	TargetFill  *float64           `json:"targetFill"`
+	Tolerance   *float64           `json:"tolerance"`
+	Targets     string             `json:"targets"`
+	Depot       *models.Coordinate `json:"depot"`
+	KioskIds    []int              `json:"kioskIds"`
+}
+
+// StreamFrame is a message of the station WebSocket: a "station" update
+// with its stream id, a "reset" when missed updates could not be replayed,
+// or a "heartbeat".
+type StreamFrame struct {
+	Type    string                `json:"type" enums:"station,reset,heartbeat"`
+	ID      uint64                `json:"id,omitempty"`
+	Station *models.StationUpdate `json:"station,omitempty"`
+}
+
+// Station is a station of the v2 API: the feed fields of models.Stations
+// under corrected names. OpenTime and CloseTime are times of day.
+type Station struct {
+	KioskId                int           `json:"kioskId"`
+	Id                     int           `json:"id"`
+	At                     time.Time     `json:"at"`
+	Name                   string        `json:"name"`
+	KioskType              int           `json:"kioskType"`
+	KioskStatus            string        `json:"kioskStatus"`
+	KioskPublicStatus      string        `json:"kioskPublicStatus"`
+	KioskConnectionStatus  string        `json:"kioskConnectionStatus"`
+	IsEventBased           bool          `json:"isEventBased"`
+	IsVirtual              bool          `json:"isVirtual"`
+	Latitude               float64       `json:"latitude"`
+	Longitude              float64       `json:"longitude"`
+	AddressStreet          string        `json:"addressStreet"`
+	AddressCity            string        `json:"addressCity"`
+	AddressState           string        `json:"addressState"`
+	AddressZipCode         string        `json:"addressZipCode"`
+	TimeZone               string        `json:"timeZone"`
+	OpenTime               *string       `json:"openTime" example:"06:00:00" extensions:"x-nullable"`
+	CloseTime              *string       `json:"closeTime" example:"22:00:00" extensions:"x-nullable"`
+	EventStart             *time.Time    `json:"eventStart" extensions:"x-nullable"`
+	EventEnd               *time.Time    `json:"eventEnd" extensions:"x-nullable"`
+	Notes                  string        `json:"notes"`
+	PublicText             string        `json:"publicText"`
+	TotalDocks             int           `json:"totalDocks"`
+	DocksAvailable         int           `json:"docksAvailable"`
+	BikesAvailable         int           `json:"bikesAvailable"`
+	ClassicBikesAvailable  int           `json:"classicBikesAvailable"`
+	SmartBikesAvailable    int           `json:"smartBikesAvailable"`
+	ElectricBikesAvailable int           `json:"electricBikesAvailable"`
+	TrikesAvailable        int           `json:"trikesAvailable"`
+	RewardBikesAvailable   int           `json:"rewardBikesAvailable"`
+	RewardDocksAvailable   int           `json:"rewardDocksAvailable"`
+	Bikes                  []models.Bike `json:"bikes"`
+}
+
+func newStation(s *models.Stations) Station {
+	return Station{
+		KioskId:                s.KioskId,
+		Id:                     s.Id,
+		At:                     s.At,
+		Name:                   s.Name,
+		KioskType:              s.KioskType,
+		KioskStatus:            s.KioskStatus,
+		KioskPublicStatus:      s.KioskPublicStatus,
+		KioskConnectionStatus:  s.KioskConnectionStatus,
+		IsEventBased:           s.IsEventBased,
+		IsVirtual:              s.IsVirtual,
+		Latitude:               s.Latitude,
+		Longitude:              s.Longitude,
+		AddressStreet:          s.AddressStreet,
+		AddressCity:            s.AddressCity,
+		AddressState:           s.AddressState,
+		AddressZipCode:         s.AddressZipCode,
+		TimeZone:               s.TimeZone,
+		OpenTime:               timeOfDay(s.OpenTime),
+		CloseTime:              timeOfDay(s.CloseTime),
+		EventStart:             s.EventStart,
+		EventEnd:               s.EventEnd,
+		Notes:                  s.Notes,
+		PublicText:             s.PublicText,
+		TotalDocks:             s.TotalDocks,
+		DocksAvailable:         s.DocksAvailable,
+		BikesAvailable:         s.BikesAvailable,
+		ClassicBikesAvailable:  s.ClassicBikesAvailable,
+		SmartBikesAvailable:    s.SmartBikesAvailable,
+		ElectricBikesAvailable: s.ElectricBikesAvailable,
+		TrikesAvailable:        s.TrikesAvailable,
+		RewardBikesAvailable:   s.RewardBikesAvailable,
+		RewardDocksAvailable:   s.RewardDocksAvailable,
+		Bikes:                  s.Bikes,
+	}
+}
+
+// timeOfDay formats a time of day, stored in a TIME column and read as a
+// time on day zero, as HH:MM:SS.
+func timeOfDay(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	s := t.Format(time.TimeOnly)
+	return &s
+}
+
+func newStations(stations []models.Stations) []Station {
+	out := make([]Station, len(stations))
+	for i := range stations {
+		out[i] = newStation(&stations[i])
+	}
+	return out
+}
+
+// StationList is the stations of the snapshot taken at At.
+type StationList struct {
+	At       time.Time `json:"at"`
+	Stations []Station `json:"stations"`
+}
+
+// Snapshot is a stored snapshot of the feed, with the weather observed
+// nearest to it when the weather feature is enabled.
+type Snapshot struct {
+	At       time.Time                  `json:"at"`
+	Stations []Station                  `json:"stations"`
+	Weather  *models.WeatherObservation `json:"weather" extensions:"x-nullable"`
+}
+
+type ConfigResponse struct {
+	Version  int64                `json:"version"`
+	LoadedAt time.Time            `json:"loadedAt"`
+	Runtime  config.RuntimeConfig `json:"runtime"`
+}
+
 func sendResponse(w http.ResponseWriter, statusCode int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
 	json.NewEncoder(w).Encode(data)
 }
+
+// badRequest answers with an invalid_argument problem.
+func badRequest(w http.ResponseWriter, r *http.Request, message string) {
+	problem.Write(w, r, apperr.InvalidArgument(message))
+}
+
+// sendError answers with the problem describing err. Failures that are not
+// the client's are recorded on span, and internal ones are shown as
+// message rather than with their cause.
+func sendError(w http.ResponseWriter, r *http.Request, span trace.Span, err error, message string) {
+	e := apperr.As(err)
+	switch e.Kind {
+	case apperr.KindInternal:
+		span.RecordError(err)
+		e = apperr.Wrap(apperr.KindInternal, e.Code, message, err)
+	case apperr.KindUnavailable:
+		span.RecordError(err)
+	}
+
+	problem.Write(w, r, e)
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/macadrich/go-bike/api"
+	"github.com/macadrich/go-bike/api/problem"
+	"github.com/macadrich/go-bike/api/validate"
+	"github.com/macadrich/go-bike/pkg/apperr"
+	"github.com/macadrich/go-bike/pkg/tracing"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// GetSnapshot returns a stored snapshot: the first one taken at or after
+// at, or the latest one.
+//
+// @Summary Get a snapshot
+// @Description Returns the stations of a snapshot of the feed and the weather observed nearest to it, if any.
+// @Tags snapshots
+// @Produce json
+// @Param at path string true "The snapshot taken at or after this time: RFC 3339, Unix seconds, now, or relative such as -1h"
+// @Success 200 {object} Snapshot
+// @Failure 400,401,404,429,500 {object} problem.Problem
+// @Security BearerAuth
+// @Router /api/v2/snapshots/{at} [get]
+func (h *Handlers) GetSnapshot(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "Handlers.GetSnapshot")
+	defer span.End()
+
+	v := validate.New(r)
+	at := v.PathTime("at")
+	if err := v.Err(); err != nil {
+		problem.Write(w, r, err)
+		return
+	}
+
+	h.sendSnapshot(ctx, w, r, span, at)
+}
+
+// GetLatestSnapshot godoc
+// @Summary Get the latest snapshot
+// @Description Returns the stations of the latest snapshot of the feed and the weather observed nearest to it, if any.
+// @Tags snapshots
+// @Produce json
+// @Success 200 {object} Snapshot
+// @Failure 401,404,429,500 {object} problem.Problem
+// @Security BearerAuth
+// @Router /api/v2/snapshots/latest [get]
+func (h *Handlers) GetLatestSnapshot(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "Handlers.GetLatestSnapshot")
+	defer span.End()
+
+	h.sendSnapshot(ctx, w, r, span, time.Time{})
+}
+
+// sendSnapshot answers with the snapshot at at, or the latest one when at
+// is zero.
+func (h *Handlers) sendSnapshot(ctx context.Context, w http.ResponseWriter, r *http.Request, span trace.Span, at time.Time) {
+	snapshot, err := h.svc.Snapshot(ctx, at, true)
+	if err != nil {
+		sendError(w, r, span, err, "Unable to get snapshot")
+		return
+	}
+
+	result := Snapshot{
+		At:       snapshot.At,
+		Stations: newStations(snapshot.Stations),
+	}
+	if snapshot.Weather.Dt != 0 {
+		result.Weather = api.WeatherObservation(&snapshot.Weather)
+	}
+
+	sendResponse(w, http.StatusOK, result)
+}
+
+// ListStations returns the stations of the latest snapshot, or of the
+// first one taken at or after at.
+//
+// @Summary List stations
+// @Tags stations
+// @Produce json
+// @Param at query string false "The snapshot taken at or after this time: RFC 3339, Unix seconds, now, or relative such as -1h; default the latest"
+// @Success 200 {object} StationList
+// @Failure 400,401,404,429,500 {object} problem.Problem
+// @Security BearerAuth
+// @Router /api/v2/stations [get]
+func (h *Handlers) ListStations(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "Handlers.ListStations")
+	defer span.End()
+
+	v := validate.New(r)
+	at := v.OptionalTime("at", time.Time{})
+	if err := v.Err(); err != nil {
+		problem.Write(w, r, err)
+		return
+	}
+
+	snapshot, err := h.svc.Snapshot(ctx, at, false)
+	if err != nil {
+		sendError(w, r, span, err, "Unable to get stations")
+		return
+	}
+
+	sendResponse(w, http.StatusOK, StationList{
+		At:       snapshot.At,
+		Stations: newStations(snapshot.Stations),
+	})
+}
+
+// GetStation returns a station in the latest snapshot, or in the first one
+// taken at or after at.
+//
+// @Summary Get a station
+// @Tags stations
+// @Produce json
+// @Param kioskId path int true "Kiosk id" minimum(1)
+// @Param at query string false "The snapshot taken at or after this time: RFC 3339, Unix seconds, now, or relative such as -1h; default the latest"
+// @Success 200 {object} Station
+// @Failure 400,401,404,429,500 {object} problem.Problem
+// @Security BearerAuth
+// @Router /api/v2/stations/{kioskId} [get]
+func (h *Handlers) GetStation(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "Handlers.GetStation")
+	defer span.End()
+
+	v := validate.New(r)
+	kioskId := v.PathID("kioskId")
+	at := v.OptionalTime("at", time.Time{})
+	if err := v.Err(); err != nil {
+		problem.Write(w, r, err)
+		return
+	}
+
+	snapshot, err := h.svc.Snapshot(ctx, at, false)
+	if err != nil {
+		sendError(w, r, span, err, "Unable to get station")
+		return
+	}
+
+	for i := range snapshot.Stations {
+		if snapshot.Stations[i].KioskId == int(kioskId) {
+			sendResponse(w, http.StatusOK, newStation(&snapshot.Stations[i]))
+			return
+		}
+	}
+	problem.Write(w, r, apperr.NotFound("station", fmt.Sprintf("station %d not found", kioskId)))
+}
+
+// snapshotPath is the v2 path of the snapshot taken at at.
+func snapshotPath(at time.Time) string {
+	return "/api/v2/snapshots/" + at.UTC().Format(time.RFC3339Nano)
+}
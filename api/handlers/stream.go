@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/macadrich/go-bike/api/problem"
+	"github.com/macadrich/go-bike/api/validate"
+	"github.com/macadrich/go-bike/pkg/stream"
+	"golang.org/x/net/websocket"
+)
+
+// streamHeartbeat keeps idle streams from being closed by proxies.
+const streamHeartbeat = 15 * time.Second
+
+// streamRetry is how long EventSource clients wait before reconnecting.
+const streamRetry = 5 * time.Second
+
+// streamFilter reads the kioskId (repeated or comma separated) and bbox
+// query parameters.
+func streamFilter(v *validate.Params) stream.Filter {
+	filter := stream.Filter{BBox: v.BBox("bbox")}
+	for _, kioskId := range v.IDs("kioskId") {
+		if filter.KioskIds == nil {
+			filter.KioskIds = make(map[int]bool)
+		}
+		filter.KioskIds[int(kioskId)] = true
+	}
+	return filter
+}
+
+// lastEventID reads the id to resume after from the Last-Event-ID header
+// sent by reconnecting EventSource clients, or from the lastEventId query
+// parameter for clients that cannot set headers.
+func lastEventID(r *http.Request, v *validate.Params) (id uint64, resume bool) {
+	name, in := "Last-Event-ID", validate.InHeader
+	value := r.Header.Get(name)
+	if value == "" {
+		name, in = "lastEventId", validate.InQuery
+		value = r.URL.Query().Get(name)
+	}
+	if value == "" {
+		return 0, false
+	}
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		v.Invalid(name, in, "must be a stream message id")
+	}
+	return id, true
+}
+
+// subscribe validates a stream request and subscribes it to the broker,
+// answering 400 itself when the request is invalid.
+func (h *Handlers) subscribe(w http.ResponseWriter, r *http.Request) (sub *stream.Subscription, backlog []stream.Message, complete bool, ok bool) {
+	v := validate.New(r)
+	filter := streamFilter(v)
+	lastID, resume := lastEventID(r, v)
+	if err := v.Err(); err != nil {
+		problem.Write(w, r, err)
+		return nil, nil, false, false
+	}
+
+	sub, backlog, complete = h.svc.Stream().Subscribe(filter, lastID, resume)
+	return sub, backlog, complete, true
+}
+
+// StationsStream pushes station availability changes as server-sent
+// events, one "station" event per changed kiosk after each ingestion.
+// Clients reconnecting with Last-Event-ID receive the changes they missed,
+// or a "reset" event when those are no longer available, after which they
+// should reload the stations.
+//
+// @Summary Stream station changes as server-sent events
+// @Tags stations
+// @Produce text/event-stream
+// @Param kioskId query []int false "Only these kiosks, repeated or comma separated" collectionFormat(csv)
+// @Param bbox query string false "Only kiosks inside minLong,minLat,maxLong,maxLat"
+// @Param Last-Event-ID header int false "Resume after this stream message id"
+// @Param lastEventId query int false "Resume after this stream message id, for clients that cannot set headers"
+// @Param access_token query string false "Bearer token, for clients that cannot set headers; also read from the access_token cookie"
+// @Success 200 {string} string "station, reset and heartbeat events"
+// @Failure 400,401,429 {object} problem.Problem
+// @Security BearerAuth
+// @Router /api/v1/stations/stream [get]
+// @Router /api/v2/stations/stream [get]
+func (h *Handlers) StationsStream(w http.ResponseWriter, r *http.Request) {
+	sub, backlog, complete, ok := h.subscribe(w, r)
+	if !ok {
+		return
+	}
+	broker := h.svc.Stream()
+	defer broker.Unsubscribe(sub)
+
+	// The stream outlives the server's write timeout.
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(w, "retry: %d\n\n", streamRetry.Milliseconds())
+	if !complete {
+		fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+	}
+	for _, msg := range backlog {
+		writeStreamEvent(w, msg)
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case msg, open := <-sub.C:
+			if !open {
+				return
+			}
+			writeStreamEvent(w, msg)
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func writeStreamEvent(w http.ResponseWriter, msg stream.Message) {
+	data, _ := json.Marshal(msg.Update)
+	fmt.Fprintf(w, "id: %d\nevent: station\ndata: %s\n\n", msg.ID, data)
+}
+
+// StationsWebSocket is StationsStream over a WebSocket. Every frame is a
+// JSON StreamFrame; resume with the lastEventId query parameter.
+//
+// @Summary Stream station changes over a WebSocket
+// @Tags stations
+// @Param kioskId query []int false "Only these kiosks, repeated or comma separated" collectionFormat(csv)
+// @Param bbox query string false "Only kiosks inside minLong,minLat,maxLong,maxLat"
+// @Param lastEventId query int false "Resume after this stream message id"
+// @Param access_token query string false "Bearer token, for clients that cannot set headers"
+// @Success 101 {object} StreamFrame "Every frame is a StreamFrame"
+// @Failure 400,401,429 {object} problem.Problem
+// @Security BearerAuth
+// @Router /api/v1/stations/ws [get]
+// @Router /api/v2/stations/ws [get]
+func (h *Handlers) StationsWebSocket(w http.ResponseWriter, r *http.Request) {
+	sub, backlog, complete, ok := h.subscribe(w, r)
+	if !ok {
+		return
+	}
+	broker := h.svc.Stream()
+	defer broker.Unsubscribe(sub)
+
+	// websocket.Server, unlike websocket.Handler, accepts any Origin: the
+	// route only takes a token the page had to know, never a cookie.
+	websocket.Server{Handler: func(ws *websocket.Conn) {
+		// Clear the deadlines the server set before the upgrade.
+		ws.SetDeadline(time.Time{})
+
+		// Incoming frames are ignored; reading only notices the close.
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+		go func() {
+			defer cancel()
+			var discard []byte
+			for websocket.Message.Receive(ws, &discard) == nil {
+			}
+		}()
+
+		send := func(frame StreamFrame) bool {
+			ws.SetWriteDeadline(time.Now().Add(streamHeartbeat))
+			return websocket.JSON.Send(ws, frame) == nil
+		}
+
+		if !complete && !send(StreamFrame{Type: "reset"}) {
+			return
+		}
+		for _, msg := range backlog {
+			if !send(stationFrame(msg)) {
+				return
+			}
+		}
+
+		heartbeat := time.NewTicker(streamHeartbeat)
+		defer heartbeat.Stop()
+
+		for {
+			var frame StreamFrame
+			select {
+			case <-ctx.Done():
+				return
+			case msg, open := <-sub.C:
+				if !open {
+					return
+				}
+				frame = stationFrame(msg)
+			case <-heartbeat.C:
+				frame = StreamFrame{Type: "heartbeat"}
+			}
+			if !send(frame) {
+				return
+			}
+		}
+	}}.ServeHTTP(w, r)
+}
+
+func stationFrame(msg stream.Message) StreamFrame {
+	return StreamFrame{Type: "station", ID: msg.ID, Station: &msg.Update}
+}
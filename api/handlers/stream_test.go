@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/macadrich/go-bike/api/middleware"
+	"github.com/macadrich/go-bike/database/models"
+	"github.com/macadrich/go-bike/pkg/stream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+)
+
+func streamServer(t *testing.T, handler func(*Handlers) http.HandlerFunc) (*httptest.Server, *stream.Broker) {
+	broker := stream.NewBroker(100)
+	mockDB := NewMockDB()
+	mockDB.On("Stream").Return(broker)
+
+	server := httptest.NewServer(middleware.Tracing(handler(NewHandlers(mockDB))))
+	t.Cleanup(server.Close)
+	t.Cleanup(broker.Close)
+	return server, broker
+}
+
+// readEvent returns the lines of the next server-sent event.
+func readEvent(t *testing.T, r *bufio.Reader) []string {
+	var lines []string
+	for {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			return lines
+		}
+		lines = append(lines, line)
+	}
+}
+
+func TestStationsStream(t *testing.T) {
+	server, broker := streamServer(t, func(h *Handlers) http.HandlerFunc { return h.StationsStream })
+
+	resp, err := http.Get(server.URL + "?kioskId=3005,3006&bbox=-75.2,39.9,-75.1,40.0")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	body := bufio.NewReader(resp.Body)
+	assert.Equal(t, []string{"retry: 5000"}, readEvent(t, body))
+
+	broker.Publish([]models.StationUpdate{
+		{KioskId: 3004, BikesAvailable: 1, Longitude: -75.16, Latitude: 39.95},
+		{KioskId: 3005, BikesAvailable: 2, Longitude: -75.16, Latitude: 39.95},
+		{KioskId: 3006, BikesAvailable: 3, Longitude: -75.3, Latitude: 39.95},
+	})
+
+	event := readEvent(t, body)
+	require.Len(t, event, 3)
+	assert.True(t, strings.HasPrefix(event[0], "id: "))
+	assert.Equal(t, "event: station", event[1])
+
+	var update models.StationUpdate
+	require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(event[2], "data: ")), &update))
+	assert.Equal(t, 3005, update.KioskId)
+	assert.Equal(t, 2, update.BikesAvailable)
+}
+
+func TestStationsStreamReset(t *testing.T) {
+	server, _ := streamServer(t, func(h *Handlers) http.HandlerFunc { return h.StationsStream })
+
+	req, err := http.NewRequest("GET", server.URL, nil)
+	require.NoError(t, err)
+	req.Header.Set("Last-Event-ID", "1")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body := bufio.NewReader(resp.Body)
+	readEvent(t, body)
+	assert.Equal(t, []string{"event: reset", "data: {}"}, readEvent(t, body))
+}
+
+func TestStationsStreamInvalid(t *testing.T) {
+	for _, query := range []string{"?kioskId=abc", "?kioskId=0", "?bbox=1,2,3", "?lastEventId=x"} {
+		t.Run(query, func(t *testing.T) {
+			mockDB := NewMockDB()
+			handlers := NewHandlers(mockDB)
+
+			req := httptest.NewRequest("GET", "/api/v1/stations/stream"+query, nil)
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(handlers.StationsStream).ServeHTTP(rr, req)
+			assert.Equal(t, http.StatusBadRequest, rr.Code)
+		})
+	}
+}
+
+func TestStationsWebSocket(t *testing.T) {
+	server, broker := streamServer(t, func(h *Handlers) http.HandlerFunc { return h.StationsWebSocket })
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+	ws, err := websocket.Dial(url+"?lastEventId=1", "", server.URL)
+	require.NoError(t, err)
+	defer ws.Close()
+
+	// The reset is sent once subscribed, so the update is not missed.
+	var frame StreamFrame
+	require.NoError(t, websocket.JSON.Receive(ws, &frame))
+	assert.Equal(t, "reset", frame.Type)
+
+	broker.Publish([]models.StationUpdate{{KioskId: 3005, BikesAvailable: 2}})
+
+	require.NoError(t, websocket.JSON.Receive(ws, &frame))
+	assert.Equal(t, "station", frame.Type)
+	assert.NotZero(t, frame.ID)
+	require.NotNil(t, frame.Station)
+	assert.Equal(t, 3005, frame.Station.KioskId)
+}
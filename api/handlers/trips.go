@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/macadrich/go-bike/api/problem"
+	"github.com/macadrich/go-bike/api/validate"
+	"github.com/macadrich/go-bike/pkg/tracing"
+)
+
+// maxTripRange bounds trip queries, which diff every snapshot in range.
+const maxTripRange = 31 * 24 * time.Hour
+
+const (
+	minFlowInterval = 5 * time.Minute
+	maxFlowInterval = 24 * time.Hour
+	defaultODLimit  = 100
+	maxODLimit      = 10000
+)
+
+// TripFlows estimates the departures and arrivals of every kiosk, or of
+// one, per interval (default 1h) by diffing consecutive bike snapshots.
+//
+// @Summary Estimate departures and arrivals
+// @Tags trips
+// @Produce json
+// @Param from query string true "Start of the range: RFC 3339, Unix seconds, now, or relative such as -24h"
+// @Param to query string false "End of the range, in the same formats as from; default now"
+// @Param interval query string false "Bucket size, a duration between 5m and 24h" default(1h)
+// @Param kioskId query int false "Only this kiosk" minimum(1)
+// @Success 200 {object} models.TripFlows
+// @Failure 400,401,429,500 {object} problem.Problem
+// @Security BearerAuth
+// @Router /api/v1/trips/flows [get]
+// @Router /api/v2/trips/flows [get]
+func (h *Handlers) TripFlows(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "Handlers.TripFlows")
+	defer span.End()
+
+	v := validate.New(r)
+	from, to := v.TimeRange(maxTripRange)
+	interval := v.Duration("interval", time.Hour, minFlowInterval, maxFlowInterval)
+	kioskId := v.ID("kioskId")
+	if err := v.Err(); err != nil {
+		problem.Write(w, r, err)
+		return
+	}
+
+	flows, err := h.svc.TripFlows(ctx, from, to, interval, int(kioskId))
+	if err != nil {
+		sendError(w, r, span, err, "Unable to estimate trip flows")
+		return
+	}
+
+	sendResponse(w, http.StatusOK, flows)
+}
+
+// TripOD estimates the number of trips between pairs of kiosks, busiest
+// first, with a gravity model fitted to their departures and arrivals.
+//
+// @Summary Estimate trips between stations
+// @Tags trips
+// @Produce json
+// @Param from query string true "Start of the range: RFC 3339, Unix seconds, now, or relative such as -24h"
+// @Param to query string false "End of the range, in the same formats as from; default now"
+// @Param limit query int false "Number of pairs" minimum(1) maximum(10000) default(100)
+// @Success 200 {object} models.ODMatrix
+// @Failure 400,401,429,500 {object} problem.Problem
+// @Security BearerAuth
+// @Router /api/v1/trips/od [get]
+// @Router /api/v2/trips/od [get]
+func (h *Handlers) TripOD(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "Handlers.TripOD")
+	defer span.End()
+
+	v := validate.New(r)
+	from, to := v.TimeRange(maxTripRange)
+	limit := v.Int("limit", defaultODLimit, 1, maxODLimit)
+	if err := v.Err(); err != nil {
+		problem.Write(w, r, err)
+		return
+	}
+
+	matrix, err := h.svc.TripOD(ctx, from, to, limit)
+	if err != nil {
+		sendError(w, r, span, err, "Unable to estimate trips")
+		return
+	}
+
+	sendResponse(w, http.StatusOK, matrix)
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/macadrich/go-bike/database/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestTripFlows(t *testing.T) {
+	from := time.Date(2024, 5, 14, 0, 0, 0, 0, time.UTC)
+	to := from.Add(24 * time.Hour)
+
+	tests := []struct {
+		name string
+		url  string
+		want int
+	}{
+		{"default interval", "/api/v1/trips/flows?from=2024-05-14T00:00:00Z&to=2024-05-15T00:00:00Z", http.StatusOK},
+		{"one kiosk", "/api/v1/trips/flows?from=2024-05-14T00:00:00Z&to=2024-05-15T00:00:00Z&kioskId=3005&interval=15m", http.StatusOK},
+		{"missing from", "/api/v1/trips/flows", http.StatusBadRequest},
+		{"range too long", "/api/v1/trips/flows?from=2024-01-01T00:00:00Z&to=2024-05-15T00:00:00Z", http.StatusBadRequest},
+		{"reversed range", "/api/v1/trips/flows?from=2024-05-15T00:00:00Z&to=2024-05-14T00:00:00Z", http.StatusBadRequest},
+		{"interval too short", "/api/v1/trips/flows?from=2024-05-14T00:00:00Z&to=2024-05-15T00:00:00Z&interval=1m", http.StatusBadRequest},
+		{"invalid kiosk", "/api/v1/trips/flows?from=2024-05-14T00:00:00Z&to=2024-05-15T00:00:00Z&kioskId=abc", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := NewMockDB()
+			mockDB.On("TripFlows", from, to, time.Hour, 0).Return(&models.TripFlows{}, nil)
+			mockDB.On("TripFlows", from, to, 15*time.Minute, 3005).Return(&models.TripFlows{}, nil)
+			handlers := NewHandlers(mockDB)
+
+			req := httptest.NewRequest("GET", tt.url, nil)
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(handlers.TripFlows).ServeHTTP(rr, req)
+			assert.Equal(t, tt.want, rr.Code)
+		})
+	}
+}
+
+func TestTripFlowsRelativeTimes(t *testing.T) {
+	// The plus sign of +30m is sent both unencoded, which query decoding
+	// turns into a space, and encoded.
+	for _, later := range []string{"+30m", "%2B30m"} {
+		t.Run(later, func(t *testing.T) {
+			mockDB := NewMockDB()
+			mockDB.On("TripFlows", mock.Anything, mock.Anything, time.Hour, 0).Return(&models.TripFlows{}, nil)
+			handlers := NewHandlers(mockDB)
+
+			req := httptest.NewRequest("GET", "/api/v1/trips/flows?from=-1h&to="+later, nil)
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(handlers.TripFlows).ServeHTTP(rr, req)
+			assert.Equal(t, http.StatusOK, rr.Code)
+
+			from := mockDB.Calls[0].Arguments.Get(0).(time.Time)
+			to := mockDB.Calls[0].Arguments.Get(1).(time.Time)
+			assert.Equal(t, 90*time.Minute, to.Sub(from))
+		})
+	}
+}
+
+func TestTripOD(t *testing.T) {
+	tests := []struct {
+		name  string
+		url   string
+		limit int
+		want  int
+	}{
+		{"default limit", "/api/v1/trips/od?from=2024-05-14T00:00:00Z&to=2024-05-15T00:00:00Z", defaultODLimit, http.StatusOK},
+		{"limit", "/api/v1/trips/od?from=2024-05-14T00:00:00Z&to=2024-05-15T00:00:00Z&limit=5", 5, http.StatusOK},
+		{"invalid limit", "/api/v1/trips/od?from=2024-05-14T00:00:00Z&to=2024-05-15T00:00:00Z&limit=0", 0, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := NewMockDB()
+			mockDB.On("TripOD", mock.Anything, mock.Anything, tt.limit).Return(&models.ODMatrix{}, nil)
+			handlers := NewHandlers(mockDB)
+
+			req := httptest.NewRequest("GET", tt.url, nil)
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(handlers.TripOD).ServeHTTP(rr, req)
+			assert.Equal(t, tt.want, rr.Code)
+		})
+	}
+}
@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/macadrich/go-bike/api/problem"
+	"github.com/macadrich/go-bike/api/validate"
+	"github.com/macadrich/go-bike/database/models"
+	"github.com/macadrich/go-bike/pkg/tracing"
+)
+
+var eventTypes = []string{models.EventBecameEmpty, models.EventBecameFull, models.EventWentOffline, models.EventRecovered}
+
+var deliveryStatuses = []string{models.DeliveryPending, models.DeliverySucceeded, models.DeliveryFailed}
+
+// validate records every invalid field of a subscription request in v.
+func (req *WebhookRequest) validate(v *validate.Params) {
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		v.Invalid("url", validate.InBody, "must be an http(s) URL")
+	}
+	var unknown []string
+	for _, t := range req.EventTypes {
+		if !slices.Contains(eventTypes, t) {
+			unknown = append(unknown, strconv.Quote(t))
+		}
+	}
+	if len(unknown) > 0 {
+		v.Invalid("eventTypes", validate.InBody, "has unknown event types "+strings.Join(unknown, ", "))
+	}
+	if slices.ContainsFunc(req.KioskIds, func(id int64) bool { return id <= 0 }) {
+		v.Invalid("kioskIds", validate.InBody, "must be positive")
+	}
+}
+
+// maxPageSize bounds the limit of paginated listings.
+const maxPageSize = 1000
+
+// CreateWebhook subscribes a URL to station events. The response holds the
+// signing secret, which is not shown again.
+//
+// @Summary Subscribe to station events
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param request body WebhookRequest true "Subscription"
+// @Success 201 {object} models.WebhookSubscription
+// @Failure 400,401,429,500 {object} problem.Problem
+// @Security BearerAuth
+// @Router /api/v1/webhooks [post]
+// @Router /api/v2/webhooks [post]
+func (h *Handlers) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "Handlers.CreateWebhook")
+	defer span.End()
+
+	var req WebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		badRequest(w, r, "request body must be a JSON object")
+		return
+	}
+	v := validate.New(r)
+	req.validate(v)
+	if err := v.Err(); err != nil {
+		problem.Write(w, r, err)
+		return
+	}
+
+	sub, err := h.svc.CreateWebhook(ctx, &models.WebhookSubscription{
+		URL:        req.URL,
+		Secret:     req.Secret,
+		EventTypes: req.EventTypes,
+		KioskIds:   req.KioskIds,
+	})
+	if err != nil {
+		sendError(w, r, span, err, "Unable to create webhook")
+		return
+	}
+
+	sendResponse(w, http.StatusCreated, sub)
+}
+
+// ListWebhooks godoc
+// @Summary List webhook subscriptions
+// @Tags webhooks
+// @Produce json
+// @Success 200 {array} models.WebhookSubscription
+// @Failure 401,429,500 {object} problem.Problem
+// @Security BearerAuth
+// @Router /api/v1/webhooks [get]
+// @Router /api/v2/webhooks [get]
+func (h *Handlers) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "Handlers.ListWebhooks")
+	defer span.End()
+
+	subs, err := h.svc.ListWebhooks(ctx)
+	if err != nil {
+		sendError(w, r, span, err, "Unable to list webhooks")
+		return
+	}
+
+	sendResponse(w, http.StatusOK, subs)
+}
+
+// DeleteWebhook godoc
+// @Summary Delete a webhook subscription
+// @Tags webhooks
+// @Param id path int true "Subscription id" minimum(1)
+// @Success 204
+// @Failure 400,401,404,429,500 {object} problem.Problem
+// @Security BearerAuth
+// @Router /api/v1/webhooks/{id} [delete]
+// @Router /api/v2/webhooks/{id} [delete]
+func (h *Handlers) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "Handlers.DeleteWebhook")
+	defer span.End()
+
+	v := validate.New(r)
+	id := v.PathID("id")
+	if err := v.Err(); err != nil {
+		problem.Write(w, r, err)
+		return
+	}
+
+	if err := h.svc.DeleteWebhook(ctx, id); err != nil {
+		sendError(w, r, span, err, "Unable to delete webhook")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListDeliveries shows the delivery log of a webhook, newest first and
+// optionally filtered by status. Pass the id of the last delivery of a page
+// as beforeId to get the next one.
+//
+// @Summary List the deliveries of a webhook
+// @Tags webhooks
+// @Produce json
+// @Param id path int true "Subscription id" minimum(1)
+// @Param status query string false "Only deliveries with this status" Enums(pending, succeeded, failed)
+// @Param beforeId query int false "Resume before the delivery with this id" minimum(1)
+// @Param limit query int false "Page size" minimum(1) maximum(1000) default(1000)
+// @Success 200 {array} models.WebhookDelivery
+// @Failure 400,401,404,429,500 {object} problem.Problem
+// @Security BearerAuth
+// @Router /api/v1/webhooks/{id}/deliveries [get]
+// @Router /api/v2/webhooks/{id}/deliveries [get]
+func (h *Handlers) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "Handlers.ListDeliveries")
+	defer span.End()
+
+	v := validate.New(r)
+	id := v.PathID("id")
+	status := v.Enum("status", "", deliveryStatuses...)
+	beforeId := v.ID("beforeId")
+	limit := v.Int("limit", maxPageSize, 1, maxPageSize)
+	if err := v.Err(); err != nil {
+		problem.Write(w, r, err)
+		return
+	}
+
+	deliveries, err := h.svc.ListDeliveries(ctx, id, status, beforeId, limit)
+	if err != nil {
+		sendError(w, r, span, err, "Unable to list deliveries")
+		return
+	}
+
+	sendResponse(w, http.StatusOK, deliveries)
+}
+
+// ReplayDelivery sends a failed delivery again. It answers 202 at once; the
+// outcome is recorded in the delivery log.
+//
+// @Summary Replay a failed delivery
+// @Tags webhooks
+// @Produce json
+// @Param deliveryId path int true "Delivery id" minimum(1)
+// @Success 202 {object} models.WebhookDelivery
+// @Failure 400,401,404,409,429,500 {object} problem.Problem
+// @Security BearerAuth
+// @Router /api/v1/webhooks/deliveries/{deliveryId}/replay [post]
+// @Router /api/v2/webhooks/deliveries/{deliveryId}/replay [post]
+func (h *Handlers) ReplayDelivery(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "Handlers.ReplayDelivery")
+	defer span.End()
+
+	v := validate.New(r)
+	id := v.PathID("deliveryId")
+	if err := v.Err(); err != nil {
+		problem.Write(w, r, err)
+		return
+	}
+
+	delivery, err := h.svc.ReplayDelivery(ctx, id)
+	if err != nil {
+		sendError(w, r, span, err, "Unable to replay delivery")
+		return
+	}
+
+	sendResponse(w, http.StatusAccepted, delivery)
+}
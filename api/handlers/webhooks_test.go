@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/macadrich/go-bike/api"
+	"github.com/macadrich/go-bike/database"
+	"github.com/macadrich/go-bike/database/models"
+	"github.com/stretchr/testify/mock"
+)
+
+func webhookRouter(handlers *Handlers) *chi.Mux {
+	router := chi.NewRouter()
+	router.Post("/webhooks", handlers.CreateWebhook)
+	router.Delete("/webhooks/{id}", handlers.DeleteWebhook)
+	router.Get("/webhooks/{id}/deliveries", handlers.ListDeliveries)
+	router.Post("/webhooks/deliveries/{deliveryId}/replay", handlers.ReplayDelivery)
+	return router
+}
+
+func TestCreateWebhook(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"ok", `{"url":"https://example.com/hook","eventTypes":["became_empty"],"kioskIds":[3005]}`, http.StatusCreated},
+		{"all events", `{"url":"http://localhost:9000/hook"}`, http.StatusCreated},
+		{"invalid json", `{"url":`, http.StatusBadRequest},
+		{"invalid url", `{"url":"ftp://example.com"}`, http.StatusBadRequest},
+		{"unknown event", `{"url":"https://example.com/hook","eventTypes":["exploded"]}`, http.StatusBadRequest},
+		{"invalid kiosk", `{"url":"https://example.com/hook","kioskIds":[-1]}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := NewMockDB()
+			mockDB.On("CreateWebhook", mock.Anything).Return(&models.WebhookSubscription{Id: 1, Secret: "s"}, nil)
+
+			req, err := http.NewRequest("POST", "/webhooks", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			rr := httptest.NewRecorder()
+			webhookRouter(NewHandlers(mockDB)).ServeHTTP(rr, req)
+			if status := rr.Code; status != tt.want {
+				t.Errorf("handler returned wrong status code: got %v want %v",
+					status, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeleteWebhook(t *testing.T) {
+	mockDB := NewMockDB()
+	mockDB.On("DeleteWebhook", int64(1)).Return(nil)
+	mockDB.On("DeleteWebhook", int64(2)).Return(database.ErrNotFound)
+	router := webhookRouter(NewHandlers(mockDB))
+
+	for url, want := range map[string]int{
+		"/webhooks/1":   http.StatusNoContent,
+		"/webhooks/2":   http.StatusNotFound,
+		"/webhooks/abc": http.StatusBadRequest,
+	} {
+		req, err := http.NewRequest("DELETE", url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if status := rr.Code; status != want {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v",
+				url, status, want)
+		}
+	}
+}
+
+func TestListDeliveries(t *testing.T) {
+	mockDB := NewMockDB()
+	mockDB.On("ListDeliveries", int64(1), "", int64(0), 1000).Return([]models.WebhookDelivery{}, nil)
+	mockDB.On("ListDeliveries", int64(1), models.DeliveryFailed, int64(40), 20).Return([]models.WebhookDelivery{}, nil)
+	mockDB.On("ListDeliveries", int64(2), "", int64(0), 1000).Return([]models.WebhookDelivery(nil), database.ErrNotFound)
+	router := webhookRouter(NewHandlers(mockDB))
+
+	for url, want := range map[string]int{
+		"/webhooks/1/deliveries":                                    http.StatusOK,
+		"/webhooks/1/deliveries?status=failed&beforeId=40&limit=20": http.StatusOK,
+		"/webhooks/2/deliveries":                                    http.StatusNotFound,
+		"/webhooks/1/deliveries?beforeId=abc":                       http.StatusBadRequest,
+		"/webhooks/1/deliveries?limit=0":                            http.StatusBadRequest,
+	} {
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if status := rr.Code; status != want {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v",
+				url, status, want)
+		}
+	}
+}
+
+func TestReplayDelivery(t *testing.T) {
+	mockDB := NewMockDB()
+	mockDB.On("ReplayDelivery", int64(1)).Return(&models.WebhookDelivery{Id: 1, Status: models.DeliveryPending}, nil)
+	mockDB.On("ReplayDelivery", int64(2)).Return(nil, database.ErrNotFound)
+	mockDB.On("ReplayDelivery", int64(3)).Return(nil, api.ErrDeliveryNotFailed)
+	router := webhookRouter(NewHandlers(mockDB))
+
+	for url, want := range map[string]int{
+		"/webhooks/deliveries/1/replay": http.StatusAccepted,
+		"/webhooks/deliveries/2/replay": http.StatusNotFound,
+		"/webhooks/deliveries/3/replay": http.StatusConflict,
+	} {
+		req, err := http.NewRequest("POST", url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if status := rr.Code; status != want {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v",
+				url, status, want)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"context"
+	"time"
+
+	"github.com/macadrich/go-bike/database/models"
+	"github.com/macadrich/go-bike/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ResolutionAuto lets StationHistory pick the resolution from the range.
+const ResolutionAuto = "auto"
+
+// Ranges up to rawHistoryRange are served from the raw snapshots and up to
+// hourlyHistoryRange from the hourly rollups; longer ones use daily rollups.
+const (
+	rawHistoryRange    = 48 * time.Hour
+	hourlyHistoryRange = 60 * 24 * time.Hour
+)
+
+// historyResolution resolves ResolutionAuto for the range from-to.
+func historyResolution(resolution string, from, to time.Time) string {
+	if resolution != ResolutionAuto && resolution != "" {
+		return resolution
+	}
+
+	switch span := to.Sub(from); {
+	case span <= rawHistoryRange:
+		return models.ResolutionRaw
+	case span <= hourlyHistoryRange:
+		return models.ResolutionHour
+	default:
+		return models.ResolutionDay
+	}
+}
+
+func (s *service) StationHistory(ctx context.Context, kioskId int, from, to time.Time, resolution string) (_ *models.StationHistory, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "service.StationHistory")
+	defer func() { tracing.End(span, err) }()
+
+	resolution = historyResolution(resolution, from, to)
+	span.SetAttributes(attribute.Int("kiosk.id", kioskId), attribute.String("history.resolution", resolution))
+
+	points, err := s.db.StationHistory(ctx, kioskId, from, to, resolution)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.StationHistory{
+		KioskId:    kioskId,
+		From:       from,
+		To:         to,
+		Resolution: resolution,
+		Points:     points,
+	}, nil
+}
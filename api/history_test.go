@@ -0,0 +1,29 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/macadrich/go-bike/database/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistoryResolution(t *testing.T) {
+	from := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		resolution string
+		to         time.Time
+		want       string
+	}{
+		{ResolutionAuto, from.Add(6 * time.Hour), models.ResolutionRaw},
+		{"", from.Add(48 * time.Hour), models.ResolutionRaw},
+		{ResolutionAuto, from.AddDate(0, 0, 7), models.ResolutionHour},
+		{ResolutionAuto, from.AddDate(1, 0, 0), models.ResolutionDay},
+		{models.ResolutionRaw, from.AddDate(1, 0, 0), models.ResolutionRaw},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, historyResolution(tt.resolution, from, tt.to), "%s over %s", tt.resolution, tt.to.Sub(from))
+	}
+}
@@ -0,0 +1,199 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/macadrich/go-bike/client"
+	"github.com/macadrich/go-bike/database/models"
+	"github.com/macadrich/go-bike/pkg/archive"
+	"github.com/macadrich/go-bike/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ImportOptions configures an import of saved feed files from Path, a
+// directory, .tar.gz archive or single file. Workers files are stored at
+// once. Progress, when set, is called after every file.
+type ImportOptions struct {
+	Path     string
+	Workers  int
+	Progress func(ImportProgress)
+}
+
+// ImportProgress counts the files of an import. Skipped files hold a
+// snapshot that was already stored, by an earlier run or another file;
+// failed ones are not feeds.
+type ImportProgress struct {
+	Files    int
+	Imported int
+	Skipped  int
+	Failed   int
+}
+
+// Import stores the snapshots of saved feed files through the same path
+// as live ingestion. Snapshots already stored are skipped, so an
+// interrupted import resumes by running it again. It stops at the first
+// database error.
+func (s *service) Import(ctx context.Context, opts ImportOptions) (_ *ImportProgress, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "service.Import")
+	defer func() { tracing.End(span, err) }()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	imp := &importer{
+		service:  s,
+		progress: opts.Progress,
+		claimed:  make(map[int64]bool),
+		months:   make(map[time.Time]bool),
+	}
+
+	type feedFile struct {
+		name string
+		data []byte
+	}
+	files := make(chan feedFile)
+
+	var wg sync.WaitGroup
+	var once sync.Once
+	var failure error
+	for i := 0; i < max(opts.Workers, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for f := range files {
+				if err := imp.importFile(ctx, f.name, f.data); err != nil {
+					once.Do(func() { failure = err })
+					cancel()
+				}
+			}
+		}()
+	}
+
+	err = archive.Walk(opts.Path, func(name string, r io.Reader) error {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		select {
+		case files <- feedFile{name, data}:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	close(files)
+	wg.Wait()
+
+	total := imp.counts
+	span.SetAttributes(
+		attribute.Int("import.files", total.Files),
+		attribute.Int("import.imported", total.Imported),
+		attribute.Int("import.skipped", total.Skipped),
+		attribute.Int("import.failed", total.Failed),
+	)
+
+	if failure != nil {
+		return &total, failure
+	}
+	return &total, err
+}
+
+// importer tracks the files of one import across its workers.
+type importer struct {
+	service  *service
+	progress func(ImportProgress)
+
+	mu      sync.Mutex
+	counts  ImportProgress
+	claimed map[int64]bool
+
+	// partitionMu serializes partition creation, which is not safe to
+	// run concurrently for the same month.
+	partitionMu sync.Mutex
+	months      map[time.Time]bool
+}
+
+// claim reports whether the snapshot last updated at at is not handled by
+// another file of the import.
+func (imp *importer) claim(at time.Time) bool {
+	imp.mu.Lock()
+	defer imp.mu.Unlock()
+
+	if imp.claimed[at.UnixNano()] {
+		return false
+	}
+	imp.claimed[at.UnixNano()] = true
+	return true
+}
+
+// ensurePartition creates the partitions for the month of at, which may be
+// older than any that maintenance creates.
+func (imp *importer) ensurePartition(ctx context.Context, at time.Time) error {
+	imp.partitionMu.Lock()
+	defer imp.partitionMu.Unlock()
+
+	month := time.Date(at.UTC().Year(), at.UTC().Month(), 1, 0, 0, 0, 0, time.UTC)
+	if imp.months[month] {
+		return nil
+	}
+	if err := imp.service.db.EnsurePartitions(ctx, at, at); err != nil {
+		return err
+	}
+	imp.months[month] = true
+	return nil
+}
+
+func (imp *importer) count(update func(*ImportProgress)) {
+	imp.mu.Lock()
+	defer imp.mu.Unlock()
+
+	imp.counts.Files++
+	update(&imp.counts)
+	if imp.progress != nil {
+		imp.progress(imp.counts)
+	}
+}
+
+// importFile stores the snapshot in data. Files that are not feeds are
+// logged and counted, only database errors are returned.
+func (imp *importer) importFile(ctx context.Context, name string, data []byte) error {
+	resp, err := client.ReadResponse(bytes.NewReader(data))
+	var at time.Time
+	if err == nil {
+		at, err = resp.LastUpdatedTime()
+	}
+	if err != nil {
+		log.Printf("import: skipping %s: %v", name, err)
+		imp.count(func(p *ImportProgress) { p.Failed++ })
+		return nil
+	}
+
+	if !imp.claim(at) {
+		imp.count(func(p *ImportProgress) { p.Skipped++ })
+		return nil
+	}
+
+	if err := imp.ensurePartition(ctx, at); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	stored, err := imp.service.ingest(ctx, resp, models.SourceImport)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	imp.count(func(p *ImportProgress) {
+		if stored != nil {
+			p.Imported++
+		} else {
+			p.Skipped++
+		}
+	})
+	return nil
+}
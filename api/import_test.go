@@ -0,0 +1,135 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/macadrich/go-bike/client"
+	"github.com/macadrich/go-bike/config"
+	"github.com/macadrich/go-bike/database"
+	"github.com/macadrich/go-bike/database/models"
+	"github.com/macadrich/go-bike/pkg/apperr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// importDB keeps ingestions and station snapshots in memory. Like the
+// partitioned tables, it only stores snapshots of months with a partition.
+type importDB struct {
+	database.Database
+
+	mu         sync.Mutex
+	ingestions map[time.Time]models.Ingestion
+	stations   map[time.Time]int
+	zone       string
+	partitions map[string]int
+}
+
+func newImportDB() *importDB {
+	return &importDB{
+		ingestions: make(map[time.Time]models.Ingestion),
+		stations:   make(map[time.Time]int),
+		partitions: map[string]int{time.Now().UTC().Format("2006_01"): 1},
+	}
+}
+
+func (db *importDB) EnsurePartitions(ctx context.Context, from, through time.Time) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	from = time.Date(from.UTC().Year(), from.UTC().Month(), 1, 0, 0, 0, 0, time.UTC)
+	for month := from; !month.After(through); month = month.AddDate(0, 1, 0) {
+		db.partitions[month.Format("2006_01")]++
+	}
+	return nil
+}
+
+func (db *importDB) IngestionExists(ctx context.Context, at time.Time) (bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	_, ok := db.ingestions[at]
+	return ok, nil
+}
+
+func (db *importDB) RecordIngestion(ctx context.Context, ingestion *models.Ingestion) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.ingestions[ingestion.At] = *ingestion
+	return nil
+}
+
+func (db *importDB) InsertStation(ctx context.Context, at time.Time, loc *time.Location, station *models.Stations) (bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.partitions[at.UTC().Format("2006_01")] == 0 {
+		return false, fmt.Errorf("pq: no partition of relation \"station_status\" found for row")
+	}
+	db.stations[at]++
+	db.zone = loc.String()
+	return true, nil
+}
+
+func TestImport(t *testing.T) {
+	feed, err := os.ReadFile("../phl.json")
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	// The same snapshot saved twice, and a file that is not a feed.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.json"), feed, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.json"), feed, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "c.json"), []byte("<html>"), 0o644))
+
+	db := newImportDB()
+	cfg := &config.Config{}
+	cfg.ThirdpartyAPI.TimeZone = "America/New_York"
+	s := &service{db: db, live: config.NewLive("", cfg)}
+
+	var calls int
+	progress, err := s.Import(context.Background(), ImportOptions{
+		Path:     dir,
+		Workers:  3,
+		Progress: func(ImportProgress) { calls++ },
+	})
+	require.NoError(t, err)
+	assert.Equal(t, ImportProgress{Files: 3, Imported: 1, Skipped: 1, Failed: 1}, *progress)
+	assert.Equal(t, 3, calls)
+
+	at := time.Date(2024, 5, 10, 13, 22, 19, 385000000, time.UTC)
+	require.Contains(t, db.ingestions, at)
+	assert.Equal(t, models.SourceImport, db.ingestions[at].Source)
+	assert.Equal(t, db.ingestions[at].Stations, db.stations[at])
+	assert.Equal(t, "America/New_York", db.zone, "rollup days follow the feed time zone")
+	assert.Equal(t, 1, db.partitions["2024_05"], "the old month gets a partition once")
+
+	// Running it again resumes: stored snapshots are skipped.
+	progress, err = s.Import(context.Background(), ImportOptions{Path: dir, Workers: 2})
+	require.NoError(t, err)
+	assert.Equal(t, ImportProgress{Files: 3, Skipped: 2, Failed: 1}, *progress)
+	assert.Equal(t, db.ingestions[at].Stations, db.stations[at])
+}
+
+func TestIngestWithoutStations(t *testing.T) {
+	for name, body := range map[string]string{
+		"empty":   `{"last_updated": "2024-05-14T06:48:19.588Z", "features": []}`,
+		"missing": `{"last_updated": "2024-05-14T06:48:19.588Z"}`,
+		"invalid": `{"last_updated": "2024-05-14T06:48:19.588Z", "features": "none"}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			resp, err := client.ReadResponse(strings.NewReader(body))
+			require.NoError(t, err)
+
+			db := newImportDB()
+			s := &service{db: db, live: config.NewLive("", &config.Config{})}
+
+			ingestion, err := s.ingest(context.Background(), resp, models.SourceLive)
+			assert.Nil(t, ingestion)
+			assert.Equal(t, apperr.KindUnavailable, apperr.As(err).Kind)
+			assert.Empty(t, db.ingestions, "nothing is recorded")
+		})
+	}
+}
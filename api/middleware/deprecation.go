@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Deprecated marks the responses of a deprecated API version: the
+// Deprecation header (RFC 9745) gives the time it was deprecated, the
+// Sunset header (RFC 8594) the time it stops being served, and a Link
+// header points to its successor.
+func Deprecated(since, sunset time.Time, successor string) func(http.Handler) http.Handler {
+	deprecation := "@" + strconv.FormatInt(since.Unix(), 10)
+	sunsetDate := sunset.UTC().Format(http.TimeFormat)
+	link := "<" + successor + `>; rel="successor-version"`
+
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Deprecation", deprecation)
+			w.Header().Set("Sunset", sunsetDate)
+			w.Header().Add("Link", link)
+			h.ServeHTTP(w, r)
+		})
+	}
+}
@@ -1,18 +1,103 @@
 package middleware
 
 import (
+	"context"
+	"crypto/subtle"
 	"net/http"
+	"strings"
+
+	"github.com/macadrich/go-bike/api/problem"
+	"github.com/macadrich/go-bike/pkg/apperr"
+)
+
+var (
+	errUnauthorized = apperr.New(apperr.KindUnauthorized, "unauthorized", "a valid bearer token is required")
+	errVerifyKey    = apperr.New(apperr.KindUnavailable, "credentials_unavailable", "unable to verify credentials")
 )
 
-func StaticTokenAuthorization(token string) func(http.Handler) http.Handler {
+// KeyVerifier reports whether key is a valid API key.
+type KeyVerifier func(ctx context.Context, key string) (bool, error)
+
+// AccessToken names the query parameter and the cookie that carry the
+// bearer token of streams, which browsers open with EventSource or
+// WebSocket and cannot give an Authorization header. Only EventSource
+// streams read the cookie; see SocketTokenAuthorization.
+const AccessToken = "access_token"
+
+// TokenAuthorization accepts requests whose bearer token is either the
+// configured token or, when verify is set, an API key it accepts.
+func TokenAuthorization(token string, verify KeyVerifier) func(http.Handler) http.Handler {
+	return authorize(token, verify, headerToken)
+}
+
+// StreamTokenAuthorization is TokenAuthorization for the stream routes: the
+// bearer token may also be given as the AccessToken query parameter or
+// cookie.
+func StreamTokenAuthorization(token string, verify KeyVerifier) func(http.Handler) http.Handler {
+	return authorize(token, verify, streamToken)
+}
+
+// SocketTokenAuthorization is TokenAuthorization for WebSocket routes: the
+// bearer token may also be given as the AccessToken query parameter, but
+// not as the cookie. Browsers send cookies with cross-site WebSocket
+// handshakes and, unlike with EventSource, no CORS check keeps the other
+// site from reading the stream.
+func SocketTokenAuthorization(token string, verify KeyVerifier) func(http.Handler) http.Handler {
+	return authorize(token, verify, queryToken)
+}
+
+func headerToken(r *http.Request) string {
+	bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return ""
+	}
+	return bearer
+}
+
+func queryToken(r *http.Request) string {
+	if bearer := headerToken(r); bearer != "" {
+		return bearer
+	}
+	return r.URL.Query().Get(AccessToken)
+}
+
+func streamToken(r *http.Request) string {
+	if bearer := queryToken(r); bearer != "" {
+		return bearer
+	}
+	if cookie, err := r.Cookie(AccessToken); err == nil {
+		return cookie.Value
+	}
+	return ""
+}
+
+func authorize(token string, verify KeyVerifier, credentials func(*http.Request) string) func(http.Handler) http.Handler {
 	return func(h http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			authToken := r.Header.Get("Authorization")
-			if authToken != "Bearer "+token {
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			bearer := credentials(r)
+			if bearer == "" {
+				problem.Write(w, r, errUnauthorized)
 				return
 			}
-			h.ServeHTTP(w, r)
+
+			if subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) == 1 {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			if verify != nil {
+				valid, err := verify(r.Context(), bearer)
+				if err != nil {
+					problem.Write(w, r, errVerifyKey)
+					return
+				}
+				if valid {
+					h.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			problem.Write(w, r, errUnauthorized)
 		})
 	}
 }
@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/macadrich/go-bike/api/problem"
+	"github.com/macadrich/go-bike/config"
+	"github.com/macadrich/go-bike/pkg/apperr"
+	"golang.org/x/time/rate"
+)
+
+// maxLimiters bounds the number of client buckets kept in memory. Beyond it,
+// buckets that are full again, i.e. idle clients, are dropped.
+const maxLimiters = 10000
+
+var errRateLimited = apperr.New(apperr.KindRateLimited, "rate_limited", "too many requests, retry later")
+
+type rateLimiter struct {
+	live *config.Live
+
+	mu       sync.Mutex
+	version  int64
+	limiters map[string]*rate.Limiter
+}
+
+// RateLimit applies a per-client token bucket configured by the RateLimit
+// section. Changes to the section take effect on the next request.
+func RateLimit(live *config.Live) func(http.Handler) http.Handler {
+	rl := &rateLimiter{
+		live:     live,
+		limiters: make(map[string]*rate.Limiter),
+	}
+
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			snap := rl.live.Current()
+			cfg := snap.Config.RateLimit
+			if !cfg.Enabled {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			limiter := rl.limiter(snap.Version, cfg, clientIP(r))
+			if !limiter.Allow() {
+				w.Header().Set("Retry-After", strconv.Itoa(int(1/cfg.RequestsPerSecond)+1))
+				problem.Write(w, r, errRateLimited)
+				return
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (rl *rateLimiter) limiter(version int64, cfg config.RateLimitConfig, key string) *rate.Limiter {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if version != rl.version {
+		for _, l := range rl.limiters {
+			l.SetLimit(rate.Limit(cfg.RequestsPerSecond))
+			l.SetBurst(cfg.Burst)
+		}
+		rl.version = version
+	}
+
+	l, ok := rl.limiters[key]
+	if !ok {
+		if len(rl.limiters) >= maxLimiters {
+			for k, idle := range rl.limiters {
+				if idle.Tokens() >= float64(cfg.Burst) {
+					delete(rl.limiters, k)
+				}
+			}
+		}
+		l = rate.NewLimiter(rate.Limit(cfg.RequestsPerSecond), cfg.Burst)
+		rl.limiters[key] = l
+	}
+
+	return l
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
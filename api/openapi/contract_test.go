@@ -0,0 +1,530 @@
+package openapi_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/macadrich/go-bike/api"
+	"github.com/macadrich/go-bike/api/handlers"
+	"github.com/macadrich/go-bike/api/openapi"
+	"github.com/macadrich/go-bike/api/routers"
+	"github.com/macadrich/go-bike/client"
+	"github.com/macadrich/go-bike/config"
+	"github.com/macadrich/go-bike/database"
+	"github.com/macadrich/go-bike/database/models"
+	"github.com/macadrich/go-bike/pkg/apperr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const token = "secret"
+
+var at = time.Date(2024, 5, 14, 6, 48, 0, 0, time.UTC)
+
+// fakeDB answers every query of the documented routes with a little data,
+// so that responses exercise most of their schema.
+type fakeDB struct {
+	database.Database
+	hookURL string
+}
+
+func station(kioskId, bikes, docks int) models.Stations {
+	return models.Stations{
+		Id: kioskId, At: at, KioskId: kioskId, Name: "Welcome Park",
+		BikesAvailable: bikes, DocksAvailable: docks, TotalDocks: bikes + docks,
+		Latitude: 39.94, Longitude: -75.14, KioskConnectionStatus: "Active",
+		Bikes: []models.Bike{{Id: 1, KioskId: kioskId, DockNumber: 1, IsElectric: true, IsAvailable: true, Battery: 80}},
+	}
+}
+
+func (db *fakeDB) QueryAllStation(ctx context.Context, lastUpdate time.Time) ([]models.Stations, error) {
+	return []models.Stations{station(3005, 12, 2), station(3006, 1, 15)}, nil
+}
+
+func (db *fakeDB) QuerySnapshot(ctx context.Context, at time.Time) ([]models.Stations, error) {
+	return []models.Stations{station(3005, 12, 2), station(3006, 1, 15)}, nil
+}
+
+func (db *fakeDB) QuerySpecificStation(ctx context.Context, kioskId int, lastUpdate time.Time) (*models.Stations, error) {
+	if kioskId == 3999 {
+		return nil, apperr.NotFound("station", "station 3999 not found")
+	}
+	s := station(kioskId, 12, 2)
+	return &s, nil
+}
+
+func (db *fakeDB) LatestSnapshot(ctx context.Context) (time.Time, error) {
+	return at, nil
+}
+
+func (db *fakeDB) NextIngestion(ctx context.Context, t time.Time) (time.Time, error) {
+	if t.After(at) {
+		return time.Time{}, nil
+	}
+	return at, nil
+}
+
+func (db *fakeDB) ListIngestions(ctx context.Context, before time.Time, limit int) ([]models.Ingestion, error) {
+	return []models.Ingestion{{At: at, Source: "indego", Stations: 2, IngestedAt: at.Add(time.Minute)}}, nil
+}
+
+func (db *fakeDB) StationHistory(ctx context.Context, kioskId int, from, to time.Time, resolution string) ([]models.HistoryPoint, error) {
+	return []models.HistoryPoint{{At: from, Samples: 12, BikesMin: 3, BikesMax: 6, BikesAvg: 4.5}}, nil
+}
+
+func (db *fakeDB) QueryEvents(ctx context.Context, since time.Time, kioskId int, afterId int64, limit int) ([]models.StationEvent, error) {
+	return []models.StationEvent{
+		{Id: 1, KioskId: 3005, At: at, Type: models.EventBecameEmpty},
+		{Id: 2, KioskId: 3006, At: at, Type: models.EventRecovered, Recovered: "full"},
+	}, nil
+}
+
+func (db *fakeDB) DockMoves(ctx context.Context, from, to time.Time, kioskId int, fn func(models.DockMove) error) error {
+	moves := []models.DockMove{
+		{KioskId: 3005, PrevAt: from, At: from.Add(10 * time.Minute), Departures: 1},
+		{KioskId: 3006, PrevAt: from, At: from.Add(10 * time.Minute), Arrivals: 1},
+	}
+	for _, move := range moves {
+		if err := fn(move); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *fakeDB) StationCoordinates(ctx context.Context) (map[int]models.Coordinate, error) {
+	return map[int]models.Coordinate{3005: {Long: -75.14, Lat: 39.94}, 3006: {Long: -75.16, Lat: 39.95}}, nil
+}
+
+func (db *fakeDB) EBikesAt(ctx context.Context, at time.Time) ([]models.Bike, error) {
+	return []models.Bike{{KioskId: 3005, IsElectric: true, Battery: 30}, {KioskId: 3006, IsElectric: true, Battery: 90}}, nil
+}
+
+func (db *fakeDB) LowBatteryBikes(ctx context.Context, at time.Time, threshold int, since time.Time) ([]models.LowBatteryBike, error) {
+	return []models.LowBatteryBike{{KioskId: 3005, DockNumber: 5, Battery: 4, LowSince: at.Add(-time.Hour)}}, nil
+}
+
+func (db *fakeDB) DockBatteryHistory(ctx context.Context, kioskId, dockNumber int, from, to time.Time) ([]models.DockBatteryPoint, error) {
+	battery := 55
+	return []models.DockBatteryPoint{{At: from, Docked: true, Electric: true, Battery: &battery}, {At: to}}, nil
+}
+
+func (db *fakeDB) Export(ctx context.Context, dataset string, from, to time.Time, kioskId int, fn func(row []any) error) error {
+	return nil
+}
+
+func (db *fakeDB) CreateWebhook(ctx context.Context, sub *models.WebhookSubscription) error {
+	sub.Id, sub.CreatedAt = 1, at
+	return nil
+}
+
+func (db *fakeDB) GetWebhook(ctx context.Context, id int64) (*models.WebhookSubscription, error) {
+	if id != 1 {
+		return nil, apperr.NotFound("webhook", fmt.Sprintf("webhook %d not found", id))
+	}
+	return &models.WebhookSubscription{Id: 1, URL: db.hookURL, EventTypes: []string{}, KioskIds: []int64{}, CreatedAt: at}, nil
+}
+
+func (db *fakeDB) ListWebhooks(ctx context.Context) ([]models.WebhookSubscription, error) {
+	return []models.WebhookSubscription{{Id: 1, URL: db.hookURL, EventTypes: []string{models.EventBecameEmpty}, KioskIds: []int64{3005}, CreatedAt: at}}, nil
+}
+
+func (db *fakeDB) DeleteWebhook(ctx context.Context, id int64) error {
+	return nil
+}
+
+func delivery(id int64, status string) models.WebhookDelivery {
+	return models.WebhookDelivery{
+		Id: id, SubscriptionId: 1, EventId: 1, EventType: models.EventBecameEmpty,
+		Payload: json.RawMessage(`{"kioskId":3005}`), Status: status, Attempts: 1,
+		ResponseStatus: 500, CreatedAt: at, UpdatedAt: at,
+	}
+}
+
+func (db *fakeDB) ListDeliveries(ctx context.Context, subscriptionId int64, status string, beforeId int64, limit int) ([]models.WebhookDelivery, error) {
+	return []models.WebhookDelivery{delivery(2, models.DeliveryFailed), delivery(1, models.DeliverySucceeded)}, nil
+}
+
+func (db *fakeDB) GetDelivery(ctx context.Context, id int64) (*models.WebhookDelivery, error) {
+	d := delivery(id, models.DeliveryFailed)
+	if id == 1 {
+		d.Status = models.DeliverySucceeded
+	}
+	return &d, nil
+}
+
+func (db *fakeDB) UpdateDelivery(ctx context.Context, d *models.WebhookDelivery) error {
+	return nil
+}
+
+func (db *fakeDB) UseAPIKey(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	return nil, database.ErrNotFound
+}
+
+func (db *fakeDB) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (db *fakeDB) SchemaVersion(ctx context.Context) (int, bool, error) {
+	return 1, false, nil
+}
+
+// fakeClient cannot reach the feeds.
+type fakeClient struct{}
+
+func (fakeClient) GetData(ctx context.Context, endpoint string) (*client.ClientResponse, error) {
+	return nil, errors.New("connection refused")
+}
+
+func (fakeClient) Ping(ctx context.Context, endpoint string) error {
+	return errors.New("connection refused")
+}
+
+func newRouter(t *testing.T) *chi.Mux {
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(hook.Close)
+
+	cfg := &config.Config{}
+	cfg.Authorization.Token = token
+	cfg.Webhooks.MaxAttempts = 1
+	cfg.API.V1Deprecated = "2026-10-19"
+	cfg.API.V1Sunset = "2027-04-19"
+	live := config.NewLive("", cfg)
+	svc := api.NewService(&fakeDB{hookURL: hook.URL}, fakeClient{}, live)
+	t.Cleanup(func() { svc.Shutdown(context.Background()) })
+
+	return routers.NewRouter(handlers.NewHandlers(svc), live)
+}
+
+func loadDocument(t *testing.T) *openapi.Document {
+	doc, err := openapi.Load()
+	require.NoError(t, err)
+	return doc
+}
+
+// TestContract sends requests to the real handlers and checks that the
+// status and the body of every response are described by the document.
+func TestContract(t *testing.T) {
+	doc := loadDocument(t)
+	router := newRouter(t)
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		noAuth bool
+		want   int
+	}{
+		{"GET", "/healthcheck", "", true, http.StatusOK},
+		{"GET", "/livez", "", true, http.StatusOK},
+		{"GET", "/readyz", "", true, http.StatusServiceUnavailable},
+		{"GET", "/openapi.json", "", true, http.StatusOK},
+		{"POST", "/api/v1/indego-data-fetch-and-store-it-db", "", false, http.StatusServiceUnavailable},
+		{"GET", "/api/v1/stations?at=2024-05-14T06:48:00Z", "", false, http.StatusOK},
+		{"GET", "/api/v1/stations?at=yesterday", "", false, http.StatusBadRequest},
+		{"GET", "/api/v1/stations?at=2024-05-14T06:48:00Z", "", true, http.StatusUnauthorized},
+		{"GET", "/api/v1/stations/3005?at=2024-05-14T06:48:00Z", "", false, http.StatusOK},
+		{"GET", "/api/v1/stations/3999?at=2024-05-14T06:48:00Z", "", false, http.StatusNotFound},
+		{"GET", "/api/v1/stations/3005/history?from=2024-05-14T00:00:00Z&to=2024-05-14T06:00:00Z", "", false, http.StatusOK},
+		{"GET", "/api/v1/stations/3005/forecast?horizon=1h", "", false, http.StatusOK},
+		{"GET", "/api/v1/stations/3999/forecast", "", false, http.StatusNotFound},
+		{"GET", "/api/v1/events?since=2024-05-14T00:00:00Z", "", false, http.StatusOK},
+		{"GET", "/api/v1/events?since=2024-05-14T00:00:00Z&limit=0", "", false, http.StatusBadRequest},
+		{"GET", "/api/v1/trips/flows?from=2024-05-14T00:00:00Z&to=2024-05-14T06:00:00Z", "", false, http.StatusOK},
+		{"GET", "/api/v1/trips/od?from=2024-05-14T00:00:00Z&to=2024-05-14T06:00:00Z", "", false, http.StatusOK},
+		{"GET", "/api/v1/ebikes/battery", "", false, http.StatusOK},
+		{"GET", "/api/v1/ebikes/low?threshold=20", "", false, http.StatusOK},
+		{"GET", "/api/v1/ebikes/stations/3005/docks/5/history?from=2024-05-14T00:00:00Z&to=2024-05-14T06:00:00Z", "", false, http.StatusOK},
+		{"POST", "/api/v1/rebalancing/plan", `{"vans": 1}`, false, http.StatusOK},
+		{"POST", "/api/v1/rebalancing/plan", `{"vans": 0, "targets": "magic"}`, false, http.StatusBadRequest},
+		{"GET", "/api/v1/export?from=2024-05-14T00:00:00Z&to=2024-05-14T06:00:00Z", "", false, http.StatusOK},
+		{"POST", "/api/v1/webhooks", `{"url": "https://example.com/hook", "eventTypes": ["became_empty"]}`, false, http.StatusCreated},
+		{"GET", "/api/v1/webhooks", "", false, http.StatusOK},
+		{"DELETE", "/api/v1/webhooks/1", "", false, http.StatusNoContent},
+		{"GET", "/api/v1/webhooks/1/deliveries?limit=10", "", false, http.StatusOK},
+		{"GET", "/api/v1/webhooks/2/deliveries", "", false, http.StatusNotFound},
+		{"POST", "/api/v1/webhooks/deliveries/2/replay", "", false, http.StatusAccepted},
+		{"POST", "/api/v1/webhooks/deliveries/1/replay", "", false, http.StatusConflict},
+		{"GET", "/api/v1/admin/config", "", false, http.StatusOK},
+		{"POST", "/api/v2/ingestions", "", false, http.StatusServiceUnavailable},
+		{"GET", "/api/v2/ingestions?limit=10", "", false, http.StatusOK},
+		{"GET", "/api/v2/snapshots/latest", "", false, http.StatusOK},
+		{"GET", "/api/v2/snapshots/2024-05-14T06:48:00Z", "", false, http.StatusOK},
+		{"GET", "/api/v2/snapshots/yesterday", "", false, http.StatusBadRequest},
+		{"GET", "/api/v2/stations", "", false, http.StatusOK},
+		{"GET", "/api/v2/stations/3005", "", false, http.StatusOK},
+		{"GET", "/api/v2/stations/3999", "", false, http.StatusNotFound},
+		{"GET", "/api/v2/stations/3005/history?from=2024-05-14T00:00:00Z&to=2024-05-14T06:00:00Z", "", false, http.StatusOK},
+		{"GET", "/api/v2/webhooks", "", false, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if !tt.noAuth {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			require.Equal(t, tt.want, rr.Code, rr.Body.String())
+
+			op := operation(t, doc, tt.method, req.URL.Path)
+			resp, ok := object(op["responses"])[strconv.Itoa(rr.Code)].(map[string]any)
+			require.True(t, ok, "status %d is not documented", rr.Code)
+
+			content := object(resp["content"])
+			if content == nil {
+				assert.Empty(t, rr.Body.String(), "undocumented body")
+				return
+			}
+
+			mediaType, _, err := mime.ParseMediaType(rr.Header().Get("Content-Type"))
+			require.NoError(t, err)
+			media, ok := content[mediaType].(map[string]any)
+			require.True(t, ok, "content type %s is not documented", mediaType)
+
+			if mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json") {
+				return
+			}
+			var body any
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+			assert.NoError(t, validate(doc, object(media["schema"]), body, "body"))
+		})
+	}
+}
+
+// TestDeprecation checks that v1 responses announce the sunset of v1 and
+// that v2 responses do not.
+func TestDeprecation(t *testing.T) {
+	router := newRouter(t)
+
+	for path, deprecated := range map[string]bool{
+		"/api/v1/stations": true,
+		"/api/v2/stations": false,
+	} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if !deprecated {
+			assert.Empty(t, rr.Header().Get("Deprecation"), path)
+			assert.Empty(t, rr.Header().Get("Sunset"), path)
+			continue
+		}
+		assert.Regexp(t, `^@\d+$`, rr.Header().Get("Deprecation"))
+		sunset, err := http.ParseTime(rr.Header().Get("Sunset"))
+		require.NoError(t, err)
+		assert.True(t, sunset.After(at))
+		assert.Equal(t, `</api/v2>; rel="successor-version"`, rr.Header().Get("Link"))
+	}
+}
+
+// TestStreamAccessToken subscribes to the stream the way a browser's
+// EventSource does, without an Authorization header.
+func TestStreamAccessToken(t *testing.T) {
+	server := httptest.NewServer(newRouter(t))
+	t.Cleanup(server.Close)
+
+	tests := []struct {
+		name   string
+		path   string
+		cookie string
+		want   int
+	}{
+		{"query", "/api/v2/stations/stream?access_token=" + token, "", http.StatusOK},
+		{"cookie", "/api/v1/stations/stream", token, http.StatusOK},
+		{"wrong token", "/api/v2/stations/stream?access_token=guess", "", http.StatusUnauthorized},
+		{"no token", "/api/v2/stations/stream", "", http.StatusUnauthorized},
+		{"other route", "/api/v2/stations?access_token=" + token, "", http.StatusUnauthorized},
+		// A cookie would let any site open the WebSocket as the user.
+		{"websocket cookie", "/api/v2/stations/ws", token, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+tt.path, nil)
+			require.NoError(t, err)
+			req.Header.Set("Accept", "text/event-stream")
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: "access_token", Value: tt.cookie})
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			assert.Equal(t, tt.want, resp.StatusCode)
+			if tt.want == http.StatusOK {
+				assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+			}
+		})
+	}
+}
+
+// TestRoutesDocumented checks that the document and the router serve the
+// same operations.
+func TestRoutesDocumented(t *testing.T) {
+	doc := loadDocument(t)
+
+	var routed []string
+	err := chi.Walk(newRouter(t), func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if !strings.HasPrefix(route, "/swagger/") {
+			routed = append(routed, method+" "+strings.TrimSuffix(route, "/"))
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	var documented []string
+	for path, item := range doc.Paths {
+		for method := range item {
+			documented = append(documented, strings.ToUpper(method)+" "+path)
+		}
+	}
+
+	sort.Strings(routed)
+	sort.Strings(documented)
+	assert.Equal(t, routed, documented)
+}
+
+// operation finds the operation of the document serving method and path.
+func operation(t *testing.T, doc *openapi.Document, method, path string) map[string]any {
+	for template, item := range doc.Paths {
+		if matchPath(template, path) {
+			op, ok := item[strings.ToLower(method)].(map[string]any)
+			require.True(t, ok, "%s %s is not documented", method, template)
+			return op
+		}
+	}
+	t.Fatalf("%s is not documented", path)
+	return nil
+}
+
+func matchPath(template, path string) bool {
+	want, got := strings.Split(template, "/"), strings.Split(path, "/")
+	if len(want) != len(got) {
+		return false
+	}
+	for i := range want {
+		if want[i] != got[i] && !strings.HasPrefix(want[i], "{") {
+			return false
+		}
+	}
+	return true
+}
+
+// validate checks a decoded JSON value against the subset of JSON Schema
+// the converted document uses. Objects with properties must not hold
+// others, so fields missing from the annotations are caught.
+func validate(doc *openapi.Document, schema map[string]any, value any, at string) error {
+	if ref, ok := schema["$ref"].(string); ok {
+		name := strings.TrimPrefix(ref, "#/components/schemas/")
+		target, ok := doc.Components.Schemas[name].(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: unknown schema %s", at, ref)
+		}
+		return validate(doc, target, value, at)
+	}
+
+	if anyOf, ok := schema["anyOf"].([]any); ok {
+		for _, s := range anyOf {
+			if validate(doc, object(s), value, at) == nil {
+				return nil
+			}
+		}
+		return fmt.Errorf("%s: %v matches none of anyOf", at, value)
+	}
+
+	if types := schemaTypes(schema["type"]); types != nil {
+		t := jsonType(value)
+		if !slices.Contains(types, t) && !(t == "integer" && slices.Contains(types, "number")) {
+			return fmt.Errorf("%s: %s is not %s", at, t, strings.Join(types, " or "))
+		}
+	}
+
+	if enum, ok := schema["enum"].([]any); ok && !slices.Contains(enum, value) {
+		return fmt.Errorf("%s: %v is not one of %v", at, value, enum)
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		props := object(schema["properties"])
+		for name, prop := range v {
+			switch {
+			case props[name] != nil:
+				if err := validate(doc, object(props[name]), prop, at+"."+name); err != nil {
+					return err
+				}
+			case schema["additionalProperties"] != nil:
+				if err := validate(doc, object(schema["additionalProperties"]), prop, at+"."+name); err != nil {
+					return err
+				}
+			case props != nil:
+				return fmt.Errorf("%s: undocumented property %q", at, name)
+			}
+		}
+	case []any:
+		for i, item := range v {
+			if err := validate(doc, object(schema["items"]), item, fmt.Sprintf("%s[%d]", at, i)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func schemaTypes(v any) []string {
+	switch t := v.(type) {
+	case string:
+		return []string{t}
+	case []any:
+		var types []string
+		for _, e := range t {
+			types = append(types, e.(string))
+		}
+		return types
+	}
+	return nil
+}
+
+func jsonType(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case []any:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+func object(v any) map[string]any {
+	m, _ := v.(map[string]any)
+	return m
+}
@@ -0,0 +1,359 @@
+// Package openapi serves the description of the API as an OpenAPI 3.1
+// document.
+//
+// The handlers are annotated for swag, which generates a Swagger 2.0
+// document into package docs. The 3.1 document is converted from it, so
+// the annotations stay the only place the API is described. After changing
+// them, run go generate ./api/openapi and then update the reference copy
+// with go test ./api/openapi -update.
+package openapi
+
+//go:generate swag init --dir ../../cmd,../handlers,. --generalInfo main.go --output ../../docs --parseDependency --parseInternal
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/macadrich/go-bike/api/problem"
+	"github.com/macadrich/go-bike/docs"
+)
+
+// Version is the OpenAPI version of the document.
+const Version = "3.1.0"
+
+// DeprecatedPrefix is the path prefix of the deprecated API version, whose
+// operations are marked deprecated.
+const DeprecatedPrefix = "/api/v1/"
+
+// Document is an OpenAPI document. Its members keep the order of the
+// specification when encoded; their content is the decoded JSON.
+type Document struct {
+	OpenAPI    string                    `json:"openapi" yaml:"openapi"`
+	Info       map[string]any            `json:"info" yaml:"info"`
+	Servers    []map[string]any          `json:"servers,omitempty" yaml:"servers,omitempty"`
+	Tags       []any                     `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Paths      map[string]map[string]any `json:"paths" yaml:"paths"`
+	Components Components                `json:"components" yaml:"components"`
+}
+
+type Components struct {
+	Schemas         map[string]any `json:"schemas,omitempty" yaml:"schemas,omitempty"`
+	SecuritySchemes map[string]any `json:"securitySchemes,omitempty" yaml:"securitySchemes,omitempty"`
+}
+
+// Load converts the generated Swagger 2.0 document. It is converted once
+// and the same document is returned afterwards, so it must not be changed.
+var Load = sync.OnceValues(func() (*Document, error) {
+	return Convert([]byte(docs.SwaggerInfo.ReadDoc()))
+})
+
+// Handler serves the OpenAPI 3.1 document.
+//
+// @Summary OpenAPI 3.1 description of the API
+// @Tags health
+// @Produce json
+// @Success 200 {object} object
+// @Failure 500 {object} problem.Problem
+// @Router /openapi.json [get]
+func Handler(w http.ResponseWriter, r *http.Request) {
+	doc, err := Load()
+	if err != nil {
+		problem.Write(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(doc)
+}
+
+// swagger is the part of a Swagger 2.0 document that swag generates.
+type swagger struct {
+	Info                map[string]any            `json:"info"`
+	BasePath            string                    `json:"basePath"`
+	Consumes            []string                  `json:"consumes"`
+	Produces            []string                  `json:"produces"`
+	Tags                []any                     `json:"tags"`
+	Paths               map[string]map[string]any `json:"paths"`
+	Definitions         map[string]any            `json:"definitions"`
+	SecurityDefinitions map[string]any            `json:"securityDefinitions"`
+}
+
+// Convert translates a Swagger 2.0 document into OpenAPI 3.1:
+//
+//   - body parameters become request bodies, and the type of the other
+//     parameters moves into their schema;
+//   - response schemas are given per media type, with problem details as
+//     application/problem+json;
+//   - definitions become component schemas, x-nullable becomes a null type,
+//     and arrays are nullable since Go encodes nil slices as null;
+//   - an API key in the Authorization header becomes a bearer scheme;
+//   - operations under DeprecatedPrefix are deprecated.
+func Convert(data []byte) (*Document, error) {
+	var src swagger
+	if err := json.Unmarshal(data, &src); err != nil {
+		return nil, fmt.Errorf("parse swagger document: %w", err)
+	}
+
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    src.Info,
+		Tags:    src.Tags,
+		Paths:   make(map[string]map[string]any, len(src.Paths)),
+		Components: Components{
+			Schemas:         make(map[string]any, len(src.Definitions)),
+			SecuritySchemes: make(map[string]any, len(src.SecurityDefinitions)),
+		},
+	}
+	if src.BasePath != "" {
+		doc.Servers = []map[string]any{{"url": src.BasePath}}
+	}
+
+	for path, item := range src.Paths {
+		converted := make(map[string]any, len(item))
+		for method, op := range item {
+			op, ok := op.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("%s: operation %s is not an object", path, method)
+			}
+			operation := convertOperation(op, or(src.Consumes, []string{"application/json"}), or(src.Produces, []string{"application/json"}))
+			if strings.HasPrefix(path, DeprecatedPrefix) {
+				operation["deprecated"] = true
+			}
+			converted[method] = operation
+		}
+		doc.Paths[path] = converted
+	}
+
+	for name, schema := range src.Definitions {
+		doc.Components.Schemas[name] = convertSchema(schema)
+	}
+
+	for name, scheme := range src.SecurityDefinitions {
+		doc.Components.SecuritySchemes[name] = convertSecurityScheme(scheme.(map[string]any))
+	}
+
+	return doc, nil
+}
+
+func convertOperation(op map[string]any, consumes, produces []string) map[string]any {
+	out := make(map[string]any, len(op))
+	for k, v := range op {
+		switch k {
+		case "consumes", "produces", "parameters", "responses":
+		default:
+			out[k] = v
+		}
+	}
+	consumes = or(stringList(op["consumes"]), consumes)
+	produces = or(stringList(op["produces"]), produces)
+
+	var params []any
+	for _, p := range list(op["parameters"]) {
+		p := p.(map[string]any)
+		if p["in"] == "body" {
+			out["requestBody"] = requestBody(p, consumes)
+			continue
+		}
+		params = append(params, convertParameter(p))
+	}
+	if params != nil {
+		out["parameters"] = params
+	}
+
+	responses := make(map[string]any)
+	for code, resp := range object(op["responses"]) {
+		responses[code] = convertResponse(resp.(map[string]any), produces)
+	}
+	out["responses"] = responses
+
+	return out
+}
+
+func requestBody(p map[string]any, consumes []string) map[string]any {
+	content := make(map[string]any, len(consumes))
+	for _, mediaType := range consumes {
+		content[mediaType] = map[string]any{"schema": convertSchema(p["schema"])}
+	}
+
+	body := map[string]any{"content": content}
+	if p["description"] != nil {
+		body["description"] = p["description"]
+	}
+	if p["required"] == true {
+		body["required"] = true
+	}
+	return body
+}
+
+// parameterFields are the members of a Swagger 2.0 parameter that describe
+// its value, and move into its schema.
+var parameterFields = []string{
+	"type", "format", "items", "enum", "default",
+	"minimum", "maximum", "exclusiveMinimum", "exclusiveMaximum",
+	"minLength", "maxLength", "pattern", "minItems", "maxItems",
+}
+
+func convertParameter(p map[string]any) map[string]any {
+	out := make(map[string]any)
+	schema := make(map[string]any)
+	for k, v := range p {
+		switch {
+		case slices.Contains(parameterFields, k):
+			schema[k] = v
+		case k == "collectionFormat":
+			// The other formats have no OpenAPI 3 equivalent.
+			if v == "csv" {
+				out["style"] = "form"
+				out["explode"] = false
+			} else if v == "multi" {
+				out["style"] = "form"
+				out["explode"] = true
+			}
+		default:
+			out[k] = v
+		}
+	}
+	out["schema"] = convertSchema(schema)
+	return out
+}
+
+func convertResponse(resp map[string]any, produces []string) map[string]any {
+	out := make(map[string]any, len(resp))
+	for k, v := range resp {
+		switch k {
+		case "schema":
+			schema := convertSchema(v)
+			mediaTypes := produces
+			if schema["$ref"] == componentRef+"problem.Problem" {
+				mediaTypes = []string{problem.ContentType}
+			}
+			content := make(map[string]any, len(mediaTypes))
+			for _, mediaType := range mediaTypes {
+				content[mediaType] = map[string]any{"schema": schema}
+			}
+			out["content"] = content
+		case "headers":
+			headers := make(map[string]any)
+			for name, h := range object(v) {
+				schema := convertSchema(h)
+				header := map[string]any{"schema": schema}
+				if d, ok := schema["description"]; ok {
+					header["description"] = d
+					delete(schema, "description")
+				}
+				headers[name] = header
+			}
+			out["headers"] = headers
+		default:
+			out[k] = v
+		}
+	}
+	return out
+}
+
+const (
+	definitionRef = "#/definitions/"
+	componentRef  = "#/components/schemas/"
+)
+
+func convertSchema(v any) map[string]any {
+	in := object(v)
+	out := make(map[string]any, len(in))
+	for k, v := range in {
+		switch k {
+		case "$ref":
+			out[k] = componentRef + strings.TrimPrefix(v.(string), definitionRef)
+		case "x-nullable":
+		case "properties":
+			props := make(map[string]any, len(object(v)))
+			for name, prop := range object(v) {
+				schema := convertSchema(prop)
+				if schema["type"] == "array" {
+					schema = nullable(schema)
+				}
+				props[name] = schema
+			}
+			out[k] = props
+		case "items", "additionalProperties":
+			if _, ok := v.(bool); ok {
+				out[k] = v
+			} else {
+				out[k] = convertSchema(v)
+			}
+		case "allOf", "anyOf", "oneOf":
+			var schemas []any
+			for _, s := range list(v) {
+				schemas = append(schemas, convertSchema(s))
+			}
+			out[k] = schemas
+		default:
+			out[k] = v
+		}
+	}
+
+	switch {
+	case out["type"] == "file":
+		out["type"] = "string"
+		out["format"] = "binary"
+	case in["x-nullable"] == true:
+		out = nullable(out)
+	}
+	return out
+}
+
+// nullable also accepts null for schema.
+func nullable(schema map[string]any) map[string]any {
+	if t, ok := schema["type"].(string); ok {
+		schema["type"] = []any{t, "null"}
+		return schema
+	}
+	return map[string]any{"anyOf": []any{schema, map[string]any{"type": "null"}}}
+}
+
+func convertSecurityScheme(scheme map[string]any) map[string]any {
+	out := make(map[string]any, len(scheme))
+	for k, v := range scheme {
+		out[k] = v
+	}
+
+	switch {
+	case scheme["type"] == "basic":
+		out["type"] = "http"
+		out["scheme"] = "basic"
+	case scheme["type"] == "apiKey" && scheme["in"] == "header" && scheme["name"] == "Authorization":
+		delete(out, "in")
+		delete(out, "name")
+		out["type"] = "http"
+		out["scheme"] = "bearer"
+	}
+	return out
+}
+
+func object(v any) map[string]any {
+	m, _ := v.(map[string]any)
+	return m
+}
+
+func list(v any) []any {
+	l, _ := v.([]any)
+	return l
+}
+
+func stringList(v any) []string {
+	var s []string
+	for _, e := range list(v) {
+		s = append(s, e.(string))
+	}
+	return s
+}
+
+func or(s, fallback []string) []string {
+	if len(s) == 0 {
+		return fallback
+	}
+	return s
+}
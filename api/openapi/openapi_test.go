@@ -0,0 +1,122 @@
+package openapi
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+var update = flag.Bool("update", false, "rewrite the reference document")
+
+const referencePath = "../../dist/reference/go-bike.yaml"
+
+const swaggerDoc = `{
+	"swagger": "2.0",
+	"info": {"title": "go-bike API", "version": "1.0"},
+	"basePath": "/",
+	"paths": {
+		"/api/v1/stations/{kioskId}": {
+			"get": {
+				"security": [{"BearerAuth": []}],
+				"produces": ["application/json"],
+				"parameters": [
+					{"type": "integer", "minimum": 1, "name": "kioskId", "in": "path", "required": true},
+					{"type": "array", "items": {"type": "integer"}, "collectionFormat": "csv", "name": "near", "in": "query"}
+				],
+				"responses": {
+					"200": {"description": "OK", "schema": {"$ref": "#/definitions/models.Stations"}},
+					"404": {"description": "Not Found", "schema": {"$ref": "#/definitions/problem.Problem"}}
+				}
+			}
+		},
+		"/api/v1/webhooks": {
+			"post": {
+				"consumes": ["application/json"],
+				"parameters": [
+					{"name": "request", "in": "body", "required": true, "schema": {"$ref": "#/definitions/handlers.WebhookRequest"}}
+				],
+				"responses": {"201": {"description": "Created"}}
+			}
+		}
+	},
+	"definitions": {
+		"models.Stations": {
+			"type": "object",
+			"properties": {
+				"closeTime": {"type": "string", "x-nullable": true},
+				"bikes": {"type": "array", "items": {"$ref": "#/definitions/models.Bike"}}
+			}
+		}
+	},
+	"securityDefinitions": {
+		"BearerAuth": {"type": "apiKey", "name": "Authorization", "in": "header"}
+	}
+}`
+
+func TestConvert(t *testing.T) {
+	doc, err := Convert([]byte(swaggerDoc))
+	require.NoError(t, err)
+
+	assert.Equal(t, Version, doc.OpenAPI)
+	assert.Equal(t, []map[string]any{{"url": "/"}}, doc.Servers)
+
+	get := doc.Paths["/api/v1/stations/{kioskId}"]["get"].(map[string]any)
+	assert.Equal(t, true, get["deprecated"])
+	assert.Equal(t, []any{
+		map[string]any{"name": "kioskId", "in": "path", "required": true, "schema": map[string]any{"type": "integer", "minimum": 1.0}},
+		map[string]any{"name": "near", "in": "query", "style": "form", "explode": false, "schema": map[string]any{"type": "array", "items": map[string]any{"type": "integer"}}},
+	}, get["parameters"])
+	responses := get["responses"].(map[string]any)
+	assert.Equal(t, map[string]any{
+		"description": "OK",
+		"content": map[string]any{
+			"application/json": map[string]any{"schema": map[string]any{"$ref": "#/components/schemas/models.Stations"}},
+		},
+	}, responses["200"])
+	assert.Contains(t, responses["404"].(map[string]any)["content"], "application/problem+json")
+
+	post := doc.Paths["/api/v1/webhooks"]["post"].(map[string]any)
+	assert.Equal(t, map[string]any{
+		"required": true,
+		"content": map[string]any{
+			"application/json": map[string]any{"schema": map[string]any{"$ref": "#/components/schemas/handlers.WebhookRequest"}},
+		},
+	}, post["requestBody"])
+	assert.NotContains(t, post, "parameters")
+
+	assert.Equal(t, map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"closeTime": map[string]any{"type": []any{"string", "null"}},
+			"bikes":     map[string]any{"type": []any{"array", "null"}, "items": map[string]any{"$ref": "#/components/schemas/models.Bike"}},
+		},
+	}, doc.Components.Schemas["models.Stations"])
+
+	assert.Equal(t, map[string]any{"type": "http", "scheme": "bearer"}, doc.Components.SecuritySchemes["BearerAuth"])
+}
+
+// TestReference checks that the reference copy of the document, published
+// with the API, matches the annotations.
+func TestReference(t *testing.T) {
+	doc, err := Load()
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	require.NoError(t, enc.Encode(doc))
+	want := buf.Bytes()
+
+	if *update {
+		require.NoError(t, os.WriteFile(referencePath, want, 0o644))
+	}
+
+	got, err := os.ReadFile(referencePath)
+	require.NoError(t, err)
+	assert.Equal(t, string(want), string(got), "%s is out of date; run go test ./api/openapi -update", referencePath)
+}
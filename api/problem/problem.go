@@ -0,0 +1,105 @@
+// Package problem writes errors as RFC 7807 problem details:
+//
+//	HTTP/1.1 404 Not Found
+//	Content-Type: application/problem+json
+//
+//	{
+//	  "type": "about:blank",
+//	  "title": "Not Found",
+//	  "status": 404,
+//	  "detail": "station 3005 not found",
+//	  "instance": "/api/v1/stations/3005",
+//	  "code": "station_not_found"
+//	}
+//
+// The code member is the apperr code, for programs; detail is for people.
+// Bad requests list their invalid fields in invalidParams, each with its
+// name, where it was given (path, query, header or body) and the reason.
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/macadrich/go-bike/pkg/apperr"
+)
+
+const ContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details object with a code extension.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Code     string `json:"code"`
+	// InvalidParams lists the invalid fields of a bad request.
+	InvalidParams []apperr.FieldError `json:"invalidParams,omitempty"`
+}
+
+// Status is the HTTP status of errors of kind.
+func Status(kind apperr.Kind) int {
+	switch kind {
+	case apperr.KindInvalidArgument:
+		return http.StatusBadRequest
+	case apperr.KindUnauthorized:
+		return http.StatusUnauthorized
+	case apperr.KindNotFound:
+		return http.StatusNotFound
+	case apperr.KindConflict:
+		return http.StatusConflict
+	case apperr.KindRateLimited:
+		return http.StatusTooManyRequests
+	case apperr.KindUnavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// New describes err as a problem with the request r.
+func New(r *http.Request, err error) Problem {
+	e := apperr.As(err)
+	status := Status(e.Kind)
+
+	return Problem{
+		Type:          "about:blank",
+		Title:         http.StatusText(status),
+		Status:        status,
+		Detail:        e.Message,
+		Instance:      r.URL.Path,
+		Code:          e.Code,
+		InvalidParams: e.Fields,
+	}
+}
+
+// Write answers r with the problem describing err. Errors that are not an
+// *apperr.Error are internal and their message is not shown.
+func Write(w http.ResponseWriter, r *http.Request, err error) {
+	send(w, New(r, err))
+}
+
+func send(w http.ResponseWriter, p Problem) {
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// NotFound answers requests for routes that do not exist.
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	Write(w, r, apperr.New(apperr.KindNotFound, "route_not_found", "no route matches "+r.URL.Path))
+}
+
+// MethodNotAllowed answers requests with a method a route does not serve.
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	send(w, Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(http.StatusMethodNotAllowed),
+		Status:   http.StatusMethodNotAllowed,
+		Detail:   r.Method + " is not allowed on " + r.URL.Path,
+		Instance: r.URL.Path,
+		Code:     "method_not_allowed",
+	})
+}
@@ -0,0 +1,61 @@
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/macadrich/go-bike/pkg/apperr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrite(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+		detail string
+	}{
+		{"invalid argument", apperr.InvalidArgument("kioskId must be a positive number"), http.StatusBadRequest, "invalid_argument", "kioskId must be a positive number"},
+		{"not found", apperr.NotFound("station", "station 3005 not found"), http.StatusNotFound, "station_not_found", "station 3005 not found"},
+		{"wrapped", fmt.Errorf("replay: %w", apperr.New(apperr.KindConflict, "delivery_not_failed", "only failed deliveries can be replayed")), http.StatusConflict, "delivery_not_failed", "only failed deliveries can be replayed"},
+		{"upstream", apperr.Upstream("bike feed unavailable", errors.New("timeout")), http.StatusServiceUnavailable, "upstream_unavailable", "bike feed unavailable"},
+		{"internal", errors.New("pq: connection refused"), http.StatusInternalServerError, "internal", "internal error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			Write(rr, httptest.NewRequest("GET", "/api/v1/stations/3005", nil), tt.err)
+
+			assert.Equal(t, tt.status, rr.Code)
+			assert.Equal(t, ContentType, rr.Header().Get("Content-Type"))
+
+			var p Problem
+			require.NoError(t, json.NewDecoder(rr.Body).Decode(&p))
+			assert.Equal(t, Problem{
+				Type:     "about:blank",
+				Title:    http.StatusText(tt.status),
+				Status:   tt.status,
+				Detail:   tt.detail,
+				Instance: "/api/v1/stations/3005",
+				Code:     tt.code,
+			}, p)
+		})
+	}
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	rr := httptest.NewRecorder()
+	MethodNotAllowed(rr, httptest.NewRequest("DELETE", "/api/v1/stations", nil))
+
+	assert.Equal(t, http.StatusMethodNotAllowed, rr.Code)
+	var p Problem
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&p))
+	assert.Equal(t, "method_not_allowed", p.Code)
+}
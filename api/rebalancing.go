@@ -0,0 +1,128 @@
+package api
+
+import (
+	"context"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/macadrich/go-bike/database/models"
+	"github.com/macadrich/go-bike/pkg/rebalance"
+	"github.com/macadrich/go-bike/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Target fill ratios are fixed or taken from the history of each station.
+const (
+	TargetsFixed      = "fixed"
+	TargetsHistorical = "historical"
+)
+
+// historyWeeks is how many past weeks, at the same hour of the week, the
+// historical targets are averaged over.
+const historyWeeks = 8
+
+// Historical targets are kept within these bounds so that no station is
+// planned to be left empty or full.
+const (
+	minHistoricalTarget = 0.2
+	maxHistoricalTarget = 0.8
+)
+
+// RebalancingOptions are the inputs of a rebalancing plan. TargetFill is
+// the fill ratio of every station with fixed targets, and of stations
+// without history with historical ones. KioskIds restricts the plan to
+// some stations.
+type RebalancingOptions struct {
+	Vans        int
+	VanCapacity int
+	MaxStops    int
+	TargetFill  float64
+	Tolerance   float64
+	Targets     string
+	Depot       *models.Coordinate
+	KioskIds    []int
+}
+
+// RebalancingPlan is a plan for the stations as of At.
+type RebalancingPlan struct {
+	At      time.Time `json:"at"`
+	Targets string    `json:"targets"`
+	rebalance.Plan
+}
+
+// historicalBuckets returns the hourly buckets at the same hour of the
+// week as at over the previous weeks, in loc so that DST is respected.
+func historicalBuckets(at time.Time, loc *time.Location) []time.Time {
+	local := at.In(loc)
+	buckets := make([]time.Time, historyWeeks)
+	for i := range buckets {
+		buckets[i] = local.AddDate(0, 0, -7*(i+1))
+	}
+	return buckets
+}
+
+// RebalancingPlan plans van routes for the stations in the latest
+// snapshot. Only active stations with docks are considered.
+func (s *service) RebalancingPlan(ctx context.Context, opts RebalancingOptions) (_ *RebalancingPlan, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "service.RebalancingPlan")
+	defer func() { tracing.End(span, err) }()
+
+	at, err := s.db.LatestSnapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := s.db.QueryAllStation(ctx, at)
+	if err != nil {
+		return nil, err
+	}
+
+	var ratios map[int]float64
+	if opts.Targets == TargetsHistorical {
+		ratios, err = s.db.FillRatios(ctx, historicalBuckets(at, s.cfg().ThirdpartyAPI.Location()))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var stations []rebalance.Station
+	for _, c := range current {
+		if c.KioskPublicStatus != "Active" || (len(opts.KioskIds) > 0 && !slices.Contains(opts.KioskIds, c.KioskId)) {
+			continue
+		}
+
+		target := opts.TargetFill
+		if ratio, ok := ratios[c.KioskId]; ok {
+			target = math.Min(math.Max(ratio, minHistoricalTarget), maxHistoricalTarget)
+		}
+
+		stations = append(stations, rebalance.Station{
+			KioskId:  c.KioskId,
+			Name:     c.Name,
+			Location: models.Coordinate{Long: c.Longitude, Lat: c.Latitude},
+			Bikes:    c.BikesAvailable,
+			// Unavailable docks can take no bikes.
+			Capacity:  c.BikesAvailable + c.DocksAvailable,
+			Target:    target,
+			Tolerance: opts.Tolerance,
+		})
+	}
+
+	plan := rebalance.Solve(stations, rebalance.Options{
+		Vans:     opts.Vans,
+		Capacity: opts.VanCapacity,
+		MaxStops: opts.MaxStops,
+		Depot:    opts.Depot,
+	})
+	span.SetAttributes(
+		attribute.Int("rebalancing.stations", len(stations)),
+		attribute.Int("rebalancing.moved", plan.Moved),
+	)
+
+	return &RebalancingPlan{
+		At:      at,
+		Targets: opts.Targets,
+		Plan:    plan,
+	}, nil
+}
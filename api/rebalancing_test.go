@@ -0,0 +1,85 @@
+package api
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/macadrich/go-bike/config"
+	"github.com/macadrich/go-bike/database"
+	"github.com/macadrich/go-bike/database/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rebalancingDB serves a fixed snapshot and fill history.
+type rebalancingDB struct {
+	database.Database
+	at       time.Time
+	stations []models.Stations
+	ratios   map[int]float64
+	buckets  []time.Time
+}
+
+func (db *rebalancingDB) LatestSnapshot(ctx context.Context) (time.Time, error) {
+	return db.at, nil
+}
+
+func (db *rebalancingDB) QueryAllStation(ctx context.Context, lastUpdate time.Time) ([]models.Stations, error) {
+	return db.stations, nil
+}
+
+func (db *rebalancingDB) FillRatios(ctx context.Context, buckets []time.Time) (map[int]float64, error) {
+	db.buckets = buckets
+	return db.ratios, nil
+}
+
+func TestHistoricalBuckets(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	// The first Monday after DST started on March 10.
+	at := time.Date(2024, 3, 11, 12, 0, 0, 0, time.UTC)
+	buckets := historicalBuckets(at, loc)
+	require.Len(t, buckets, historyWeeks)
+	assert.Equal(t, 8, buckets[0].Hour(), "same local hour")
+	assert.Equal(t, time.Date(2024, 3, 4, 13, 0, 0, 0, time.UTC), buckets[0].UTC(), "before DST")
+	assert.Equal(t, time.Monday, buckets[historyWeeks-1].Weekday())
+}
+
+func TestRebalancingPlan(t *testing.T) {
+	at := time.Date(2024, 5, 14, 12, 0, 0, 0, time.UTC)
+	db := &rebalancingDB{
+		at: at,
+		stations: []models.Stations{
+			{KioskId: 3005, KioskPublicStatus: "Active", BikesAvailable: 10, DocksAvailable: 0, Longitude: -75.16, Latitude: 39.95},
+			{KioskId: 3006, KioskPublicStatus: "Active", BikesAvailable: 0, DocksAvailable: 10, Longitude: -75.15, Latitude: 39.95},
+			{KioskId: 3007, KioskPublicStatus: "Unavailable", BikesAvailable: 0, DocksAvailable: 10},
+		},
+		// 3006 usually fills up at this hour; it is capped at 0.8.
+		ratios: map[int]float64{3006: 0.95},
+	}
+	cfg := &config.Config{}
+	cfg.ThirdpartyAPI.TimeZone = "America/New_York"
+	s := &service{db: db, live: config.NewLive("", cfg)}
+
+	opts := RebalancingOptions{Vans: 1, VanCapacity: 20, MaxStops: 10, TargetFill: 0.5, Targets: TargetsFixed}
+	plan, err := s.RebalancingPlan(context.TODO(), opts)
+	require.NoError(t, err)
+	assert.Equal(t, at, plan.At)
+	assert.Equal(t, 5, plan.Moved)
+	assert.Nil(t, db.buckets)
+
+	opts.Targets = TargetsHistorical
+	plan, err = s.RebalancingPlan(context.TODO(), opts)
+	require.NoError(t, err)
+	assert.Len(t, db.buckets, historyWeeks)
+	// 3006 wants 8 bikes but 3005 can only spare 5.
+	assert.Equal(t, 5, plan.Moved)
+	assert.Equal(t, 3, plan.Deficit)
+
+	opts.KioskIds = []int{3005}
+	plan, err = s.RebalancingPlan(context.TODO(), opts)
+	require.NoError(t, err)
+	assert.Empty(t, plan.Routes)
+}
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/macadrich/go-bike/config"
+	"github.com/macadrich/go-bike/database/models"
+	"github.com/macadrich/go-bike/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// MaintainPartitions creates the snapshot partitions needed for the coming
+// months and applies the retention policy to the old ones.
+func (s *service) MaintainPartitions(ctx context.Context) (err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "service.MaintainPartitions")
+	defer func() { tracing.End(span, err) }()
+
+	retention := s.cfg().Retention
+	now := time.Now()
+
+	if err := s.db.EnsurePartitions(ctx, now, now.AddDate(0, retention.PremakeMonths, 0)); err != nil {
+		return err
+	}
+
+	if !retention.Enabled {
+		return nil
+	}
+
+	partitions, err := s.db.Partitions(ctx)
+	if err != nil {
+		return err
+	}
+
+	expired := expiredPartitions(partitions, now.AddDate(0, 0, -retention.Days))
+	span.SetAttributes(attribute.Int("partitions.expired", len(expired)))
+
+	for _, partition := range expired {
+		if retention.Mode == config.RetentionArchive {
+			if err := s.archivePartition(ctx, partition, retention.ArchiveDir); err != nil {
+				return err
+			}
+		}
+		if err := s.db.DropPartition(ctx, partition); err != nil {
+			return err
+		}
+		log.Printf("retention: removed partition %s (%s)", partition.Name, retention.Mode)
+	}
+
+	return nil
+}
+
+// expiredPartitions returns the partitions whose rows are all older than
+// cutoff.
+func expiredPartitions(partitions []models.Partition, cutoff time.Time) []models.Partition {
+	var expired []models.Partition
+	for _, partition := range partitions {
+		if !partition.To.After(cutoff) {
+			expired = append(expired, partition)
+		}
+	}
+	return expired
+}
+
+// archivePartition exports partition to dir/<name>.csv.gz. The file is
+// written under a temporary name and renamed once complete, so a partition
+// is never dropped with only part of it archived.
+func (s *service) archivePartition(ctx context.Context, partition models.Partition, dir string) (err error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("error creating archive directory: %w", err)
+	}
+
+	path := filepath.Join(dir, partition.Name+".csv.gz")
+	tmp, err := os.CreateTemp(dir, partition.Name+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("error creating archive: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	gz := gzip.NewWriter(tmp)
+	if err := s.db.ArchivePartition(ctx, partition, gz); err != nil {
+		return fmt.Errorf("error archiving partition %s: %w", partition.Name, err)
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
@@ -1,30 +1,96 @@
 package routers
 
 import (
+	"net/http"
+
 	"github.com/go-chi/chi/v5"
 	"github.com/macadrich/go-bike/api/handlers"
 	"github.com/macadrich/go-bike/api/middleware"
+	"github.com/macadrich/go-bike/api/openapi"
+	"github.com/macadrich/go-bike/api/problem"
 	"github.com/macadrich/go-bike/config"
 	httpSwagger "github.com/swaggo/http-swagger"
 )
 
-func NewRouter(handlers *handlers.Handlers) *chi.Mux {
+func NewRouter(handlers *handlers.Handlers, live *config.Live) *chi.Mux {
 	r := chi.NewRouter()
+	r.Use(middleware.Tracing)
+	r.NotFound(problem.NotFound)
+	r.MethodNotAllowed(problem.MethodNotAllowed)
 
 	r.Get("/swagger/*", httpSwagger.Handler(
 		httpSwagger.URL("http://localhost:8080/swagger/doc.json"), //The url pointing to API definition"
 	))
 
+	cfg := live.Current().Config
+	token := cfg.Authorization.Token
+	auth := middleware.TokenAuthorization(token, handlers.VerifyAPIKey)
+	streamAuth := middleware.StreamTokenAuthorization(token, handlers.VerifyAPIKey)
+	socketAuth := middleware.SocketTokenAuthorization(token, handlers.VerifyAPIKey)
+
 	r.Group(func(r chi.Router) {
-		r.Use(middleware.StaticTokenAuthorization(config.LoadAuthorization()))
+		r.Use(middleware.RateLimit(live))
 		r.Route("/api/v1", func(r chi.Router) {
-			r.Post("/indego-data-fetch-and-store-it-db", handlers.InsertStation)
-			r.Get("/stations", handlers.QueryAllStation)
-			r.Get("/stations/{kioskId}", handlers.QuerySpecificStation)
+			// v1 is served alongside its successor v2 until its sunset.
+			if deprecated, sunset, ok := cfg.API.V1Deprecation(); ok {
+				r.Use(middleware.Deprecated(deprecated, sunset, "/api/v2"))
+			}
+			routeStreams(r, handlers, streamAuth, socketAuth)
+			r.Group(func(r chi.Router) {
+				r.Use(auth)
+				r.Post("/indego-data-fetch-and-store-it-db", handlers.InsertStation)
+				r.Get("/stations", handlers.QueryAllStation)
+				r.Get("/stations/{kioskId}", handlers.QuerySpecificStation)
+				routeShared(r, handlers)
+			})
+		})
+		r.Route("/api/v2", func(r chi.Router) {
+			routeStreams(r, handlers, streamAuth, socketAuth)
+			r.Group(func(r chi.Router) {
+				r.Use(auth)
+				r.Post("/ingestions", handlers.CreateIngestion)
+				r.Get("/ingestions", handlers.ListIngestions)
+				r.Get("/snapshots/latest", handlers.GetLatestSnapshot)
+				r.Get("/snapshots/{at}", handlers.GetSnapshot)
+				r.Get("/stations", handlers.ListStations)
+				r.Get("/stations/{kioskId}", handlers.GetStation)
+				routeShared(r, handlers)
+			})
 		})
 	})
 
 	r.Get("/healthcheck", handlers.HealthCheck)
+	r.Get("/livez", handlers.Livez)
+	r.Get("/readyz", handlers.Readyz)
+	r.Get("/openapi.json", openapi.Handler)
 
 	return r
 }
+
+// routeStreams adds the stream routes, which v1 and v2 have in common.
+// Browsers cannot set headers on them, so they take the token from a query
+// parameter as well, and the event stream also from a cookie.
+func routeStreams(r chi.Router, handlers *handlers.Handlers, streamAuth, socketAuth func(http.Handler) http.Handler) {
+	r.With(streamAuth).Get("/stations/stream", handlers.StationsStream)
+	r.With(socketAuth).Get("/stations/ws", handlers.StationsWebSocket)
+}
+
+// routeShared adds the other routes that v1 and v2 have in common.
+func routeShared(r chi.Router, handlers *handlers.Handlers) {
+	r.Get("/stations/{kioskId}/history", handlers.StationHistory)
+	r.Get("/stations/{kioskId}/forecast", handlers.StationForecast)
+	r.Get("/events", handlers.QueryEvents)
+	r.Get("/trips/flows", handlers.TripFlows)
+	r.Get("/trips/od", handlers.TripOD)
+	r.Get("/ebikes/battery", handlers.EBikeBattery)
+	r.Get("/ebikes/low", handlers.LowBatteryBikes)
+	r.Get("/ebikes/stations/{kioskId}/docks/{dockNumber}/history", handlers.DockBatteryHistory)
+	r.Post("/rebalancing/plan", handlers.RebalancingPlan)
+	r.Get("/export", handlers.Export)
+	r.Post("/webhooks", handlers.CreateWebhook)
+	r.Get("/webhooks", handlers.ListWebhooks)
+	r.Delete("/webhooks/{id}", handlers.DeleteWebhook)
+	r.Get("/webhooks/{id}/deliveries", handlers.ListDeliveries)
+	r.Post("/webhooks/deliveries/{deliveryId}/replay", handlers.ReplayDelivery)
+	r.Get("/admin/config", handlers.ActiveConfig)
+}
@@ -2,53 +2,256 @@ package api
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
 
 	"github.com/macadrich/go-bike/client"
 	"github.com/macadrich/go-bike/config"
 	"github.com/macadrich/go-bike/database"
+	"github.com/macadrich/go-bike/database/migrate"
 	"github.com/macadrich/go-bike/database/models"
+	"github.com/macadrich/go-bike/migrations"
+	"github.com/macadrich/go-bike/pkg/apperr"
+	"github.com/macadrich/go-bike/pkg/events"
+	"github.com/macadrich/go-bike/pkg/health"
+	"github.com/macadrich/go-bike/pkg/stream"
+	"github.com/macadrich/go-bike/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type IService interface {
 	InsertStation(ctx context.Context) error
-	QueryAllStation(ctx context.Context, lastUpdate string) (*models.StationsResponse, error)
-	QuerySpecificStation(kioskId int, lastUpdate string) (*models.Stations, error)
+	Ingest(ctx context.Context) (*models.Ingestion, error)
+	Ingestions(ctx context.Context, before time.Time, limit int) ([]models.Ingestion, error)
+	Snapshot(ctx context.Context, at time.Time, weather bool) (*models.StationsResponse, error)
+	Import(ctx context.Context, opts ImportOptions) (*ImportProgress, error)
+	QueryAllStation(ctx context.Context, lastUpdate time.Time) (*models.StationsResponse, error)
+	QuerySpecificStation(ctx context.Context, kioskId int, lastUpdate time.Time) (*models.Stations, error)
+	QueryEvents(ctx context.Context, since time.Time, kioskId int, afterId int64, limit int) ([]models.StationEvent, error)
+	CreateWebhook(ctx context.Context, sub *models.WebhookSubscription) (*models.WebhookSubscription, error)
+	ListWebhooks(ctx context.Context) ([]models.WebhookSubscription, error)
+	DeleteWebhook(ctx context.Context, id int64) error
+	ListDeliveries(ctx context.Context, subscriptionId int64, status string, beforeId int64, limit int) ([]models.WebhookDelivery, error)
+	ReplayDelivery(ctx context.Context, id int64) (*models.WebhookDelivery, error)
+	StationHistory(ctx context.Context, kioskId int, from, to time.Time, resolution string) (*models.StationHistory, error)
+	TripFlows(ctx context.Context, from, to time.Time, interval time.Duration, kioskId int) (*models.TripFlows, error)
+	TripOD(ctx context.Context, from, to time.Time, limit int) (*models.ODMatrix, error)
+	EBikeBattery(ctx context.Context, kioskId int) (*models.EBikeBattery, error)
+	LowBatteryBikes(ctx context.Context, threshold int) (*models.LowBatteryReport, error)
+	DockBatteryHistory(ctx context.Context, kioskId, dockNumber int, from, to time.Time) (*models.DockBatteryHistory, error)
+	RebalancingPlan(ctx context.Context, opts RebalancingOptions) (*RebalancingPlan, error)
+	Forecast(ctx context.Context, kioskId int, horizon time.Duration, weather bool) (*models.StationForecast, error)
+	Export(ctx context.Context, req ExportRequest, w io.Writer) error
+	CreateAPIKey(ctx context.Context, name string) (*models.APIKey, string, error)
+	VerifyAPIKey(ctx context.Context, key string) (bool, error)
+	CheckReadiness(ctx context.Context) *health.Report
+	MaintainPartitions(ctx context.Context) error
+	Stream() *stream.Broker
+	Shutdown(ctx context.Context) error
+	ActiveConfig() *config.Snapshot
 }
 
+// ErrShuttingDown is returned for ingestions requested after Shutdown.
+var ErrShuttingDown = apperr.New(apperr.KindUnavailable, "shutting_down", "service is shutting down")
+
 type service struct {
-	db     database.Database
-	client client.IClient
-	cfg    *config.DBConfig
+	db        database.Database
+	client    client.IClient
+	live      *config.Live
+	readiness *health.Checker
+	broker    *stream.Broker
+
+	webhookClient *http.Client
+
+	mu         sync.Mutex
+	closing    bool
+	ingestions sync.WaitGroup
+	deliveries sync.WaitGroup
+	// stopping is cancelled by Shutdown to abandon webhook retries.
+	stopping       context.Context
+	cancelStopping context.CancelFunc
+}
+
+func NewService(db database.Database, client client.IClient, live *config.Live) IService {
+	s := &service{
+		db:            db,
+		client:        client,
+		live:          live,
+		broker:        stream.NewBroker(streamHistory),
+		webhookClient: &http.Client{},
+	}
+	s.stopping, s.cancelStopping = context.WithCancel(context.Background())
+
+	s.readiness = health.NewChecker(s.cfg().Health.CheckTimeout)
+	s.readiness.Register("database", s.db.Ping)
+	s.readiness.Register("migrations", s.checkMigrations)
+	s.readiness.Register("snapshot", s.checkSnapshotAge)
+	s.readiness.Register("upstream", s.checkUpstream)
+
+	return s
+}
+
+// cfg returns the active configuration snapshot.
+func (s *service) cfg() *config.Config {
+	return s.live.Current().Config
 }
 
-func NewService(db database.Database, client client.IClient) IService {
-	return &service{db, client, config.LoadDBConfig()}
+func (s *service) ActiveConfig() *config.Snapshot {
+	return s.live.Current()
 }
 
 func (s *service) InsertStation(ctx context.Context) error {
-	resp, err := s.client.GetData(ctx, s.cfg.ThirdpartyAPI.BikeURL)
-	if err != nil || resp.HasInValidData() {
-		return err
+	_, err := s.Ingest(ctx)
+	return err
+}
+
+// Ingest fetches the live feed and stores its snapshot. It returns the
+// recorded ingestion, or nil when the feed held no valid data or its
+// snapshot was stored before.
+func (s *service) Ingest(ctx context.Context) (_ *models.Ingestion, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "service.Ingest")
+	defer func() { tracing.End(span, err) }()
+
+	if !s.beginIngestion() {
+		return nil, ErrShuttingDown
 	}
+	defer s.ingestions.Done()
 
-	for _, v := range resp.Stations() {
-		if err := s.db.InsertStation(resp.LastUpdated(), &v.Properties); err != nil {
-			return err
-		}
+	resp, err := s.client.GetData(ctx, s.cfg().ThirdpartyAPI.BikeURL)
+	if err != nil {
+		return nil, apperr.Upstream("bike feed unavailable", err)
+	}
+	if resp.HasInValidData() {
+		return nil, nil
 	}
 
-	return nil
+	return s.ingest(ctx, resp, models.SourceLive)
 }
 
-func (s *service) QueryAllStation(ctx context.Context, lastUpdate string) (*models.StationsResponse, error) {
-	listOfStations, err := s.db.QueryAllStation(lastUpdate)
+// Ingestions lists the stored snapshots before the given time, newest
+// first.
+func (s *service) Ingestions(ctx context.Context, before time.Time, limit int) (_ []models.Ingestion, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "service.Ingestions")
+	defer func() { tracing.End(span, err) }()
+
+	return s.db.ListIngestions(ctx, before, limit)
+}
+
+// ingest stores a feed snapshot unless one with the same last_updated was
+// stored before, and returns its ingestion if it did. Live snapshots also
+// produce events, stream updates and webhook deliveries, and record the
+// weather; imported ones only fill in history.
+func (s *service) ingest(ctx context.Context, resp *client.ClientResponse, source string) (_ *models.Ingestion, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "service.ingest")
+	defer func() { tracing.End(span, err) }()
+
+	lastUpdated, err := resp.LastUpdatedTime()
 	if err != nil {
 		return nil, err
 	}
+	span.SetAttributes(attribute.String("ingestion.source", source))
 
-	weatherURL := fmt.Sprintf("%s?q=%s&appid=%s&units=imperial", s.cfg.ThirdpartyAPI.WeatherURL, s.cfg.ThirdpartyAPI.City, s.cfg.ThirdpartyAPI.APIKey)
-	result, err := s.client.GetData(ctx, weatherURL)
+	loc := s.cfg().ThirdpartyAPI.Location()
+	stations := resp.Stations(loc)
+	span.SetAttributes(attribute.Int("stations.count", len(stations)))
+	// A feed without stations is broken, not empty: recording it would
+	// report every kiosk as gone offline.
+	if len(stations) == 0 {
+		return nil, apperr.Upstream("bike feed unavailable", errors.New("no stations in feed response"))
+	}
+
+	exists, err := s.db.IngestionExists(ctx, lastUpdated)
+	if err != nil || exists {
+		return nil, err
+	}
+
+	live := source == models.SourceLive
+
+	// previous holds the last snapshot of every kiosk, the latest of which
+	// was taken at last.
+	var previous map[int]models.Stations
+	var last time.Time
+	if live {
+		if previous, err = s.db.PreviousStatuses(ctx, lastUpdated); err != nil {
+			return nil, err
+		}
+		for _, prev := range previous {
+			if prev.At.After(last) {
+				last = prev.At
+			}
+		}
+	}
+
+	var detected []models.StationEvent
+	updates := make([]models.StationUpdate, 0, len(stations))
+	present := make(map[int]bool, len(stations))
+	for _, v := range stations {
+		present[v.Properties.KioskId] = true
+		// A station already stored by an interrupted ingestion of the
+		// same snapshot is not counted twice.
+		inserted, err := s.db.InsertStation(ctx, lastUpdated, loc, &v.Properties)
+		if err != nil {
+			return nil, err
+		}
+		if !inserted {
+			continue
+		}
+		if prev, ok := previous[v.Properties.KioskId]; ok {
+			detected = append(detected, events.Detect(events.Previous(prev, last), &v.Properties, lastUpdated)...)
+		}
+		updates = append(updates, stationUpdate(lastUpdated, &v.Properties))
+	}
+	detected = append(detected, events.Missing(previous, present, last, lastUpdated)...)
+
+	ingestion := &models.Ingestion{
+		At:         lastUpdated,
+		Source:     source,
+		Stations:   len(stations),
+		IngestedAt: time.Now(),
+	}
+	if err := s.db.RecordIngestion(ctx, ingestion); err != nil {
+		return nil, err
+	}
+	if !live {
+		return ingestion, nil
+	}
+
+	span.SetAttributes(attribute.Int("stream.published", s.broker.Publish(updates)))
+
+	if s.cfg().Features.Weather {
+		// Weather only adds context; the snapshot is stored regardless.
+		if err := s.recordWeather(ctx); err != nil {
+			span.RecordError(err)
+			log.Println("error recording weather:", err)
+		}
+	}
+
+	inserted, err := s.db.InsertEvents(ctx, detected)
+	if err != nil {
+		return ingestion, err
+	}
+	span.SetAttributes(attribute.Int("events.count", len(inserted)))
+
+	return ingestion, s.dispatchEvents(ctx, inserted)
+}
+
+func (s *service) QueryEvents(ctx context.Context, since time.Time, kioskId int, afterId int64, limit int) (_ []models.StationEvent, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "service.QueryEvents")
+	defer func() { tracing.End(span, err) }()
+
+	return s.db.QueryEvents(ctx, since, kioskId, afterId, limit)
+}
+
+func (s *service) QueryAllStation(ctx context.Context, lastUpdate time.Time) (_ *models.StationsResponse, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "service.QueryAllStation")
+	defer func() { tracing.End(span, err) }()
+
+	listOfStations, err := s.db.QueryAllStation(ctx, lastUpdate)
 	if err != nil {
 		return nil, err
 	}
@@ -56,17 +259,210 @@ func (s *service) QueryAllStation(ctx context.Context, lastUpdate string) (*mode
 	response := models.StationsResponse{
 		At:       lastUpdate,
 		Stations: listOfStations,
-		Weather:  *result.WeatherUpdate(),
 	}
 
+	if !s.cfg().Features.Weather {
+		return &response, nil
+	}
+
+	weather, err := s.fetchWeather(ctx)
+	if err != nil {
+		return nil, err
+	}
+	response.Weather = *weather
+
 	return &response, nil
 }
 
-func (s *service) QuerySpecificStation(kioskId int, lastUpdate string) (*models.Stations, error) {
-	station, err := s.db.QuerySpecificStation(kioskId, lastUpdate)
+// Snapshot returns the stations of the first snapshot at or after at, or of
+// the latest one when at is zero. With weather, and the weather feature
+// enabled, it adds the stored observation nearest in time to the
+// snapshot, if there is one within maxWeatherAge. The weather feed is not
+// called: a failing feed must not fail the snapshot.
+func (s *service) Snapshot(ctx context.Context, at time.Time, weather bool) (_ *models.StationsResponse, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "service.Snapshot")
+	defer func() { tracing.End(span, err) }()
+
+	if at.IsZero() {
+		if at, err = s.db.LatestSnapshot(ctx); err != nil {
+			return nil, err
+		}
+		if at.IsZero() {
+			return nil, apperr.NotFound("snapshot", "no snapshot has been ingested")
+		}
+	} else {
+		next, err := s.db.NextIngestion(ctx, at)
+		if err != nil {
+			return nil, err
+		}
+		if next.IsZero() {
+			return nil, apperr.NotFound("snapshot", "no snapshot at or after "+at.Format(time.RFC3339))
+		}
+		at = next
+	}
+
+	stations, err := s.db.QuerySnapshot(ctx, at)
+	if err != nil {
+		return nil, err
+	}
+	if len(stations) == 0 {
+		return nil, apperr.NotFound("snapshot", "no stations in the snapshot at "+at.Format(time.RFC3339))
+	}
+
+	response := &models.StationsResponse{
+		At:       at,
+		Stations: stations,
+	}
+	if !weather || !s.cfg().Features.Weather {
+		return response, nil
+	}
+
+	obs, err := s.db.NearestWeather(ctx, at)
+	if err != nil {
+		return nil, err
+	}
+	if obs != nil && obs.At.Sub(at).Abs() <= maxWeatherAge {
+		response.Weather = weatherMap(obs)
+	}
+
+	return response, nil
+}
+
+// fetchWeather gets the current weather of the configured city.
+func (s *service) fetchWeather(ctx context.Context) (*models.WeatherMap, error) {
+	cfg := s.cfg()
+	weatherURL := fmt.Sprintf("%s?q=%s&appid=%s&units=imperial", cfg.ThirdpartyAPI.WeatherURL, cfg.Weather.City, cfg.ThirdpartyAPI.APIKey)
+	result, err := s.client.GetData(ctx, weatherURL)
+	if err != nil {
+		return nil, apperr.Upstream("weather feed unavailable", err)
+	}
+
+	weather := result.WeatherUpdate()
+	if weather == nil {
+		return nil, apperr.Upstream("weather feed unavailable", errors.New("invalid weather response"))
+	}
+	return weather, nil
+}
+
+func (s *service) QuerySpecificStation(ctx context.Context, kioskId int, lastUpdate time.Time) (_ *models.Stations, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "service.QuerySpecificStation")
+	defer func() { tracing.End(span, err) }()
+
+	span.SetAttributes(attribute.Int("kiosk.id", kioskId))
+
+	station, err := s.db.QuerySpecificStation(ctx, kioskId, lastUpdate)
 	if err != nil {
 		return nil, err
 	}
 
 	return station, nil
 }
+
+func (s *service) CheckReadiness(ctx context.Context) *health.Report {
+	ctx, span := tracing.Tracer().Start(ctx, "service.CheckReadiness")
+	defer span.End()
+
+	report := s.readiness.Run(ctx)
+	span.SetAttributes(attribute.String("health.status", report.Status))
+
+	return report
+}
+
+func (s *service) checkMigrations(ctx context.Context) error {
+	latest, err := migrate.Latest(migrations.FS)
+	if err != nil {
+		return err
+	}
+
+	version, dirty, err := s.db.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	if dirty {
+		return fmt.Errorf("schema version %d is dirty", version)
+	}
+
+	if version < latest {
+		return fmt.Errorf("pending migrations: at version %d, latest is %d", version, latest)
+	}
+
+	return nil
+}
+
+func (s *service) checkSnapshotAge(ctx context.Context) error {
+	latest, err := s.db.LatestSnapshot(ctx)
+	if err != nil {
+		return err
+	}
+
+	if latest.IsZero() {
+		return errors.New("no snapshot has been ingested")
+	}
+
+	if age := time.Since(latest); age > s.cfg().Health.MaxSnapshotAge {
+		return fmt.Errorf("newest snapshot is %s old, threshold is %s", age.Round(time.Second), s.cfg().Health.MaxSnapshotAge)
+	}
+
+	return nil
+}
+
+func (s *service) checkUpstream(ctx context.Context) error {
+	if err := s.client.Ping(ctx, s.cfg().ThirdpartyAPI.BikeURL); err != nil {
+		return fmt.Errorf("bike feed: %w", err)
+	}
+
+	if err := s.client.Ping(ctx, s.cfg().ThirdpartyAPI.WeatherURL); err != nil {
+		return fmt.Errorf("weather: %w", err)
+	}
+
+	return nil
+}
+
+// Shutdown stops accepting new ingestions and waits for running ones to
+// finish, or for ctx to expire. Webhook deliveries still being retried are
+// then abandoned and left failed, to be replayed later.
+func (s *service) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closing = true
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.ingestions.Wait()
+		s.cancelStopping()
+		s.deliveries.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for ingestion: %w", ctx.Err())
+	}
+}
+
+func (s *service) beginDelivery() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closing {
+		return false
+	}
+	s.deliveries.Add(1)
+
+	return true
+}
+
+func (s *service) beginIngestion() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closing {
+		return false
+	}
+	s.ingestions.Add(1)
+
+	return true
+}
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/macadrich/go-bike/config"
+	"github.com/macadrich/go-bike/database"
+	"github.com/macadrich/go-bike/database/models"
+	"github.com/macadrich/go-bike/pkg/apperr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// snapshotsDB serves the stations and weather of stored snapshots, and
+// records the snapshots it was asked for.
+type snapshotsDB struct {
+	database.Database
+	latest   time.Time
+	stations []models.Stations
+	weather  []models.WeatherObservation
+	queried  []time.Time
+}
+
+func (db *snapshotsDB) LatestSnapshot(ctx context.Context) (time.Time, error) {
+	return db.latest, nil
+}
+
+func (db *snapshotsDB) NextIngestion(ctx context.Context, at time.Time) (time.Time, error) {
+	for _, s := range db.stations {
+		if !s.At.Before(at) {
+			return s.At, nil
+		}
+	}
+	return time.Time{}, nil
+}
+
+func (db *snapshotsDB) QuerySnapshot(ctx context.Context, at time.Time) ([]models.Stations, error) {
+	db.queried = append(db.queried, at)
+	var stations []models.Stations
+	for _, s := range db.stations {
+		if s.At.Equal(at) {
+			stations = append(stations, s)
+		}
+	}
+	return stations, nil
+}
+
+func (db *snapshotsDB) NearestWeather(ctx context.Context, at time.Time) (*models.WeatherObservation, error) {
+	var nearest *models.WeatherObservation
+	for i, obs := range db.weather {
+		if nearest == nil || obs.At.Sub(at).Abs() < nearest.At.Sub(at).Abs() {
+			nearest = &db.weather[i]
+		}
+	}
+	return nearest, nil
+}
+
+func TestSnapshot(t *testing.T) {
+	first := time.Date(2024, 5, 14, 6, 48, 0, 0, time.UTC)
+	second := first.Add(10 * time.Minute)
+	db := &snapshotsDB{latest: second, stations: []models.Stations{
+		{KioskId: 3005, At: first},
+		{KioskId: 3006, At: first},
+		{KioskId: 3005, At: second},
+		{KioskId: 3006, At: second},
+	}}
+	s := &service{db: db, live: config.NewLive("", &config.Config{})}
+
+	snapshot, err := s.Snapshot(context.TODO(), first.Add(-time.Minute), true)
+	require.NoError(t, err)
+	assert.Equal(t, first, snapshot.At)
+	assert.Len(t, snapshot.Stations, 2)
+	assert.Zero(t, snapshot.Weather.Dt)
+	assert.Equal(t, []time.Time{first}, db.queried, "only the resolved snapshot is loaded")
+
+	snapshot, err = s.Snapshot(context.TODO(), time.Time{}, false)
+	require.NoError(t, err)
+	assert.Equal(t, second, snapshot.At)
+	assert.Len(t, snapshot.Stations, 2)
+
+	_, err = s.Snapshot(context.TODO(), second.Add(time.Minute), false)
+	assert.Equal(t, "snapshot_not_found", apperr.As(err).Code)
+
+	db.latest = time.Time{}
+	_, err = s.Snapshot(context.TODO(), time.Time{}, false)
+	assert.Equal(t, "snapshot_not_found", apperr.As(err).Code)
+}
+
+func TestSnapshotStoredWeather(t *testing.T) {
+	at := time.Date(2024, 5, 14, 6, 48, 0, 0, time.UTC)
+	db := &snapshotsDB{latest: at.Add(24 * time.Hour), stations: []models.Stations{{KioskId: 3005, At: at}}}
+	cfg := &config.Config{}
+	cfg.Features.Weather = true
+	s := &service{db: db, live: config.NewLive("", cfg)}
+
+	// An earlier snapshot gets the observation nearest to it, not the
+	// current weather.
+	db.weather = []models.WeatherObservation{
+		{At: at.Add(-20 * time.Minute), Condition: "Clouds", Temperature: 61.5},
+		{At: at.Add(5 * time.Minute), Condition: "Rain", Description: "light rain", Temperature: 58.25, Humidity: 90},
+	}
+	snapshot, err := s.Snapshot(context.TODO(), at, true)
+	require.NoError(t, err)
+	assert.Equal(t, &db.weather[1], WeatherObservation(&snapshot.Weather))
+
+	// Observations too far away are left out.
+	db.weather = []models.WeatherObservation{{At: at.Add(-6 * time.Hour), Condition: "Clear"}}
+	snapshot, err = s.Snapshot(context.TODO(), at, true)
+	require.NoError(t, err)
+	assert.Zero(t, snapshot.Weather.Dt)
+
+	// So does the latest snapshot, without calling the weather feed.
+	db.latest = at
+	db.weather = []models.WeatherObservation{{At: at.Add(-10 * time.Minute), Condition: "Clear", Temperature: 64}}
+	snapshot, err = s.Snapshot(context.TODO(), time.Time{}, true)
+	require.NoError(t, err)
+	assert.Equal(t, &db.weather[0], WeatherObservation(&snapshot.Weather))
+}
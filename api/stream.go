@@ -0,0 +1,33 @@
+package api
+
+import (
+	"time"
+
+	"github.com/macadrich/go-bike/database/models"
+	"github.com/macadrich/go-bike/pkg/stream"
+)
+
+// streamHistory is how many station updates are kept for clients resuming
+// a stream; a full Indego snapshot is a little over 200 stations.
+const streamHistory = 10000
+
+// Stream returns the broker of live station updates.
+func (s *service) Stream() *stream.Broker {
+	return s.broker
+}
+
+func stationUpdate(at time.Time, station *models.Stations) models.StationUpdate {
+	return models.StationUpdate{
+		KioskId:                station.KioskId,
+		At:                     at,
+		Latitude:               station.Latitude,
+		Longitude:              station.Longitude,
+		BikesAvailable:         station.BikesAvailable,
+		DocksAvailable:         station.DocksAvailable,
+		ClassicBikesAvailable:  station.ClassicBikesAvailable,
+		SmartBikesAvailable:    station.SmartBikesAvailable,
+		ElectricBikesAvailable: station.ElectricBikesAvailable,
+		KioskPublicStatus:      station.KioskPublicStatus,
+		KioskConnectionStatus:  station.KioskConnectionStatus,
+	}
+}
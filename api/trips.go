@@ -0,0 +1,92 @@
+package api
+
+import (
+	"context"
+	"time"
+
+	"github.com/macadrich/go-bike/database/models"
+	"github.com/macadrich/go-bike/pkg/tracing"
+	"github.com/macadrich/go-bike/pkg/trips"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// maxSnapshotGap is the longest time between two snapshots that are still
+// diffed; movements across a longer ingestion outage cannot be placed in
+// an interval.
+const maxSnapshotGap = time.Hour
+
+// meanTripKm scales the distance decay of the origin-destination model.
+// Indego trips average about two kilometres.
+const meanTripKm = 2.0
+
+// flows infers the station flows in [from, to), from aligned to interval.
+func (s *service) flows(ctx context.Context, from, to time.Time, interval time.Duration, kioskId int) (time.Time, []models.StationFlow, error) {
+	from = from.UTC().Truncate(interval)
+
+	counter := trips.NewCounter(interval, maxSnapshotGap)
+	err := s.db.DockMoves(ctx, from, to, kioskId, func(move models.DockMove) error {
+		counter.Add(move)
+		return nil
+	})
+	if err != nil {
+		return from, nil, err
+	}
+
+	return from, counter.Flows(), nil
+}
+
+// TripFlows estimates the departures and arrivals per kiosk and interval,
+// optionally for a single kiosk. from is aligned down to the interval.
+func (s *service) TripFlows(ctx context.Context, from, to time.Time, interval time.Duration, kioskId int) (_ *models.TripFlows, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "service.TripFlows")
+	defer func() { tracing.End(span, err) }()
+
+	from, flows, err := s.flows(ctx, from, to, interval, kioskId)
+	if err != nil {
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int("trips.flows", len(flows)))
+
+	return &models.TripFlows{
+		From:     from,
+		To:       to,
+		Interval: interval.String(),
+		Flows:    flows,
+	}, nil
+}
+
+// TripOD estimates the trips between kiosks in [from, to) from their total
+// departures and arrivals, returning at most limit pairs, busiest first.
+func (s *service) TripOD(ctx context.Context, from, to time.Time, limit int) (_ *models.ODMatrix, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "service.TripOD")
+	defer func() { tracing.End(span, err) }()
+
+	// One bucket per hour keeps the outage rule the same as for TripFlows.
+	from, flows, err := s.flows(ctx, from, to, time.Hour, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	coords, err := s.db.StationCoordinates(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	matrix := &models.ODMatrix{From: from, To: to}
+	departures := make(map[int]int)
+	arrivals := make(map[int]int)
+	for _, flow := range flows {
+		departures[flow.KioskId] += flow.Departures
+		arrivals[flow.KioskId] += flow.Arrivals
+		matrix.Departures += flow.Departures
+		matrix.Arrivals += flow.Arrivals
+	}
+
+	matrix.Pairs = trips.EstimateOD(departures, arrivals, coords, meanTripKm)
+	if len(matrix.Pairs) > limit {
+		matrix.Pairs = matrix.Pairs[:limit]
+	}
+	span.SetAttributes(attribute.Int("trips.departures", matrix.Departures), attribute.Int("trips.pairs", len(matrix.Pairs)))
+
+	return matrix, nil
+}
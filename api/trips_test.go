@@ -0,0 +1,79 @@
+package api
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/macadrich/go-bike/database"
+	"github.com/macadrich/go-bike/database/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tripsDB serves fixed dock moves and coordinates.
+type tripsDB struct {
+	database.Database
+	moves []models.DockMove
+}
+
+func (db *tripsDB) DockMoves(ctx context.Context, from, to time.Time, kioskId int, fn func(models.DockMove) error) error {
+	for _, move := range db.moves {
+		if move.At.Before(from) || !move.At.Before(to) {
+			continue
+		}
+		if err := fn(move); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *tripsDB) StationCoordinates(ctx context.Context) (map[int]models.Coordinate, error) {
+	return map[int]models.Coordinate{
+		3005: {Long: -75.14403, Lat: 39.94733},
+		3006: {Long: -75.16374, Lat: 39.95220},
+	}, nil
+}
+
+func tripsMoves(from time.Time) []models.DockMove {
+	return []models.DockMove{
+		// An arrival in the hour before the range that must not be
+		// reported.
+		{KioskId: 3005, PrevAt: from.Add(-time.Hour), At: from.Add(-time.Minute), Arrivals: 1},
+		{KioskId: 3005, PrevAt: from.Add(-time.Minute), At: from.Add(10 * time.Minute), Departures: 2},
+		{KioskId: 3006, PrevAt: from.Add(-time.Minute), At: from.Add(20 * time.Minute), Arrivals: 2},
+	}
+}
+
+func TestTripFlows(t *testing.T) {
+	from := time.Date(2024, 5, 14, 6, 0, 0, 0, time.UTC)
+	s := &service{db: &tripsDB{moves: tripsMoves(from)}}
+
+	result, err := s.TripFlows(context.TODO(), from.Add(15*time.Minute), from.Add(2*time.Hour), time.Hour, 0)
+	require.NoError(t, err)
+	assert.Equal(t, from, result.From, "aligned to the interval")
+	assert.Equal(t, "1h0m0s", result.Interval)
+	assert.Equal(t, []models.StationFlow{
+		{KioskId: 3005, Bucket: from, Departures: 2},
+		{KioskId: 3006, Bucket: from, Arrivals: 2},
+	}, result.Flows)
+}
+
+func TestTripOD(t *testing.T) {
+	from := time.Date(2024, 5, 14, 6, 0, 0, 0, time.UTC)
+	s := &service{db: &tripsDB{moves: tripsMoves(from)}}
+
+	matrix, err := s.TripOD(context.TODO(), from, from.Add(time.Hour), 10)
+	require.NoError(t, err)
+	assert.Equal(t, 2, matrix.Departures)
+	assert.Equal(t, 2, matrix.Arrivals)
+	require.Len(t, matrix.Pairs, 1)
+	assert.Equal(t, 3005, matrix.Pairs[0].Origin)
+	assert.Equal(t, 3006, matrix.Pairs[0].Destination)
+	assert.InDelta(t, 2, matrix.Pairs[0].Trips, 0.01)
+
+	matrix, err = s.TripOD(context.TODO(), from, from.Add(time.Hour), 0)
+	require.NoError(t, err)
+	assert.Empty(t, matrix.Pairs)
+}
@@ -0,0 +1,287 @@
+// Package validate reads the path and query parameters of a request.
+//
+// A Params collects every invalid parameter rather than stopping at the
+// first, so a client learns about all of them at once:
+//
+//	v := validate.New(r)
+//	kioskId := v.PathID("kioskId")
+//	at := v.Time("at")
+//	limit := v.Int("limit", 100, 1, 1000)
+//	if err := v.Err(); err != nil {
+//		problem.Write(w, r, err)
+//		return
+//	}
+//
+// Each reader returns a zero value, or its fallback, for a parameter that
+// is invalid; the values must not be used unless Err returns nil.
+package validate
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/macadrich/go-bike/pkg/apperr"
+	"github.com/macadrich/go-bike/pkg/stream"
+	"github.com/macadrich/go-bike/pkg/utils"
+)
+
+// Where a parameter is given.
+const (
+	InPath   = "path"
+	InQuery  = "query"
+	InHeader = "header"
+	InBody   = "body"
+)
+
+const (
+	reasonRequired = "is required"
+	reasonTime     = "must be an RFC 3339 timestamp, Unix seconds, now, or a duration from now such as -1h"
+	reasonID       = "must be a positive number"
+	reasonBBox     = "must be minLon,minLat,maxLon,maxLat with each minimum at most its maximum"
+	reasonBool     = "must be true or false"
+)
+
+// Params reads the parameters of one request.
+type Params struct {
+	r      *http.Request
+	query  url.Values
+	now    time.Time
+	fields []apperr.FieldError
+}
+
+func New(r *http.Request) *Params {
+	return &Params{r: r, query: r.URL.Query(), now: time.Now()}
+}
+
+// Now is the time relative timestamps are read against, fixed for the
+// request so that from=-1h and to=now are exactly an hour apart.
+func (p *Params) Now() time.Time {
+	return p.now
+}
+
+// Invalid records that a parameter is invalid for reason, e.g. for checks
+// spanning several parameters.
+func (p *Params) Invalid(name, in, reason string) {
+	p.fields = append(p.fields, apperr.FieldError{Name: name, In: in, Reason: reason})
+}
+
+// Valid reports whether no parameter, or none of the named ones, has been
+// found invalid so far.
+func (p *Params) Valid(names ...string) bool {
+	for _, f := range p.fields {
+		if len(names) == 0 || slices.Contains(names, f.Name) {
+			return false
+		}
+	}
+	return true
+}
+
+// Err returns an invalid-argument error listing the invalid parameters, or
+// nil when there are none.
+func (p *Params) Err() error {
+	if len(p.fields) == 0 {
+		return nil
+	}
+	return apperr.InvalidFields(p.fields...)
+}
+
+// PathID reads a positive integer id from the path.
+func (p *Params) PathID(name string) int64 {
+	id, err := strconv.ParseInt(chi.URLParam(p.r, name), 10, 64)
+	if err != nil || id <= 0 {
+		p.Invalid(name, InPath, reasonID)
+		return 0
+	}
+	return id
+}
+
+// PathInt reads an integer between min and max from the path.
+func (p *Params) PathInt(name string, min, max int) int {
+	n, err := strconv.Atoi(chi.URLParam(p.r, name))
+	if err != nil || n < min || n > max {
+		p.Invalid(name, InPath, rangeReason(min, max))
+		return 0
+	}
+	return n
+}
+
+// PathTime reads a timestamp from the path; see utils.ParseTime for its
+// formats.
+func (p *Params) PathTime(name string) time.Time {
+	t, err := utils.ParseTime(chi.URLParam(p.r, name), p.now)
+	if err != nil {
+		p.Invalid(name, InPath, reasonTime)
+		return time.Time{}
+	}
+	return t
+}
+
+// Time reads a required timestamp; see utils.ParseTime for its formats.
+func (p *Params) Time(name string) time.Time {
+	if !p.query.Has(name) {
+		p.Invalid(name, InQuery, reasonRequired)
+		return time.Time{}
+	}
+	return p.OptionalTime(name, time.Time{})
+}
+
+// OptionalTime reads a timestamp, or returns fallback when it is absent.
+func (p *Params) OptionalTime(name string, fallback time.Time) time.Time {
+	if !p.query.Has(name) {
+		return fallback
+	}
+	t, err := utils.ParseTime(p.query.Get(name), p.now)
+	if err != nil {
+		p.Invalid(name, InQuery, reasonTime)
+		return fallback
+	}
+	return t
+}
+
+// ID reads an optional positive integer id, zero when it is absent.
+func (p *Params) ID(name string) int64 {
+	if !p.query.Has(name) {
+		return 0
+	}
+	id, err := strconv.ParseInt(p.query.Get(name), 10, 64)
+	if err != nil || id <= 0 {
+		p.Invalid(name, InQuery, reasonID)
+		return 0
+	}
+	return id
+}
+
+// IDs reads positive integer ids given repeatedly or comma separated, as
+// in kioskId=3005,3006&kioskId=3007.
+func (p *Params) IDs(name string) []int64 {
+	var ids []int64
+	for _, value := range p.query[name] {
+		for _, s := range strings.Split(value, ",") {
+			id, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+			if err != nil || id <= 0 {
+				p.Invalid(name, InQuery, reasonID)
+				return nil
+			}
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// Int reads an integer between min and max, or returns fallback when it is
+// absent. Pass math.MaxInt as max for no upper bound.
+func (p *Params) Int(name string, fallback, min, max int) int {
+	if !p.query.Has(name) {
+		return fallback
+	}
+	n, err := strconv.Atoi(p.query.Get(name))
+	if err != nil || n < min || n > max {
+		p.Invalid(name, InQuery, rangeReason(min, max))
+		return fallback
+	}
+	return n
+}
+
+// Duration reads a duration such as 30m between min and max, or returns
+// fallback when it is absent.
+func (p *Params) Duration(name string, fallback, min, max time.Duration) time.Duration {
+	if !p.query.Has(name) {
+		return fallback
+	}
+	d, err := time.ParseDuration(p.query.Get(name))
+	if err != nil || d < min || d > max {
+		p.Invalid(name, InQuery, fmt.Sprintf("must be a duration between %s and %s", FormatDuration(min), FormatDuration(max)))
+		return fallback
+	}
+	return d
+}
+
+// Bool reads true or false, or returns fallback when it is absent.
+func (p *Params) Bool(name string, fallback bool) bool {
+	if !p.query.Has(name) {
+		return fallback
+	}
+	b, err := strconv.ParseBool(p.query.Get(name))
+	if err != nil {
+		p.Invalid(name, InQuery, reasonBool)
+		return fallback
+	}
+	return b
+}
+
+// Enum reads one of values, or returns fallback when it is absent.
+func (p *Params) Enum(name, fallback string, values ...string) string {
+	if !p.query.Has(name) {
+		return fallback
+	}
+	v := p.query.Get(name)
+	if !slices.Contains(values, v) {
+		p.Invalid(name, InQuery, "must be one of "+strings.Join(values, ", "))
+		return fallback
+	}
+	return v
+}
+
+// BBox reads an optional bounding box, nil when it is absent.
+func (p *Params) BBox(name string) *stream.BBox {
+	if !p.query.Has(name) {
+		return nil
+	}
+	bbox, err := stream.ParseBBox(p.query.Get(name))
+	if err != nil || bbox.MinLon < -180 || bbox.MaxLon > 180 || bbox.MinLat < -90 || bbox.MaxLat > 90 {
+		p.Invalid(name, InQuery, reasonBBox)
+		return nil
+	}
+	return &bbox
+}
+
+// TimeRange reads the required from and the optional to, default now, and
+// checks that to is after from by at most maxRange, if it is not zero.
+func (p *Params) TimeRange(maxRange time.Duration) (from, to time.Time) {
+	from = p.Time("from")
+	to = p.OptionalTime("to", p.now)
+
+	if p.Valid("from", "to") {
+		switch {
+		case !to.After(from):
+			p.Invalid("to", InQuery, "must be after from")
+		case maxRange > 0 && to.Sub(from) > maxRange:
+			p.Invalid("to", InQuery, "must be at most "+FormatDuration(maxRange)+" after from")
+		}
+	}
+	return from, to
+}
+
+func rangeReason(min, max int) string {
+	switch {
+	case min == 1 && max == math.MaxInt:
+		return reasonID
+	case max == math.MaxInt:
+		return fmt.Sprintf("must be a number of at least %d", min)
+	default:
+		return fmt.Sprintf("must be between %d and %d", min, max)
+	}
+}
+
+// FormatDuration writes d without its zero units: 24h rather than 24h0m0s,
+// and several whole days as days.
+func FormatDuration(d time.Duration) string {
+	if d > 24*time.Hour && d%(24*time.Hour) == 0 {
+		return strconv.Itoa(int(d/(24*time.Hour))) + " days"
+	}
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = s[:len(s)-2]
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = s[:len(s)-2]
+	}
+	return s
+}
@@ -0,0 +1,147 @@
+package validate
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/macadrich/go-bike/pkg/apperr"
+	"github.com/macadrich/go-bike/pkg/stream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// request builds a request for target with the given path parameters.
+func request(target string, path map[string]string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	rctx := chi.NewRouteContext()
+	for k, v := range path {
+		rctx.URLParams.Add(k, v)
+	}
+	return r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+}
+
+func TestTime(t *testing.T) {
+	v := New(request("/?rfc=2024-05-14T06:48:00Z&unix=1715669280&now=now&ago=-1h&later=%2B30m&unencoded=+30m&offset=2024-05-14T08:48:00+02:00", nil))
+	now := v.Now()
+	at := time.Date(2024, 5, 14, 6, 48, 0, 0, time.UTC)
+
+	assert.Equal(t, at, v.Time("rfc"))
+	assert.Equal(t, at, v.Time("unix"))
+	assert.Equal(t, now, v.Time("now"))
+	assert.Equal(t, now.Add(-time.Hour), v.Time("ago"))
+	assert.Equal(t, now.Add(30*time.Minute), v.Time("later"))
+	assert.Equal(t, now.Add(30*time.Minute), v.Time("unencoded"))
+	assert.True(t, at.Equal(v.Time("offset")))
+	assert.Equal(t, at, v.OptionalTime("missing", at))
+	assert.NoError(t, v.Err())
+
+	v = New(request("/?at=yesterday", nil))
+	v.Time("at")
+	v.Time("from")
+	assert.Equal(t, []apperr.FieldError{
+		{Name: "at", In: InQuery, Reason: reasonTime},
+		{Name: "from", In: InQuery, Reason: reasonRequired},
+	}, apperr.As(v.Err()).Fields)
+}
+
+func TestTimeRange(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		want   []apperr.FieldError
+	}{
+		{"ok", "/?from=-2h&to=-1h", nil},
+		{"default to", "/?from=-2h", nil},
+		{"missing from", "/?to=now", []apperr.FieldError{{Name: "from", In: InQuery, Reason: reasonRequired}}},
+		{"reversed", "/?from=-1h&to=-2h", []apperr.FieldError{{Name: "to", In: InQuery, Reason: "must be after from"}}},
+		{"too long", "/?from=-50h", []apperr.FieldError{{Name: "to", In: InQuery, Reason: "must be at most 24h after from"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := New(request(tt.target, nil))
+			v.TimeRange(24 * time.Hour)
+			if tt.want == nil {
+				assert.NoError(t, v.Err())
+				return
+			}
+			assert.Equal(t, tt.want, apperr.As(v.Err()).Fields)
+		})
+	}
+}
+
+func TestNumbers(t *testing.T) {
+	v := New(request("/?kioskId=3005&limit=50&ids=1,2&ids=3&horizon=1h&weather=true", map[string]string{"id": "7", "dock": "0"}))
+
+	assert.Equal(t, int64(7), v.PathID("id"))
+	assert.Equal(t, 0, v.PathInt("dock", 0, math.MaxInt))
+	assert.Equal(t, int64(3005), v.ID("kioskId"))
+	assert.Equal(t, int64(0), v.ID("afterId"))
+	assert.Equal(t, []int64{1, 2, 3}, v.IDs("ids"))
+	assert.Equal(t, 50, v.Int("limit", 100, 1, 1000))
+	assert.Equal(t, 100, v.Int("offset", 100, 0, math.MaxInt))
+	assert.Equal(t, time.Hour, v.Duration("horizon", time.Minute, 5*time.Minute, 24*time.Hour))
+	assert.True(t, v.Bool("weather", false))
+	assert.NoError(t, v.Err())
+
+	v = New(request("/?kioskId=0&limit=5000&ids=1,x&horizon=2m&weather=maybe&offset=-1", map[string]string{"id": "abc", "dock": "-1"}))
+	v.PathID("id")
+	v.PathInt("dock", 0, math.MaxInt)
+	v.ID("kioskId")
+	v.IDs("ids")
+	assert.Equal(t, 100, v.Int("limit", 100, 1, 1000))
+	v.Int("offset", 0, 0, math.MaxInt)
+	v.Duration("horizon", time.Minute, 5*time.Minute, 24*time.Hour)
+	v.Bool("weather", false)
+	assert.Equal(t, []apperr.FieldError{
+		{Name: "id", In: InPath, Reason: "must be a positive number"},
+		{Name: "dock", In: InPath, Reason: "must be a number of at least 0"},
+		{Name: "kioskId", In: InQuery, Reason: "must be a positive number"},
+		{Name: "ids", In: InQuery, Reason: "must be a positive number"},
+		{Name: "limit", In: InQuery, Reason: "must be between 1 and 1000"},
+		{Name: "offset", In: InQuery, Reason: "must be a number of at least 0"},
+		{Name: "horizon", In: InQuery, Reason: "must be a duration between 5m and 24h"},
+		{Name: "weather", In: InQuery, Reason: "must be true or false"},
+	}, apperr.As(v.Err()).Fields)
+}
+
+func TestEnumAndBBox(t *testing.T) {
+	v := New(request("/?format=ndjson&bbox=-75.2,39.9,-75.1,40.0", nil))
+	assert.Equal(t, "ndjson", v.Enum("format", "csv", "csv", "ndjson"))
+	assert.Equal(t, "csv", v.Enum("dataset", "csv", "csv", "ndjson"))
+	assert.Equal(t, &stream.BBox{MinLon: -75.2, MinLat: 39.9, MaxLon: -75.1, MaxLat: 40.0}, v.BBox("bbox"))
+	assert.Nil(t, v.BBox("missing"))
+	assert.NoError(t, v.Err())
+
+	v = New(request("/?format=xml&bbox=1,2,3&area=-200,0,0,1", nil))
+	v.Enum("format", "csv", "csv", "ndjson")
+	v.BBox("bbox")
+	v.BBox("area")
+	err := v.Err()
+	require.Error(t, err)
+	assert.ErrorIs(t, err, apperr.ErrInvalidArgument)
+	assert.Equal(t, []apperr.FieldError{
+		{Name: "format", In: InQuery, Reason: "must be one of csv, ndjson"},
+		{Name: "bbox", In: InQuery, Reason: reasonBBox},
+		{Name: "area", In: InQuery, Reason: reasonBBox},
+	}, apperr.As(err).Fields)
+	assert.Equal(t, "format must be one of csv, ndjson; bbox "+reasonBBox+"; area "+reasonBBox, apperr.As(err).Message)
+}
+
+func TestFormatDuration(t *testing.T) {
+	for d, want := range map[time.Duration]string{
+		5 * time.Minute:     "5m",
+		24 * time.Hour:      "24h",
+		31 * 24 * time.Hour: "31 days",
+		90 * time.Minute:    "1h30m",
+		2 * time.Hour:       "2h",
+		30 * time.Second:    "30s",
+	} {
+		assert.Equal(t, want, FormatDuration(d))
+	}
+}
@@ -0,0 +1,238 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"slices"
+
+	"github.com/macadrich/go-bike/database/models"
+	"github.com/macadrich/go-bike/pkg/apperr"
+	"github.com/macadrich/go-bike/pkg/tracing"
+	"github.com/macadrich/go-bike/pkg/webhook"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ErrDeliveryNotFailed is returned when replaying a delivery that has not
+// failed.
+var ErrDeliveryNotFailed = apperr.New(apperr.KindConflict, "delivery_not_failed", "only failed deliveries can be replayed")
+
+// webhookPayload is the body of every webhook request.
+type webhookPayload struct {
+	Type  string              `json:"type"`
+	Event models.StationEvent `json:"event"`
+}
+
+// subscribed reports whether sub wants event.
+func subscribed(sub *models.WebhookSubscription, event *models.StationEvent) bool {
+	if len(sub.EventTypes) > 0 && !slices.Contains(sub.EventTypes, event.Type) {
+		return false
+	}
+	if len(sub.KioskIds) > 0 && !slices.Contains(sub.KioskIds, int64(event.KioskId)) {
+		return false
+	}
+	return true
+}
+
+// CreateWebhook stores a subscription, generating its secret when none is
+// given. The returned subscription is the only one to include the secret.
+func (s *service) CreateWebhook(ctx context.Context, sub *models.WebhookSubscription) (_ *models.WebhookSubscription, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "service.CreateWebhook")
+	defer func() { tracing.End(span, err) }()
+
+	if sub.Secret == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		sub.Secret = hex.EncodeToString(secret)
+	}
+	if sub.EventTypes == nil {
+		sub.EventTypes = []string{}
+	}
+	if sub.KioskIds == nil {
+		sub.KioskIds = []int64{}
+	}
+
+	if err := s.db.CreateWebhook(ctx, sub); err != nil {
+		return nil, err
+	}
+
+	return sub, nil
+}
+
+func (s *service) ListWebhooks(ctx context.Context) (_ []models.WebhookSubscription, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "service.ListWebhooks")
+	defer func() { tracing.End(span, err) }()
+
+	subs, err := s.db.ListWebhooks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range subs {
+		subs[i].Secret = ""
+	}
+
+	return subs, nil
+}
+
+func (s *service) DeleteWebhook(ctx context.Context, id int64) (err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "service.DeleteWebhook")
+	defer func() { tracing.End(span, err) }()
+
+	return s.db.DeleteWebhook(ctx, id)
+}
+
+// ListDeliveries returns a page of the delivery log of a subscription.
+func (s *service) ListDeliveries(ctx context.Context, subscriptionId int64, status string, beforeId int64, limit int) (_ []models.WebhookDelivery, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "service.ListDeliveries")
+	defer func() { tracing.End(span, err) }()
+
+	if _, err := s.db.GetWebhook(ctx, subscriptionId); err != nil {
+		return nil, err
+	}
+
+	return s.db.ListDeliveries(ctx, subscriptionId, status, beforeId, limit)
+}
+
+// ReplayDelivery sends a failed delivery again, in the background, with the
+// usual retries.
+func (s *service) ReplayDelivery(ctx context.Context, id int64) (_ *models.WebhookDelivery, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "service.ReplayDelivery")
+	defer func() { tracing.End(span, err) }()
+
+	delivery, err := s.db.GetDelivery(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if delivery.Status != models.DeliveryFailed {
+		return nil, ErrDeliveryNotFailed
+	}
+
+	sub, err := s.db.GetWebhook(ctx, delivery.SubscriptionId)
+	if err != nil {
+		return nil, err
+	}
+
+	delivery.Status = models.DeliveryPending
+	if err := s.db.UpdateDelivery(ctx, delivery); err != nil {
+		return nil, err
+	}
+
+	if !s.beginDelivery() {
+		return nil, ErrShuttingDown
+	}
+	replay := *delivery
+	go func() {
+		defer s.deliveries.Done()
+		s.deliver(*sub, &replay)
+	}()
+
+	return delivery, nil
+}
+
+// dispatchEvents records a pending delivery of every event to each
+// subscription that wants it and sends them in the background.
+func (s *service) dispatchEvents(ctx context.Context, events []models.StationEvent) (err error) {
+	if len(events) == 0 {
+		return nil
+	}
+
+	ctx, span := tracing.Tracer().Start(ctx, "service.dispatchEvents")
+	defer func() { tracing.End(span, err) }()
+
+	subs, err := s.db.ListWebhooks(ctx)
+	if err != nil {
+		return err
+	}
+
+	dispatched := 0
+	for _, sub := range subs {
+		for _, event := range events {
+			if !subscribed(&sub, &event) {
+				continue
+			}
+
+			payload, err := json.Marshal(webhookPayload{Type: event.Type, Event: event})
+			if err != nil {
+				return err
+			}
+
+			delivery := &models.WebhookDelivery{
+				SubscriptionId: sub.Id,
+				EventId:        event.Id,
+				EventType:      event.Type,
+				Payload:        payload,
+				Status:         models.DeliveryPending,
+			}
+			if err := s.db.CreateDelivery(ctx, delivery); err != nil {
+				return err
+			}
+
+			// Called from an ingestion, which Shutdown waits for before
+			// waiting for deliveries, so no closing check is needed.
+			s.deliveries.Add(1)
+			go func(sub models.WebhookSubscription) {
+				defer s.deliveries.Done()
+				s.deliver(sub, delivery)
+			}(sub)
+			dispatched++
+		}
+	}
+
+	span.SetAttributes(attribute.Int("webhooks.dispatched", dispatched))
+	return nil
+}
+
+// deliver sends delivery to sub, recording every attempt in the delivery
+// log. It gives up when the service shuts down, leaving the delivery failed
+// so that it can be replayed.
+func (s *service) deliver(sub models.WebhookSubscription, delivery *models.WebhookDelivery) {
+	ctx, span := tracing.Tracer().Start(s.stopping, "service.deliver")
+	defer span.End()
+	span.SetAttributes(attribute.Int64("webhook.id", sub.Id), attribute.Int64("webhook.delivery", delivery.Id))
+
+	// Records must be written even while giving up on shutdown.
+	recordCtx := context.WithoutCancel(ctx)
+	record := func() {
+		if err := s.db.UpdateDelivery(recordCtx, delivery); err != nil {
+			log.Printf("webhook: error recording delivery %d: %v", delivery.Id, err)
+		}
+	}
+
+	cfg := s.cfg().Webhooks
+	sender := &webhook.Sender{
+		Client:         s.webhookClient,
+		MaxAttempts:    cfg.MaxAttempts,
+		InitialBackoff: cfg.InitialBackoff,
+		MaxBackoff:     cfg.MaxBackoff,
+		Timeout:        cfg.Timeout,
+	}
+
+	previous := delivery.Attempts
+	last := sender.Deliver(ctx, webhook.Request{
+		URL:        sub.URL,
+		Secret:     sub.Secret,
+		Event:      delivery.EventType,
+		DeliveryId: delivery.Id,
+		Body:       delivery.Payload,
+	}, func(attempt webhook.Attempt) {
+		delivery.Attempts = previous + attempt.Number
+		delivery.ResponseStatus = attempt.StatusCode
+		delivery.Status = models.DeliverySucceeded
+		delivery.LastError = ""
+		if attempt.Err != nil {
+			delivery.Status = models.DeliveryPending
+			delivery.LastError = attempt.Err.Error()
+		}
+		record()
+	})
+
+	if last.Err != nil {
+		span.RecordError(last.Err)
+		delivery.Status = models.DeliveryFailed
+		record()
+	}
+}
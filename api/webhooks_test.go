@@ -0,0 +1,164 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/macadrich/go-bike/config"
+	"github.com/macadrich/go-bike/database"
+	"github.com/macadrich/go-bike/database/models"
+	"github.com/macadrich/go-bike/pkg/webhook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// webhookDB keeps subscriptions and deliveries in memory. Other methods of
+// database.Database are not used by these tests.
+type webhookDB struct {
+	database.Database
+
+	mu         sync.Mutex
+	subs       []models.WebhookSubscription
+	deliveries map[int64]models.WebhookDelivery
+}
+
+func (db *webhookDB) ListWebhooks(ctx context.Context) ([]models.WebhookSubscription, error) {
+	return db.subs, nil
+}
+
+func (db *webhookDB) GetWebhook(ctx context.Context, id int64) (*models.WebhookSubscription, error) {
+	for _, sub := range db.subs {
+		if sub.Id == id {
+			return &sub, nil
+		}
+	}
+	return nil, database.ErrNotFound
+}
+
+func (db *webhookDB) CreateDelivery(ctx context.Context, d *models.WebhookDelivery) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	d.Id = int64(len(db.deliveries) + 1)
+	db.deliveries[d.Id] = *d
+	return nil
+}
+
+func (db *webhookDB) UpdateDelivery(ctx context.Context, d *models.WebhookDelivery) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.deliveries[d.Id] = *d
+	return nil
+}
+
+func (db *webhookDB) GetDelivery(ctx context.Context, id int64) (*models.WebhookDelivery, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	d, ok := db.deliveries[id]
+	if !ok {
+		return nil, database.ErrNotFound
+	}
+	return &d, nil
+}
+
+func (db *webhookDB) delivery(id int64) models.WebhookDelivery {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.deliveries[id]
+}
+
+func newWebhookService(t *testing.T, db *webhookDB) *service {
+	cfg := &config.Config{}
+	cfg.Webhooks = config.WebhooksConfig{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     time.Millisecond,
+		Timeout:        time.Second,
+	}
+	return NewService(db, nil, config.NewLive("", cfg)).(*service)
+}
+
+func TestSubscribed(t *testing.T) {
+	event := &models.StationEvent{KioskId: 3005, Type: models.EventBecameEmpty}
+
+	assert.True(t, subscribed(&models.WebhookSubscription{}, event))
+	assert.True(t, subscribed(&models.WebhookSubscription{EventTypes: []string{models.EventBecameEmpty}, KioskIds: []int64{3005}}, event))
+	assert.False(t, subscribed(&models.WebhookSubscription{EventTypes: []string{models.EventBecameFull}}, event))
+	assert.False(t, subscribed(&models.WebhookSubscription{KioskIds: []int64{3006}}, event))
+}
+
+func TestDispatchEvents(t *testing.T) {
+	var mu sync.Mutex
+	var received []webhookPayload
+	fail := true
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := webhook.Verify("secret", r.Header.Get(webhook.SignatureHeader), body, time.Now(), time.Minute); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if fail {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		var payload webhookPayload
+		json.Unmarshal(body, &payload)
+		received = append(received, payload)
+	}))
+	defer receiver.Close()
+
+	db := &webhookDB{
+		subs: []models.WebhookSubscription{
+			{Id: 1, URL: receiver.URL, Secret: "secret", KioskIds: []int64{3005}},
+			{Id: 2, URL: receiver.URL, Secret: "secret", EventTypes: []string{models.EventWentOffline}},
+		},
+		deliveries: make(map[int64]models.WebhookDelivery),
+	}
+	s := newWebhookService(t, db)
+
+	events := []models.StationEvent{
+		{Id: 10, KioskId: 3005, Type: models.EventBecameEmpty},
+		{Id: 11, KioskId: 3006, Type: models.EventBecameFull},
+	}
+
+	// Every attempt fails: the delivery ends up failed after MaxAttempts.
+	require.NoError(t, s.dispatchEvents(context.Background(), events))
+	s.deliveries.Wait()
+
+	require.Len(t, db.deliveries, 1)
+	failed := db.delivery(1)
+	assert.Equal(t, models.DeliveryFailed, failed.Status)
+	assert.Equal(t, 3, failed.Attempts)
+	assert.Equal(t, http.StatusInternalServerError, failed.ResponseStatus)
+	assert.Equal(t, int64(10), failed.EventId)
+
+	// Once the receiver recovers, the failed delivery can be replayed.
+	mu.Lock()
+	fail = false
+	mu.Unlock()
+
+	replayed, err := s.ReplayDelivery(context.Background(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, models.DeliveryPending, replayed.Status)
+	s.deliveries.Wait()
+
+	succeeded := db.delivery(1)
+	assert.Equal(t, models.DeliverySucceeded, succeeded.Status)
+	assert.Equal(t, 4, succeeded.Attempts)
+	assert.Empty(t, succeeded.LastError)
+	require.Len(t, received, 1)
+	assert.Equal(t, models.EventBecameEmpty, received[0].Type)
+	assert.Equal(t, 3005, received[0].Event.KioskId)
+
+	_, err = s.ReplayDelivery(context.Background(), 1)
+	assert.ErrorIs(t, err, ErrDeliveryNotFailed)
+}
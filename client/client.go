@@ -2,14 +2,22 @@ package client
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"net/http"
+	neturl "net/url"
 	"time"
+
+	"github.com/macadrich/go-bike/pkg/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.25.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type IClient interface {
 	GetData(ctx context.Context, endpoint string) (*ClientResponse, error)
+	Ping(ctx context.Context, endpoint string) error
 }
 
 type client struct {
@@ -33,8 +41,17 @@ func NewClient() IClient {
 	}
 }
 
-func (c *client) GetData(ctx context.Context, url string) (*ClientResponse, error) {
-	result := make(chan map[string]interface{})
+func (c *client) GetData(ctx context.Context, url string) (_ *ClientResponse, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "client.GetData",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(http.MethodGet),
+			attribute.String("server.address", hostOf(url)),
+		),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	result := make(chan map[string]interface{}, 1)
 	errCh := make(chan error, 1)
 
 	go func() {
@@ -43,21 +60,27 @@ func (c *client) GetData(ctx context.Context, url string) (*ClientResponse, erro
 		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 		if err != nil {
 			errCh <- fmt.Errorf("error request: %w", err)
+			return
 		}
+		otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
 
 		resp, err := c.httpClient.Do(req)
 		if err != nil {
 			errCh <- fmt.Errorf("error sending request: %w", err)
+			return
 		}
 		defer resp.Body.Close()
+		span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
 
 		if resp.StatusCode != http.StatusOK {
 			errCh <- fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+			return
 		}
 
-		var jsonData map[string]interface{}
-		if err := json.NewDecoder(resp.Body).Decode(&jsonData); err != nil {
-			errCh <- fmt.Errorf("error decoding JSON: %w", err)
+		jsonData, err := decode(resp.Body)
+		if err != nil {
+			errCh <- err
+			return
 		}
 		time.Sleep(2 * time.Second)
 		result <- jsonData
@@ -73,3 +96,41 @@ func (c *client) GetData(ctx context.Context, url string) (*ClientResponse, erro
 		return response, nil
 	}
 }
+
+// Ping reports whether endpoint is reachable. Any response below 500 counts,
+// so an upstream rejecting a missing API key is still considered up.
+func (c *client) Ping(ctx context.Context, url string) (err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "client.Ping",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("server.address", hostOf(url))),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return fmt.Errorf("error request: %w", err)
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending request: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// hostOf returns the host part of rawURL without exposing query parameters
+// such as API keys in span attributes.
+func hostOf(rawURL string) string {
+	u, err := neturl.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
@@ -2,7 +2,10 @@ package client
 
 import (
 	"encoding/json"
+	"fmt"
+	"io"
 	"log"
+	"time"
 
 	"github.com/macadrich/go-bike/database/models"
 )
@@ -16,17 +19,114 @@ type ClientResponse struct {
 	data map[string]interface{}
 }
 
+func decode(r io.Reader) (map[string]interface{}, error) {
+	var data map[string]interface{}
+	if err := json.NewDecoder(r).Decode(&data); err != nil {
+		return nil, fmt.Errorf("error decoding JSON: %w", err)
+	}
+	return data, nil
+}
+
+// ReadResponse decodes a feed saved to disk, such as phl.json, the way
+// GetData decodes it from the network.
+func ReadResponse(r io.Reader) (*ClientResponse, error) {
+	data, err := decode(r)
+	if err != nil {
+		return nil, err
+	}
+	return &ClientResponse{data}, nil
+}
+
 type stationsResponse struct {
 	Geometry   models.Geometry `json:"geometry"`
 	Properties models.Stations `json:"properties"`
 	Type       string          `json:"type"`
 }
 
+// feedProperties shadows the time fields of models.Stations, which the feed
+// sends as free-form strings, so they can be parsed explicitly.
+type feedProperties struct {
+	models.Stations
+	CloseTime  *string `json:"closeTime"`
+	EventEnd   *string `json:"eventEnd"`
+	EventStart *string `json:"eventStart"`
+	OpenTime   *string `json:"openTime"`
+}
+
+type feedFeature struct {
+	Geometry   models.Geometry `json:"geometry"`
+	Properties feedProperties  `json:"properties"`
+	Type       string          `json:"type"`
+}
+
+// feedLayouts are tried in order. Layouts without an offset are local to
+// the station's time zone.
+var feedLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"15:04:05",
+	"15:04",
+}
+
+// parseFeedTime parses an optional feed timestamp or time of day in loc.
+func parseFeedTime(value *string, loc *time.Location) (*time.Time, error) {
+	if value == nil || *value == "" {
+		return nil, nil
+	}
+
+	for _, layout := range feedLayouts {
+		if t, err := time.ParseInLocation(layout, *value, loc); err == nil {
+			return &t, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unrecognised time %q", *value)
+}
+
+// toStation converts a feature. Times that cannot be parsed are logged and
+// left null rather than dropping the station, as migration 6 does for
+// legacy rows.
+func (f *feedFeature) toStation(defaultLoc *time.Location) stationsResponse {
+	station := f.Properties.Stations
+
+	loc := defaultLoc
+	if station.TimeZone != "" {
+		if stationLoc, err := time.LoadLocation(station.TimeZone); err == nil {
+			loc = stationLoc
+		}
+	}
+
+	for _, field := range []struct {
+		dst **time.Time
+		src *string
+	}{
+		{&station.CloseTime, f.Properties.CloseTime},
+		{&station.EventEnd, f.Properties.EventEnd},
+		{&station.EventStart, f.Properties.EventStart},
+		{&station.OpenTime, f.Properties.OpenTime},
+	} {
+		t, err := parseFeedTime(field.src, loc)
+		if err != nil {
+			log.Printf("kiosk %d: %v", station.KioskId, err)
+		}
+		*field.dst = t
+	}
+
+	return stationsResponse{Geometry: f.Geometry, Properties: station, Type: f.Type}
+}
+
 func (resp *ClientResponse) HasInValidData() bool {
 	if resp.data != nil {
 		return false
 	}
-	return len(resp.LastUpdated()) == 0 || len(resp.Stations()) == 0
+	return len(resp.LastUpdated()) == 0 || len(resp.Stations(time.UTC)) == 0
+}
+
+// LastUpdatedTime parses LastUpdated.
+func (resp *ClientResponse) LastUpdatedTime() (time.Time, error) {
+	return time.Parse(time.RFC3339, resp.LastUpdated())
 }
 
 func (resp *ClientResponse) LastUpdated() string {
@@ -41,7 +141,9 @@ func (resp *ClientResponse) LastUpdated() string {
 	return ""
 }
 
-func (resp *ClientResponse) Stations() []stationsResponse {
+// Stations decodes the feed's features. Local times without an offset are
+// read in the station's own time zone, or loc when the feed has none.
+func (resp *ClientResponse) Stations(loc *time.Location) []stationsResponse {
 	if resp.data != nil {
 		features, ok := resp.data[Stations].([]any)
 		if !ok {
@@ -49,19 +151,24 @@ func (resp *ClientResponse) Stations() []stationsResponse {
 			return nil
 		}
 
-		var listOfStations []stationsResponse
+		var listOfFeatures []feedFeature
 		jsonData, err := json.Marshal(features)
 		if err != nil {
 			log.Println("Error marshaling features:", err)
 			return nil
 		}
 
-		err = json.Unmarshal(jsonData, &listOfStations)
+		err = json.Unmarshal(jsonData, &listOfFeatures)
 		if err != nil {
 			log.Println("Error unmarshaling features:", err)
 			return nil
 		}
 
+		listOfStations := make([]stationsResponse, 0, len(listOfFeatures))
+		for i := range listOfFeatures {
+			listOfStations = append(listOfStations, listOfFeatures[i].toStation(loc))
+		}
+
 		return listOfStations
 	}
 
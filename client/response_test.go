@@ -0,0 +1,123 @@
+package client
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFeedTime(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	value := func(s string) *string { return &s }
+
+	tests := []struct {
+		name  string
+		value *string
+		want  time.Time
+	}{
+		{"offset", value("2024-05-14T06:48:19.588Z"), time.Date(2024, 5, 14, 6, 48, 19, 588000000, time.UTC)},
+		{"local", value("2024-05-14T08:00:00"), time.Date(2024, 5, 14, 8, 0, 0, 0, loc)},
+		{"time of day", value("06:30"), time.Date(0, 1, 1, 6, 30, 0, 0, loc)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseFeedTime(tt.value, loc)
+			require.NoError(t, err)
+			require.NotNil(t, got)
+			assert.True(t, tt.want.Equal(*got), "got %v want %v", got, tt.want)
+		})
+	}
+
+	got, err := parseFeedTime(nil, loc)
+	assert.NoError(t, err)
+	assert.Nil(t, got)
+
+	got, err = parseFeedTime(value(""), loc)
+	assert.NoError(t, err)
+	assert.Nil(t, got)
+
+	_, err = parseFeedTime(value("noon"), loc)
+	assert.Error(t, err)
+}
+
+func TestStationsUsesStationTimeZone(t *testing.T) {
+	resp := &ClientResponse{data: map[string]any{
+		Latest: "2024-05-14T06:48:19.588Z",
+		Stations: []any{
+			map[string]any{
+				"type":     "Feature",
+				"geometry": map[string]any{"type": "Point", "coordinates": []any{-75.16, 39.95}},
+				"properties": map[string]any{
+					"kioskId":    3005,
+					"timeZone":   "America/Los_Angeles",
+					"eventStart": "2024-05-14T08:00:00",
+					"openTime":   nil,
+				},
+			},
+		},
+	}}
+
+	stations := resp.Stations(time.UTC)
+	require.Len(t, stations, 1)
+
+	station := stations[0].Properties
+	assert.Equal(t, 3005, station.KioskId)
+	assert.Nil(t, station.OpenTime)
+	require.NotNil(t, station.EventStart)
+	assert.Equal(t, time.Date(2024, 5, 14, 15, 0, 0, 0, time.UTC), station.EventStart.UTC())
+
+	at, err := resp.LastUpdatedTime()
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 5, 14, 6, 48, 19, 588000000, time.UTC), at)
+}
+
+func TestStationsKeepsBadTimes(t *testing.T) {
+	resp := &ClientResponse{data: map[string]any{
+		Latest: "2024-05-14T06:48:19.588Z",
+		Stations: []any{
+			map[string]any{
+				"type":     "Feature",
+				"geometry": map[string]any{"type": "Point", "coordinates": []any{-75.16, 39.95}},
+				"properties": map[string]any{
+					"kioskId":   3005,
+					"openTime":  "06:00",
+					"closeTime": "noon",
+				},
+			},
+		},
+	}}
+
+	stations := resp.Stations(time.UTC)
+	require.Len(t, stations, 1, "the station is kept")
+
+	station := stations[0].Properties
+	assert.Equal(t, 3005, station.KioskId)
+	assert.Nil(t, station.CloseTime, "unparseable times become null")
+	require.NotNil(t, station.OpenTime)
+	assert.Equal(t, 6, station.OpenTime.Hour())
+}
+
+func TestReadResponse(t *testing.T) {
+	f, err := os.Open("../phl.json")
+	require.NoError(t, err)
+	defer f.Close()
+
+	resp, err := ReadResponse(f)
+	require.NoError(t, err)
+	assert.False(t, resp.HasInValidData())
+
+	lastUpdated, err := resp.LastUpdatedTime()
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 5, 10, 13, 22, 19, 385000000, time.UTC), lastUpdated)
+	assert.NotEmpty(t, resp.Stations(time.UTC))
+
+	_, err = ReadResponse(strings.NewReader("<html>"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/macadrich/go-bike/api"
+	"github.com/macadrich/go-bike/config"
+	"github.com/macadrich/go-bike/database/postgres"
+	"github.com/macadrich/go-bike/pkg/export"
+	"github.com/macadrich/go-bike/pkg/utils"
+)
+
+// runExport writes an export to a local file, by default named after the
+// dataset and range in the current directory.
+func runExport(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	dataset := flags.String("dataset", export.DatasetStations, "dataset to export: stations, bikes or weather")
+	format := flags.String("format", export.FormatCSV, "output format: csv, ndjson or parquet")
+	from := flags.String("from", "", "start of the range: RFC 3339, Unix seconds or relative such as -24h (required)")
+	to := flags.String("to", "", "end of the range, in the same formats as -from (default now)")
+	kioskId := flags.Int("kiosk", 0, "export only this kiosk")
+	output := flags.String("o", "", "output file (default <dataset>-<from>-<to>.<format>)")
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+
+	req := api.ExportRequest{Dataset: *dataset, Format: *format, KioskId: *kioskId}
+	if _, ok := export.Columns(req.Dataset); !ok {
+		return fmt.Errorf("export: unknown dataset %q", req.Dataset)
+	}
+	if !export.ValidFormat(req.Format) {
+		return fmt.Errorf("export: unknown format %q", req.Format)
+	}
+
+	var err error
+	if req.From, err = utils.ParseTimestamp(*from); err != nil {
+		return fmt.Errorf("export: -from: %w", err)
+	}
+	req.To = time.Now()
+	if *to != "" {
+		if req.To, err = utils.ParseTimestamp(*to); err != nil {
+			return fmt.Errorf("export: -to: %w", err)
+		}
+	}
+	if !req.To.After(req.From) {
+		return errors.New("export: -to must be after -from")
+	}
+
+	path := *output
+	if path == "" {
+		path = req.Filename()
+	}
+
+	db, err := postgres.NewDB(&cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	service := api.NewService(db, nil, config.NewLive("", cfg))
+
+	// The file only appears once the export is complete.
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := service.Export(context.Background(), req, tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	log.Printf("exported %s to %s", req.Dataset, path)
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/macadrich/go-bike/api"
+	"github.com/macadrich/go-bike/config"
+	"github.com/macadrich/go-bike/database/postgres"
+)
+
+// runImport backfills history from saved feed files. Interrupting it is
+// safe: running it again skips the snapshots already stored.
+func runImport(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	workers := flags.Int("workers", 4, "number of files stored at once")
+	every := flags.Int("progress", 100, "report progress every n files")
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), "Usage: go-bike import [flags] <directory, .tar.gz or .json file>\n\nFlags:\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("import: missing path")
+	}
+	if *workers < 1 || *every < 1 {
+		return errors.New("import: -workers and -progress must be positive")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	db, err := postgres.NewDB(&cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	service := api.NewService(db, nil, config.NewLive("", cfg))
+
+	start := time.Now()
+	progress, err := service.Import(ctx, api.ImportOptions{
+		Path:    flags.Arg(0),
+		Workers: *workers,
+		Progress: func(p api.ImportProgress) {
+			if p.Files%*every == 0 {
+				log.Printf("import: %d files, %d imported, %d skipped, %d failed (%s)",
+					p.Files, p.Imported, p.Skipped, p.Failed, time.Since(start).Round(time.Second))
+			}
+		},
+	})
+	if progress != nil {
+		log.Printf("import: done with %d files: %d imported, %d skipped, %d failed in %s",
+			progress.Files, progress.Imported, progress.Skipped, progress.Failed, time.Since(start).Round(time.Second))
+	}
+	if errors.Is(err, context.Canceled) {
+		return errors.New("import: interrupted, run it again to resume")
+	}
+
+	return err
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/macadrich/go-bike/api"
+	"github.com/macadrich/go-bike/client"
+	"github.com/macadrich/go-bike/config"
+	"github.com/macadrich/go-bike/database/postgres"
+)
+
+// ingestShutdownTimeout bounds the wait for webhook deliveries after an
+// ingestion; those still being retried are left failed for a replay.
+const ingestShutdownTimeout = 10 * time.Second
+
+// runIngest fetches and stores the current feed once, as the scheduler of
+// serve does on every tick.
+func runIngest(cfg *config.Config, args []string) error {
+	if len(args) == 0 || args[0] != "once" {
+		return errors.New("ingest: missing subcommand, want once")
+	}
+	if len(args) > 1 {
+		return fmt.Errorf("ingest once: unexpected argument %q", args[1])
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	db, err := postgres.NewDB(&cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	service := api.NewService(db, client.NewClient(), config.NewLive("", cfg))
+
+	if err := service.MaintainPartitions(ctx); err != nil {
+		return err
+	}
+
+	start := time.Now()
+	err = service.InsertStation(ctx)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), ingestShutdownTimeout)
+	defer cancel()
+	if err := service.Shutdown(shutdownCtx); err != nil {
+		log.Println("ingest:", err)
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Printf("ingested the feed in %s", time.Since(start).Round(time.Millisecond))
+	return nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/macadrich/go-bike/api"
+	"github.com/macadrich/go-bike/config"
+	"github.com/macadrich/go-bike/database/models"
+	"github.com/macadrich/go-bike/database/postgres"
+)
+
+// createdKey is the JSON output of keys create.
+type createdKey struct {
+	*models.APIKey
+	Key string `json:"key"`
+}
+
+// runKeys manages API keys directly in the database.
+func runKeys(cfg *config.Config, args []string) error {
+	if len(args) == 0 || args[0] != "create" {
+		return errors.New("keys: missing subcommand, want create")
+	}
+
+	flags := flag.NewFlagSet("keys create", flag.ContinueOnError)
+	name := flags.String("name", "", "what the key is for, e.g. the client using it (required)")
+	output := outputFlag(flags)
+	if err := flags.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+	*name = strings.TrimSpace(*name)
+	if *name == "" || len(*name) > 100 {
+		return errors.New("keys create: -name is required, up to 100 characters")
+	}
+
+	db, err := postgres.NewDB(&cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	service := api.NewService(db, nil, config.NewLive("", cfg))
+
+	record, key, err := service.CreateAPIKey(context.Background(), *name)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(os.Stderr, "Store this key now, it cannot be shown again.")
+	return printResult(*output, createdKey{record, key}, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "ID\tNAME\tCREATED\tKEY")
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", record.Id, record.Name, record.CreatedAt.Format(time.RFC3339), key)
+	})
+}
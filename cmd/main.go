@@ -1,30 +1,103 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"log"
-	"net/http"
+	"os"
+	_ "time/tzdata"
 
-	"github.com/macadrich/go-bike/api"
-	"github.com/macadrich/go-bike/api/handlers"
-	"github.com/macadrich/go-bike/api/routers"
-	"github.com/macadrich/go-bike/client"
 	"github.com/macadrich/go-bike/config"
-
-	"github.com/macadrich/go-bike/database/postgres"
 )
 
+const usage = `Usage: go-bike [flags] <command> [arguments]
+
+Commands:
+  serve                      run the HTTP API (default)
+  ingest once                fetch and store the current feed once
+  stations list [flags]      list the stations served by the API
+  stations get [flags] <kiosk>
+                             show one station
+  history [flags] <kiosk>    show the availability of a station over time
+  keys create -name <name>   create an API key
+  migrate up                 apply all pending migrations
+  migrate down [n]           revert the last n migrations (all when omitted)
+  migrate status             show the schema version and pending migrations
+  migrate force <version>    set the schema version without running migrations
+  export [flags]             export stations, bikes or weather to a CSV,
+                             NDJSON or Parquet file; see export -h
+  import [flags] <path>      backfill history from saved feed files in a
+                             directory or .tar.gz archive; see import -h
+
+The stations and history commands call the API and accept -server,
+-token and -output table|json; see <command> -h.
+
+Flags:
+`
+
+// @title go-bike API
+// @version 2.0
+// @description Availability, history and analytics of the Indego bike-share
+// @description stations, with the weather of each snapshot. Errors are
+// @description application/problem+json documents. /api/v1 is deprecated in
+// @description favour of /api/v2; its responses carry Deprecation and Sunset
+// @description headers.
+// @BasePath /
+//
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description "Bearer " followed by the configured token or an API key.
 func main() {
+	configPath := flag.String("config", config.DefaultPath, "path to the configuration file")
+	printConfig := flag.Bool("print-config", false, "print the effective configuration with secrets redacted and exit")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
 
-	db, err := postgres.NewDB(config.LoadDBConfig())
+	cfg, err := config.Load(*configPath)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	client := client.NewClient()
-	service := api.NewService(db, client)
-	handlers := handlers.NewHandlers(service)
-	router := routers.NewRouter(handlers)
+	if *printConfig {
+		if err := cfg.Dump(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	args := flag.Args()
+	command := "serve"
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+
+	switch command {
+	case "serve":
+		err = serve(*configPath, cfg)
+	case "ingest":
+		err = runIngest(cfg, args)
+	case "stations":
+		err = runStations(cfg, args)
+	case "history":
+		err = runHistory(cfg, args)
+	case "keys":
+		err = runKeys(cfg, args)
+	case "migrate":
+		err = runMigrate(cfg, args)
+	case "export":
+		err = runExport(cfg, args)
+	case "import":
+		err = runImport(cfg, args)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
 
-	log.Println("Server is running on: 8080")
-	http.ListenAndServe(":8080", router)
+	if err != nil {
+		log.Fatal(err)
+	}
 }
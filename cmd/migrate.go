@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/macadrich/go-bike/config"
+	"github.com/macadrich/go-bike/database/migrate"
+	"github.com/macadrich/go-bike/database/postgres"
+	"github.com/macadrich/go-bike/migrations"
+)
+
+func newMigrator(cfg *config.Config) (*migrate.Migrator, func() error, error) {
+	db, err := postgres.Open(&cfg.Database)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m, err := migrate.New(db, migrations.FS)
+	if err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+
+	return m, db.Close, nil
+}
+
+func migrateUp(ctx context.Context, cfg *config.Config) error {
+	m, closeDB, err := newMigrator(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	applied, err := m.Up(ctx)
+	if err != nil {
+		return err
+	}
+	log.Printf("applied %d migration(s)", applied)
+
+	return nil
+}
+
+func runMigrate(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New("migrate: missing subcommand, want up, down, status or force")
+	}
+
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		return migrateUp(ctx, cfg)
+	case "down":
+		steps := 0
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n <= 0 {
+				return fmt.Errorf("migrate down: invalid number of steps %q", args[1])
+			}
+			steps = n
+		}
+
+		m, closeDB, err := newMigrator(cfg)
+		if err != nil {
+			return err
+		}
+		defer closeDB()
+
+		reverted, err := m.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		log.Printf("reverted %d migration(s)", reverted)
+		return nil
+	case "status":
+		m, closeDB, err := newMigrator(cfg)
+		if err != nil {
+			return err
+		}
+		defer closeDB()
+
+		status, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("version: %d (dirty: %t, pending: %d)\n\n", status.Version, status.Dirty, status.Pending())
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tSTATE")
+		for _, mig := range status.Migrations {
+			state := "pending"
+			if mig.Applied {
+				state = "applied"
+			}
+			fmt.Fprintf(w, "%06d\t%s\t%s\n", mig.Version, mig.Name, state)
+		}
+		return w.Flush()
+	case "force":
+		if len(args) < 2 {
+			return errors.New("migrate force: missing version")
+		}
+		version, err := strconv.Atoi(args[1])
+		if err != nil || version < 0 {
+			return fmt.Errorf("migrate force: invalid version %q", args[1])
+		}
+
+		m, closeDB, err := newMigrator(cfg)
+		if err != nil {
+			return err
+		}
+		defer closeDB()
+
+		if err := m.Force(ctx, version); err != nil {
+			return err
+		}
+		log.Printf("forced schema version to %d", version)
+		return nil
+	default:
+		return fmt.Errorf("migrate: unknown subcommand %q", args[0])
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"text/tabwriter"
+
+	"github.com/macadrich/go-bike/config"
+	"github.com/macadrich/go-bike/pkg/gobikeclient"
+)
+
+// Output modes of the commands that print results.
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// outputFlag registers the -output flag on flags.
+func outputFlag(flags *flag.FlagSet) *string {
+	return flags.String("output", outputTable, "output format: table or json")
+}
+
+// printResult writes v as indented JSON, or as a table drawn by table.
+func printResult(output string, v any, table func(w *tabwriter.Writer)) error {
+	switch output {
+	case outputJSON:
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case outputTable:
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		table(w)
+		return w.Flush()
+	default:
+		return fmt.Errorf("unknown output format %q, want table or json", output)
+	}
+}
+
+// apiFlags registers the flags that select the API a command talks to and
+// returns a function building the client once they are parsed. Both default
+// to the configuration, so that on the host running the server no flags
+// are needed.
+func apiFlags(flags *flag.FlagSet, cfg *config.Config) func() (*gobikeclient.Client, error) {
+	server := flags.String("server", "", "base URL of the API (default derived from Server.Address)")
+	token := flags.String("token", "", "bearer token or API key (default Authorization.Token)")
+
+	return func() (*gobikeclient.Client, error) {
+		url := *server
+		if url == "" {
+			url = serverURL(cfg.Server.Address)
+		}
+		bearer := *token
+		if bearer == "" {
+			bearer = cfg.Authorization.Token
+		}
+		return gobikeclient.New(url, gobikeclient.WithToken(bearer))
+	}
+}
+
+// serverURL is the URL of a server listening on address, e.g. :8080.
+func serverURL(address string) string {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return "http://" + address
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+	return "http://" + net.JoinHostPort(host, port)
+}
+
+// parseArgs parses flags, allowing them after the positional arguments as
+// in "stations get 3005 -output json". It returns the positional arguments.
+func parseArgs(flags *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+		if len(args) > 0 && args[0] == "--" {
+			return append(positional, args[1:]...), nil
+		}
+	}
+}
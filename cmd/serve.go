@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/macadrich/go-bike/api"
+	"github.com/macadrich/go-bike/api/handlers"
+	"github.com/macadrich/go-bike/api/routers"
+	"github.com/macadrich/go-bike/client"
+	"github.com/macadrich/go-bike/config"
+	"github.com/macadrich/go-bike/pkg/scheduler"
+	"github.com/macadrich/go-bike/pkg/tracing"
+
+	"github.com/macadrich/go-bike/database/postgres"
+)
+
+func serve(configPath string, cfg *config.Config) error {
+	var logLevel slog.LevelVar
+	logLevel.Set(cfg.Log.SlogLevel())
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: &logLevel})))
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracing, err := tracing.Setup(ctx, &cfg.Tracing)
+	if err != nil {
+		return err
+	}
+
+	if cfg.Database.AutoMigrate {
+		if err := migrateUp(ctx, cfg); err != nil {
+			return err
+		}
+	}
+
+	db, err := postgres.NewDB(&cfg.Database)
+	if err != nil {
+		return err
+	}
+
+	live := config.NewLive(configPath, cfg)
+
+	client := client.NewClient()
+	service := api.NewService(db, client, live)
+	handlers := handlers.NewHandlers(service)
+	router := routers.NewRouter(handlers, live)
+
+	// The partition of the current month must exist before the first
+	// ingestion; later months are created by the retention job.
+	if err := service.MaintainPartitions(ctx); err != nil {
+		log.Println("error maintaining partitions:", err)
+	}
+
+	ingestion := scheduler.New("ingestion", service.InsertStation)
+	ingestion.Update(cfg.Scheduler.Enabled, cfg.Scheduler.Interval)
+
+	retention := scheduler.New("retention", service.MaintainPartitions)
+	retention.Update(true, cfg.Retention.Interval)
+
+	live.OnChange(func(snap *config.Snapshot) {
+		logLevel.Set(snap.Config.Log.SlogLevel())
+		ingestion.Update(snap.Config.Scheduler.Enabled, snap.Config.Scheduler.Interval)
+		retention.Update(true, snap.Config.Retention.Interval)
+	})
+	live.Watch()
+
+	go ingestion.Run(ctx)
+	go retention.Run(ctx)
+
+	serverCfg := cfg.Server
+	server := &http.Server{
+		Addr:              serverCfg.Address,
+		Handler:           router,
+		ReadTimeout:       serverCfg.ReadTimeout,
+		ReadHeaderTimeout: serverCfg.ReadHeaderTimeout,
+		WriteTimeout:      serverCfg.WriteTimeout,
+		IdleTimeout:       serverCfg.IdleTimeout,
+		MaxHeaderBytes:    serverCfg.MaxHeaderBytes,
+	}
+
+	// Streaming requests only end when their subscription is closed.
+	server.RegisterOnShutdown(service.Stream().Close)
+
+	serverErr := make(chan error, 1)
+	go func() {
+		log.Println("Server is running on:", serverCfg.Address)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+		close(serverErr)
+	}()
+
+	select {
+	case err := <-serverErr:
+		log.Println("server error:", err)
+	case <-ctx.Done():
+		log.Println("shutting down")
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), serverCfg.ShutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Println("error draining requests:", err)
+	}
+
+	if err := service.Shutdown(shutdownCtx); err != nil {
+		log.Println("error draining ingestion:", err)
+	}
+
+	if err := db.Close(); err != nil {
+		log.Println("error closing database:", err)
+	}
+
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		log.Println("error flushing traces:", err)
+	}
+
+	return nil
+}
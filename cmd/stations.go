@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/macadrich/go-bike/config"
+	"github.com/macadrich/go-bike/pkg/gobikeclient"
+	"github.com/macadrich/go-bike/pkg/utils"
+)
+
+// runStations shows stations as served by the API.
+func runStations(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New("stations: missing subcommand, want list or get")
+	}
+	command, args := args[0], args[1:]
+
+	flags := flag.NewFlagSet("stations "+command, flag.ContinueOnError)
+	newClient := apiFlags(flags, cfg)
+	output := outputFlag(flags)
+	at := flags.String("at", "", "show the first snapshot at or after this time: RFC 3339, Unix seconds or relative such as -1h (default the latest)")
+
+	switch command {
+	case "list":
+		flags.Usage = func() {
+			fmt.Fprint(flags.Output(), "Usage: go-bike stations list [flags]\n\nFlags:\n")
+			flags.PrintDefaults()
+		}
+	case "get":
+		flags.Usage = func() {
+			fmt.Fprint(flags.Output(), "Usage: go-bike stations get [flags] <kiosk>\n\nFlags:\n")
+			flags.PrintDefaults()
+		}
+	default:
+		return fmt.Errorf("stations: unknown subcommand %q", command)
+	}
+
+	positional, err := parseArgs(flags, args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+
+	var when time.Time
+	if *at != "" {
+		if when, err = utils.ParseTimestamp(*at); err != nil {
+			return fmt.Errorf("stations %s: -at: %w", command, err)
+		}
+	}
+
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	if command == "list" {
+		if len(positional) > 0 {
+			return fmt.Errorf("stations list: unexpected argument %q", positional[0])
+		}
+		result, err := c.ListStations(ctx, when)
+		if err != nil {
+			return err
+		}
+		return printResult(*output, result, func(w *tabwriter.Writer) {
+			fmt.Fprintf(w, "snapshot: %s\n\n", result.At.Format(time.RFC3339))
+			fmt.Fprintln(w, "KIOSK\tNAME\tSTATUS\tBIKES\tE-BIKES\tDOCKS")
+			for _, s := range result.Stations {
+				fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%d\t%d\n", s.KioskId, s.Name, s.KioskPublicStatus,
+					s.BikesAvailable, s.ElectricBikesAvailable, s.DocksAvailable)
+			}
+		})
+	}
+
+	if len(positional) != 1 {
+		flags.Usage()
+		return errors.New("stations get: want exactly one kiosk")
+	}
+	kioskId, err := strconv.Atoi(positional[0])
+	if err != nil || kioskId <= 0 {
+		return fmt.Errorf("stations get: invalid kiosk %q", positional[0])
+	}
+
+	station, err := c.GetStation(ctx, kioskId, when)
+	if err != nil {
+		return err
+	}
+	return printResult(*output, station, func(w *tabwriter.Writer) {
+		printStation(w, station)
+	})
+}
+
+func printStation(w *tabwriter.Writer, s *gobikeclient.Station) {
+	fmt.Fprintf(w, "kiosk:\t%d\n", s.KioskId)
+	fmt.Fprintf(w, "name:\t%s\n", s.Name)
+	fmt.Fprintf(w, "address:\t%s, %s %s %s\n", s.AddressStreet, s.AddressCity, s.AddressState, s.AddressZipCode)
+	fmt.Fprintf(w, "location:\t%.6f, %.6f\n", s.Latitude, s.Longitude)
+	fmt.Fprintf(w, "status:\t%s (%s)\n", s.KioskPublicStatus, s.KioskConnectionStatus)
+	fmt.Fprintf(w, "at:\t%s\n", s.At.Format(time.RFC3339))
+	fmt.Fprintf(w, "bikes:\t%d (%d classic, %d electric, %d smart)\n", s.BikesAvailable,
+		s.ClassicBikesAvailable, s.ElectricBikesAvailable, s.SmartBikesAvailable)
+	fmt.Fprintf(w, "docks:\t%d of %d free\n", s.DocksAvailable, s.TotalDocks)
+}
+
+// runHistory shows the availability of a kiosk over time.
+func runHistory(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("history", flag.ContinueOnError)
+	newClient := apiFlags(flags, cfg)
+	output := outputFlag(flags)
+	from := flags.String("from", "", "start of the range: RFC 3339, Unix seconds or relative such as -24h (default 24 hours before -to)")
+	to := flags.String("to", "", "end of the range, in the same formats as -from (default now)")
+	resolution := flags.String("resolution", "auto", "auto, raw, hour or day")
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), "Usage: go-bike history [flags] <kiosk>\n\nFlags:\n")
+		flags.PrintDefaults()
+	}
+
+	positional, err := parseArgs(flags, args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+	if len(positional) != 1 {
+		flags.Usage()
+		return errors.New("history: want exactly one kiosk")
+	}
+	kioskId, err := strconv.Atoi(positional[0])
+	if err != nil || kioskId <= 0 {
+		return fmt.Errorf("history: invalid kiosk %q", positional[0])
+	}
+
+	end := time.Now()
+	if *to != "" {
+		if end, err = utils.ParseTimestamp(*to); err != nil {
+			return fmt.Errorf("history: -to: %w", err)
+		}
+	}
+	start := end.Add(-24 * time.Hour)
+	if *from != "" {
+		if start, err = utils.ParseTimestamp(*from); err != nil {
+			return fmt.Errorf("history: -from: %w", err)
+		}
+	}
+
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	history, err := c.StationHistory(context.Background(), kioskId, start, end, *resolution)
+	if err != nil {
+		return err
+	}
+	return printResult(*output, history, func(w *tabwriter.Writer) {
+		fmt.Fprintf(w, "kiosk %d, %s to %s by %s\n\n", history.KioskId,
+			history.From.Format(time.RFC3339), history.To.Format(time.RFC3339), history.Resolution)
+		fmt.Fprintln(w, "AT\tSAMPLES\tBIKES\tMIN\tMAX\tDOCKS\tE-BIKES\tEMPTY (MIN)\tFULL (MIN)")
+		for _, p := range history.Points {
+			fmt.Fprintf(w, "%s\t%d\t%.1f\t%d\t%d\t%.1f\t%.1f\t%.0f\t%.0f\n", p.At.Format(time.RFC3339), p.Samples,
+				p.BikesAvg, p.BikesMin, p.BikesMax, p.DocksAvg, p.EBikesAvg, p.MinutesEmpty, p.MinutesFull)
+		}
+	})
+}
@@ -1,56 +1,525 @@
 package config
 
 import (
-	"log"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
+// DefaultPath is where the configuration file is looked up when no --config
+// flag is given. Unlike an explicit path, it is allowed to be missing.
+const DefaultPath = "./config/config.yaml"
+
+// EnvPrefix prefixes every environment variable override, e.g.
+// GOBIKE_DATABASE_HOST overrides Database.Host.
+const EnvPrefix = "GOBIKE"
+
+const redacted = "******"
+
+const defaultCity = "Philadelphia"
+
+type Config struct {
+	Authorization AuthorizationConfig `mapstructure:"Authorization"`
+	Server        ServerConfig        `mapstructure:"Server"`
+	Database      DBConfig            `mapstructure:"Database"`
+	ThirdpartyAPI ThirdpartyAPI       `mapstructure:"ThirdpartAPI"`
+	Tracing       TracingConfig       `mapstructure:"Tracing"`
+	Health        HealthConfig        `mapstructure:"Health"`
+	API           APIConfig           `mapstructure:"API"`
+
+	RuntimeConfig `mapstructure:",squash"`
+}
+
+// RuntimeConfig groups the sections that are reloaded while the service is
+// running. Every other section only takes effect after a restart.
+type RuntimeConfig struct {
+	Scheduler SchedulerConfig `mapstructure:"Scheduler" json:"scheduler"`
+	RateLimit RateLimitConfig `mapstructure:"RateLimit" json:"rateLimit"`
+	Log       LogConfig       `mapstructure:"Log" json:"log"`
+	Features  FeaturesConfig  `mapstructure:"Features" json:"features"`
+	Weather   WeatherConfig   `mapstructure:"Weather" json:"weather"`
+	Retention RetentionConfig `mapstructure:"Retention" json:"retention"`
+	Webhooks  WebhooksConfig  `mapstructure:"Webhooks" json:"webhooks"`
+	EBikes    EBikesConfig    `mapstructure:"EBikes" json:"ebikes"`
+}
+
+// SchedulerConfig controls the periodic ingestion of the bike feed.
+type SchedulerConfig struct {
+	Enabled  bool          `mapstructure:"Enabled" json:"enabled"`
+	Interval time.Duration `mapstructure:"Interval" json:"interval" swaggertype:"integer"`
+}
+
+// RateLimitConfig is a per-client token bucket applied to the /api routes.
+type RateLimitConfig struct {
+	Enabled           bool    `mapstructure:"Enabled" json:"enabled"`
+	RequestsPerSecond float64 `mapstructure:"RequestsPerSecond" json:"requestsPerSecond"`
+	Burst             int     `mapstructure:"Burst" json:"burst"`
+}
+
+type LogConfig struct {
+	Level string `mapstructure:"Level" json:"level"`
+}
+
+// SlogLevel converts Level, already checked by Validate, to a slog.Level.
+func (c LogConfig) SlogLevel() slog.Level {
+	var level slog.Level
+	_ = level.UnmarshalText([]byte(c.Level))
+	return level
+}
+
+// FeaturesConfig toggles optional behaviour at runtime.
+type FeaturesConfig struct {
+	Weather bool `mapstructure:"Weather" json:"weather"`
+}
+
+// WeatherConfig selects the city whose current weather is fetched.
+type WeatherConfig struct {
+	City string `mapstructure:"City" json:"city"`
+}
+
+// RetentionConfig controls the monthly partitions of the snapshot tables.
+// Every Interval, partitions are created PremakeMonths ahead and, when
+// Enabled, those entirely older than Days are dropped, along with the
+// ingestions of their month, or, with Mode "archive", first exported as
+// gzipped CSV to ArchiveDir.
+type RetentionConfig struct {
+	Enabled       bool          `mapstructure:"Enabled" json:"enabled"`
+	Days          int           `mapstructure:"Days" json:"days"`
+	Mode          string        `mapstructure:"Mode" json:"mode"`
+	ArchiveDir    string        `mapstructure:"ArchiveDir" json:"archiveDir"`
+	Interval      time.Duration `mapstructure:"Interval" json:"interval" swaggertype:"integer"`
+	PremakeMonths int           `mapstructure:"PremakeMonths" json:"premakeMonths"`
+}
+
+const (
+	RetentionDrop    = "drop"
+	RetentionArchive = "archive"
+)
+
+// WebhooksConfig controls the delivery of station events to webhook
+// subscribers. Failed attempts are retried MaxAttempts times in total,
+// waiting InitialBackoff, then twice as long each time up to MaxBackoff.
+type WebhooksConfig struct {
+	MaxAttempts    int           `mapstructure:"MaxAttempts" json:"maxAttempts"`
+	InitialBackoff time.Duration `mapstructure:"InitialBackoff" json:"initialBackoff" swaggertype:"integer"`
+	MaxBackoff     time.Duration `mapstructure:"MaxBackoff" json:"maxBackoff" swaggertype:"integer"`
+	Timeout        time.Duration `mapstructure:"Timeout" json:"timeout" swaggertype:"integer"`
+}
+
+// EBikesConfig controls the low-battery report. E-bikes below LowBattery
+// percent are listed; how long they have been low is looked up at most
+// LowBatteryLookback back.
+type EBikesConfig struct {
+	LowBattery         int           `mapstructure:"LowBattery" json:"lowBattery"`
+	LowBatteryLookback time.Duration `mapstructure:"LowBatteryLookback" json:"lowBatteryLookback" swaggertype:"integer"`
+}
+
+type AuthorizationConfig struct {
+	Token string `mapstructure:"Token"`
+}
+
+// ThirdpartyAPI configures the upstream feeds. TimeZone is the IANA zone
+// used for feed times that carry no offset and no station time zone.
 type ThirdpartyAPI struct {
-	APIKey     string
-	BikeURL    string
-	WeatherURL string
-	City       string
+	APIKey     string `mapstructure:"APIKey"`
+	BikeURL    string `mapstructure:"BicycleTrancit"`
+	WeatherURL string `mapstructure:"CurrentWeather"`
+	TimeZone   string `mapstructure:"TimeZone"`
+}
+
+// Location loads TimeZone, already checked by Validate.
+func (c *ThirdpartyAPI) Location() *time.Location {
+	loc, err := time.LoadLocation(c.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
 }
 
+// DBConfig holds the Postgres connection settings. URL, when set, is used as
+// the DSN as is and takes precedence over the individual fields. With
+// AutoMigrate the embedded migrations are applied when the server starts.
 type DBConfig struct {
-	DBHost        string
-	DBPort        string
-	DBUser        string
-	DBPassword    string
-	DBName        string
-	ThirdpartyAPI ThirdpartyAPI
+	URL         string `mapstructure:"URL"`
+	AutoMigrate bool   `mapstructure:"AutoMigrate"`
+	DBHost      string `mapstructure:"Host"`
+	DBPort      string `mapstructure:"Port"`
+	DBUser      string `mapstructure:"User"`
+	DBPassword  string `mapstructure:"Password"`
+	DBName      string `mapstructure:"Name"`
+}
+
+// DSN returns the connection string for lib/pq.
+func (c *DBConfig) DSN() string {
+	if c.URL != "" {
+		return c.URL
+	}
+	return fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable", c.DBHost, c.DBUser, c.DBPassword, c.DBName, c.DBPort)
+}
+
+// TracingConfig selects where OpenTelemetry spans are exported. Exporter is
+// one of "none", "stdout" or "otlp"; Endpoint is only used by "otlp".
+type TracingConfig struct {
+	Exporter    string  `mapstructure:"Exporter"`
+	Endpoint    string  `mapstructure:"Endpoint"`
+	Insecure    bool    `mapstructure:"Insecure"`
+	ServiceName string  `mapstructure:"ServiceName"`
+	SampleRatio float64 `mapstructure:"SampleRatio"`
+}
+
+// HealthConfig tunes the readiness probe. A snapshot older than
+// MaxSnapshotAge marks the service as not ready.
+type HealthConfig struct {
+	CheckTimeout   time.Duration `mapstructure:"CheckTimeout"`
+	MaxSnapshotAge time.Duration `mapstructure:"MaxSnapshotAge"`
+}
+
+// APIConfig dates the deprecation of API v1, which its responses announce
+// in the Deprecation and Sunset headers. Both are UTC dates such as
+// 2026-10-19; when both are empty, v1 is not marked deprecated.
+type APIConfig struct {
+	V1Deprecated string `mapstructure:"V1Deprecated"`
+	V1Sunset     string `mapstructure:"V1Sunset"`
+}
+
+// V1Deprecation parses V1Deprecated and V1Sunset, already checked by
+// Validate. ok is false when v1 is not deprecated.
+func (c *APIConfig) V1Deprecation() (deprecated, sunset time.Time, ok bool) {
+	deprecated, err := time.Parse(time.DateOnly, c.V1Deprecated)
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+	sunset, err = time.Parse(time.DateOnly, c.V1Sunset)
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+	return deprecated, sunset, true
+}
+
+// ServerConfig holds the HTTP listener settings. ShutdownTimeout bounds how
+// long in-flight requests and ingestions are given to finish on exit.
+type ServerConfig struct {
+	Address           string        `mapstructure:"Address"`
+	ReadTimeout       time.Duration `mapstructure:"ReadTimeout"`
+	ReadHeaderTimeout time.Duration `mapstructure:"ReadHeaderTimeout"`
+	WriteTimeout      time.Duration `mapstructure:"WriteTimeout"`
+	IdleTimeout       time.Duration `mapstructure:"IdleTimeout"`
+	MaxHeaderBytes    int           `mapstructure:"MaxHeaderBytes"`
+	ShutdownTimeout   time.Duration `mapstructure:"ShutdownTimeout"`
+}
+
+func setDefaults(v *viper.Viper) {
+	v.SetDefault("Authorization.Token", "")
+
+	v.SetDefault("Server.Address", ":8080")
+	v.SetDefault("Server.ReadTimeout", "10s")
+	v.SetDefault("Server.ReadHeaderTimeout", "5s")
+	v.SetDefault("Server.WriteTimeout", "60s")
+	v.SetDefault("Server.IdleTimeout", "120s")
+	v.SetDefault("Server.MaxHeaderBytes", 1<<20)
+	v.SetDefault("Server.ShutdownTimeout", "30s")
+
+	v.SetDefault("Database.URL", "")
+	v.SetDefault("Database.AutoMigrate", false)
+	v.SetDefault("Database.Host", "localhost")
+	v.SetDefault("Database.Port", "5432")
+	v.SetDefault("Database.User", "")
+	v.SetDefault("Database.Password", "")
+	v.SetDefault("Database.Name", "indegodb")
+
+	v.SetDefault("ThirdpartAPI.APIKey", "")
+	v.SetDefault("ThirdpartAPI.CurrentWeather", "https://api.openweathermap.org/data/2.5/weather")
+	v.SetDefault("ThirdpartAPI.BicycleTrancit", "https://bts-status.bicycletransit.workers.dev/phl")
+	v.SetDefault("ThirdpartAPI.TimeZone", "America/New_York")
+
+	v.SetDefault("Tracing.Exporter", "none")
+	v.SetDefault("Tracing.Endpoint", "")
+	v.SetDefault("Tracing.Insecure", false)
+	v.SetDefault("Tracing.ServiceName", "go-bike")
+	v.SetDefault("Tracing.SampleRatio", 1.0)
+
+	v.SetDefault("Health.CheckTimeout", "2s")
+	v.SetDefault("Health.MaxSnapshotAge", "15m")
+
+	v.SetDefault("API.V1Deprecated", "2026-10-19")
+	v.SetDefault("API.V1Sunset", "2027-04-19")
+
+	v.SetDefault("Scheduler.Enabled", false)
+	v.SetDefault("Scheduler.Interval", "1m")
+	v.SetDefault("RateLimit.Enabled", false)
+	v.SetDefault("RateLimit.RequestsPerSecond", 10)
+	v.SetDefault("RateLimit.Burst", 20)
+	v.SetDefault("Log.Level", "info")
+	v.SetDefault("Features.Weather", true)
+	v.SetDefault("Weather.City", defaultCity)
+	v.SetDefault("Retention.Enabled", false)
+	v.SetDefault("Retention.Days", 90)
+	v.SetDefault("Retention.Mode", RetentionDrop)
+	v.SetDefault("Retention.ArchiveDir", "./archive")
+	v.SetDefault("Retention.Interval", "24h")
+	v.SetDefault("Retention.PremakeMonths", 2)
+	v.SetDefault("Webhooks.MaxAttempts", 5)
+	v.SetDefault("Webhooks.InitialBackoff", "2s")
+	v.SetDefault("Webhooks.MaxBackoff", "1m")
+	v.SetDefault("Webhooks.Timeout", "10s")
+
+	v.SetDefault("EBikes.LowBattery", 20)
+	v.SetDefault("EBikes.LowBatteryLookback", "168h")
 }
 
-func Config() *viper.Viper {
+// Load reads the configuration once from defaults, the file at path and
+// environment variables, in increasing order of precedence, and validates
+// the result. DATABASE_URL is honoured as well as GOBIKE_DATABASE_URL.
+func Load(path string) (*Config, error) {
 	v := viper.New()
-	v.SetConfigFile("./config/config.yaml")
+	setDefaults(v)
 
-	if err := v.ReadInConfig(); err != nil {
-		log.Fatalf("Error reading config file: %s", err)
+	v.SetEnvPrefix(EnvPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+	if err := v.BindEnv("Database.URL", EnvPrefix+"_DATABASE_URL", "DATABASE_URL"); err != nil {
+		return nil, err
 	}
 
-	return v
+	if path != "" {
+		v.SetConfigFile(path)
+		if err := v.ReadInConfig(); err != nil {
+			if !(path == DefaultPath && errors.Is(err, fs.ErrNotExist)) {
+				return nil, fmt.Errorf("error reading config file: %w", err)
+			}
+		}
+	}
+
+	// The city used to be set as ThirdpartAPI.City, which is still read
+	// when Weather.City is left at its default.
+	if city := v.GetString("ThirdpartAPI.City"); city != "" && v.GetString("Weather.City") == defaultCity {
+		v.Set("Weather.City", city)
+	}
+
+	var cfg Config
+	if err := v.Unmarshal(&cfg); err != nil {
+		return nil, fmt.Errorf("error decoding config: %w", err)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &cfg, nil
+}
+
+// FieldError describes a single invalid configuration key.
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+// ValidationError lists every invalid field found by Validate.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "invalid configuration (%d errors):", len(e.Fields))
+	for _, f := range e.Fields {
+		fmt.Fprintf(&b, "\n  %s: %s", f.Field, f.Message)
+	}
+	return b.String()
 }
 
-func LoadDBConfig() *DBConfig {
-	v := Config()
-	return &DBConfig{
-		DBHost:     v.GetString("Database.Host"),
-		DBPort:     v.GetString("Database.Port"),
-		DBUser:     v.GetString("Database.User"),
-		DBPassword: v.GetString("Database.Password"),
-		DBName:     v.GetString("Database.Name"),
-		ThirdpartyAPI: ThirdpartyAPI{
-			APIKey:     v.GetString("ThirdpartAPI.APIKey"),
-			BikeURL:    v.GetString("ThirdpartAPI.BicycleTrancit"),
-			WeatherURL: v.GetString("ThirdpartAPI.CurrentWeather"),
-			City:       v.GetString("ThirdpartAPI.City"),
-		},
+func (e *ValidationError) add(field, format string, args ...any) {
+	e.Fields = append(e.Fields, FieldError{field, fmt.Sprintf(format, args...)})
+}
+
+// Validate checks every field and reports all problems at once.
+func (c *Config) Validate() error {
+	verr := &ValidationError{}
+
+	if c.Authorization.Token == "" {
+		verr.add("Authorization.Token", "must not be empty")
+	}
+
+	if c.Server.Address == "" {
+		verr.add("Server.Address", "must not be empty")
+	}
+	for _, d := range []struct {
+		field string
+		value time.Duration
+	}{
+		{"Server.ReadTimeout", c.Server.ReadTimeout},
+		{"Server.ReadHeaderTimeout", c.Server.ReadHeaderTimeout},
+		{"Server.WriteTimeout", c.Server.WriteTimeout},
+		{"Server.IdleTimeout", c.Server.IdleTimeout},
+		{"Server.ShutdownTimeout", c.Server.ShutdownTimeout},
+		{"Health.CheckTimeout", c.Health.CheckTimeout},
+		{"Health.MaxSnapshotAge", c.Health.MaxSnapshotAge},
+	} {
+		if d.value <= 0 {
+			verr.add(d.field, "must be a positive duration, got %s", d.value)
+		}
+	}
+	if c.Server.MaxHeaderBytes <= 0 {
+		verr.add("Server.MaxHeaderBytes", "must be positive, got %d", c.Server.MaxHeaderBytes)
+	}
+
+	if c.Database.URL != "" {
+		if u, err := url.Parse(c.Database.URL); err != nil || (u.Scheme != "postgres" && u.Scheme != "postgresql") {
+			verr.add("Database.URL", "must be a postgres:// URL")
+		}
+	} else {
+		if c.Database.DBHost == "" {
+			verr.add("Database.Host", "must not be empty")
+		}
+		if _, err := strconv.Atoi(c.Database.DBPort); err != nil {
+			verr.add("Database.Port", "must be a number, got %q", c.Database.DBPort)
+		}
+		if c.Database.DBUser == "" {
+			verr.add("Database.User", "must not be empty")
+		}
+		if c.Database.DBName == "" {
+			verr.add("Database.Name", "must not be empty")
+		}
+	}
+
+	if !isHTTPURL(c.ThirdpartyAPI.BikeURL) {
+		verr.add("ThirdpartAPI.BicycleTrancit", "must be an http(s) URL, got %q", c.ThirdpartyAPI.BikeURL)
+	}
+	if !isHTTPURL(c.ThirdpartyAPI.WeatherURL) {
+		verr.add("ThirdpartAPI.CurrentWeather", "must be an http(s) URL, got %q", c.ThirdpartyAPI.WeatherURL)
+	}
+	if _, err := time.LoadLocation(c.ThirdpartyAPI.TimeZone); err != nil || c.ThirdpartyAPI.TimeZone == "" {
+		verr.add("ThirdpartAPI.TimeZone", "must be an IANA time zone, got %q", c.ThirdpartyAPI.TimeZone)
+	}
+
+	if c.API.V1Deprecated != "" || c.API.V1Sunset != "" {
+		deprecated, derr := time.Parse(time.DateOnly, c.API.V1Deprecated)
+		if derr != nil {
+			verr.add("API.V1Deprecated", "must be a date such as 2026-10-19, got %q", c.API.V1Deprecated)
+		}
+		sunset, serr := time.Parse(time.DateOnly, c.API.V1Sunset)
+		if serr != nil {
+			verr.add("API.V1Sunset", "must be a date such as 2027-04-19, got %q", c.API.V1Sunset)
+		}
+		if derr == nil && serr == nil && !sunset.After(deprecated) {
+			verr.add("API.V1Sunset", "must be after API.V1Deprecated, got %q", c.API.V1Sunset)
+		}
+	}
+
+	switch c.Tracing.Exporter {
+	case "none", "stdout":
+	case "otlp":
+		if c.Tracing.Endpoint == "" {
+			verr.add("Tracing.Endpoint", "is required by the otlp exporter")
+		}
+	default:
+		verr.add("Tracing.Exporter", "must be one of none, stdout, otlp, got %q", c.Tracing.Exporter)
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		verr.add("Tracing.SampleRatio", "must be between 0 and 1, got %v", c.Tracing.SampleRatio)
+	}
+
+	if c.Weather.City == "" {
+		verr.add("Weather.City", "must not be empty")
+	}
+	if c.Scheduler.Interval <= 0 {
+		verr.add("Scheduler.Interval", "must be a positive duration, got %s", c.Scheduler.Interval)
+	}
+	if c.RateLimit.RequestsPerSecond <= 0 {
+		verr.add("RateLimit.RequestsPerSecond", "must be positive, got %v", c.RateLimit.RequestsPerSecond)
+	}
+	if c.RateLimit.Burst <= 0 {
+		verr.add("RateLimit.Burst", "must be positive, got %d", c.RateLimit.Burst)
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.Log.Level)); err != nil {
+		verr.add("Log.Level", "must be one of debug, info, warn, error, got %q", c.Log.Level)
+	}
+	if c.Retention.Days <= 0 {
+		verr.add("Retention.Days", "must be positive, got %d", c.Retention.Days)
+	}
+	switch c.Retention.Mode {
+	case RetentionDrop:
+	case RetentionArchive:
+		if c.Retention.ArchiveDir == "" {
+			verr.add("Retention.ArchiveDir", "is required by the archive mode")
+		}
+	default:
+		verr.add("Retention.Mode", "must be one of drop, archive, got %q", c.Retention.Mode)
+	}
+	if c.Retention.Interval <= 0 {
+		verr.add("Retention.Interval", "must be a positive duration, got %s", c.Retention.Interval)
+	}
+	if c.Retention.PremakeMonths < 0 {
+		verr.add("Retention.PremakeMonths", "must not be negative, got %d", c.Retention.PremakeMonths)
+	}
+	if c.Webhooks.MaxAttempts <= 0 {
+		verr.add("Webhooks.MaxAttempts", "must be positive, got %d", c.Webhooks.MaxAttempts)
+	}
+	for _, d := range []struct {
+		field string
+		value time.Duration
+	}{
+		{"Webhooks.InitialBackoff", c.Webhooks.InitialBackoff},
+		{"Webhooks.MaxBackoff", c.Webhooks.MaxBackoff},
+		{"Webhooks.Timeout", c.Webhooks.Timeout},
+	} {
+		if d.value <= 0 {
+			verr.add(d.field, "must be a positive duration, got %s", d.value)
+		}
+	}
+
+	if c.EBikes.LowBattery < 1 || c.EBikes.LowBattery > 100 {
+		verr.add("EBikes.LowBattery", "must be between 1 and 100, got %d", c.EBikes.LowBattery)
+	}
+	if c.EBikes.LowBatteryLookback <= 0 {
+		verr.add("EBikes.LowBatteryLookback", "must be a positive duration, got %s", c.EBikes.LowBatteryLookback)
+	}
+
+	if len(verr.Fields) > 0 {
+		return verr
+	}
+
+	return nil
+}
+
+func isHTTPURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// Redacted returns a copy of c with tokens, passwords and API keys masked.
+func (c Config) Redacted() Config {
+	if c.Authorization.Token != "" {
+		c.Authorization.Token = redacted
+	}
+	if c.Database.DBPassword != "" {
+		c.Database.DBPassword = redacted
+	}
+	if c.ThirdpartyAPI.APIKey != "" {
+		c.ThirdpartyAPI.APIKey = redacted
+	}
+	if u, err := url.Parse(c.Database.URL); err == nil && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), redacted)
+			c.Database.URL = u.String()
+		}
 	}
+	return c
 }
 
-func LoadAuthorization() string {
-	v := Config()
-	return v.GetString("Authorization.Token")
+// Dump writes the redacted configuration to w as indented JSON.
+func (c *Config) Dump(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(c.Redacted())
 }
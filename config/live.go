@@ -0,0 +1,102 @@
+package config
+
+import (
+	"log/slog"
+	"os"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+// Snapshot is one immutable version of the configuration.
+type Snapshot struct {
+	Version  int64     `json:"version"`
+	LoadedAt time.Time `json:"loadedAt"`
+	Config   *Config   `json:"-"`
+}
+
+// Live holds the active configuration and swaps it atomically when the
+// config file changes. Only RuntimeConfig is taken from a reloaded file;
+// the remaining sections keep their start-up values.
+type Live struct {
+	path      string
+	base      Config
+	current   atomic.Pointer[Snapshot]
+	mu        sync.Mutex
+	listeners []func(*Snapshot)
+}
+
+func NewLive(path string, cfg *Config) *Live {
+	l := &Live{path: path, base: *cfg}
+	l.current.Store(&Snapshot{Version: 1, LoadedAt: time.Now(), Config: cfg})
+	return l
+}
+
+// Current returns the active snapshot. Callers should read it once per
+// operation so they see a consistent configuration.
+func (l *Live) Current() *Snapshot {
+	return l.current.Load()
+}
+
+// OnChange registers fn to be called with every new snapshot.
+func (l *Live) OnChange(fn func(*Snapshot)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.listeners = append(l.listeners, fn)
+}
+
+// Watch reloads the configuration whenever the file changes.
+func (l *Live) Watch() {
+	if _, err := os.Stat(l.path); err != nil {
+		return
+	}
+
+	v := viper.New()
+	v.SetConfigFile(l.path)
+	v.OnConfigChange(func(e fsnotify.Event) {
+		if _, err := l.Reload(); err != nil {
+			slog.Error("config reload rejected", "path", l.path, "error", err)
+		}
+	})
+	v.WatchConfig()
+}
+
+// Reload re-reads and validates the configuration. An invalid file leaves
+// the active snapshot untouched. It reports whether a new version was
+// installed.
+func (l *Live) Reload() (bool, error) {
+	loaded, err := Load(l.path)
+	if err != nil {
+		return false, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	prev := l.current.Load()
+	if reflect.DeepEqual(prev.Config.RuntimeConfig, loaded.RuntimeConfig) {
+		return false, nil
+	}
+
+	restartOnly := *loaded
+	restartOnly.RuntimeConfig = l.base.RuntimeConfig
+	if !reflect.DeepEqual(restartOnly, l.base) {
+		slog.Warn("config changes outside the runtime sections require a restart", "path", l.path)
+	}
+
+	next := l.base
+	next.RuntimeConfig = loaded.RuntimeConfig
+	snap := &Snapshot{Version: prev.Version + 1, LoadedAt: time.Now(), Config: &next}
+	l.current.Store(snap)
+
+	slog.Info("config reloaded", "version", snap.Version)
+	for _, fn := range l.listeners {
+		fn(snap)
+	}
+
+	return true, nil
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLiveReload(t *testing.T) {
+	path := writeConfig(t, testConfig)
+	cfg, err := Load(path)
+	require.NoError(t, err)
+
+	live := NewLive(path, cfg)
+	var notified *Snapshot
+	live.OnChange(func(snap *Snapshot) { notified = snap })
+
+	changed := strings.Replace(testConfig, `Host: "postgres"`, `Host: "elsewhere"`, 1) + `
+Scheduler:
+  Enabled: true
+  Interval: "30s"
+Weather:
+  City: "Pittsburgh"
+`
+	require.NoError(t, os.WriteFile(path, []byte(changed), 0o600))
+
+	ok, err := live.Reload()
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	snap := live.Current()
+	assert.Equal(t, int64(2), snap.Version)
+	assert.Same(t, snap, notified)
+	assert.True(t, snap.Config.Scheduler.Enabled)
+	assert.Equal(t, 30*time.Second, snap.Config.Scheduler.Interval)
+	assert.Equal(t, "Pittsburgh", snap.Config.Weather.City)
+	assert.Equal(t, "postgres", snap.Config.Database.DBHost, "restart-only sections must not change")
+}
+
+func TestLiveReloadUnchanged(t *testing.T) {
+	path := writeConfig(t, testConfig)
+	cfg, err := Load(path)
+	require.NoError(t, err)
+
+	live := NewLive(path, cfg)
+	ok, err := live.Reload()
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, int64(1), live.Current().Version)
+}
+
+func TestLiveReloadInvalidKeepsCurrent(t *testing.T) {
+	path := writeConfig(t, testConfig)
+	cfg, err := Load(path)
+	require.NoError(t, err)
+
+	live := NewLive(path, cfg)
+	invalid := strings.Replace(testConfig, "Server:", "Log:\n  Level: \"loud\"\nServer:", 1)
+	require.NoError(t, os.WriteFile(path, []byte(invalid), 0o600))
+
+	_, err = live.Reload()
+	assert.Error(t, err)
+	assert.Equal(t, int64(1), live.Current().Version)
+	assert.Equal(t, "info", live.Current().Config.Log.Level)
+}
@@ -1,9 +1,69 @@
 package database
 
-import "github.com/macadrich/go-bike/database/models"
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/macadrich/go-bike/database/models"
+	"github.com/macadrich/go-bike/pkg/apperr"
+)
+
+// ErrNotFound matches the errors returned when a record looked up by id
+// does not exist, which are apperr.NotFound errors naming the record.
+var ErrNotFound = apperr.ErrNotFound
 
 type Database interface {
-	InsertStation(lastUpdated string, station *models.Stations) error
-	QueryAllStation(lastUpdate string) ([]models.Stations, error)
-	QuerySpecificStation(kioskId int, lastUpdate string) (*models.Stations, error)
+	InsertStation(ctx context.Context, lastUpdated time.Time, loc *time.Location, station *models.Stations) (bool, error)
+	QueryAllStation(ctx context.Context, lastUpdate time.Time) ([]models.Stations, error)
+	QuerySpecificStation(ctx context.Context, kioskId int, lastUpdate time.Time) (*models.Stations, error)
+	QuerySnapshot(ctx context.Context, at time.Time) ([]models.Stations, error)
+
+	IngestionExists(ctx context.Context, at time.Time) (bool, error)
+	RecordIngestion(ctx context.Context, ingestion *models.Ingestion) error
+	ListIngestions(ctx context.Context, before time.Time, limit int) ([]models.Ingestion, error)
+	NextIngestion(ctx context.Context, at time.Time) (time.Time, error)
+
+	PreviousStatuses(ctx context.Context, before time.Time) (map[int]models.Stations, error)
+	InsertEvents(ctx context.Context, events []models.StationEvent) ([]models.StationEvent, error)
+	QueryEvents(ctx context.Context, since time.Time, kioskId int, afterId int64, limit int) ([]models.StationEvent, error)
+
+	CreateWebhook(ctx context.Context, sub *models.WebhookSubscription) error
+	GetWebhook(ctx context.Context, id int64) (*models.WebhookSubscription, error)
+	ListWebhooks(ctx context.Context) ([]models.WebhookSubscription, error)
+	DeleteWebhook(ctx context.Context, id int64) error
+	CreateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error
+	UpdateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error
+	GetDelivery(ctx context.Context, id int64) (*models.WebhookDelivery, error)
+	ListDeliveries(ctx context.Context, subscriptionId int64, status string, beforeId int64, limit int) ([]models.WebhookDelivery, error)
+
+	StationHistory(ctx context.Context, kioskId int, from, to time.Time, resolution string) ([]models.HistoryPoint, error)
+	FillRatios(ctx context.Context, buckets []time.Time) (map[int]float64, error)
+
+	DockMoves(ctx context.Context, from, to time.Time, kioskId int, fn func(models.DockMove) error) error
+	StationCoordinates(ctx context.Context) (map[int]models.Coordinate, error)
+
+	EBikesAt(ctx context.Context, at time.Time) ([]models.Bike, error)
+	LowBatteryBikes(ctx context.Context, at time.Time, threshold int, since time.Time) ([]models.LowBatteryBike, error)
+	DockBatteryHistory(ctx context.Context, kioskId, dockNumber int, from, to time.Time) ([]models.DockBatteryPoint, error)
+
+	InsertWeather(ctx context.Context, obs *models.WeatherObservation) error
+	WeatherHistory(ctx context.Context, from, to time.Time) ([]models.WeatherObservation, error)
+	NearestWeather(ctx context.Context, at time.Time) (*models.WeatherObservation, error)
+
+	Export(ctx context.Context, dataset string, from, to time.Time, kioskId int, fn func(row []any) error) error
+
+	CreateAPIKey(ctx context.Context, key *models.APIKey) error
+	UseAPIKey(ctx context.Context, keyHash string) (*models.APIKey, error)
+
+	Ping(ctx context.Context) error
+	SchemaVersion(ctx context.Context) (version int, dirty bool, err error)
+	LatestSnapshot(ctx context.Context) (time.Time, error)
+
+	EnsurePartitions(ctx context.Context, from, through time.Time) error
+	Partitions(ctx context.Context) ([]models.Partition, error)
+	ArchivePartition(ctx context.Context, partition models.Partition, w io.Writer) error
+	DropPartition(ctx context.Context, partition models.Partition) error
+
+	Close() error
 }
@@ -0,0 +1,161 @@
+package migrate
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"testing/fstest"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/macadrich/go-bike/migrations"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testFS = fstest.MapFS{
+	"000001_first.up.sql":    {Data: []byte("CREATE TABLE first (id INT)")},
+	"000001_first.down.sql":  {Data: []byte("DROP TABLE first")},
+	"000002_second.up.sql":   {Data: []byte("CREATE TABLE second (id INT)")},
+	"000002_second.down.sql": {Data: []byte("DROP TABLE second")},
+}
+
+func expectLock(mock sqlmock.Sqlmock) {
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_lock($1)")).WithArgs(lockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE IF NOT EXISTS schema_migrations")).WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+func expectUnlock(mock sqlmock.Sqlmock) {
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_unlock($1)")).WithArgs(lockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+func expectVersion(mock sqlmock.Sqlmock, version int, dirty bool) {
+	rows := sqlmock.NewRows([]string{"version", "dirty"})
+	if version > 0 || dirty {
+		rows.AddRow(version, dirty)
+	}
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT version, dirty FROM schema_migrations LIMIT 1")).WillReturnRows(rows)
+}
+
+func expectSetVersion(mock sqlmock.Sqlmock, version int, dirty bool) {
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM schema_migrations")).WillReturnResult(sqlmock.NewResult(0, 1))
+	if version == 0 && !dirty {
+		return
+	}
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO schema_migrations (version, dirty) VALUES ($1, $2)")).WithArgs(version, dirty).WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func expectApply(mock sqlmock.Sqlmock, version int, script string, next int) {
+	expectSetVersion(mock, version, true)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(script)).WillReturnResult(sqlmock.NewResult(0, 0))
+	expectSetVersion(mock, next, false)
+	mock.ExpectCommit()
+}
+
+func TestLoadEmbedded(t *testing.T) {
+	list, err := Load(migrations.FS)
+	require.NoError(t, err)
+	require.NotEmpty(t, list)
+
+	for i, m := range list {
+		assert.NotEmpty(t, m.Up, "migration %d up", m.Version)
+		assert.NotEmpty(t, m.Down, "migration %d down", m.Version)
+		if i > 0 {
+			assert.Greater(t, m.Version, list[i-1].Version)
+		}
+	}
+	assert.Equal(t, "stations", list[0].Name)
+}
+
+func TestLoadMissingDown(t *testing.T) {
+	_, err := Load(fstest.MapFS{
+		"000001_first.up.sql": {Data: []byte("CREATE TABLE first (id INT)")},
+	})
+	assert.Error(t, err)
+}
+
+func TestUp(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	m, err := New(db, testFS)
+	require.NoError(t, err)
+
+	expectLock(mock)
+	expectVersion(mock, 0, false)
+	expectApply(mock, 1, "CREATE TABLE first (id INT)", 1)
+	expectApply(mock, 2, "CREATE TABLE second (id INT)", 2)
+	expectUnlock(mock)
+
+	applied, err := m.Up(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, applied)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpDirty(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	m, err := New(db, testFS)
+	require.NoError(t, err)
+
+	expectLock(mock)
+	expectVersion(mock, 2, true)
+	expectUnlock(mock)
+
+	_, err = m.Up(context.TODO())
+	assert.ErrorIs(t, err, ErrDirty)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDown(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	m, err := New(db, testFS)
+	require.NoError(t, err)
+
+	expectLock(mock)
+	expectVersion(mock, 2, false)
+	expectApply(mock, 2, "DROP TABLE second", 1)
+	expectUnlock(mock)
+
+	reverted, err := m.Down(context.TODO(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, reverted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStatus(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	m, err := New(db, testFS)
+	require.NoError(t, err)
+
+	expectLock(mock)
+	expectVersion(mock, 1, false)
+	expectUnlock(mock)
+
+	status, err := m.Status(context.TODO())
+	require.NoError(t, err)
+	assert.Equal(t, 1, status.Version)
+	assert.Equal(t, 1, status.Pending())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestForceUnknownVersion(t *testing.T) {
+	db, _, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	m, err := New(db, testFS)
+	require.NoError(t, err)
+
+	assert.Error(t, m.Force(context.TODO(), 42))
+}
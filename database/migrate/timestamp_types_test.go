@@ -0,0 +1,111 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	_ "github.com/lib/pq"
+	"github.com/macadrich/go-bike/migrations"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testDatabase connects to the Postgres database named by
+// GOBIKE_TEST_DATABASE_URL, in a schema of its own that is dropped after the
+// test. Tests using it are skipped when the variable is not set.
+func testDatabase(t *testing.T) *sql.DB {
+	dsn := os.Getenv("GOBIKE_TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("GOBIKE_TEST_DATABASE_URL is not set")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	require.NoError(t, err)
+	// A single connection keeps the search path and time zone below.
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	schema := fmt.Sprintf("migrate_test_%d", time.Now().UnixNano())
+	_, err = db.Exec("CREATE SCHEMA " + schema + "; SET search_path TO " + schema)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Exec("DROP SCHEMA " + schema + " CASCADE") })
+
+	return db
+}
+
+// migrationsThrough returns the embedded migrations up to version.
+func migrationsThrough(t *testing.T, version int) fs.FS {
+	entries, err := fs.ReadDir(migrations.FS, ".")
+	require.NoError(t, err)
+
+	fsys := fstest.MapFS{}
+	for _, e := range entries {
+		v, err := strconv.Atoi(e.Name()[:6])
+		if err != nil || v > version {
+			continue
+		}
+		data, err := fs.ReadFile(migrations.FS, e.Name())
+		require.NoError(t, err)
+		fsys[e.Name()] = &fstest.MapFile{Data: data}
+	}
+	return fsys
+}
+
+func migrateTo(t *testing.T, db *sql.DB, version int) {
+	m, err := New(db, migrationsThrough(t, version))
+	require.NoError(t, err)
+	_, err = m.Up(context.TODO())
+	require.NoError(t, err)
+}
+
+func TestTimestampTypesUsesStationTimeZone(t *testing.T) {
+	db := testDatabase(t)
+	migrateTo(t, db, 5)
+
+	// The conversion must not depend on the session time zone.
+	_, err := db.Exec("SET TIME ZONE 'Asia/Tokyo'")
+	require.NoError(t, err)
+
+	insert := `INSERT INTO station_info (
+		kiosk_id, valid_from, name, is_event_based, is_virtual, latitude, longitude,
+		address_street, address_city, address_state, address_zipcode,
+		close_time, event_end, event_start, notes, open_time, public_text, timezone
+	) VALUES ($1, '2024-05-14 06:48:00', 'Welcome Park', false, false, 39.94, -75.14,
+		'', 'Philadelphia', 'PA', '19106', $2, $3, $4, '', $5, '', $6)`
+	_, err = db.Exec(insert, 3005, "noon", "2024-05-14T10:00:00Z", "2024-05-14T08:00:00", "06:00", "America/Los_Angeles")
+	require.NoError(t, err)
+	_, err = db.Exec(insert, 3006, "", "2024-05-14T08:00:00", "TBD", "", "")
+	require.NoError(t, err)
+
+	migrateTo(t, db, 6)
+
+	type row struct {
+		eventStart, eventEnd sql.NullTime
+		openTime, closeTime  sql.NullString
+	}
+	read := func(kioskId int) row {
+		var r row
+		err := db.QueryRow(`SELECT event_start, event_end, open_time::TEXT, close_time::TEXT
+			FROM station_info WHERE kiosk_id = $1`, kioskId).Scan(&r.eventStart, &r.eventEnd, &r.openTime, &r.closeTime)
+		require.NoError(t, err)
+		return r
+	}
+
+	pacific := read(3005)
+	assert.Equal(t, time.Date(2024, 5, 14, 15, 0, 0, 0, time.UTC), pacific.eventStart.Time.UTC())
+	assert.Equal(t, time.Date(2024, 5, 14, 10, 0, 0, 0, time.UTC), pacific.eventEnd.Time.UTC())
+	assert.Equal(t, sql.NullString{String: "06:00:00", Valid: true}, pacific.openTime)
+	assert.False(t, pacific.closeTime.Valid, "unparseable times become NULL")
+
+	fallback := read(3006)
+	assert.False(t, fallback.eventStart.Valid, "unparseable times become NULL")
+	assert.Equal(t, time.Date(2024, 5, 14, 12, 0, 0, 0, time.UTC), fallback.eventEnd.Time.UTC())
+	assert.False(t, fallback.openTime.Valid)
+}
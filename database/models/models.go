@@ -1,7 +1,12 @@
 package models
 
+import (
+	"encoding/json"
+	"time"
+)
+
 type Snapshots struct {
-	At       string     `json:"at"`
+	At       time.Time  `json:"at"`
 	Stations Stations   `json:"stations"`
 	Weather  WeatherMap `json:"weather"`
 }
@@ -31,7 +36,7 @@ type Weather struct {
 }
 
 type Rain struct {
-	Hour float32
+	Hour float32 `json:"Hour"`
 }
 
 type Clouds struct {
@@ -70,40 +75,40 @@ type CurrentWeather struct {
 }
 
 type Stations struct {
-	Id                     int       `json:"id"`
-	At                     string    `json:"at"`
-	IsEventBased           bool      `json:"isEventBased"`
-	IsVirtual              bool      `json:"isVirtual"`
-	KioskId                int       `json:"kioskId"`
-	TrikesAvailable        int       `json:"trikesAvailable"`
-	TotalDocks             int       `json:"totalDocks"`
-	DocksAvailable         int       `json:"docksAvailable"`
-	BikesAvailable         int       `json:"bikesAvailable"`
-	ClassicBikesAvailable  int       `json:"classicBikesAvailable"`
-	SmartBikesAvailable    int       `json:"smartBikesAvailable"`
-	ElectricBikesAvailable int       `json:"electricBikesAvailable"`
-	RewardBikesAvailable   int       `json:"rewardBikesAvailable"`
-	RewardDocksAvailable   int       `json:"rewardDocksAvailable"`
-	KioskType              int       `json:"kioskType"`
-	Latitude               float64   `json:"latitude"`
-	Longitude              float64   `json:"longitude"`
-	Name                   string    `json:"name"`
-	KioskStatus            string    `json:"kiokStatus"`
-	KioskPublicStatus      string    `json:"kioskPublicStatus"`
-	KioskConnectionStatus  string    `json:"kioskConnectionStatus"`
-	AddressStreet          string    `json:"addressStreet"`
-	AddressCity            string    `json:"addressCity"`
-	AddressState           string    `json:"addressState"`
-	AddressZipCode         string    `json:"addressZipCode"`
-	CloseTime              string    `json:"closeTime"`
-	EventEnd               string    `json:"eventEnd"`
-	EventStart             string    `json:"eventStart"`
-	Notes                  string    `json:"notes"`
-	OpenTime               string    `json:"openTime"`
-	PublicText             string    `json:"publicText"`
-	TimeZone               string    `json:"timeZone"`
-	Coordinates            []float64 `json:"coordinates"`
-	Bikes                  []Bike    `json:"bikes"`
+	Id                     int        `json:"id"`
+	At                     time.Time  `json:"at"`
+	IsEventBased           bool       `json:"isEventBased"`
+	IsVirtual              bool       `json:"isVirtual"`
+	KioskId                int        `json:"kioskId"`
+	TrikesAvailable        int        `json:"trikesAvailable"`
+	TotalDocks             int        `json:"totalDocks"`
+	DocksAvailable         int        `json:"docksAvailable"`
+	BikesAvailable         int        `json:"bikesAvailable"`
+	ClassicBikesAvailable  int        `json:"classicBikesAvailable"`
+	SmartBikesAvailable    int        `json:"smartBikesAvailable"`
+	ElectricBikesAvailable int        `json:"electricBikesAvailable"`
+	RewardBikesAvailable   int        `json:"rewardBikesAvailable"`
+	RewardDocksAvailable   int        `json:"rewardDocksAvailable"`
+	KioskType              int        `json:"kioskType"`
+	Latitude               float64    `json:"latitude"`
+	Longitude              float64    `json:"longitude"`
+	Name                   string     `json:"name"`
+	KioskStatus            string     `json:"kiokStatus"`
+	KioskPublicStatus      string     `json:"kioskPublicStatus"`
+	KioskConnectionStatus  string     `json:"kioskConnectionStatus"`
+	AddressStreet          string     `json:"addressStreet"`
+	AddressCity            string     `json:"addressCity"`
+	AddressState           string     `json:"addressState"`
+	AddressZipCode         string     `json:"addressZipCode"`
+	CloseTime              *time.Time `json:"closeTime" extensions:"x-nullable"`
+	EventEnd               *time.Time `json:"eventEnd" extensions:"x-nullable"`
+	EventStart             *time.Time `json:"eventStart" extensions:"x-nullable"`
+	Notes                  string     `json:"notes"`
+	OpenTime               *time.Time `json:"openTime" extensions:"x-nullable"`
+	PublicText             string     `json:"publicText"`
+	TimeZone               string     `json:"timeZone"`
+	Coordinates            []float64  `json:"coordinates"`
+	Bikes                  []Bike     `json:"bikes"`
 }
 
 type Geometry struct {
@@ -128,13 +133,285 @@ type Coordinate struct {
 
 // responses
 type StationsResponse struct {
-	At       string     `json:"at"`
+	At       time.Time  `json:"at"`
 	Stations []Stations `json:"stations"`
 	Weather  WeatherMap `json:"weather"`
 }
 
 type StationResponse struct {
-	At      string     `json:"at"`
+	At      time.Time  `json:"at"`
 	Station Stations   `json:"stations"`
 	Weather WeatherMap `json:"weather"`
 }
+
+// Partition is one monthly partition of a snapshot table, holding the rows
+// with From <= at < To.
+type Partition struct {
+	Table string    `json:"table"`
+	Name  string    `json:"name"`
+	From  time.Time `json:"from"`
+	To    time.Time `json:"to"`
+}
+
+// Resolutions of a station history. Hour and day are read from the rollup
+// tables maintained on ingestion.
+const (
+	ResolutionRaw  = "raw"
+	ResolutionHour = "hour"
+	ResolutionDay  = "day"
+)
+
+// HistoryPoint aggregates the snapshots of one kiosk from At until the next
+// point. Raw points are a single snapshot, so min, max and average agree.
+type HistoryPoint struct {
+	At           time.Time `json:"at"`
+	Samples      int       `json:"samples"`
+	BikesMin     int       `json:"bikesMin"`
+	BikesMax     int       `json:"bikesMax"`
+	BikesAvg     float64   `json:"bikesAvg"`
+	DocksMin     int       `json:"docksMin"`
+	DocksMax     int       `json:"docksMax"`
+	DocksAvg     float64   `json:"docksAvg"`
+	EBikesMin    int       `json:"ebikesMin"`
+	EBikesMax    int       `json:"ebikesMax"`
+	EBikesAvg    float64   `json:"ebikesAvg"`
+	MinutesEmpty float64   `json:"minutesEmpty"`
+	MinutesFull  float64   `json:"minutesFull"`
+}
+
+type StationHistory struct {
+	KioskId    int            `json:"kioskId"`
+	From       time.Time      `json:"from"`
+	To         time.Time      `json:"to"`
+	Resolution string         `json:"resolution" enums:"raw,hour,day"`
+	Points     []HistoryPoint `json:"points"`
+}
+
+// Types of StationEvent. A recovered event names the condition that ended
+// in Recovered.
+const (
+	EventBecameEmpty = "became_empty"
+	EventBecameFull  = "became_full"
+	EventWentOffline = "went_offline"
+	EventRecovered   = "recovered"
+)
+
+// StationEvent is a change of state of a kiosk between two snapshots.
+type StationEvent struct {
+	Id                    int64     `json:"id"`
+	KioskId               int       `json:"kioskId"`
+	At                    time.Time `json:"at"`
+	Type                  string    `json:"type" enums:"became_empty,became_full,went_offline,recovered"`
+	Recovered             string    `json:"recovered,omitempty" enums:"offline,empty,full"`
+	BikesAvailable        int       `json:"bikesAvailable"`
+	DocksAvailable        int       `json:"docksAvailable"`
+	KioskConnectionStatus string    `json:"kioskConnectionStatus"`
+}
+
+// WebhookSubscription receives the station events of the given types and
+// kiosks, or all of them when the lists are empty. Secret is only returned
+// when the subscription is created.
+type WebhookSubscription struct {
+	Id         int64     `json:"id"`
+	URL        string    `json:"url"`
+	Secret     string    `json:"secret,omitempty"`
+	EventTypes []string  `json:"eventTypes"`
+	KioskIds   []int64   `json:"kioskIds"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// Statuses of a WebhookDelivery.
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// WebhookDelivery records the attempts to send one event to one
+// subscription.
+type WebhookDelivery struct {
+	Id             int64           `json:"id"`
+	SubscriptionId int64           `json:"subscriptionId"`
+	EventId        int64           `json:"eventId"`
+	EventType      string          `json:"eventType"`
+	Payload        json.RawMessage `json:"payload" swaggertype:"object"`
+	Status         string          `json:"status" enums:"pending,succeeded,failed"`
+	Attempts       int             `json:"attempts"`
+	ResponseStatus int             `json:"responseStatus"`
+	LastError      string          `json:"lastError,omitempty"`
+	CreatedAt      time.Time       `json:"createdAt"`
+	UpdatedAt      time.Time       `json:"updatedAt"`
+}
+
+// StationUpdate is the live availability of a kiosk, streamed whenever it
+// changes.
+type StationUpdate struct {
+	KioskId                int       `json:"kioskId"`
+	At                     time.Time `json:"at"`
+	Latitude               float64   `json:"latitude"`
+	Longitude              float64   `json:"longitude"`
+	BikesAvailable         int       `json:"bikesAvailable"`
+	DocksAvailable         int       `json:"docksAvailable"`
+	ClassicBikesAvailable  int       `json:"classicBikesAvailable"`
+	SmartBikesAvailable    int       `json:"smartBikesAvailable"`
+	ElectricBikesAvailable int       `json:"electricBikesAvailable"`
+	KioskPublicStatus      string    `json:"kioskPublicStatus"`
+	KioskConnectionStatus  string    `json:"kioskConnectionStatus"`
+}
+
+// DockMove counts the bikes that left and arrived at a kiosk's docks
+// between two consecutive snapshots.
+type DockMove struct {
+	KioskId    int
+	PrevAt     time.Time
+	At         time.Time
+	Departures int
+	Arrivals   int
+}
+
+// StationFlow estimates the bikes that left and arrived at a kiosk during
+// the interval starting at Bucket.
+type StationFlow struct {
+	KioskId    int       `json:"kioskId"`
+	Bucket     time.Time `json:"bucket"`
+	Departures int       `json:"departures"`
+	Arrivals   int       `json:"arrivals"`
+}
+
+type TripFlows struct {
+	From     time.Time     `json:"from"`
+	To       time.Time     `json:"to"`
+	Interval string        `json:"interval"`
+	Flows    []StationFlow `json:"flows"`
+}
+
+// ODPair is the estimated number of trips from Origin to Destination.
+type ODPair struct {
+	Origin      int     `json:"origin"`
+	Destination int     `json:"destination"`
+	Trips       float64 `json:"trips"`
+	DistanceKm  float64 `json:"distanceKm"`
+}
+
+type ODMatrix struct {
+	From       time.Time `json:"from"`
+	To         time.Time `json:"to"`
+	Departures int       `json:"departures"`
+	Arrivals   int       `json:"arrivals"`
+	Pairs      []ODPair  `json:"pairs"`
+}
+
+// BatteryDistribution summarises the charge of the e-bikes docked at one
+// kiosk, or in the whole system when KioskId is zero. Buckets[i] counts the
+// batteries between 10*i and 10*i+9 percent, the last one up to 100.
+type BatteryDistribution struct {
+	KioskId int     `json:"kioskId,omitempty"`
+	Count   int     `json:"count"`
+	Min     int     `json:"min"`
+	Max     int     `json:"max"`
+	Mean    float64 `json:"mean"`
+	Median  float64 `json:"median"`
+	Buckets [10]int `json:"buckets"`
+}
+
+type EBikeBattery struct {
+	At       time.Time             `json:"at"`
+	System   BatteryDistribution   `json:"system"`
+	Stations []BatteryDistribution `json:"stations"`
+}
+
+// LowBatteryBike is an e-bike below the low-battery threshold in the
+// latest snapshot. LowSince is the first snapshot from which its dock has
+// held a low e-bike without interruption, bounded by the lookback.
+type LowBatteryBike struct {
+	KioskId       int       `json:"kioskId"`
+	DockNumber    int       `json:"dockNumber"`
+	Battery       int       `json:"battery"`
+	LowSince      time.Time `json:"lowSince"`
+	LowForMinutes int       `json:"lowForMinutes"`
+}
+
+type LowBatteryReport struct {
+	At        time.Time        `json:"at"`
+	Threshold int              `json:"threshold"`
+	Bikes     []LowBatteryBike `json:"bikes"`
+}
+
+// DockBatteryPoint is the content of a dock in one snapshot. Battery is
+// only set when an e-bike is docked.
+type DockBatteryPoint struct {
+	At       time.Time `json:"at"`
+	Docked   bool      `json:"docked"`
+	Electric bool      `json:"electric"`
+	Battery  *int      `json:"battery" extensions:"x-nullable"`
+}
+
+type DockBatteryHistory struct {
+	KioskId    int                `json:"kioskId"`
+	DockNumber int                `json:"dockNumber"`
+	From       time.Time          `json:"from"`
+	To         time.Time          `json:"to"`
+	Points     []DockBatteryPoint `json:"points"`
+}
+
+// WeatherObservation is the weather reported at At. Condition is the
+// OpenWeather group, e.g. Clear, Clouds or Rain.
+type WeatherObservation struct {
+	At          time.Time `json:"at"`
+	Condition   string    `json:"condition"`
+	Description string    `json:"description"`
+	Temperature float64   `json:"temperature"`
+	FeelsLike   float64   `json:"feelsLike"`
+	Humidity    int       `json:"humidity"`
+	WindSpeed   float64   `json:"windSpeed"`
+	Clouds      int       `json:"clouds"`
+}
+
+// ForecastRange is a prediction with the bounds of its confidence band.
+type ForecastRange struct {
+	Predicted float64 `json:"predicted"`
+	Low       float64 `json:"low"`
+	High      float64 `json:"high"`
+}
+
+// StationForecast predicts the availability of a kiosk at Target from its
+// snapshot at At. Low and High bound the Confidence band; Samples is the
+// number of past hours the seasonal baseline of Target is based on.
+type StationForecast struct {
+	KioskId      int           `json:"kioskId"`
+	At           time.Time     `json:"at"`
+	Target       time.Time     `json:"target"`
+	Horizon      string        `json:"horizon"`
+	Bikes        ForecastRange `json:"bikes"`
+	Docks        ForecastRange `json:"docks"`
+	ChanceOfBike float64       `json:"chanceOfBike"`
+	ChanceOfDock float64       `json:"chanceOfDock"`
+	Confidence   float64       `json:"confidence"`
+	Samples      int           `json:"samples"`
+	Weather      string        `json:"weather,omitempty"`
+}
+
+// Sources of ingested snapshots.
+const (
+	SourceLive   = "live"
+	SourceImport = "import"
+)
+
+// Ingestion records a stored feed snapshot: its last_updated time, where
+// it came from and how many stations it held.
+type Ingestion struct {
+	At         time.Time `json:"at"`
+	Source     string    `json:"source"`
+	Stations   int       `json:"stations"`
+	IngestedAt time.Time `json:"ingestedAt"`
+}
+
+// APIKey is a named credential for the HTTP API. Only the hash of the key
+// is stored.
+type APIKey struct {
+	Id         int64      `json:"id"`
+	Name       string     `json:"name"`
+	KeyHash    string     `json:"-"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+}
@@ -0,0 +1,54 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/macadrich/go-bike/database/models"
+	"github.com/macadrich/go-bike/pkg/apperr"
+	"github.com/macadrich/go-bike/pkg/tracing"
+)
+
+// CreateAPIKey stores key and sets its id and creation time.
+func (p *postgresDB) CreateAPIKey(ctx context.Context, key *models.APIKey) (err error) {
+	ctx, span := startSpan(ctx, "CreateAPIKey")
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		INSERT INTO api_keys (name, key_hash)
+		VALUES($1,$2)
+		RETURNING id, created_at
+	`
+	err = p.db.QueryRowContext(ctx, query, key.Name, key.KeyHash).Scan(&key.Id, &key.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("error inserting api key: %w", err)
+	}
+
+	return nil
+}
+
+// UseAPIKey looks up the key with the given hash and records that it was
+// used. Unknown keys are not found.
+func (p *postgresDB) UseAPIKey(ctx context.Context, keyHash string) (_ *models.APIKey, err error) {
+	ctx, span := startSpan(ctx, "UseAPIKey")
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		UPDATE api_keys SET last_used_at = now()
+		WHERE key_hash = $1
+		RETURNING id, name, key_hash, created_at, last_used_at
+	`
+	var key models.APIKey
+	err = p.db.QueryRowContext(ctx, query, keyHash).
+		Scan(&key.Id, &key.Name, &key.KeyHash, &key.CreatedAt, &key.LastUsedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, apperr.NotFound("api_key", "API key not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+
+	return &key, nil
+}
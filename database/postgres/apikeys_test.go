@@ -0,0 +1,67 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/macadrich/go-bike/database"
+	"github.com/macadrich/go-bike/database/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testKeyHash = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+
+func TestCreateAPIKey(t *testing.T) {
+	db, mock := NewMock()
+	postgres := &postgresDB{db}
+	defer postgres.db.Close()
+
+	created := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO api_keys (name, key_hash)")).
+		WithArgs("dashboard", testKeyHash).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(7, created))
+
+	key := &models.APIKey{Name: "dashboard", KeyHash: testKeyHash}
+	require.NoError(t, postgres.CreateAPIKey(context.TODO(), key))
+	assert.Equal(t, int64(7), key.Id)
+	assert.Equal(t, created, key.CreatedAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUseAPIKey(t *testing.T) {
+	db, mock := NewMock()
+	postgres := &postgresDB{db}
+	defer postgres.db.Close()
+
+	created := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	used := created.Add(time.Hour)
+	mock.ExpectQuery(regexp.QuoteMeta("UPDATE api_keys SET last_used_at = now()")).
+		WithArgs(testKeyHash).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "key_hash", "created_at", "last_used_at"}).
+			AddRow(7, "dashboard", testKeyHash, created, used))
+
+	key, err := postgres.UseAPIKey(context.TODO(), testKeyHash)
+	require.NoError(t, err)
+	assert.Equal(t, "dashboard", key.Name)
+	require.NotNil(t, key.LastUsedAt)
+	assert.Equal(t, used, *key.LastUsedAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUseAPIKeyUnknown(t *testing.T) {
+	db, mock := NewMock()
+	postgres := &postgresDB{db}
+	defer postgres.db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta("UPDATE api_keys SET last_used_at = now()")).
+		WithArgs(testKeyHash).WillReturnError(sql.ErrNoRows)
+
+	_, err := postgres.UseAPIKey(context.TODO(), testKeyHash)
+	assert.ErrorIs(t, err, database.ErrNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,132 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/macadrich/go-bike/database/models"
+	"github.com/macadrich/go-bike/pkg/tracing"
+)
+
+// EBikesAt returns the e-bikes docked in the snapshot taken at at.
+func (p *postgresDB) EBikesAt(ctx context.Context, at time.Time) (_ []models.Bike, err error) {
+	ctx, span := startSpan(ctx, "EBikesAt")
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		SELECT id, kiosk_id, at, dock_number, is_electric, is_available, COALESCE(battery, 0)
+		FROM bikes
+		WHERE at = $1 AND is_electric
+		ORDER BY kiosk_id, dock_number
+	`
+	rows, err := p.db.QueryContext(ctx, query, at)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer rows.Close()
+
+	bikes := []models.Bike{}
+	for rows.Next() {
+		bike, _, err := scanBike(rows)
+		if err != nil {
+			return nil, err
+		}
+		bikes = append(bikes, bike)
+	}
+
+	return bikes, rows.Err()
+}
+
+// LowBatteryBikes returns the e-bikes below threshold in the snapshot taken
+// at at. For each, LowSince is the first snapshot of its kiosk after the
+// last one, no earlier than since, in which the dock did not hold an e-bike
+// below threshold. Bikes low for longest come first.
+func (p *postgresDB) LowBatteryBikes(ctx context.Context, at time.Time, threshold int, since time.Time) (_ []models.LowBatteryBike, err error) {
+	ctx, span := startSpan(ctx, "LowBatteryBikes")
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		WITH low AS (
+			SELECT kiosk_id, dock_number, COALESCE(battery, 0) AS battery
+			FROM bikes
+			WHERE at = $1 AND is_electric AND COALESCE(battery, 0) < $2
+		)
+		SELECT l.kiosk_id, l.dock_number, l.battery, (
+			SELECT MIN(s.at) FROM station_status s
+			WHERE s.kiosk_id = l.kiosk_id AND s.at >= $3 AND s.at <= $1
+				AND s.at > COALESCE((
+					SELECT MAX(x.at) FROM station_status x
+					WHERE x.kiosk_id = l.kiosk_id AND x.at >= $3 AND x.at < $1
+						AND NOT EXISTS (
+							SELECT 1 FROM bikes y
+							WHERE y.kiosk_id = x.kiosk_id AND y.at = x.at AND y.dock_number = l.dock_number
+								AND y.is_electric AND COALESCE(y.battery, 0) < $2
+						)
+				), '-infinity'::timestamptz)
+		) AS low_since
+		FROM low l
+		ORDER BY low_since, l.kiosk_id, l.dock_number
+	`
+	rows, err := p.db.QueryContext(ctx, query, at, threshold, since)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer rows.Close()
+
+	bikes := []models.LowBatteryBike{}
+	for rows.Next() {
+		var bike models.LowBatteryBike
+		var lowSince sql.NullTime
+		if err := rows.Scan(&bike.KioskId, &bike.DockNumber, &bike.Battery, &lowSince); err != nil {
+			return nil, err
+		}
+		bike.LowSince = at
+		if lowSince.Valid {
+			bike.LowSince = lowSince.Time
+		}
+		bikes = append(bikes, bike)
+	}
+
+	return bikes, rows.Err()
+}
+
+// DockBatteryHistory returns the content of one dock in every snapshot of
+// its kiosk taken in [from, to).
+func (p *postgresDB) DockBatteryHistory(ctx context.Context, kioskId, dockNumber int, from, to time.Time) (_ []models.DockBatteryPoint, err error) {
+	ctx, span := startSpan(ctx, "DockBatteryHistory")
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		SELECT s.at, b.is_electric, b.battery
+		FROM station_status s
+		LEFT JOIN bikes b ON b.kiosk_id = s.kiosk_id AND b.at = s.at AND b.dock_number = $2
+		WHERE s.kiosk_id = $1 AND s.at >= $3 AND s.at < $4
+		ORDER BY s.at
+	`
+	rows, err := p.db.QueryContext(ctx, query, kioskId, dockNumber, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer rows.Close()
+
+	points := []models.DockBatteryPoint{}
+	for rows.Next() {
+		var point models.DockBatteryPoint
+		var electric sql.NullBool
+		var battery sql.NullInt64
+		if err := rows.Scan(&point.At, &electric, &battery); err != nil {
+			return nil, err
+		}
+		point.Docked = electric.Valid
+		point.Electric = electric.Bool
+		if point.Electric && battery.Valid {
+			level := int(battery.Int64)
+			point.Battery = &level
+		}
+		points = append(points, point)
+	}
+
+	return points, rows.Err()
+}
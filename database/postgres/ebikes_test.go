@@ -0,0 +1,75 @@
+package postgres
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEBikesAt(t *testing.T) {
+	db, mock := NewMock()
+	postgres := &postgresDB{db}
+	defer postgres.db.Close()
+
+	at := time.Date(2024, 5, 14, 6, 48, 0, 0, time.UTC)
+	rows := sqlmock.NewRows([]string{"id", "kiosk_id", "at", "dock_number", "is_electric", "is_available", "battery"}).
+		AddRow(1, 3005, at, 5, true, true, 35)
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE at = $1 AND is_electric")).WithArgs(at).WillReturnRows(rows)
+
+	bikes, err := postgres.EBikesAt(context.TODO(), at)
+	require.NoError(t, err)
+	require.Len(t, bikes, 1)
+	assert.Equal(t, 35, bikes[0].Battery)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLowBatteryBikes(t *testing.T) {
+	db, mock := NewMock()
+	postgres := &postgresDB{db}
+	defer postgres.db.Close()
+
+	at := time.Date(2024, 5, 14, 6, 48, 0, 0, time.UTC)
+	since := at.Add(-7 * 24 * time.Hour)
+	rows := sqlmock.NewRows([]string{"kiosk_id", "dock_number", "battery", "low_since"}).
+		AddRow(3005, 5, 12, at.Add(-3*time.Hour)).
+		AddRow(3006, 2, 4, nil)
+	mock.ExpectQuery(regexp.QuoteMeta("WITH low AS")).WithArgs(at, 20, since).WillReturnRows(rows)
+
+	bikes, err := postgres.LowBatteryBikes(context.TODO(), at, 20, since)
+	require.NoError(t, err)
+	require.Len(t, bikes, 2)
+	assert.Equal(t, at.Add(-3*time.Hour), bikes[0].LowSince)
+	assert.Equal(t, at, bikes[1].LowSince)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDockBatteryHistory(t *testing.T) {
+	db, mock := NewMock()
+	postgres := &postgresDB{db}
+	defer postgres.db.Close()
+
+	from := time.Date(2024, 5, 14, 0, 0, 0, 0, time.UTC)
+	to := from.Add(time.Hour)
+	rows := sqlmock.NewRows([]string{"at", "is_electric", "battery"}).
+		AddRow(from, true, 60).
+		AddRow(from.Add(time.Minute), nil, nil).
+		AddRow(from.Add(2*time.Minute), false, 0)
+	mock.ExpectQuery(regexp.QuoteMeta("AND b.dock_number = $2")).WithArgs(3005, 5, from, to).WillReturnRows(rows)
+
+	points, err := postgres.DockBatteryHistory(context.TODO(), 3005, 5, from, to)
+	require.NoError(t, err)
+	require.Len(t, points, 3)
+	require.NotNil(t, points[0].Battery)
+	assert.Equal(t, 60, *points[0].Battery)
+	assert.False(t, points[1].Docked)
+	assert.Nil(t, points[1].Battery)
+	assert.True(t, points[2].Docked)
+	assert.False(t, points[2].Electric)
+	assert.Nil(t, points[2].Battery)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
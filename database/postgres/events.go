@@ -0,0 +1,133 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/macadrich/go-bike/database/models"
+	"github.com/macadrich/go-bike/pkg/tracing"
+)
+
+// PreviousStatuses returns, per kiosk, the status in its own last snapshot
+// taken before before, so that kiosks absent from the latest snapshots are
+// still compared. Only the fields compared by event detection, and At, are
+// set.
+func (p *postgresDB) PreviousStatuses(ctx context.Context, before time.Time) (_ map[int]models.Stations, err error) {
+	ctx, span := startSpan(ctx, "PreviousStatuses")
+	defer func() { tracing.End(span, err) }()
+
+	// The same rows as DISTINCT ON (kiosk_id) ... ORDER BY kiosk_id, at DESC,
+	// read with one index lookup per kiosk instead of a scan of the history.
+	query := `
+		SELECT s.at, s.kiosk_id, COALESCE(s.bikes_available, 0), COALESCE(s.docks_available, 0),
+		s.kiosk_connection_status
+		FROM (SELECT DISTINCT kiosk_id FROM station_info) k
+		CROSS JOIN LATERAL (
+			SELECT * FROM station_status
+			WHERE kiosk_id = k.kiosk_id AND at < $1
+			ORDER BY at DESC LIMIT 1
+		) s
+	`
+	rows, err := p.db.QueryContext(ctx, query, before)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer rows.Close()
+
+	statuses := make(map[int]models.Stations)
+	for rows.Next() {
+		var station models.Stations
+		err := rows.Scan(&station.At, &station.KioskId, &station.BikesAvailable, &station.DocksAvailable,
+			&station.KioskConnectionStatus)
+		if err != nil {
+			return nil, err
+		}
+		statuses[station.KioskId] = station
+	}
+
+	return statuses, rows.Err()
+}
+
+// InsertEvents stores events and returns those that were new, with their
+// ids. Events already recorded are skipped.
+func (p *postgresDB) InsertEvents(ctx context.Context, events []models.StationEvent) (_ []models.StationEvent, err error) {
+	ctx, span := startSpan(ctx, "InsertEvents")
+	defer func() { tracing.End(span, err) }()
+
+	if len(events) == 0 {
+		return nil, nil
+	}
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO station_events
+		(kiosk_id, at, type, recovered, bikes_available, docks_available, kiosk_connection_status)
+		VALUES($1,$2,$3,$4,$5,$6,$7)
+		ON CONFLICT (kiosk_id, at, type, recovered) DO NOTHING
+		RETURNING id
+	`
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	var inserted []models.StationEvent
+	for _, e := range events {
+		err := stmt.QueryRowContext(ctx, e.KioskId, e.At, e.Type, e.Recovered, e.BikesAvailable,
+			e.DocksAvailable, e.KioskConnectionStatus).Scan(&e.Id)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error inserting event: %w", err)
+		}
+		inserted = append(inserted, e)
+	}
+
+	return inserted, tx.Commit()
+}
+
+// QueryEvents returns the events detected at or after since, oldest first,
+// optionally for a single kiosk when kioskId is not zero. Events at since
+// with an id up to afterId are skipped, so the time and id of the last
+// event of a page resume the listing. A zero limit returns every event.
+func (p *postgresDB) QueryEvents(ctx context.Context, since time.Time, kioskId int, afterId int64, limit int) (_ []models.StationEvent, err error) {
+	ctx, span := startSpan(ctx, "QueryEvents")
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		SELECT id, kiosk_id, at, type, recovered, COALESCE(bikes_available, 0),
+		COALESCE(docks_available, 0), kiosk_connection_status
+		FROM station_events
+		WHERE (at, id) > ($1, $3) AND ($2 = 0 OR kiosk_id = $2)
+		ORDER BY at ASC, id ASC
+		LIMIT NULLIF($4::integer, 0)
+	`
+	rows, err := p.db.QueryContext(ctx, query, since, kioskId, afterId, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer rows.Close()
+
+	events := []models.StationEvent{}
+	for rows.Next() {
+		var e models.StationEvent
+		err := rows.Scan(&e.Id, &e.KioskId, &e.At, &e.Type, &e.Recovered, &e.BikesAvailable,
+			&e.DocksAvailable, &e.KioskConnectionStatus)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
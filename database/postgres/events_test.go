@@ -0,0 +1,87 @@
+package postgres
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/macadrich/go-bike/database/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreviousStatuses(t *testing.T) {
+	db, mock := NewMock()
+	postgres := &postgresDB{db}
+	defer postgres.db.Close()
+
+	before := time.Date(2024, 5, 14, 6, 48, 0, 0, time.UTC)
+	prev := before.Add(-time.Minute)
+	rows := sqlmock.NewRows([]string{"at", "kiosk_id", "bikes_available", "docks_available", "kiosk_connection_status"}).
+		AddRow(prev, 3005, 0, 12, "Active").
+		AddRow(prev.Add(-time.Hour), 3006, 4, 8, "Unresponsive")
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE kiosk_id = k.kiosk_id AND at < $1")).
+		WithArgs(before).WillReturnRows(rows)
+
+	statuses, err := postgres.PreviousStatuses(context.TODO(), before)
+	require.NoError(t, err)
+	assert.Len(t, statuses, 2)
+	assert.Equal(t, 12, statuses[3005].DocksAvailable)
+	assert.Equal(t, "Unresponsive", statuses[3006].KioskConnectionStatus)
+	assert.Equal(t, prev.Add(-time.Hour), statuses[3006].At)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestInsertEvents(t *testing.T) {
+	db, mock := NewMock()
+	postgres := &postgresDB{db}
+	defer postgres.db.Close()
+
+	at := time.Date(2024, 5, 14, 6, 48, 0, 0, time.UTC)
+	events := []models.StationEvent{
+		{KioskId: 3005, At: at, Type: models.EventBecameEmpty, DocksAvailable: 12, KioskConnectionStatus: "Active"},
+		{KioskId: 3006, At: at, Type: models.EventRecovered, Recovered: "offline", BikesAvailable: 4, DocksAvailable: 8, KioskConnectionStatus: "Active"},
+	}
+
+	mock.ExpectBegin()
+	prep := mock.ExpectPrepare("INSERT INTO station_events")
+	prep.ExpectQuery().WithArgs(events[0].KioskId, events[0].At, events[0].Type, events[0].Recovered,
+		events[0].BikesAvailable, events[0].DocksAvailable, events[0].KioskConnectionStatus).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	// The second event was already recorded.
+	prep.ExpectQuery().WithArgs(events[1].KioskId, events[1].At, events[1].Type, events[1].Recovered,
+		events[1].BikesAvailable, events[1].DocksAvailable, events[1].KioskConnectionStatus).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectCommit()
+
+	inserted, err := postgres.InsertEvents(context.TODO(), events)
+	require.NoError(t, err)
+	require.Len(t, inserted, 1)
+	assert.Equal(t, int64(7), inserted[0].Id)
+
+	inserted, err = postgres.InsertEvents(context.TODO(), nil)
+	assert.NoError(t, err)
+	assert.Empty(t, inserted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestQueryEvents(t *testing.T) {
+	db, mock := NewMock()
+	postgres := &postgresDB{db}
+	defer postgres.db.Close()
+
+	since := time.Date(2024, 5, 14, 0, 0, 0, 0, time.UTC)
+	rows := sqlmock.NewRows([]string{"id", "kiosk_id", "at", "type", "recovered", "bikes_available",
+		"docks_available", "kiosk_connection_status"}).
+		AddRow(1, 3005, since.Add(time.Hour), models.EventBecameFull, "", 12, 0, "Active")
+	mock.ExpectQuery(regexp.QuoteMeta("FROM station_events")).WithArgs(since, 3005, int64(0), 0).WillReturnRows(rows)
+
+	events, err := postgres.QueryEvents(context.TODO(), since, 3005, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, models.EventBecameFull, events[0].Type)
+	assert.Equal(t, int64(1), events[0].Id)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
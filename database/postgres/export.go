@@ -0,0 +1,192 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/macadrich/go-bike/pkg/export"
+	"github.com/macadrich/go-bike/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// exportBatch is how many rows are fetched from the export cursor at once.
+const exportBatch = 1000
+
+// exportQueries select the columns of each export dataset, in order, for
+// snapshots in [$1, $2). Queries by kiosk take the kiosk id, or 0 for all,
+// as $3.
+var exportQueries = map[string]struct {
+	query   string
+	byKiosk bool
+}{
+	export.DatasetStations: {`
+		SELECT s.at, s.kiosk_id, i.name, i.kiosk_type, i.total_docks, s.docks_available,
+			s.bikes_available, s.classic_bikes_available, s.smart_bikes_available,
+			s.electric_bikes_available, s.trikes_available, s.reward_bikes_available,
+			s.reward_docks_available, s.kiosk_status, s.kiosk_public_status,
+			s.kiosk_connection_status, i.is_event_based, i.is_virtual, i.latitude,
+			i.longitude, i.address_street, i.address_city, i.address_state, i.address_zipcode
+		FROM station_status s
+		LEFT JOIN station_info i ON i.kiosk_id = s.kiosk_id
+			AND i.valid_from <= s.at AND (i.valid_to IS NULL OR s.at < i.valid_to)
+		WHERE s.at >= $1 AND s.at < $2 AND ($3 = 0 OR s.kiosk_id = $3)
+		ORDER BY s.at, s.kiosk_id`, true},
+	export.DatasetBikes: {`
+		SELECT at, kiosk_id, dock_number, is_electric, is_available, battery
+		FROM bikes
+		WHERE at >= $1 AND at < $2 AND ($3 = 0 OR kiosk_id = $3)
+		ORDER BY at, kiosk_id, dock_number`, true},
+	export.DatasetWeather: {`
+		SELECT at, condition, description, temperature, feels_like, humidity, wind_speed, clouds
+		FROM weather_observations
+		WHERE at >= $1 AND at < $2
+		ORDER BY at`, false},
+}
+
+// Export passes every row of dataset in [from, to), optionally of a single
+// kiosk, to fn in the column order of export.Columns. Rows are read
+// through a server-side cursor, a batch at a time, so that exports of any
+// size run in constant memory.
+func (p *postgresDB) Export(ctx context.Context, dataset string, from, to time.Time, kioskId int, fn func(row []any) error) (err error) {
+	ctx, span := startSpan(ctx, "Export")
+	defer func() { tracing.End(span, err) }()
+
+	q, ok := exportQueries[dataset]
+	if !ok {
+		return fmt.Errorf("unknown export dataset %q", dataset)
+	}
+	columns, _ := export.Columns(dataset)
+
+	args := []any{from, to}
+	if q.byKiosk {
+		args = append(args, kioskId)
+	}
+
+	tx, err := p.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DECLARE export_cursor NO SCROLL CURSOR FOR "+q.query, args...); err != nil {
+		return fmt.Errorf("error declaring export cursor: %w", err)
+	}
+
+	values := make([]exportValue, len(columns))
+	dest := make([]any, len(columns))
+	for i, col := range columns {
+		values[i] = newExportValue(col.Type)
+		dest[i] = values[i]
+	}
+	row := make([]any, len(columns))
+
+	total := 0
+	for {
+		n, err := p.fetchExport(ctx, tx, dest, func() error {
+			for i, v := range values {
+				row[i] = v.value()
+			}
+			return fn(row)
+		})
+		total += n
+		if err != nil {
+			return err
+		}
+		if n < exportBatch {
+			break
+		}
+	}
+	span.SetAttributes(attribute.Int("export.rows", total))
+
+	return tx.Commit()
+}
+
+// fetchExport fetches the next batch of the export cursor, calling fn
+// after scanning each row into dest, and returns the number of rows.
+func (p *postgresDB) fetchExport(ctx context.Context, tx *sql.Tx, dest []any, fn func() error) (int, error) {
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf("FETCH %d FROM export_cursor", exportBatch))
+	if err != nil {
+		return 0, fmt.Errorf("query error: %w", err)
+	}
+	defer rows.Close()
+
+	n := 0
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return n, err
+		}
+		n++
+		if err := fn(); err != nil {
+			return n, err
+		}
+	}
+
+	return n, rows.Err()
+}
+
+// exportValue scans a nullable column into the Go type export expects.
+type exportValue interface {
+	sql.Scanner
+	value() any
+}
+
+func newExportValue(t export.Type) exportValue {
+	switch t {
+	case export.Int:
+		return &exportInt{}
+	case export.Float:
+		return &exportFloat{}
+	case export.Bool:
+		return &exportBool{}
+	case export.Time:
+		return &exportTime{}
+	}
+	return &exportString{}
+}
+
+type exportString struct{ sql.NullString }
+
+func (v *exportString) value() any {
+	if !v.Valid {
+		return nil
+	}
+	return v.String
+}
+
+type exportInt struct{ sql.NullInt64 }
+
+func (v *exportInt) value() any {
+	if !v.Valid {
+		return nil
+	}
+	return v.Int64
+}
+
+type exportFloat struct{ sql.NullFloat64 }
+
+func (v *exportFloat) value() any {
+	if !v.Valid {
+		return nil
+	}
+	return v.Float64
+}
+
+type exportBool struct{ sql.NullBool }
+
+func (v *exportBool) value() any {
+	if !v.Valid {
+		return nil
+	}
+	return v.Bool
+}
+
+type exportTime struct{ sql.NullTime }
+
+func (v *exportTime) value() any {
+	if !v.Valid {
+		return nil
+	}
+	return v.Time
+}
@@ -0,0 +1,72 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/macadrich/go-bike/pkg/export"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExport(t *testing.T) {
+	db, mock := NewMock()
+	postgres := &postgresDB{db}
+	defer postgres.db.Close()
+
+	from := time.Date(2024, 5, 14, 0, 0, 0, 0, time.UTC)
+	to := from.Add(24 * time.Hour)
+	at := from.Add(time.Hour)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("DECLARE export_cursor NO SCROLL CURSOR FOR")).
+		WithArgs(from, to, 3005).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta("FETCH 1000 FROM export_cursor")).
+		WillReturnRows(sqlmock.NewRows([]string{"at", "kiosk_id", "dock_number", "is_electric", "is_available", "battery"}).
+			AddRow(at, 3005, 1, true, true, 80).
+			AddRow(at, 3005, 2, false, true, nil))
+	mock.ExpectCommit()
+
+	var rows [][]any
+	err := postgres.Export(context.TODO(), export.DatasetBikes, from, to, 3005, func(row []any) error {
+		rows = append(rows, append([]any(nil), row...))
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, [][]any{
+		{at, int64(3005), int64(1), true, true, int64(80)},
+		{at, int64(3005), int64(2), false, true, nil},
+	}, rows)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestExportStopsOnError(t *testing.T) {
+	db, mock := NewMock()
+	postgres := &postgresDB{db}
+	defer postgres.db.Close()
+
+	from := time.Date(2024, 5, 14, 0, 0, 0, 0, time.UTC)
+	to := from.Add(24 * time.Hour)
+
+	// Weather is not kept per kiosk.
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("FROM weather_observations")).
+		WithArgs(from, to).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta("FETCH 1000 FROM export_cursor")).
+		WillReturnRows(sqlmock.NewRows([]string{"at", "condition", "description", "temperature", "feels_like", "humidity", "wind_speed", "clouds"}).
+			AddRow(from, "Rain", "light rain", 58.3, 57.9, 88, 9.2, 100))
+	mock.ExpectRollback()
+
+	errWrite := errors.New("client went away")
+	err := postgres.Export(context.TODO(), export.DatasetWeather, from, to, 0, func(row []any) error {
+		return errWrite
+	})
+	assert.ErrorIs(t, err, errWrite)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	assert.Error(t, postgres.Export(context.TODO(), "trips", from, to, 0, nil))
+}
@@ -0,0 +1,84 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/macadrich/go-bike/database/models"
+	"github.com/macadrich/go-bike/pkg/tracing"
+)
+
+// IngestionExists reports whether the snapshot last updated at at was
+// stored before.
+func (p *postgresDB) IngestionExists(ctx context.Context, at time.Time) (_ bool, err error) {
+	ctx, span := startSpan(ctx, "IngestionExists")
+	defer func() { tracing.End(span, err) }()
+
+	var exists bool
+	err = p.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM ingestions WHERE at = $1)", at).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("query error: %w", err)
+	}
+
+	return exists, nil
+}
+
+// RecordIngestion marks a snapshot as stored. Recording it again is a
+// no-op.
+func (p *postgresDB) RecordIngestion(ctx context.Context, ingestion *models.Ingestion) (err error) {
+	ctx, span := startSpan(ctx, "RecordIngestion")
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		INSERT INTO ingestions (at, source, stations, ingested_at)
+		VALUES($1,$2,$3,$4)
+		ON CONFLICT (at) DO NOTHING
+	`
+	_, err = p.db.ExecContext(ctx, query, ingestion.At, ingestion.Source, ingestion.Stations, ingestion.IngestedAt)
+	if err != nil {
+		return fmt.Errorf("error recording ingestion: %w", err)
+	}
+
+	return nil
+}
+
+// NextIngestion returns the time of the first snapshot stored at or after
+// at, or zero when there is none.
+func (p *postgresDB) NextIngestion(ctx context.Context, at time.Time) (_ time.Time, err error) {
+	ctx, span := startSpan(ctx, "NextIngestion")
+	defer func() { tracing.End(span, err) }()
+
+	var next sql.NullTime
+	if err := p.db.QueryRowContext(ctx, "SELECT MIN(at) FROM ingestions WHERE at >= $1", at).Scan(&next); err != nil {
+		return time.Time{}, fmt.Errorf("query error: %w", err)
+	}
+
+	return next.Time, nil
+}
+
+// ListIngestions lists up to limit stored snapshots before the given time,
+// newest first.
+func (p *postgresDB) ListIngestions(ctx context.Context, before time.Time, limit int) (_ []models.Ingestion, err error) {
+	ctx, span := startSpan(ctx, "ListIngestions")
+	defer func() { tracing.End(span, err) }()
+
+	query := "SELECT at, source, stations, ingested_at FROM ingestions WHERE at < $1 ORDER BY at DESC LIMIT $2"
+	rows, err := p.db.QueryContext(ctx, query, before, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer rows.Close()
+
+	ingestions := []models.Ingestion{}
+	for rows.Next() {
+		var i models.Ingestion
+		if err := rows.Scan(&i.At, &i.Source, &i.Stations, &i.IngestedAt); err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		ingestions = append(ingestions, i)
+	}
+
+	return ingestions, rows.Err()
+}
@@ -0,0 +1,85 @@
+package postgres
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/macadrich/go-bike/database/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIngestionExists(t *testing.T) {
+	db, mock := NewMock()
+	postgres := &postgresDB{db}
+	defer postgres.db.Close()
+
+	at := time.Date(2024, 5, 14, 6, 48, 19, 588000000, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT EXISTS (SELECT 1 FROM ingestions WHERE at = $1)")).
+		WithArgs(at).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+	exists, err := postgres.IngestionExists(context.TODO(), at)
+	require.NoError(t, err)
+	assert.True(t, exists)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNextIngestion(t *testing.T) {
+	db, mock := NewMock()
+	postgres := &postgresDB{db}
+	defer postgres.db.Close()
+
+	at := time.Date(2024, 5, 14, 6, 48, 19, 588000000, time.UTC)
+	query := regexp.QuoteMeta("SELECT MIN(at) FROM ingestions WHERE at >= $1")
+	mock.ExpectQuery(query).WithArgs(at.Add(-time.Minute)).WillReturnRows(sqlmock.NewRows([]string{"min"}).AddRow(at))
+	mock.ExpectQuery(query).WithArgs(at.Add(time.Minute)).WillReturnRows(sqlmock.NewRows([]string{"min"}).AddRow(nil))
+
+	next, err := postgres.NextIngestion(context.TODO(), at.Add(-time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, at, next)
+
+	next, err = postgres.NextIngestion(context.TODO(), at.Add(time.Minute))
+	require.NoError(t, err)
+	assert.True(t, next.IsZero())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRecordIngestion(t *testing.T) {
+	db, mock := NewMock()
+	postgres := &postgresDB{db}
+	defer postgres.db.Close()
+
+	ingestion := &models.Ingestion{
+		At:         time.Date(2024, 5, 14, 6, 48, 19, 588000000, time.UTC),
+		Source:     models.SourceImport,
+		Stations:   250,
+		IngestedAt: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+	}
+	mock.ExpectExec(regexp.QuoteMeta("ON CONFLICT (at) DO NOTHING")).
+		WithArgs(ingestion.At, ingestion.Source, ingestion.Stations, ingestion.IngestedAt).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	require.NoError(t, postgres.RecordIngestion(context.TODO(), ingestion))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListIngestions(t *testing.T) {
+	db, mock := NewMock()
+	postgres := &postgresDB{db}
+	defer postgres.db.Close()
+
+	before := time.Date(2024, 5, 15, 0, 0, 0, 0, time.UTC)
+	at := time.Date(2024, 5, 14, 6, 48, 19, 588000000, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta("FROM ingestions WHERE at < $1 ORDER BY at DESC LIMIT $2")).
+		WithArgs(before, 10).
+		WillReturnRows(sqlmock.NewRows([]string{"at", "source", "stations", "ingested_at"}).
+			AddRow(at, models.SourceLive, 250, at.Add(time.Second)))
+
+	ingestions, err := postgres.ListIngestions(context.TODO(), before, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []models.Ingestion{{At: at, Source: models.SourceLive, Stations: 250, IngestedAt: at.Add(time.Second)}}, ingestions)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
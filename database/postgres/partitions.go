@@ -0,0 +1,176 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/macadrich/go-bike/database/models"
+	"github.com/macadrich/go-bike/pkg/tracing"
+)
+
+// partitionedTables are range partitioned by month on at, see migration 7.
+var partitionedTables = []string{"station_status", "bikes"}
+
+const partitionSuffix = "_p"
+
+// monthStart truncates t to the first instant of its UTC month.
+func monthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// newPartition describes the partition of table holding month.
+func newPartition(table string, month time.Time) models.Partition {
+	from := monthStart(month)
+	return models.Partition{
+		Table: table,
+		Name:  table + partitionSuffix + from.Format("2006_01"),
+		From:  from,
+		To:    from.AddDate(0, 1, 0),
+	}
+}
+
+// parsePartition recovers a partition from its name, reporting false for
+// tables that do not follow the <table>_pYYYY_MM convention.
+func parsePartition(table, name string) (models.Partition, bool) {
+	suffix, ok := strings.CutPrefix(name, table+partitionSuffix)
+	if !ok {
+		return models.Partition{}, false
+	}
+	month, err := time.Parse("2006_01", suffix)
+	if err != nil {
+		return models.Partition{}, false
+	}
+	return newPartition(table, month), true
+}
+
+// EnsurePartitions creates the monthly partitions of every snapshot table
+// from the month of from through the month of through.
+func (p *postgresDB) EnsurePartitions(ctx context.Context, from, through time.Time) (err error) {
+	ctx, span := startSpan(ctx, "EnsurePartitions")
+	defer func() { tracing.End(span, err) }()
+
+	last := monthStart(through)
+	for month := monthStart(from); !month.After(last); month = month.AddDate(0, 1, 0) {
+		for _, table := range partitionedTables {
+			partition := newPartition(table, month)
+			query := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s PARTITION OF %s FOR VALUES FROM (%s) TO (%s)",
+				pq.QuoteIdentifier(partition.Name), pq.QuoteIdentifier(table),
+				pq.QuoteLiteral(partition.From.Format(time.RFC3339)), pq.QuoteLiteral(partition.To.Format(time.RFC3339)))
+			if _, err := p.db.ExecContext(ctx, query); err != nil {
+				return fmt.Errorf("error creating partition %s: %w", partition.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Partitions lists the monthly partitions of every snapshot table, oldest
+// first.
+func (p *postgresDB) Partitions(ctx context.Context) (_ []models.Partition, err error) {
+	ctx, span := startSpan(ctx, "Partitions")
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		SELECT parent.relname, child.relname
+		FROM pg_inherits
+		JOIN pg_class parent ON parent.oid = pg_inherits.inhparent
+		JOIN pg_class child ON child.oid = pg_inherits.inhrelid
+		WHERE parent.relname = ANY($1)
+		ORDER BY child.relname
+	`
+	rows, err := p.db.QueryContext(ctx, query, pq.Array(partitionedTables))
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer rows.Close()
+
+	var partitions []models.Partition
+	for rows.Next() {
+		var table, name string
+		if err := rows.Scan(&table, &name); err != nil {
+			return nil, err
+		}
+		if partition, ok := parsePartition(table, name); ok {
+			partitions = append(partitions, partition)
+		}
+	}
+
+	return partitions, rows.Err()
+}
+
+// ArchivePartition writes every row of partition to w as CSV with a header.
+func (p *postgresDB) ArchivePartition(ctx context.Context, partition models.Partition, w io.Writer) (err error) {
+	ctx, span := startSpan(ctx, "ArchivePartition")
+	defer func() { tracing.End(span, err) }()
+
+	rows, err := p.db.QueryContext(ctx, "SELECT * FROM "+pq.QuoteIdentifier(partition.Name)+" ORDER BY at, id")
+	if err != nil {
+		return fmt.Errorf("query error: %w", err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	out := csv.NewWriter(w)
+	if err := out.Write(columns); err != nil {
+		return err
+	}
+
+	values := make([]sql.NullString, len(columns))
+	dest := make([]any, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	record := make([]string, len(columns))
+
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+		for i, v := range values {
+			record[i] = v.String
+		}
+		if err := out.Write(record); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	out.Flush()
+	return out.Error()
+}
+
+// DropPartition removes partition and its rows, and the ingestions of its
+// month, so that no ingestion is left pointing at a removed snapshot.
+func (p *postgresDB) DropPartition(ctx context.Context, partition models.Partition) (err error) {
+	ctx, span := startSpan(ctx, "DropPartition")
+	defer func() { tracing.End(span, err) }()
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DROP TABLE IF EXISTS "+pq.QuoteIdentifier(partition.Name)); err != nil {
+		return fmt.Errorf("error dropping partition %s: %w", partition.Name, err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM ingestions WHERE at >= $1 AND at < $2", partition.From, partition.To); err != nil {
+		return fmt.Errorf("error deleting ingestions of partition %s: %w", partition.Name, err)
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,109 @@
+package postgres
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/macadrich/go-bike/database/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPartition(t *testing.T) {
+	partition := newPartition("bikes", time.Date(2024, 12, 31, 23, 30, 0, 0, time.UTC))
+
+	assert.Equal(t, models.Partition{
+		Table: "bikes",
+		Name:  "bikes_p2024_12",
+		From:  time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC),
+		To:    time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+	}, partition)
+
+	parsed, ok := parsePartition("bikes", "bikes_p2024_12")
+	assert.True(t, ok)
+	assert.Equal(t, partition, parsed)
+
+	_, ok = parsePartition("bikes", "bikes_default")
+	assert.False(t, ok)
+}
+
+func TestEnsurePartitions(t *testing.T) {
+	db, mock := NewMock()
+	postgres := &postgresDB{db}
+	defer postgres.db.Close()
+
+	from := time.Date(2019, 12, 20, 0, 0, 0, 0, time.UTC)
+	for _, month := range []time.Time{from, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)} {
+		for _, table := range partitionedTables {
+			partition := newPartition(table, month)
+			query := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS "%s" PARTITION OF "%s" FOR VALUES FROM ('%s') TO ('%s')`,
+				partition.Name, table, partition.From.Format(time.RFC3339), partition.To.Format(time.RFC3339))
+			mock.ExpectExec(regexp.QuoteMeta(query)).WillReturnResult(sqlmock.NewResult(0, 0))
+		}
+	}
+
+	err := postgres.EnsurePartitions(context.TODO(), from, time.Date(2020, 1, 31, 23, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPartitions(t *testing.T) {
+	db, mock := NewMock()
+	postgres := &postgresDB{db}
+	defer postgres.db.Close()
+
+	rows := sqlmock.NewRows([]string{"parent", "child"}).
+		AddRow("bikes", "bikes_p2024_05").
+		AddRow("station_status", "station_status_p2024_05").
+		AddRow("station_status", "station_status_old")
+	mock.ExpectQuery("FROM pg_inherits").WithArgs(pq.Array(partitionedTables)).WillReturnRows(rows)
+
+	partitions, err := postgres.Partitions(context.TODO())
+	require.NoError(t, err)
+	require.Len(t, partitions, 2)
+	assert.Equal(t, "bikes_p2024_05", partitions[0].Name)
+	assert.Equal(t, "station_status", partitions[1].Table)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestArchivePartition(t *testing.T) {
+	db, mock := NewMock()
+	postgres := &postgresDB{db}
+	defer postgres.db.Close()
+
+	partition := newPartition("bikes", time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC))
+	at := time.Date(2024, 5, 14, 6, 48, 19, 0, time.UTC)
+	rows := sqlmock.NewRows([]string{"id", "at", "kiosk_id", "battery"}).
+		AddRow(1, at, 3005, 87).
+		AddRow(2, at, 3005, nil)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "bikes_p2024_05" ORDER BY at, id`)).WillReturnRows(rows)
+
+	var buf bytes.Buffer
+	err := postgres.ArchivePartition(context.TODO(), partition, &buf)
+	require.NoError(t, err)
+	assert.Equal(t, "id,at,kiosk_id,battery\n1,2024-05-14T06:48:19Z,3005,87\n2,2024-05-14T06:48:19Z,3005,\n", buf.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDropPartition(t *testing.T) {
+	db, mock := NewMock()
+	postgres := &postgresDB{db}
+	defer postgres.db.Close()
+
+	partition := newPartition("station_status", time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DROP TABLE IF EXISTS "station_status_p2024_05"`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM ingestions WHERE at >= $1 AND at < $2`)).
+		WithArgs(partition.From, partition.To).WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectCommit()
+
+	err := postgres.DropPartition(context.TODO(), partition)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -6,10 +6,14 @@ import (
 	"fmt"
 	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"github.com/macadrich/go-bike/config"
 	"github.com/macadrich/go-bike/database"
 	"github.com/macadrich/go-bike/database/models"
+	"github.com/macadrich/go-bike/pkg/apperr"
+	"github.com/macadrich/go-bike/pkg/tracing"
+	semconv "go.opentelemetry.io/otel/semconv/v1.25.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type postgresDB struct {
@@ -17,8 +21,23 @@ type postgresDB struct {
 }
 
 func NewDB(cfg *config.DBConfig) (database.Database, error) {
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable", cfg.DBHost, cfg.DBUser, cfg.DBPassword, cfg.DBName, cfg.DBPort)
-	return initDB("postgres", dsn, 25, 5)
+	return initDB("postgres", cfg.DSN(), 25, 5)
+}
+
+// Open returns a plain connection pool, for tools such as the migration
+// runner that need direct access to the database.
+func Open(cfg *config.DBConfig) (*sql.DB, error) {
+	db, err := sql.Open("postgres", cfg.DSN())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
 }
 
 func initDB(dialect, dsn string, idleConn, maxConn int) (database.Database, error) {
@@ -38,85 +57,318 @@ func initDB(dialect, dsn string, idleConn, maxConn int) (database.Database, erro
 	return &postgresDB{db}, nil
 }
 
-func (p *postgresDB) InsertStation(lastUpdated string, station *models.Stations) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+// startSpan opens a client span for a single database operation.
+func startSpan(ctx context.Context, operation string) (context.Context, trace.Span) {
+	return tracing.Tracer().Start(ctx, "postgresDB."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBOperation(operation),
+		),
+	)
+}
+
+// stationColumns selects a models.Stations from a station_status row "s"
+// joined with the station_info version "i" valid at that snapshot.
+const stationColumns = `
+		s.at, i.name, s.kiosk_id, i.total_docks, i.is_event_based,
+		i.is_virtual, s.trikes_available, s.docks_available,
+		s.bikes_available, s.classic_bikes_available, s.smart_bikes_available,
+		s.electric_bikes_available, s.reward_bikes_available, s.reward_docks_available,
+		i.kiosk_type, i.latitude, i.longitude, s.kiosk_status,
+		s.kiosk_public_status, s.kiosk_connection_status, i.address_street,
+		i.address_city, i.address_state, i.address_zipcode, i.close_time,
+		i.event_end, i.event_start, i.notes, i.open_time, i.public_text, i.timezone
+		FROM station_status s
+		JOIN station_info i ON i.kiosk_id = s.kiosk_id
+		AND i.valid_from <= s.at AND (i.valid_to IS NULL OR s.at < i.valid_to)`
+
+func scanStation(rows *sql.Rows, station *models.Stations) error {
+	err := rows.Scan(
+		&station.At, &station.Name, &station.KioskId, &station.TotalDocks, &station.IsEventBased,
+		&station.IsVirtual, &station.TrikesAvailable, &station.DocksAvailable,
+		&station.BikesAvailable, &station.ClassicBikesAvailable, &station.SmartBikesAvailable,
+		&station.ElectricBikesAvailable, &station.RewardBikesAvailable, &station.RewardDocksAvailable,
+		&station.KioskType, &station.Latitude, &station.Longitude, &station.KioskStatus,
+		&station.KioskPublicStatus, &station.KioskConnectionStatus, &station.AddressStreet, &station.AddressCity,
+		&station.AddressState, &station.AddressZipCode, &station.CloseTime, &station.EventEnd,
+		&station.EventStart, &station.Notes, &station.OpenTime, &station.PublicText, &station.TimeZone,
+	)
+	if err != nil {
+		return err
+	}
+
+	station.Coordinates = []float64{station.Longitude, station.Latitude}
+	return nil
+}
+
+// stationLock serialises the writes of a kiosk's snapshots, so that the
+// duplicate check, station_info versions and rollups hold under concurrent
+// imports.
+const stationLock = "SELECT pg_advisory_xact_lock(hashtext('station'), $1)"
+
+// InsertStation stores one snapshot of a station: a station_status row, its
+// bikes, its hourly and daily rollups, with days starting at midnight in loc,
+// and, when the static attributes changed, a new station_info version.
+// A snapshot already stored for the kiosk at lastUpdated is left alone and
+// reported as not inserted.
+func (p *postgresDB) InsertStation(ctx context.Context, lastUpdated time.Time, loc *time.Location, station *models.Stations) (_ bool, err error) {
+	ctx, span := startSpan(ctx, "InsertStation")
+	defer func() { tracing.End(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, stationLock, station.KioskId); err != nil {
+		return false, fmt.Errorf("error locking station: %w", err)
+	}
+
 	query := `
-		INSERT INTO stations 
+		INSERT INTO station_status 
 		(
-			at, name, kiosk_id, total_docks, is_event_based,
-			is_virtual, trikes_available, docks_available,
-			bikes_available, classic_bikes_available, smart_bikes_available,
-			electric_bikes_available, reward_bikes_available, reward_docks_available,
-			kiosk_type, latitude, longitude, kiosk_status,
-			kiosk_public_status, kiosk_connection_status, address_street,
-			address_city, address_state, address_zipcode, close_time,
-			event_end, event_start, notes, open_time, public_text, timezone 
+			at, kiosk_id, trikes_available, docks_available, bikes_available,
+			classic_bikes_available, smart_bikes_available, electric_bikes_available,
+			reward_bikes_available, reward_docks_available, kiosk_status,
+			kiosk_public_status, kiosk_connection_status
 		) 
-		VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19,$20,$21,$22,$23,$24,$25,$26,$27,$28,$29,$30,$31)
+		SELECT $1::timestamptz, $2::integer, $3::integer, $4::integer, $5::integer,
+			$6::integer, $7::integer, $8::integer, $9::integer, $10::integer,
+			$11::varchar, $12::varchar, $13::varchar
+		WHERE NOT EXISTS (SELECT 1 FROM station_status WHERE kiosk_id = $2 AND at = $1)
 	`
-	stmt, err := p.db.PrepareContext(ctx, query)
-	if err != nil {
-		return err
-	}
-	defer stmt.Close()
-
-	result, err := stmt.ExecContext(
-		ctx, lastUpdated, station.Name, station.KioskId, station.TotalDocks,
-		station.IsEventBased, station.IsVirtual, station.TrikesAvailable,
+	result, err := tx.ExecContext(
+		ctx, query, lastUpdated, station.KioskId, station.TrikesAvailable,
 		station.DocksAvailable, station.BikesAvailable, station.ClassicBikesAvailable,
 		station.SmartBikesAvailable, station.ElectricBikesAvailable, station.RewardBikesAvailable,
-		station.RewardDocksAvailable, station.KioskType, station.Latitude, station.Longitude,
-		station.KioskStatus, station.KioskPublicStatus, station.KioskConnectionStatus, station.AddressStreet,
-		station.AddressCity, station.AddressState, station.AddressZipCode, station.CloseTime,
-		station.EventEnd, station.EventStart, station.Notes, station.OpenTime,
-		station.PublicText, station.TimeZone,
+		station.RewardDocksAvailable, station.KioskStatus, station.KioskPublicStatus,
+		station.KioskConnectionStatus,
 	)
-
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	rowAffected, rowErr := result.RowsAffected()
 	if rowErr != nil {
-		return fmt.Errorf("error on row affected: %w", rowErr)
+		return false, fmt.Errorf("error on row affected: %w", rowErr)
+	}
+	if rowAffected == 0 {
+		return false, nil
 	}
 
-	if rowAffected > 0 {
-		if len(station.Bikes) > 0 {
-			err := p.insertBikes(ctx, station.KioskId, lastUpdated, station.Bikes)
-			if err != nil {
-				return err
-			}
+	if err := p.upsertStationInfo(ctx, tx, lastUpdated, station); err != nil {
+		return false, err
+	}
+
+	if len(station.Bikes) > 0 {
+		if err := p.insertBikes(ctx, tx, station.KioskId, lastUpdated, station.Bikes); err != nil {
+			return false, err
 		}
+	}
+
+	if err := updateRollups(ctx, tx, station.KioskId, lastUpdated, loc); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// stationInfoAttributes are the static attributes of a station_info
+// version, in the order of scanStationInfo.
+const stationInfoAttributes = `name, total_docks, is_event_based, is_virtual, kiosk_type,
+		latitude, longitude, address_street, address_city, address_state,
+		address_zipcode, close_time, event_end, event_start, notes,
+		open_time, public_text, timezone`
+
+// stationInfoVersion is a stored station_info row.
+type stationInfoVersion struct {
+	id        int64
+	validFrom time.Time
+	validTo   sql.NullTime
+	models.Stations
+}
+
+// queryStationInfo returns the station_info version selected by where, or
+// nil when there is none.
+func queryStationInfo(ctx context.Context, tx *sql.Tx, where string, args ...any) (*stationInfoVersion, error) {
+	var v stationInfoVersion
+	err := tx.QueryRowContext(ctx, "SELECT id, valid_from, valid_to, "+stationInfoAttributes+" FROM station_info "+where, args...).Scan(
+		&v.id, &v.validFrom, &v.validTo,
+		&v.Name, &v.TotalDocks, &v.IsEventBased, &v.IsVirtual, &v.KioskType,
+		&v.Latitude, &v.Longitude, &v.AddressStreet, &v.AddressCity, &v.AddressState,
+		&v.AddressZipCode, &v.CloseTime, &v.EventEnd, &v.EventStart, &v.Notes,
+		&v.OpenTime, &v.PublicText, &v.TimeZone,
+	)
+	switch {
+	case err == sql.ErrNoRows:
+		return nil, nil
+	case err != nil:
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	return &v, nil
+}
 
-		if len(station.Coordinates) > 0 {
-			err := p.insertCoordinates(ctx, station.KioskId, station.Coordinates)
+// upsertStationInfo makes the station_info version valid at lastUpdated
+// match the static attributes of station. Snapshots usually arrive in
+// order and only ever close the current version, but imported ones may be
+// older than the versions already stored:
+//
+//   - a snapshot before the first version extends it back, or opens a
+//     version ending where the first one starts;
+//   - a snapshot inside a version with other attributes splits it, and
+//     the old version resumes at the next snapshot stored after it.
+func (p *postgresDB) upsertStationInfo(ctx context.Context, tx *sql.Tx, lastUpdated time.Time, station *models.Stations) (err error) {
+	ctx, span := startSpan(ctx, "upsertStationInfo")
+	defer func() { tracing.End(span, err) }()
+
+	current, err := queryStationInfo(ctx, tx,
+		"WHERE kiosk_id = $1 AND valid_from <= $2 AND (valid_to IS NULL OR $2 < valid_to)",
+		station.KioskId, lastUpdated)
+	if err != nil {
+		return err
+	}
+
+	if current == nil {
+		following, err := queryStationInfo(ctx, tx,
+			"WHERE kiosk_id = $1 AND valid_from > $2 ORDER BY valid_from LIMIT 1",
+			station.KioskId, lastUpdated)
+		switch {
+		case err != nil:
+			return err
+		case following == nil:
+			return p.insertStationInfo(ctx, tx, lastUpdated, sql.NullTime{}, station)
+		case sameStationInfo(&following.Stations, station):
+			_, err := tx.ExecContext(ctx, "UPDATE station_info SET valid_from = $1 WHERE id = $2", lastUpdated, following.id)
 			if err != nil {
-				return err
+				return fmt.Errorf("error extending station info: %w", err)
 			}
+			return nil
+		default:
+			return p.insertStationInfo(ctx, tx, lastUpdated, sql.NullTime{Time: following.validFrom, Valid: true}, station)
 		}
 	}
 
+	if sameStationInfo(&current.Stations, station) {
+		return nil
+	}
+
+	var next sql.NullTime
+	err = tx.QueryRowContext(ctx,
+		"SELECT MIN(at) FROM station_status WHERE kiosk_id = $1 AND at > $2 AND ($3::timestamptz IS NULL OR at < $3)",
+		station.KioskId, lastUpdated, current.validTo).Scan(&next)
+	if err != nil {
+		return fmt.Errorf("query error: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE station_info SET valid_to = $1 WHERE id = $2", lastUpdated, current.id)
+	if err != nil {
+		return fmt.Errorf("error closing station info: %w", err)
+	}
+
+	if !next.Valid {
+		return p.insertStationInfo(ctx, tx, lastUpdated, current.validTo, station)
+	}
+
+	if err := p.insertStationInfo(ctx, tx, lastUpdated, next, station); err != nil {
+		return err
+	}
+	resume := `
+		INSERT INTO station_info (kiosk_id, valid_from, valid_to, ` + stationInfoAttributes + `)
+		SELECT kiosk_id, $2, $3, ` + stationInfoAttributes + `
+		FROM station_info WHERE id = $1
+	`
+	if _, err := tx.ExecContext(ctx, resume, current.id, next.Time, current.validTo); err != nil {
+		return fmt.Errorf("error resuming station info: %w", err)
+	}
+
 	return nil
 }
 
-func (p *postgresDB) QueryAllStation(lastUpdate string) ([]models.Stations, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
-	defer cancel()
+// insertStationInfo opens a version with the attributes of station valid
+// from validFrom until validTo, or until further notice. Only the current
+// version updates the station's geometry.
+func (p *postgresDB) insertStationInfo(ctx context.Context, tx *sql.Tx, validFrom time.Time, validTo sql.NullTime, station *models.Stations) error {
+	insert := `
+		INSERT INTO station_info 
+		(
+			kiosk_id, valid_from, valid_to, name, total_docks, is_event_based,
+			is_virtual, kiosk_type, latitude, longitude, address_street,
+			address_city, address_state, address_zipcode, close_time,
+			event_end, event_start, notes, open_time, public_text, timezone 
+		) 
+		VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19,$20,$21)
+	`
+	_, err := tx.ExecContext(
+		ctx, insert, station.KioskId, validFrom, validTo, station.Name, station.TotalDocks,
+		station.IsEventBased, station.IsVirtual, station.KioskType, station.Latitude,
+		station.Longitude, station.AddressStreet, station.AddressCity, station.AddressState,
+		station.AddressZipCode, timeOfDay(station.CloseTime), station.EventEnd, station.EventStart,
+		station.Notes, timeOfDay(station.OpenTime), station.PublicText, station.TimeZone,
+	)
+	if err != nil {
+		return fmt.Errorf("error inserting station info: %w", err)
+	}
 
-	query := `
-		SELECT at, name, kiosk_id, total_docks, is_event_based,
-		is_virtual, trikes_available, docks_available,
-		bikes_available, classic_bikes_available, smart_bikes_available,
-		electric_bikes_available, reward_bikes_available, reward_docks_available,
-		kiosk_type, latitude, longitude, kiosk_status,
-		kiosk_public_status, kiosk_connection_status, address_street,
-		address_city, address_state, address_zipcode, close_time,
-		event_end, event_start, notes, open_time, public_text, timezone
-		FROM stations WHERE at >= $1 ORDER BY at ASC
+	if validTo.Valid {
+		return nil
+	}
+
+	geometry := `
+		INSERT INTO geometry (kiosk_id, type, coordinates) VALUES($1,$2,$3)
+		ON CONFLICT (kiosk_id) DO UPDATE SET type = EXCLUDED.type, coordinates = EXCLUDED.coordinates
 	`
+	_, err = tx.ExecContext(ctx, geometry, station.KioskId, "Point", pq.Array([]float64{station.Longitude, station.Latitude}))
+	if err != nil {
+		return fmt.Errorf("error upserting geometry: %w", err)
+	}
+
+	return nil
+}
+
+// timeOfDay formats an optional opening or closing time for a TIME column.
+func timeOfDay(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return t.Format("15:04:05")
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+func sameTimeOfDay(a, b *time.Time) bool {
+	return timeOfDay(a) == timeOfDay(b)
+}
+
+func sameStationInfo(a, b *models.Stations) bool {
+	return a.Name == b.Name && a.TotalDocks == b.TotalDocks &&
+		a.IsEventBased == b.IsEventBased && a.IsVirtual == b.IsVirtual &&
+		a.KioskType == b.KioskType && a.Latitude == b.Latitude &&
+		a.Longitude == b.Longitude && a.AddressStreet == b.AddressStreet &&
+		a.AddressCity == b.AddressCity && a.AddressState == b.AddressState &&
+		a.AddressZipCode == b.AddressZipCode && sameTimeOfDay(a.CloseTime, b.CloseTime) &&
+		sameTime(a.EventEnd, b.EventEnd) && sameTime(a.EventStart, b.EventStart) &&
+		a.Notes == b.Notes && sameTimeOfDay(a.OpenTime, b.OpenTime) &&
+		a.PublicText == b.PublicText && a.TimeZone == b.TimeZone
+}
+
+func (p *postgresDB) QueryAllStation(ctx context.Context, lastUpdate time.Time) (_ []models.Stations, err error) {
+	ctx, span := startSpan(ctx, "QueryAllStation")
+	defer func() { tracing.End(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, time.Second*5)
+	defer cancel()
+
+	query := "SELECT" + stationColumns + " WHERE s.at >= $1 ORDER BY s.at ASC"
 
 	rows, err := p.db.QueryContext(ctx, query, lastUpdate)
 	if err != nil {
@@ -127,57 +379,76 @@ func (p *postgresDB) QueryAllStation(lastUpdate string) ([]models.Stations, erro
 	var stations []models.Stations
 	for rows.Next() {
 		var station models.Stations
-
-		err := rows.Scan(
-			&station.At, &station.Name, &station.KioskId, &station.TotalDocks, &station.IsEventBased,
-			&station.IsVirtual, &station.TrikesAvailable, &station.DocksAvailable,
-			&station.BikesAvailable, &station.ClassicBikesAvailable, &station.SmartBikesAvailable,
-			&station.ElectricBikesAvailable, &station.RewardBikesAvailable, &station.RewardDocksAvailable,
-			&station.KioskType, &station.Latitude, &station.Longitude, &station.KioskStatus,
-			&station.KioskPublicStatus, &station.KioskConnectionStatus, &station.AddressStreet, &station.AddressCity,
-			&station.AddressState, &station.AddressZipCode, &station.CloseTime, &station.EventEnd,
-			&station.EventStart, &station.Notes, &station.OpenTime, &station.PublicText, &station.TimeZone,
-		)
-		if err != nil {
+		if err := scanStation(rows, &station); err != nil {
 			return nil, fmt.Errorf("scan error: %w", err)
 		}
 		stations = append(stations, station)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("scan error: %w", err)
+	}
+
+	bikes, err := p.fetchBikesSince(ctx, lastUpdate)
+	if err != nil {
+		return nil, fmt.Errorf("fetch error: %w", err)
+	}
 
 	for i, s := range stations {
-		bikes, err := p.fetchBikes(ctx, s.KioskId)
-		if err != nil {
-			return nil, fmt.Errorf("fetch error: %w", err)
+		stations[i].Bikes = bikes[newSnapshotKey(s.KioskId, s.At)]
+	}
+
+	return stations, nil
+}
+
+// QuerySnapshot returns the stations of the snapshot taken at at, with
+// their bikes.
+func (p *postgresDB) QuerySnapshot(ctx context.Context, at time.Time) (_ []models.Stations, err error) {
+	ctx, span := startSpan(ctx, "QuerySnapshot")
+	defer func() { tracing.End(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, time.Second*5)
+	defer cancel()
+
+	query := "SELECT" + stationColumns + " WHERE s.at = $1 ORDER BY s.kiosk_id"
+
+	rows, err := p.db.QueryContext(ctx, query, at)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer rows.Close()
+
+	var stations []models.Stations
+	for rows.Next() {
+		var station models.Stations
+		if err := scanStation(rows, &station); err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
 		}
-		stations[i].Bikes = bikes
+		stations = append(stations, station)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("scan error: %w", err)
+	}
+
+	bikes, err := p.fetchSnapshotBikes(ctx, at)
+	if err != nil {
+		return nil, fmt.Errorf("fetch error: %w", err)
 	}
 
 	for i, s := range stations {
-		coordinates, err := p.fetchCoordinates(ctx, s.KioskId)
-		if err != nil {
-			return nil, fmt.Errorf("fetch error: %w", err)
-		}
-		stations[i].Coordinates = coordinates
+		stations[i].Bikes = bikes[s.KioskId]
 	}
 
 	return stations, nil
 }
 
-func (p *postgresDB) QuerySpecificStation(kioskId int, lastUpdate string) (*models.Stations, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+func (p *postgresDB) QuerySpecificStation(ctx context.Context, kioskId int, lastUpdate time.Time) (_ *models.Stations, err error) {
+	ctx, span := startSpan(ctx, "QuerySpecificStation")
+	defer func() { tracing.End(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, time.Second*5)
 	defer cancel()
 
-	query := `
-		SELECT at, name, kiosk_id, total_docks, is_event_based,
-		is_virtual, trikes_available, docks_available,
-		bikes_available, classic_bikes_available, smart_bikes_available,
-		electric_bikes_available, reward_bikes_available, reward_docks_available,
-		kiosk_type, latitude, longitude, kiosk_status,
-		kiosk_public_status, kiosk_connection_status, address_street,
-		address_city, address_state, address_zipcode, close_time,
-		event_end, event_start, notes, open_time, public_text, timezone
-		FROM stations WHERE kiosk_id = $1 AND at >= $2 ORDER BY at ASC
-	`
+	query := "SELECT" + stationColumns + " WHERE s.kiosk_id = $1 AND s.at >= $2 ORDER BY s.at ASC"
 
 	rows, err := p.db.QueryContext(ctx, query, kioskId, lastUpdate)
 	if err != nil {
@@ -187,115 +458,186 @@ func (p *postgresDB) QuerySpecificStation(kioskId int, lastUpdate string) (*mode
 
 	var station models.Stations
 	for rows.Next() {
-		err := rows.Scan(
-			&station.At, &station.Name, &station.KioskId, &station.TotalDocks, &station.IsEventBased,
-			&station.IsVirtual, &station.TrikesAvailable, &station.DocksAvailable,
-			&station.BikesAvailable, &station.ClassicBikesAvailable, &station.SmartBikesAvailable,
-			&station.ElectricBikesAvailable, &station.RewardBikesAvailable, &station.RewardDocksAvailable,
-			&station.KioskType, &station.Latitude, &station.Longitude, &station.KioskStatus,
-			&station.KioskPublicStatus, &station.KioskConnectionStatus, &station.AddressStreet, &station.AddressCity,
-			&station.AddressState, &station.AddressZipCode, &station.CloseTime, &station.EventEnd,
-			&station.EventStart, &station.Notes, &station.OpenTime, &station.PublicText, &station.TimeZone,
-		)
-		if err != nil {
+		if err := scanStation(rows, &station); err != nil {
 			return nil, fmt.Errorf("scan error: %w", err)
 		}
 	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("scan error: %w", err)
+	}
 
-	bikes, err := p.fetchBikes(ctx, station.KioskId)
-	if err != nil {
-		return nil, fmt.Errorf("fetch error: %w", err)
+	if station.At.IsZero() {
+		return nil, apperr.NotFound("station", fmt.Sprintf("station %d not found", kioskId))
 	}
-	station.Bikes = bikes
 
-	coordinates, err := p.fetchCoordinates(ctx, station.KioskId)
+	bikes, err := p.fetchBikes(ctx, station.KioskId, station.At)
 	if err != nil {
 		return nil, fmt.Errorf("fetch error: %w", err)
 	}
-	station.Coordinates = coordinates
+	station.Bikes = bikes
 
 	return &station, nil
 }
 
-func (p *postgresDB) insertCoordinates(ctx context.Context, kioskId int, coordinates []float64) error {
-	query := "INSERT INTO coordinates (kiosk_id, longitude, latitude) VALUES($1,$2,$3)"
+func (p *postgresDB) insertBikes(ctx context.Context, tx *sql.Tx, kioskId int, lastUpdated time.Time, bikes []models.Bike) (err error) {
+	ctx, span := startSpan(ctx, "insertBikes")
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		INSERT INTO bikes 
+		(at, kiosk_id, dock_number, is_electric, is_available, battery)
+		VALUES($1,$2,$3,$4,$5,$6)
+	`
 
-	stmt, err := p.db.PrepareContext(ctx, query)
+	stmt, err := tx.PrepareContext(ctx, query)
 	if err != nil {
 		return err
 	}
 	defer stmt.Close()
 
-	_, err = stmt.ExecContext(ctx, kioskId, coordinates[0], coordinates[1])
-	if err != nil {
-		return fmt.Errorf("error inserting data into database: %w", err)
+	for _, bike := range bikes {
+		_, err := stmt.ExecContext(ctx, lastUpdated, kioskId, bike.DockNumber, bike.IsElectric, bike.IsAvailable, bike.Battery)
+		if err != nil {
+			return fmt.Errorf("error inserting data into database: %w", err)
+		}
 	}
 
 	return nil
 }
 
-func (p *postgresDB) fetchCoordinates(ctx context.Context, kioskId int) ([]float64, error) {
-	query := "SELECT longitude, latitude FROM coordinates WHERE kiosk_id = $1"
-	rows, err := p.db.QueryContext(ctx, query, kioskId)
+// snapshotKey identifies the bikes docked at a kiosk in one snapshot. The
+// time is kept as microseconds, Postgres' precision, so that keys compare
+// equal regardless of time.Location.
+type snapshotKey struct {
+	kioskId int
+	at      int64
+}
+
+func newSnapshotKey(kioskId int, at time.Time) snapshotKey {
+	return snapshotKey{kioskId, at.UnixMicro()}
+}
+
+func scanBike(rows *sql.Rows) (models.Bike, time.Time, error) {
+	var bike models.Bike
+	var at time.Time
+	err := rows.Scan(&bike.Id, &bike.KioskId, &at, &bike.DockNumber, &bike.IsElectric, &bike.IsAvailable, &bike.Battery)
+	return bike, at, err
+}
+
+func (p *postgresDB) fetchBikes(ctx context.Context, kioskId int, at time.Time) (_ []models.Bike, err error) {
+	ctx, span := startSpan(ctx, "fetchBikes")
+	defer func() { tracing.End(span, err) }()
+
+	query := "SELECT id, kiosk_id, at, dock_number, is_electric, is_available, battery FROM bikes WHERE kiosk_id = $1 AND at = $2"
+	rows, err := p.db.QueryContext(ctx, query, kioskId, at)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var coordinates []float64
+	var bikes []models.Bike
 	for rows.Next() {
-		var longitude float64
-		var latitude float64
-		err := rows.Scan(&longitude, &latitude)
+		bike, _, err := scanBike(rows)
 		if err != nil {
 			return nil, err
 		}
-		coordinates = append(coordinates, []float64{longitude, latitude}...)
+		bikes = append(bikes, bike)
 	}
 
-	return coordinates, nil
+	return bikes, rows.Err()
 }
 
-func (p *postgresDB) insertBikes(ctx context.Context, kioskId int, lastUpdated string, bikes []models.Bike) error {
-	query := `
-		INSERT INTO bikes 
-		(at, kiosk_id, dock_number, is_electric, is_available, battery)
-		VALUES($1,$2,$3,$4,$5,$6)
-	`
+// fetchBikesSince loads the bikes of every snapshot taken at or after since
+// in one query, grouped by kiosk and snapshot.
+func (p *postgresDB) fetchBikesSince(ctx context.Context, since time.Time) (_ map[snapshotKey][]models.Bike, err error) {
+	ctx, span := startSpan(ctx, "fetchBikesSince")
+	defer func() { tracing.End(span, err) }()
 
-	stmt, err := p.db.PrepareContext(ctx, query)
+	query := "SELECT id, kiosk_id, at, dock_number, is_electric, is_available, battery FROM bikes WHERE at >= $1"
+	rows, err := p.db.QueryContext(ctx, query, since)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer stmt.Close()
+	defer rows.Close()
 
-	for _, bike := range bikes {
-		_, err := stmt.ExecContext(ctx, lastUpdated, kioskId, bike.DockNumber, bike.IsElectric, bike.IsAvailable, bike.Battery)
+	bikes := make(map[snapshotKey][]models.Bike)
+	for rows.Next() {
+		bike, at, err := scanBike(rows)
 		if err != nil {
-			return fmt.Errorf("error inserting data into database: %w", err)
+			return nil, err
 		}
+		key := newSnapshotKey(bike.KioskId, at)
+		bikes[key] = append(bikes[key], bike)
 	}
 
-	return nil
+	return bikes, rows.Err()
 }
 
-func (p *postgresDB) fetchBikes(ctx context.Context, kioskId int) ([]models.Bike, error) {
-	query := "SELECT id, kiosk_id, dock_number, is_electric, is_available, battery FROM bikes WHERE kiosk_id = $1"
-	rows, err := p.db.QueryContext(ctx, query, kioskId)
+// fetchSnapshotBikes loads the bikes of the snapshot taken at at, grouped
+// by kiosk.
+func (p *postgresDB) fetchSnapshotBikes(ctx context.Context, at time.Time) (_ map[int][]models.Bike, err error) {
+	ctx, span := startSpan(ctx, "fetchSnapshotBikes")
+	defer func() { tracing.End(span, err) }()
+
+	query := "SELECT id, kiosk_id, at, dock_number, is_electric, is_available, battery FROM bikes WHERE at = $1"
+	rows, err := p.db.QueryContext(ctx, query, at)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var bikes []models.Bike
+	bikes := make(map[int][]models.Bike)
 	for rows.Next() {
-		var bike models.Bike
-		err := rows.Scan(&bike.Id, &bike.KioskId, &bike.DockNumber, &bike.IsElectric, &bike.IsAvailable, &bike.Battery)
+		bike, _, err := scanBike(rows)
 		if err != nil {
 			return nil, err
 		}
-		bikes = append(bikes, bike)
+		bikes[bike.KioskId] = append(bikes[bike.KioskId], bike)
+	}
+
+	return bikes, rows.Err()
+}
+
+func (p *postgresDB) Ping(ctx context.Context) (err error) {
+	ctx, span := startSpan(ctx, "Ping")
+	defer func() { tracing.End(span, err) }()
+
+	return p.db.PingContext(ctx)
+}
+
+// SchemaVersion reads the version recorded by the migration tool in the
+// schema_migrations table.
+func (p *postgresDB) SchemaVersion(ctx context.Context) (_ int, _ bool, err error) {
+	ctx, span := startSpan(ctx, "SchemaVersion")
+	defer func() { tracing.End(span, err) }()
+
+	var version int
+	var dirty bool
+	err = p.db.QueryRowContext(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("query error: %w", err)
+	}
+
+	return version, dirty, nil
+}
+
+// LatestSnapshot returns the time of the newest ingested snapshot, or the
+// zero time when nothing has been ingested yet.
+func (p *postgresDB) LatestSnapshot(ctx context.Context) (_ time.Time, err error) {
+	ctx, span := startSpan(ctx, "LatestSnapshot")
+	defer func() { tracing.End(span, err) }()
+
+	var at sql.NullTime
+	if err := p.db.QueryRowContext(ctx, "SELECT MAX(at) FROM station_status").Scan(&at); err != nil {
+		return time.Time{}, fmt.Errorf("query error: %w", err)
 	}
 
-	return bikes, nil
+	return at.Time, nil
+}
+
+func (p *postgresDB) Close() error {
+	return p.db.Close()
 }
@@ -9,9 +9,13 @@ import (
 	"os"
 	"regexp"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/macadrich/go-bike/database"
 	"github.com/macadrich/go-bike/database/models"
+	"github.com/macadrich/go-bike/pkg/apperr"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -52,35 +56,55 @@ func DumpFiles() []MockIndegoStations {
 	return listOfStations
 }
 
-func TestInsertStation(t *testing.T) {
-	db, mock := NewMock()
-	postgres := &postgresDB{db}
-	defer func() {
-		postgres.db.Close()
-	}()
+var stationColumnNames = []string{"at", "name", "kiosk_id", "total_docks", "is_event_based",
+	"is_virtual", "trikes_available", "docks_available",
+	"bikes_available", "classic_bikes_available", "smart_bikes_available",
+	"electric_bikes_available", "reward_bikes_available", "reward_docks_available",
+	"kiosk_type", "latitude", "longitude", "kiosk_status",
+	"kiosk_public_status", "kiosk_connection_status", "address_street",
+	"address_city", "address_state", "address_zipcode", "close_time",
+	"event_end", "event_start", "notes", "open_time", "public_text", "timezone"}
 
-	//var station = &models.Stations{}
-	listOfStations := DumpFiles()
-	station := listOfStations[0].Properties
+var bikeColumnNames = []string{"id", "kiosk_id", "at", "dock_number", "is_electric", "is_available", "battery"}
 
-	query := `
-		INSERT INTO stations 
+const stationInfoQuery = "FROM station_info WHERE kiosk_id = $1 AND valid_from <= $2 AND (valid_to IS NULL OR $2 < valid_to)"
+
+const followingStationInfoQuery = "FROM station_info WHERE kiosk_id = $1 AND valid_from > $2 ORDER BY valid_from LIMIT 1"
+
+const nextSnapshotQuery = "SELECT MIN(at) FROM station_status WHERE kiosk_id = $1 AND at > $2"
+
+const stationInfoInsert = `
+		INSERT INTO station_info 
 		(
-			at, name, kiosk_id, total_docks, is_event_based,
-			is_virtual, trikes_available, docks_available,
-			bikes_available, classic_bikes_available, smart_bikes_available,
-			electric_bikes_available, reward_bikes_available, reward_docks_available,
-			kiosk_type, latitude, longitude, kiosk_status,
-			kiosk_public_status, kiosk_connection_status, address_street,
+			kiosk_id, valid_from, valid_to, name, total_docks, is_event_based,
+			is_virtual, kiosk_type, latitude, longitude, address_street,
 			address_city, address_state, address_zipcode, close_time,
 			event_end, event_start, notes, open_time, public_text, timezone 
 		) 
-		VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19,$20,$21,$22,$23,$24,$25,$26,$27,$28,$29,$30,$31)
+		VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19,$20,$21)
+	`
+
+const geometryUpsert = `
+		INSERT INTO geometry (kiosk_id, type, coordinates) VALUES($1,$2,$3)
+		ON CONFLICT (kiosk_id) DO UPDATE SET type = EXCLUDED.type, coordinates = EXCLUDED.coordinates
 	`
 
-	lastUpdated := "2024-05-14T06:48:19.588Z"
-	prep := mock.ExpectPrepare(regexp.QuoteMeta(query))
-	prep.ExpectExec().WithArgs(lastUpdated, station.Name, station.KioskId, station.TotalDocks,
+const stationStatusInsert = "INSERT INTO station_status"
+
+// stationInfoRow is the station_info version 1 of station, valid from
+// validFrom until validTo, or until further notice when nil.
+func stationInfoRow(station models.Stations, validFrom time.Time, validTo any) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "valid_from", "valid_to", "name", "total_docks", "is_event_based", "is_virtual", "kiosk_type",
+		"latitude", "longitude", "address_street", "address_city", "address_state",
+		"address_zipcode", "close_time", "event_end", "event_start", "notes",
+		"open_time", "public_text", "timezone"}).AddRow(1, validFrom, validTo, station.Name, station.TotalDocks, station.IsEventBased,
+		station.IsVirtual, station.KioskType, station.Latitude, station.Longitude, station.AddressStreet,
+		station.AddressCity, station.AddressState, station.AddressZipCode, station.CloseTime, station.EventEnd,
+		station.EventStart, station.Notes, station.OpenTime, station.PublicText, station.TimeZone)
+}
+
+func stationRows(station models.Stations) *sqlmock.Rows {
+	return sqlmock.NewRows(stationColumnNames).AddRow(station.At, station.Name, station.KioskId, station.TotalDocks,
 		station.IsEventBased, station.IsVirtual, station.TrikesAvailable,
 		station.DocksAvailable, station.BikesAvailable, station.ClassicBikesAvailable,
 		station.SmartBikesAvailable, station.ElectricBikesAvailable, station.RewardBikesAvailable,
@@ -88,203 +112,317 @@ func TestInsertStation(t *testing.T) {
 		station.KioskStatus, station.KioskPublicStatus, station.KioskConnectionStatus, station.AddressStreet,
 		station.AddressCity, station.AddressState, station.AddressZipCode, station.CloseTime,
 		station.EventEnd, station.EventStart, station.Notes, station.OpenTime,
-		station.PublicText, station.TimeZone,
-	).WillReturnResult(sqlmock.NewResult(0, 1))
-
-	kioskId := 3005
-	// Expect bikes
-	bikeQuery := "INSERT INTO bikes (at, kiosk_id, dock_number, is_electric, is_available, battery) VALUES($1,$2,$3,$4,$5,$6)"
-
-	station.Bikes = station.Bikes[:1]
-	bikes := station.Bikes
-	bike := bikes[0]
+		station.PublicText, station.TimeZone)
+}
 
-	prep = mock.ExpectPrepare(regexp.QuoteMeta(bikeQuery))
-	prep.ExpectExec().WithArgs(&lastUpdated, &kioskId, &bike.DockNumber, &bike.IsElectric, &bike.IsAvailable, &bike.Battery).WillReturnResult(sqlmock.NewResult(0, 1))
+func expectStationInfoInsert(mock sqlmock.Sqlmock, validFrom time.Time, validTo any, station models.Stations) {
+	mock.ExpectExec(regexp.QuoteMeta(stationInfoInsert)).WithArgs(station.KioskId, validFrom, validTo, station.Name, station.TotalDocks,
+		station.IsEventBased, station.IsVirtual, station.KioskType, station.Latitude,
+		station.Longitude, station.AddressStreet, station.AddressCity, station.AddressState,
+		station.AddressZipCode, station.CloseTime, station.EventEnd, station.EventStart,
+		station.Notes, station.OpenTime, station.PublicText, station.TimeZone,
+	).WillReturnResult(sqlmock.NewResult(1, 1))
+	if validTo == nil {
+		mock.ExpectExec(regexp.QuoteMeta(geometryUpsert)).WithArgs(station.KioskId, "Point",
+			pq.Array([]float64{station.Longitude, station.Latitude}),
+		).WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+}
 
-	// Expect coordinates
-	coordinatesQuery := "INSERT INTO coordinates (kiosk_id, longitude, latitude) VALUES($1,$2,$3)"
-	coordinates := station.Coordinates
+func expectStationStatusInsert(mock sqlmock.Sqlmock, lastUpdated time.Time, station models.Stations, inserted bool) {
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_xact_lock(hashtext('station'), $1)")).
+		WithArgs(station.KioskId).WillReturnResult(sqlmock.NewResult(0, 0))
 
-	prep = mock.ExpectPrepare(regexp.QuoteMeta(coordinatesQuery))
-	prep.ExpectExec().WithArgs(&kioskId, &coordinates[0], &coordinates[1]).WillReturnResult(sqlmock.NewResult(0, 1))
+	affected := int64(0)
+	if inserted {
+		affected = 1
+	}
+	mock.ExpectExec(regexp.QuoteMeta(stationStatusInsert)).WithArgs(lastUpdated, station.KioskId, station.TrikesAvailable,
+		station.DocksAvailable, station.BikesAvailable, station.ClassicBikesAvailable,
+		station.SmartBikesAvailable, station.ElectricBikesAvailable, station.RewardBikesAvailable,
+		station.RewardDocksAvailable, station.KioskStatus, station.KioskPublicStatus,
+		station.KioskConnectionStatus,
+	).WillReturnResult(sqlmock.NewResult(1, affected))
+}
 
-	err := postgres.InsertStation(lastUpdated, &station)
-	assert.NoError(t, err)
+func expectBikesInsert(mock sqlmock.Sqlmock, lastUpdated time.Time, kioskId int, bike models.Bike) {
+	bikeQuery := "INSERT INTO bikes (at, kiosk_id, dock_number, is_electric, is_available, battery) VALUES($1,$2,$3,$4,$5,$6)"
+	prep := mock.ExpectPrepare(regexp.QuoteMeta(bikeQuery))
+	prep.ExpectExec().WithArgs(lastUpdated, kioskId, bike.DockNumber, bike.IsElectric, bike.IsAvailable, bike.Battery).WillReturnResult(sqlmock.NewResult(0, 1))
 }
 
-func TestQueryAllStation(t *testing.T) {
+func TestInsertStation(t *testing.T) {
 	db, mock := NewMock()
 	postgres := &postgresDB{db}
 	defer func() {
 		postgres.db.Close()
 	}()
 
-	query := `
-		SELECT at, name, kiosk_id, total_docks, is_event_based,
-		is_virtual, trikes_available, docks_available,
-		bikes_available, classic_bikes_available, smart_bikes_available,
-		electric_bikes_available, reward_bikes_available, reward_docks_available,
-		kiosk_type, latitude, longitude, kiosk_status,
-		kiosk_public_status, kiosk_connection_status, address_street,
-		address_city, address_state, address_zipcode, close_time,
-		event_end, event_start, notes, open_time, public_text, timezone
-		FROM stations WHERE at >= $1 ORDER BY at ASC
-	`
-
 	listOfStations := DumpFiles()
 	station := listOfStations[0].Properties
+	station.Bikes = station.Bikes[:1]
 
-	rows := sqlmock.NewRows([]string{"at", "name", "kiosk_id", "total_docks", "is_event_based",
-		"is_virtual", "trikes_available", "docks_available",
-		"bikes_available", "classic_bikes_available", "smart_bikes_available",
-		"electric_bikes_available", "reward_bikes_available", "reward_docks_available",
-		"kiosk_type", "latitude", "longitude", "kiosk_status",
-		"kiosk_public_status", "kiosk_connection_status", "address_street",
-		"address_city", "address_state", "address_zipcode", "close_time",
-		"event_end", "event_start", "notes", "open_time", "public_text", "timezone"}).AddRow(station.At, station.Name, station.KioskId, station.TotalDocks,
-		station.IsEventBased, station.IsVirtual, station.TrikesAvailable,
-		station.DocksAvailable, station.BikesAvailable, station.ClassicBikesAvailable,
-		station.SmartBikesAvailable, station.ElectricBikesAvailable, station.RewardBikesAvailable,
-		station.RewardDocksAvailable, station.KioskType, station.Latitude, station.Longitude,
-		station.KioskStatus, station.KioskPublicStatus, station.KioskConnectionStatus, station.AddressStreet,
-		station.AddressCity, station.AddressState, station.AddressZipCode, station.CloseTime,
-		station.EventEnd, station.EventStart, station.Notes, station.OpenTime,
-		station.PublicText, station.TimeZone)
+	lastUpdated := time.Date(2024, 5, 14, 6, 48, 19, 588000000, time.UTC)
+	kioskId := 3005
 
-	lastUpdated := "2024-05-14T06:48:19.588Z"
-	mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs(lastUpdated).WillReturnRows(rows)
+	mock.ExpectBegin()
+	expectStationStatusInsert(mock, lastUpdated, station, true)
+	mock.ExpectQuery(regexp.QuoteMeta(stationInfoQuery)).WithArgs(kioskId, lastUpdated).WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(regexp.QuoteMeta(followingStationInfoQuery)).WithArgs(kioskId, lastUpdated).WillReturnError(sql.ErrNoRows)
+	expectStationInfoInsert(mock, lastUpdated, nil, station)
+	expectBikesInsert(mock, lastUpdated, kioskId, station.Bikes[0])
+	expectRollups(mock, station.KioskId, lastUpdated, time.UTC)
+	mock.ExpectCommit()
 
-	// Add the expected sub query for bikes
-	bike := station.Bikes[0]
-	bikesQuery := "SELECT id, kiosk_id, dock_number, is_electric, is_available, battery FROM bikes WHERE kiosk_id = $1"
-	bikeRows := sqlmock.NewRows([]string{
-		"id", "kiosk_id", "dock_number", "is_electric", "is_available", "battery",
-	}).AddRow(&bike.Id, &bike.KioskId, &bike.DockNumber, &bike.IsElectric, &bike.IsAvailable, &bike.Battery)
-	kioskId := 3005
-	mock.ExpectQuery(regexp.QuoteMeta(bikesQuery)).WithArgs(kioskId).WillReturnRows(bikeRows)
+	inserted, err := postgres.InsertStation(context.TODO(), lastUpdated, time.UTC, &station)
+	assert.NoError(t, err)
+	assert.True(t, inserted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
 
-	// Add the expected sub query for coordinates
-	coordinate := station.Coordinates
-	coordinateQuery := "SELECT longitude, latitude FROM coordinates WHERE kiosk_id = $1"
-	coordinateRows := sqlmock.NewRows([]string{"longitude", "latitude"}).AddRow(&coordinate[0], &coordinate[1])
-	mock.ExpectQuery(regexp.QuoteMeta(coordinateQuery)).WithArgs(kioskId).WillReturnRows(coordinateRows)
+func TestInsertStationDuplicate(t *testing.T) {
+	db, mock := NewMock()
+	postgres := &postgresDB{db}
+	defer postgres.db.Close()
 
-	stations, err := postgres.QueryAllStation(lastUpdated)
-	assert.NotEmpty(t, stations)
+	station := DumpFiles()[0].Properties
+	lastUpdated := time.Date(2024, 5, 14, 6, 48, 19, 588000000, time.UTC)
+
+	mock.ExpectBegin()
+	expectStationStatusInsert(mock, lastUpdated, station, false)
+	mock.ExpectRollback()
+
+	inserted, err := postgres.InsertStation(context.TODO(), lastUpdated, time.UTC, &station)
 	assert.NoError(t, err)
-	assert.Len(t, stations, 1)
+	assert.False(t, inserted)
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
-func TestQuerySpecificStation(t *testing.T) {
+func TestInsertStationUnchangedInfo(t *testing.T) {
 	db, mock := NewMock()
 	postgres := &postgresDB{db}
 	defer func() {
 		postgres.db.Close()
 	}()
 
-	query := `
-		SELECT at, name, kiosk_id, total_docks, is_event_based,
-		is_virtual, trikes_available, docks_available,
-		bikes_available, classic_bikes_available, smart_bikes_available,
-		electric_bikes_available, reward_bikes_available, reward_docks_available,
-		kiosk_type, latitude, longitude, kiosk_status,
-		kiosk_public_status, kiosk_connection_status, address_street,
-		address_city, address_state, address_zipcode, close_time,
-		event_end, event_start, notes, open_time, public_text, timezone
-		FROM stations WHERE kiosk_id = $1 AND at >= $2 ORDER BY at ASC
-	`
-
 	listOfStations := DumpFiles()
 	station := listOfStations[0].Properties
+	station.Bikes = nil
 
-	rows := sqlmock.NewRows([]string{"at", "name", "kiosk_id", "total_docks", "is_event_based",
-		"is_virtual", "trikes_available", "docks_available",
-		"bikes_available", "classic_bikes_available", "smart_bikes_available",
-		"electric_bikes_available", "reward_bikes_available", "reward_docks_available",
-		"kiosk_type", "latitude", "longitude", "kiosk_status",
-		"kiosk_public_status", "kiosk_connection_status", "address_street",
-		"address_city", "address_state", "address_zipcode", "close_time",
-		"event_end", "event_start", "notes", "open_time", "public_text", "timezone"}).AddRow(station.At, station.Name, station.KioskId, station.TotalDocks,
-		station.IsEventBased, station.IsVirtual, station.TrikesAvailable,
-		station.DocksAvailable, station.BikesAvailable, station.ClassicBikesAvailable,
-		station.SmartBikesAvailable, station.ElectricBikesAvailable, station.RewardBikesAvailable,
-		station.RewardDocksAvailable, station.KioskType, station.Latitude, station.Longitude,
-		station.KioskStatus, station.KioskPublicStatus, station.KioskConnectionStatus, station.AddressStreet,
-		station.AddressCity, station.AddressState, station.AddressZipCode, station.CloseTime,
-		station.EventEnd, station.EventStart, station.Notes, station.OpenTime,
-		station.PublicText, station.TimeZone)
-
-	lastUpdated := "2024-05-14T06:48:19.588Z"
-	kioskId := int(3005)
-	mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs(kioskId, lastUpdated).WillReturnRows(rows)
+	lastUpdated := time.Date(2024, 5, 14, 6, 48, 19, 588000000, time.UTC)
 
-	// Add the expected sub query for bikes
-	bike := station.Bikes[0]
+	mock.ExpectBegin()
+	expectStationStatusInsert(mock, lastUpdated, station, true)
+	mock.ExpectQuery(regexp.QuoteMeta(stationInfoQuery)).WithArgs(station.KioskId, lastUpdated).
+		WillReturnRows(stationInfoRow(station, lastUpdated.Add(-time.Hour), nil))
+	expectRollups(mock, station.KioskId, lastUpdated, time.UTC)
+	mock.ExpectCommit()
 
-	bikesQuery := "SELECT id, kiosk_id, dock_number, is_electric, is_available, battery FROM bikes WHERE kiosk_id = $1"
-	bikeColumn := []string{"id", "kiosk_id", "dock_number", "is_electric", "is_available", "battery"}
+	_, err := postgres.InsertStation(context.TODO(), lastUpdated, time.UTC, &station)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
 
-	bikeRows := sqlmock.NewRows(bikeColumn).AddRow(&bike.Id, &bike.KioskId, &bike.DockNumber, &bike.IsElectric, &bike.IsAvailable, &bike.Battery)
-	mock.ExpectQuery(regexp.QuoteMeta(bikesQuery)).WithArgs(kioskId).WillReturnRows(bikeRows)
+func TestInsertStationChangedInfo(t *testing.T) {
+	db, mock := NewMock()
+	postgres := &postgresDB{db}
+	defer func() {
+		postgres.db.Close()
+	}()
 
-	// Add the expected sub query for coordinates
-	coordinate := station.Coordinates
-	coordinateQuery := "SELECT longitude, latitude FROM coordinates WHERE kiosk_id = $1"
-	coordinateColumn := []string{"longitude", "latitude"}
+	listOfStations := DumpFiles()
+	station := listOfStations[0].Properties
+	station.Bikes = nil
+
+	previous := station
+	previous.Name = "Old Name"
+	previous.TotalDocks = station.TotalDocks - 2
+
+	lastUpdated := time.Date(2024, 5, 14, 6, 48, 19, 588000000, time.UTC)
+
+	mock.ExpectBegin()
+	expectStationStatusInsert(mock, lastUpdated, station, true)
+	mock.ExpectQuery(regexp.QuoteMeta(stationInfoQuery)).WithArgs(station.KioskId, lastUpdated).
+		WillReturnRows(stationInfoRow(previous, lastUpdated.Add(-time.Hour), nil))
+	mock.ExpectQuery(regexp.QuoteMeta(nextSnapshotQuery)).WithArgs(station.KioskId, lastUpdated, nil).
+		WillReturnRows(sqlmock.NewRows([]string{"min"}).AddRow(nil))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE station_info SET valid_to = $1 WHERE id = $2")).
+		WithArgs(lastUpdated, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	expectStationInfoInsert(mock, lastUpdated, nil, station)
+	expectRollups(mock, station.KioskId, lastUpdated, time.UTC)
+	mock.ExpectCommit()
+
+	_, err := postgres.InsertStation(context.TODO(), lastUpdated, time.UTC, &station)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
 
-	coordinateRows := sqlmock.NewRows(coordinateColumn).AddRow(&coordinate[0], &coordinate[1])
-	mock.ExpectQuery(regexp.QuoteMeta(coordinateQuery)).WithArgs(kioskId).WillReturnRows(coordinateRows)
+func TestInsertStationSplitsInfo(t *testing.T) {
+	db, mock := NewMock()
+	postgres := &postgresDB{db}
+	defer postgres.db.Close()
+
+	station := DumpFiles()[0].Properties
+	station.Bikes = nil
+
+	current := station
+	current.Name = "New Name"
+
+	// An imported snapshot older than the kiosk's latest one, with the
+	// attributes it had then.
+	lastUpdated := time.Date(2024, 5, 14, 6, 48, 19, 588000000, time.UTC)
+	next := lastUpdated.Add(time.Minute)
+
+	mock.ExpectBegin()
+	expectStationStatusInsert(mock, lastUpdated, station, true)
+	mock.ExpectQuery(regexp.QuoteMeta(stationInfoQuery)).WithArgs(station.KioskId, lastUpdated).
+		WillReturnRows(stationInfoRow(current, lastUpdated.Add(-time.Hour), nil))
+	mock.ExpectQuery(regexp.QuoteMeta(nextSnapshotQuery)).WithArgs(station.KioskId, lastUpdated, nil).
+		WillReturnRows(sqlmock.NewRows([]string{"min"}).AddRow(next))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE station_info SET valid_to = $1 WHERE id = $2")).
+		WithArgs(lastUpdated, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	expectStationInfoInsert(mock, lastUpdated, next, station)
+	mock.ExpectExec(regexp.QuoteMeta("SELECT kiosk_id, $2, $3,")).
+		WithArgs(1, next, nil).WillReturnResult(sqlmock.NewResult(2, 1))
+	expectRollups(mock, station.KioskId, lastUpdated, time.UTC)
+	mock.ExpectCommit()
+
+	_, err := postgres.InsertStation(context.TODO(), lastUpdated, time.UTC, &station)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
 
-	stations, err := postgres.QuerySpecificStation(kioskId, lastUpdated)
-	assert.NotEmpty(t, stations)
+func TestInsertStationExtendsInfo(t *testing.T) {
+	db, mock := NewMock()
+	postgres := &postgresDB{db}
+	defer postgres.db.Close()
+
+	station := DumpFiles()[0].Properties
+	station.Bikes = nil
+
+	// An imported snapshot from before the first version, with the same
+	// attributes.
+	lastUpdated := time.Date(2024, 5, 14, 6, 48, 19, 588000000, time.UTC)
+
+	mock.ExpectBegin()
+	expectStationStatusInsert(mock, lastUpdated, station, true)
+	mock.ExpectQuery(regexp.QuoteMeta(stationInfoQuery)).WithArgs(station.KioskId, lastUpdated).WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(regexp.QuoteMeta(followingStationInfoQuery)).WithArgs(station.KioskId, lastUpdated).
+		WillReturnRows(stationInfoRow(station, lastUpdated.AddDate(0, 1, 0), nil))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE station_info SET valid_from = $1 WHERE id = $2")).
+		WithArgs(lastUpdated, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	expectRollups(mock, station.KioskId, lastUpdated, time.UTC)
+	mock.ExpectCommit()
+
+	_, err := postgres.InsertStation(context.TODO(), lastUpdated, time.UTC, &station)
 	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
-func TestFetchCooordinates(t *testing.T) {
+func TestQueryAllStation(t *testing.T) {
 	db, mock := NewMock()
 	postgres := &postgresDB{db}
 	defer func() {
 		postgres.db.Close()
 	}()
 
+	query := "SELECT" + stationColumns + " WHERE s.at >= $1 ORDER BY s.at ASC"
+
 	listOfStations := DumpFiles()
 	station := listOfStations[0].Properties
+	station.At = time.Date(2024, 5, 14, 6, 48, 19, 588000000, time.UTC)
 
-	kioskId := 3005
+	lastUpdated := time.Date(2024, 5, 14, 6, 48, 19, 588000000, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs(lastUpdated).WillReturnRows(stationRows(station))
+
+	// Add the expected query for the bikes of every snapshot
+	bike := station.Bikes[0]
+	bikesQuery := "SELECT id, kiosk_id, at, dock_number, is_electric, is_available, battery FROM bikes WHERE at >= $1"
+	bikeRows := sqlmock.NewRows(bikeColumnNames).
+		AddRow(bike.Id, station.KioskId, station.At, bike.DockNumber, bike.IsElectric, bike.IsAvailable, bike.Battery).
+		AddRow(bike.Id, 3006, station.At, bike.DockNumber, bike.IsElectric, bike.IsAvailable, bike.Battery)
+	mock.ExpectQuery(regexp.QuoteMeta(bikesQuery)).WithArgs(lastUpdated).WillReturnRows(bikeRows)
+
+	stations, err := postgres.QueryAllStation(context.TODO(), lastUpdated)
+	assert.NotEmpty(t, stations)
+	assert.NoError(t, err)
+	assert.Len(t, stations, 1)
+	assert.Len(t, stations[0].Bikes, 1)
+	assert.Equal(t, []float64{station.Longitude, station.Latitude}, stations[0].Coordinates)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
 
-	coordinate := station.Coordinates
-	coordinateQuery := "SELECT longitude, latitude FROM coordinates WHERE kiosk_id = $1"
-	coordinateColumn := []string{"longitude", "latitude"}
+func TestQuerySnapshot(t *testing.T) {
+	db, mock := NewMock()
+	postgres := &postgresDB{db}
+	defer postgres.db.Close()
 
-	coordinateRows := sqlmock.NewRows(coordinateColumn).AddRow(&coordinate[0], &coordinate[1])
-	mock.ExpectQuery(regexp.QuoteMeta(coordinateQuery)).WithArgs(kioskId).WillReturnRows(coordinateRows)
+	query := "SELECT" + stationColumns + " WHERE s.at = $1 ORDER BY s.kiosk_id"
 
-	bikes, err := postgres.fetchCoordinates(context.TODO(), kioskId)
-	assert.NotEmpty(t, bikes)
+	listOfStations := DumpFiles()
+	station := listOfStations[0].Properties
+	station.At = time.Date(2024, 5, 14, 6, 48, 19, 588000000, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs(station.At).WillReturnRows(stationRows(station))
+
+	// Only the bikes of that snapshot are loaded.
+	bike := station.Bikes[0]
+	bikesQuery := "SELECT id, kiosk_id, at, dock_number, is_electric, is_available, battery FROM bikes WHERE at = $1"
+	bikeRows := sqlmock.NewRows(bikeColumnNames).
+		AddRow(bike.Id, station.KioskId, station.At, bike.DockNumber, bike.IsElectric, bike.IsAvailable, bike.Battery).
+		AddRow(bike.Id, 3006, station.At, bike.DockNumber, bike.IsElectric, bike.IsAvailable, bike.Battery)
+	mock.ExpectQuery(regexp.QuoteMeta(bikesQuery)).WithArgs(station.At).WillReturnRows(bikeRows)
+
+	stations, err := postgres.QuerySnapshot(context.TODO(), station.At)
 	assert.NoError(t, err)
+	assert.Len(t, stations, 1)
+	assert.Len(t, stations[0].Bikes, 1)
+	assert.Equal(t, station.At, stations[0].At)
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
-func TestInsertCoordinates(t *testing.T) {
+func TestQuerySpecificStation(t *testing.T) {
 	db, mock := NewMock()
 	postgres := &postgresDB{db}
 	defer func() {
 		postgres.db.Close()
 	}()
 
+	query := "SELECT" + stationColumns + " WHERE s.kiosk_id = $1 AND s.at >= $2 ORDER BY s.at ASC"
+
 	listOfStations := DumpFiles()
 	station := listOfStations[0].Properties
+	station.At = time.Date(2024, 5, 14, 6, 48, 19, 588000000, time.UTC)
 
-	query := "INSERT INTO coordinates (kiosk_id, longitude, latitude) VALUES($1,$2,$3)"
-
-	coordinates := station.Coordinates
-	kioskId := 3005
+	lastUpdated := time.Date(2024, 5, 14, 6, 48, 19, 588000000, time.UTC)
+	kioskId := int(3005)
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs(kioskId, lastUpdated).WillReturnRows(stationRows(station))
 
-	prep := mock.ExpectPrepare(regexp.QuoteMeta(query))
-	prep.ExpectExec().WithArgs(&kioskId, &coordinates[0], &coordinates[1]).WillReturnResult(sqlmock.NewResult(0, 1))
+	// Add the expected sub query for bikes
+	bike := station.Bikes[0]
+	bikesQuery := "SELECT id, kiosk_id, at, dock_number, is_electric, is_available, battery FROM bikes WHERE kiosk_id = $1 AND at = $2"
+	bikeRows := sqlmock.NewRows(bikeColumnNames).AddRow(bike.Id, kioskId, station.At, bike.DockNumber, bike.IsElectric, bike.IsAvailable, bike.Battery)
+	mock.ExpectQuery(regexp.QuoteMeta(bikesQuery)).WithArgs(kioskId, station.At).WillReturnRows(bikeRows)
 
-	err := postgres.insertCoordinates(context.TODO(), kioskId, coordinates)
+	stations, err := postgres.QuerySpecificStation(context.TODO(), kioskId, lastUpdated)
+	assert.NotEmpty(t, stations)
 	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestQuerySpecificStationNotFound(t *testing.T) {
+	db, mock := NewMock()
+	postgres := &postgresDB{db}
+	defer postgres.db.Close()
+
+	query := "SELECT" + stationColumns + " WHERE s.kiosk_id = $1 AND s.at >= $2 ORDER BY s.at ASC"
+	lastUpdated := time.Date(2024, 5, 14, 6, 48, 19, 588000000, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs(9999, lastUpdated).
+		WillReturnRows(sqlmock.NewRows(nil))
+
+	_, err := postgres.QuerySpecificStation(context.TODO(), 9999, lastUpdated)
+	assert.ErrorIs(t, err, database.ErrNotFound)
+	assert.ErrorIs(t, err, apperr.NotFound("station", ""))
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
 func TestFetchBikes(t *testing.T) {
@@ -299,14 +437,13 @@ func TestFetchBikes(t *testing.T) {
 
 	bike := station.Bikes[0]
 	kioskId := 3005
+	at := time.Date(2024, 5, 14, 6, 48, 19, 588000000, time.UTC)
 
-	bikesQuery := "SELECT id, kiosk_id, dock_number, is_electric, is_available, battery FROM bikes WHERE kiosk_id = $1"
-	bikeColumn := []string{"id", "kiosk_id", "dock_number", "is_electric", "is_available", "battery"}
-
-	bikeRows := sqlmock.NewRows(bikeColumn).AddRow(&bike.Id, &bike.KioskId, &bike.DockNumber, &bike.IsElectric, &bike.IsAvailable, &bike.Battery)
-	mock.ExpectQuery(regexp.QuoteMeta(bikesQuery)).WithArgs(kioskId).WillReturnRows(bikeRows)
+	bikesQuery := "SELECT id, kiosk_id, at, dock_number, is_electric, is_available, battery FROM bikes WHERE kiosk_id = $1 AND at = $2"
+	bikeRows := sqlmock.NewRows(bikeColumnNames).AddRow(bike.Id, kioskId, at, bike.DockNumber, bike.IsElectric, bike.IsAvailable, bike.Battery)
+	mock.ExpectQuery(regexp.QuoteMeta(bikesQuery)).WithArgs(kioskId, at).WillReturnRows(bikeRows)
 
-	bikes, err := postgres.fetchBikes(context.TODO(), kioskId)
+	bikes, err := postgres.fetchBikes(context.TODO(), kioskId, at)
 	assert.NotEmpty(t, bikes)
 	assert.NoError(t, err)
 }
@@ -321,16 +458,16 @@ func TestInsertBikes(t *testing.T) {
 	listOfStations := DumpFiles()
 	station := listOfStations[0].Properties
 
-	query := "INSERT INTO bikes (at, kiosk_id, dock_number, is_electric, is_available, battery) VALUES($1,$2,$3,$4,$5,$6)"
-
 	bikes := station.Bikes[:1] // limit 1
-	bike := bikes[0]
-	lastUpdated := "2024-05-14T06:48:19.588Z"
+	lastUpdated := time.Date(2024, 5, 14, 6, 48, 19, 588000000, time.UTC)
 	kioskId := 3005
 
-	prep := mock.ExpectPrepare(regexp.QuoteMeta(query))
-	prep.ExpectExec().WithArgs(&lastUpdated, &kioskId, &bike.DockNumber, &bike.IsElectric, &bike.IsAvailable, &bike.Battery).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectBegin()
+	expectBikesInsert(mock, lastUpdated, kioskId, bikes[0])
+
+	tx, err := db.Begin()
+	assert.NoError(t, err)
 
-	err := postgres.insertBikes(context.TODO(), kioskId, lastUpdated, bikes)
+	err = postgres.insertBikes(context.TODO(), tx, kioskId, lastUpdated, bikes)
 	assert.NoError(t, err)
 }
@@ -0,0 +1,187 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/macadrich/go-bike/database/models"
+	"github.com/macadrich/go-bike/pkg/tracing"
+)
+
+// rollupUpsert recomputes the rollup row of a kiosk's bucket from its
+// samples in [$2, $3), ordered by time, so that the result does not depend
+// on the order in which snapshots were stored. Each sample lasts until the
+// next one or the end of the bucket. %s is the table.
+const rollupUpsert = `
+		INSERT INTO %s AS r
+		(
+			kiosk_id, bucket, samples, bikes_min, bikes_max, bikes_sum,
+			docks_min, docks_max, docks_sum, ebikes_min, ebikes_max, ebikes_sum,
+			minutes_empty, minutes_full, last_at, last_bikes, last_docks
+		)
+		SELECT
+			$1, $2, COUNT(*),
+			MIN(bikes), MAX(bikes), SUM(bikes),
+			MIN(docks), MAX(docks), SUM(docks),
+			MIN(ebikes), MAX(ebikes), SUM(ebikes),
+			COALESCE(SUM(EXTRACT(EPOCH FROM next_at - at) / 60) FILTER (WHERE bikes = 0), 0),
+			COALESCE(SUM(EXTRACT(EPOCH FROM next_at - at) / 60) FILTER (WHERE docks = 0), 0),
+			MAX(at),
+			(array_agg(bikes ORDER BY at DESC))[1],
+			(array_agg(docks ORDER BY at DESC))[1]
+		FROM (
+			SELECT at, COALESCE(bikes_available, 0) AS bikes, COALESCE(docks_available, 0) AS docks,
+			COALESCE(electric_bikes_available, 0) AS ebikes, COALESCE(LEAD(at) OVER (ORDER BY at), $3) AS next_at
+			FROM station_status WHERE kiosk_id = $1 AND at >= $2 AND at < $3
+		) s
+		ON CONFLICT (kiosk_id, bucket) DO UPDATE SET
+			samples = EXCLUDED.samples,
+			bikes_min = EXCLUDED.bikes_min,
+			bikes_max = EXCLUDED.bikes_max,
+			bikes_sum = EXCLUDED.bikes_sum,
+			docks_min = EXCLUDED.docks_min,
+			docks_max = EXCLUDED.docks_max,
+			docks_sum = EXCLUDED.docks_sum,
+			ebikes_min = EXCLUDED.ebikes_min,
+			ebikes_max = EXCLUDED.ebikes_max,
+			ebikes_sum = EXCLUDED.ebikes_sum,
+			minutes_empty = EXCLUDED.minutes_empty,
+			minutes_full = EXCLUDED.minutes_full,
+			last_at = EXCLUDED.last_at,
+			last_bikes = EXCLUDED.last_bikes,
+			last_docks = EXCLUDED.last_docks
+	`
+
+// rollupTables maps a resolution to its table and bucket width.
+var rollupTables = map[string]struct {
+	table string
+	width string
+}{
+	models.ResolutionHour: {"station_rollup_hourly", "1 hour"},
+	models.ResolutionDay:  {"station_rollup_daily", "1 day"},
+}
+
+// hourBucket and dayBucket return the start of the rollup buckets holding
+// at. Days start at midnight in loc, so they stay aligned across DST.
+func hourBucket(at time.Time) time.Time {
+	return at.UTC().Truncate(time.Hour)
+}
+
+func dayBucket(at time.Time, loc *time.Location) time.Time {
+	y, m, d := at.In(loc).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, loc)
+}
+
+// updateRollups refreshes the hourly and daily rollups of the buckets
+// holding a kiosk's snapshot taken at. It runs in the transaction storing
+// the snapshot, under the kiosk's lock.
+func updateRollups(ctx context.Context, tx *sql.Tx, kioskId int, at time.Time, loc *time.Location) error {
+	day := dayBucket(at, loc)
+	for _, bucket := range []struct {
+		resolution string
+		start, end time.Time
+	}{
+		{models.ResolutionHour, hourBucket(at), hourBucket(at).Add(time.Hour)},
+		{models.ResolutionDay, day, day.AddDate(0, 0, 1)},
+	} {
+		query := fmt.Sprintf(rollupUpsert, rollupTables[bucket.resolution].table)
+		if _, err := tx.ExecContext(ctx, query, kioskId, bucket.start, bucket.end); err != nil {
+			return fmt.Errorf("error updating %s rollup: %w", bucket.resolution, err)
+		}
+	}
+
+	return nil
+}
+
+// StationHistory returns the history of a kiosk between from and to at the
+// given resolution. Rollup buckets overlapping the range are included.
+func (p *postgresDB) StationHistory(ctx context.Context, kioskId int, from, to time.Time, resolution string) (_ []models.HistoryPoint, err error) {
+	ctx, span := startSpan(ctx, "StationHistory")
+	defer func() { tracing.End(span, err) }()
+
+	var query string
+	if resolution == models.ResolutionRaw {
+		query = `
+		SELECT at, 1, bikes, bikes, bikes, docks, docks, docks, ebikes, ebikes, ebikes, 0, 0
+		FROM (
+			SELECT at, COALESCE(bikes_available, 0) AS bikes, COALESCE(docks_available, 0) AS docks,
+			COALESCE(electric_bikes_available, 0) AS ebikes
+			FROM station_status WHERE kiosk_id = $1 AND at >= $2 AND at < $3
+		) s ORDER BY at ASC
+	`
+	} else {
+		rollup, ok := rollupTables[resolution]
+		if !ok {
+			return nil, fmt.Errorf("unknown resolution %q", resolution)
+		}
+		query = fmt.Sprintf(`
+		SELECT bucket, samples, bikes_min, bikes_max, bikes_sum::float8 / samples,
+		docks_min, docks_max, docks_sum::float8 / samples,
+		ebikes_min, ebikes_max, ebikes_sum::float8 / samples,
+		minutes_empty, minutes_full
+		FROM %s WHERE kiosk_id = $1 AND bucket > $2::timestamptz - INTERVAL '%s' AND bucket < $3
+		ORDER BY bucket ASC
+	`, rollup.table, rollup.width)
+	}
+
+	rows, err := p.db.QueryContext(ctx, query, kioskId, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer rows.Close()
+
+	points := []models.HistoryPoint{}
+	for rows.Next() {
+		var point models.HistoryPoint
+		err := rows.Scan(&point.At, &point.Samples, &point.BikesMin, &point.BikesMax, &point.BikesAvg,
+			&point.DocksMin, &point.DocksMax, &point.DocksAvg, &point.EBikesMin, &point.EBikesMax,
+			&point.EBikesAvg, &point.MinutesEmpty, &point.MinutesFull)
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, point)
+	}
+
+	return points, rows.Err()
+}
+
+// FillRatios returns, per kiosk, the share of its docks holding a bike
+// over the given hourly buckets, from the hourly rollups.
+func (p *postgresDB) FillRatios(ctx context.Context, buckets []time.Time) (_ map[int]float64, err error) {
+	ctx, span := startSpan(ctx, "FillRatios")
+	defer func() { tracing.End(span, err) }()
+
+	values := make([]string, len(buckets))
+	for i, b := range buckets {
+		values[i] = hourBucket(b).Format(time.RFC3339)
+	}
+
+	query := `
+		SELECT kiosk_id, SUM(bikes_sum)::float8 / NULLIF(SUM(bikes_sum + docks_sum), 0)
+		FROM station_rollup_hourly
+		WHERE bucket = ANY($1::timestamptz[])
+		GROUP BY kiosk_id
+	`
+	rows, err := p.db.QueryContext(ctx, query, pq.Array(values))
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer rows.Close()
+
+	ratios := make(map[int]float64)
+	for rows.Next() {
+		var kioskId int
+		var ratio sql.NullFloat64
+		if err := rows.Scan(&kioskId, &ratio); err != nil {
+			return nil, err
+		}
+		if ratio.Valid {
+			ratios[kioskId] = ratio.Float64
+		}
+	}
+
+	return ratios, rows.Err()
+}
@@ -0,0 +1,180 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/macadrich/go-bike/database/migrate"
+	"github.com/macadrich/go-bike/database/models"
+	"github.com/macadrich/go-bike/migrations"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRollupBuckets(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	// 02:30 UTC on 10 March 2024 is still 9 March in New York.
+	at := time.Date(2024, 3, 10, 2, 30, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2024, 3, 10, 2, 0, 0, 0, time.UTC), hourBucket(at))
+	assert.True(t, time.Date(2024, 3, 9, 5, 0, 0, 0, time.UTC).Equal(dayBucket(at, loc)))
+
+	// The day after the switch to DST starts four hours after midnight UTC.
+	at = time.Date(2024, 3, 11, 12, 0, 0, 0, time.UTC)
+	assert.True(t, time.Date(2024, 3, 11, 4, 0, 0, 0, time.UTC).Equal(dayBucket(at, loc)))
+}
+
+// expectRollups expects the refresh of the hourly and daily rollups of
+// kioskId holding at, with days in loc.
+func expectRollups(mock sqlmock.Sqlmock, kioskId int, at time.Time, loc *time.Location) {
+	hour, day := hourBucket(at), dayBucket(at, loc)
+	mock.ExpectExec(regexp.QuoteMeta(fmt.Sprintf(rollupUpsert, "station_rollup_hourly"))).
+		WithArgs(kioskId, hour, hour.Add(time.Hour)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(fmt.Sprintf(rollupUpsert, "station_rollup_daily"))).
+		WithArgs(kioskId, day, day.AddDate(0, 0, 1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func TestUpdateRollups(t *testing.T) {
+	db, mock := NewMock()
+	defer db.Close()
+
+	loc, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	at := time.Date(2024, 5, 14, 2, 48, 19, 0, time.UTC)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(fmt.Sprintf(rollupUpsert, "station_rollup_hourly"))).
+		WithArgs(3005, time.Date(2024, 5, 14, 2, 0, 0, 0, time.UTC), time.Date(2024, 5, 14, 3, 0, 0, 0, time.UTC)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// 02:48 UTC is still 13 May in New York.
+	mock.ExpectExec(regexp.QuoteMeta(fmt.Sprintf(rollupUpsert, "station_rollup_daily"))).
+		WithArgs(3005, time.Date(2024, 5, 13, 0, 0, 0, 0, loc), time.Date(2024, 5, 14, 0, 0, 0, 0, loc)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	require.NoError(t, updateRollups(context.TODO(), tx, 3005, at, loc))
+	require.NoError(t, tx.Commit())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestRollupMinutesUntilBucketEnd runs the rollup against Postgres, where
+// the minutes are computed, when GOBIKE_TEST_DATABASE_URL is set.
+func TestRollupMinutesUntilBucketEnd(t *testing.T) {
+	dsn := os.Getenv("GOBIKE_TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("GOBIKE_TEST_DATABASE_URL is not set")
+	}
+	ctx := context.TODO()
+
+	db, err := sql.Open("postgres", dsn)
+	require.NoError(t, err)
+	// A single connection keeps the search path below.
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	schema := fmt.Sprintf("rollup_test_%d", time.Now().UnixNano())
+	_, err = db.Exec("CREATE SCHEMA " + schema + "; SET search_path TO " + schema)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Exec("DROP SCHEMA " + schema + " CASCADE") })
+
+	m, err := migrate.New(db, migrations.FS)
+	require.NoError(t, err)
+	_, err = m.Up(ctx)
+	require.NoError(t, err)
+
+	postgres := &postgresDB{db}
+	hour := time.Date(2024, 5, 14, 6, 0, 0, 0, time.UTC)
+	require.NoError(t, postgres.EnsurePartitions(ctx, hour, hour))
+
+	// Three bikes from 06:10, none from 06:40 until the hour ends.
+	insert := `INSERT INTO station_status (at, kiosk_id, bikes_available, docks_available,
+		kiosk_status, kiosk_public_status, kiosk_connection_status)
+		VALUES ($1, 3005, $2, $3, 'FullService', 'Active', 'Active')`
+	_, err = db.Exec(insert, hour.Add(10*time.Minute), 3, 12)
+	require.NoError(t, err)
+	_, err = db.Exec(insert, hour.Add(40*time.Minute), 0, 15)
+	require.NoError(t, err)
+
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	require.NoError(t, updateRollups(ctx, tx, 3005, hour.Add(40*time.Minute), time.UTC))
+	require.NoError(t, tx.Commit())
+
+	points, err := postgres.StationHistory(ctx, 3005, hour, hour.Add(time.Hour), models.ResolutionHour)
+	require.NoError(t, err)
+	require.Len(t, points, 1)
+	assert.Equal(t, 20.0, points[0].MinutesEmpty)
+	assert.Equal(t, 0.0, points[0].MinutesFull)
+}
+
+func TestStationHistory(t *testing.T) {
+	columns := []string{"at", "samples", "bikes_min", "bikes_max", "bikes_avg", "docks_min", "docks_max",
+		"docks_avg", "ebikes_min", "ebikes_max", "ebikes_avg", "minutes_empty", "minutes_full"}
+	from := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 5, 8, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		resolution string
+		query      string
+	}{
+		{models.ResolutionRaw, "FROM station_status WHERE kiosk_id = $1 AND at >= $2 AND at < $3"},
+		{models.ResolutionHour, "FROM station_rollup_hourly WHERE kiosk_id = $1 AND bucket > $2::timestamptz - INTERVAL '1 hour'"},
+		{models.ResolutionDay, "FROM station_rollup_daily WHERE kiosk_id = $1 AND bucket > $2::timestamptz - INTERVAL '1 day'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.resolution, func(t *testing.T) {
+			db, mock := NewMock()
+			postgres := &postgresDB{db}
+			defer postgres.db.Close()
+
+			rows := sqlmock.NewRows(columns).AddRow(from, 60, 0, 7, 3.5, 8, 15, 11.5, 0, 2, 0.5, 12.0, 0.0)
+			mock.ExpectQuery(regexp.QuoteMeta(tt.query)).WithArgs(3005, from, to).WillReturnRows(rows)
+
+			points, err := postgres.StationHistory(context.TODO(), 3005, from, to, tt.resolution)
+			require.NoError(t, err)
+			require.Len(t, points, 1)
+			assert.Equal(t, 3.5, points[0].BikesAvg)
+			assert.Equal(t, 12.0, points[0].MinutesEmpty)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+
+	postgres := &postgresDB{}
+	_, err := postgres.StationHistory(context.TODO(), 3005, from, to, "week")
+	assert.Error(t, err)
+}
+
+func TestFillRatios(t *testing.T) {
+	db, mock := NewMock()
+	postgres := &postgresDB{db}
+	defer postgres.db.Close()
+
+	buckets := []time.Time{
+		time.Date(2024, 5, 7, 8, 30, 0, 0, time.UTC),
+		time.Date(2024, 4, 30, 8, 0, 0, 0, time.UTC),
+	}
+	rows := sqlmock.NewRows([]string{"kiosk_id", "ratio"}).
+		AddRow(3005, 0.25).
+		AddRow(3006, nil)
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE bucket = ANY($1::timestamptz[])")).
+		WithArgs(pq.Array([]string{"2024-05-07T08:00:00Z", "2024-04-30T08:00:00Z"})).
+		WillReturnRows(rows)
+
+	ratios, err := postgres.FillRatios(context.TODO(), buckets)
+	require.NoError(t, err)
+	assert.Equal(t, map[int]float64{3005: 0.25}, ratios)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,90 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/macadrich/go-bike/database/models"
+	"github.com/macadrich/go-bike/pkg/tracing"
+)
+
+// DockMoves passes the departures and arrivals between every snapshot of a
+// kiosk taken in [from, to) and the one before it, optionally for a single
+// kiosk; the first pair may start at the last snapshot before from. The
+// docks are diffed in the database, as described in package trips, so
+// only pairs with movement are sent.
+func (p *postgresDB) DockMoves(ctx context.Context, from, to time.Time, kioskId int, fn func(models.DockMove) error) (err error) {
+	ctx, span := startSpan(ctx, "DockMoves")
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		WITH pairs AS (
+			SELECT kiosk_id, prev_at, at
+			FROM (
+				SELECT kiosk_id, at, LAG(at) OVER (PARTITION BY kiosk_id ORDER BY at) AS prev_at
+				FROM station_status
+				WHERE at >= COALESCE((SELECT MAX(at) FROM station_status WHERE at < $1), $1)
+					AND at < $2 AND ($3 = 0 OR kiosk_id = $3)
+			) s
+			WHERE prev_at IS NOT NULL AND at >= $1
+		)
+		SELECT p.kiosk_id, p.prev_at, p.at, m.departures, m.arrivals
+		FROM pairs p
+		CROSS JOIN LATERAL (
+			SELECT
+				COUNT(*) FILTER (WHERE b.dock_number IS NOT NULL
+					AND (a.dock_number IS NULL OR a.is_electric IS DISTINCT FROM b.is_electric)) AS departures,
+				COUNT(*) FILTER (WHERE a.dock_number IS NOT NULL
+					AND (b.dock_number IS NULL OR a.is_electric IS DISTINCT FROM b.is_electric)) AS arrivals
+			FROM (SELECT dock_number, is_electric FROM bikes WHERE kiosk_id = p.kiosk_id AND at = p.prev_at) b
+			FULL JOIN (SELECT dock_number, is_electric FROM bikes WHERE kiosk_id = p.kiosk_id AND at = p.at) a
+				ON a.dock_number = b.dock_number
+		) m
+		WHERE m.departures > 0 OR m.arrivals > 0
+	`
+	rows, err := p.db.QueryContext(ctx, query, from, to, kioskId)
+	if err != nil {
+		return fmt.Errorf("query error: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var move models.DockMove
+		if err := rows.Scan(&move.KioskId, &move.PrevAt, &move.At, &move.Departures, &move.Arrivals); err != nil {
+			return err
+		}
+		if err := fn(move); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// StationCoordinates returns the location of every kiosk.
+func (p *postgresDB) StationCoordinates(ctx context.Context) (_ map[int]models.Coordinate, err error) {
+	ctx, span := startSpan(ctx, "StationCoordinates")
+	defer func() { tracing.End(span, err) }()
+
+	rows, err := p.db.QueryContext(ctx, "SELECT kiosk_id, coordinates FROM geometry")
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer rows.Close()
+
+	coords := make(map[int]models.Coordinate)
+	for rows.Next() {
+		var kioskId int
+		var point pq.Float64Array
+		if err := rows.Scan(&kioskId, &point); err != nil {
+			return nil, err
+		}
+		if len(point) == 2 {
+			coords[kioskId] = models.Coordinate{Long: point[0], Lat: point[1]}
+		}
+	}
+
+	return coords, rows.Err()
+}
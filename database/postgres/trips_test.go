@@ -0,0 +1,76 @@
+package postgres
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/macadrich/go-bike/database/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDockMoves(t *testing.T) {
+	db, mock := NewMock()
+	postgres := &postgresDB{db}
+	defer postgres.db.Close()
+
+	from := time.Date(2024, 5, 14, 6, 0, 0, 0, time.UTC)
+	to := from.Add(time.Hour)
+	rows := sqlmock.NewRows([]string{"kiosk_id", "prev_at", "at", "departures", "arrivals"}).
+		AddRow(3005, from.Add(-time.Minute), from.Add(time.Minute), 2, 0).
+		AddRow(3006, from.Add(time.Minute), from.Add(2*time.Minute), 1, 1)
+	mock.ExpectQuery(regexp.QuoteMeta("LAG(at) OVER (PARTITION BY kiosk_id ORDER BY at)")).
+		WithArgs(from, to, 0).WillReturnRows(rows)
+
+	var moves []models.DockMove
+	err := postgres.DockMoves(context.TODO(), from, to, 0, func(move models.DockMove) error {
+		moves = append(moves, move)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, moves, 2)
+	assert.Equal(t, models.DockMove{KioskId: 3005, PrevAt: from.Add(-time.Minute), At: from.Add(time.Minute), Departures: 2}, moves[0])
+	assert.Equal(t, 1, moves[1].Arrivals)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDockMovesStops(t *testing.T) {
+	db, mock := NewMock()
+	postgres := &postgresDB{db}
+	defer postgres.db.Close()
+
+	from := time.Date(2024, 5, 14, 6, 0, 0, 0, time.UTC)
+	rows := sqlmock.NewRows([]string{"kiosk_id", "prev_at", "at", "departures", "arrivals"}).
+		AddRow(3005, from, from.Add(time.Minute), 1, 0).
+		AddRow(3006, from, from.Add(time.Minute), 1, 0)
+	mock.ExpectQuery(regexp.QuoteMeta("FULL JOIN")).WillReturnRows(rows)
+
+	calls := 0
+	err := postgres.DockMoves(context.TODO(), from, from.Add(time.Hour), 0, func(models.DockMove) error {
+		calls++
+		return context.Canceled
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, calls)
+}
+
+func TestStationCoordinates(t *testing.T) {
+	db, mock := NewMock()
+	postgres := &postgresDB{db}
+	defer postgres.db.Close()
+
+	rows := sqlmock.NewRows([]string{"kiosk_id", "coordinates"}).
+		AddRow(3005, "{-75.14403,39.94733}").
+		AddRow(3006, "{}")
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT kiosk_id, coordinates FROM geometry")).WillReturnRows(rows)
+
+	coords, err := postgres.StationCoordinates(context.TODO())
+	require.NoError(t, err)
+	assert.Len(t, coords, 1)
+	assert.Equal(t, -75.14403, coords[3005].Long)
+	assert.Equal(t, 39.94733, coords[3005].Lat)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,94 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/macadrich/go-bike/database/models"
+	"github.com/macadrich/go-bike/pkg/tracing"
+)
+
+// InsertWeather stores an observation. The same observation is reported
+// until OpenWeather updates it, so repeats are ignored.
+func (p *postgresDB) InsertWeather(ctx context.Context, obs *models.WeatherObservation) (err error) {
+	ctx, span := startSpan(ctx, "InsertWeather")
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		INSERT INTO weather_observations
+		(at, condition, description, temperature, feels_like, humidity, wind_speed, clouds)
+		VALUES($1,$2,$3,$4,$5,$6,$7,$8)
+		ON CONFLICT (at) DO NOTHING
+	`
+	_, err = p.db.ExecContext(ctx, query, obs.At, obs.Condition, obs.Description, obs.Temperature,
+		obs.FeelsLike, obs.Humidity, obs.WindSpeed, obs.Clouds)
+	if err != nil {
+		return fmt.Errorf("error inserting weather: %w", err)
+	}
+
+	return nil
+}
+
+// WeatherHistory returns the observations in [from, to), oldest first.
+func (p *postgresDB) WeatherHistory(ctx context.Context, from, to time.Time) (_ []models.WeatherObservation, err error) {
+	ctx, span := startSpan(ctx, "WeatherHistory")
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		SELECT at, condition, description, temperature, feels_like, humidity, wind_speed, clouds
+		FROM weather_observations
+		WHERE at >= $1 AND at < $2
+		ORDER BY at ASC
+	`
+	rows, err := p.db.QueryContext(ctx, query, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer rows.Close()
+
+	observations := []models.WeatherObservation{}
+	for rows.Next() {
+		var obs models.WeatherObservation
+		err := rows.Scan(&obs.At, &obs.Condition, &obs.Description, &obs.Temperature, &obs.FeelsLike,
+			&obs.Humidity, &obs.WindSpeed, &obs.Clouds)
+		if err != nil {
+			return nil, err
+		}
+		observations = append(observations, obs)
+	}
+
+	return observations, rows.Err()
+}
+
+// NearestWeather returns the observation closest in time to at, or nil when
+// none is stored.
+func (p *postgresDB) NearestWeather(ctx context.Context, at time.Time) (_ *models.WeatherObservation, err error) {
+	ctx, span := startSpan(ctx, "NearestWeather")
+	defer func() { tracing.End(span, err) }()
+
+	// The closest observation on either side, each found with the index.
+	query := `
+		SELECT at, condition, description, temperature, feels_like, humidity, wind_speed, clouds
+		FROM (
+			(SELECT * FROM weather_observations WHERE at <= $1 ORDER BY at DESC LIMIT 1)
+			UNION ALL
+			(SELECT * FROM weather_observations WHERE at > $1 ORDER BY at ASC LIMIT 1)
+		) w
+		ORDER BY ABS(EXTRACT(EPOCH FROM w.at - $1::timestamptz)) ASC
+		LIMIT 1
+	`
+	var obs models.WeatherObservation
+	err = p.db.QueryRowContext(ctx, query, at).Scan(&obs.At, &obs.Condition, &obs.Description, &obs.Temperature,
+		&obs.FeelsLike, &obs.Humidity, &obs.WindSpeed, &obs.Clouds)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+
+	return &obs, nil
+}
@@ -0,0 +1,78 @@
+package postgres
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/macadrich/go-bike/database/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInsertWeather(t *testing.T) {
+	db, mock := NewMock()
+	postgres := &postgresDB{db}
+	defer postgres.db.Close()
+
+	obs := &models.WeatherObservation{
+		At:          time.Date(2024, 5, 14, 6, 45, 0, 0, time.UTC),
+		Condition:   "Rain",
+		Description: "light rain",
+		Temperature: 58.3,
+		FeelsLike:   57.9,
+		Humidity:    88,
+		WindSpeed:   9.2,
+		Clouds:      100,
+	}
+	mock.ExpectExec(regexp.QuoteMeta("ON CONFLICT (at) DO NOTHING")).
+		WithArgs(obs.At, obs.Condition, obs.Description, obs.Temperature, obs.FeelsLike, obs.Humidity, obs.WindSpeed, obs.Clouds).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	require.NoError(t, postgres.InsertWeather(context.TODO(), obs))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWeatherHistory(t *testing.T) {
+	db, mock := NewMock()
+	postgres := &postgresDB{db}
+	defer postgres.db.Close()
+
+	from := time.Date(2024, 5, 14, 0, 0, 0, 0, time.UTC)
+	to := from.Add(24 * time.Hour)
+	rows := sqlmock.NewRows([]string{"at", "condition", "description", "temperature", "feels_like", "humidity", "wind_speed", "clouds"}).
+		AddRow(from.Add(time.Hour), "Clear", "clear sky", 61.0, 60.2, 50, 4.1, 0)
+	mock.ExpectQuery(regexp.QuoteMeta("FROM weather_observations")).WithArgs(from, to).WillReturnRows(rows)
+
+	observations, err := postgres.WeatherHistory(context.TODO(), from, to)
+	require.NoError(t, err)
+	require.Len(t, observations, 1)
+	assert.Equal(t, "Clear", observations[0].Condition)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNearestWeather(t *testing.T) {
+	db, mock := NewMock()
+	postgres := &postgresDB{db}
+	defer postgres.db.Close()
+
+	at := time.Date(2024, 5, 14, 6, 48, 0, 0, time.UTC)
+	query := regexp.QuoteMeta("ORDER BY ABS(EXTRACT(EPOCH FROM w.at - $1::timestamptz)) ASC")
+	rows := sqlmock.NewRows([]string{"at", "condition", "description", "temperature", "feels_like", "humidity", "wind_speed", "clouds"}).
+		AddRow(at.Add(-3*time.Minute), "Rain", "light rain", 58.3, 57.9, 88, 9.2, 100)
+	mock.ExpectQuery(query).WithArgs(at).WillReturnRows(rows)
+	mock.ExpectQuery(query).WithArgs(at).WillReturnRows(sqlmock.NewRows([]string{"at"}))
+
+	obs, err := postgres.NearestWeather(context.TODO(), at)
+	require.NoError(t, err)
+	require.NotNil(t, obs)
+	assert.Equal(t, "Rain", obs.Condition)
+	assert.Equal(t, at.Add(-3*time.Minute), obs.At)
+
+	obs, err = postgres.NearestWeather(context.TODO(), at)
+	require.NoError(t, err)
+	assert.Nil(t, obs)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
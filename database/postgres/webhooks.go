@@ -0,0 +1,212 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/macadrich/go-bike/database/models"
+	"github.com/macadrich/go-bike/pkg/apperr"
+	"github.com/macadrich/go-bike/pkg/tracing"
+)
+
+const webhookColumns = "id, url, secret, event_types, kiosk_ids, created_at"
+
+const deliveryColumns = `id, subscription_id, event_id, event_type, payload, status, attempts,
+		response_status, last_error, created_at, updated_at`
+
+func errWebhookNotFound(id int64) error {
+	return apperr.NotFound("webhook", fmt.Sprintf("webhook %d not found", id))
+}
+
+func errDeliveryNotFound(id int64) error {
+	return apperr.NotFound("delivery", fmt.Sprintf("delivery %d not found", id))
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanWebhook(row scanner) (models.WebhookSubscription, error) {
+	var sub models.WebhookSubscription
+	var kioskIds pq.Int64Array
+	err := row.Scan(&sub.Id, &sub.URL, &sub.Secret, pq.Array(&sub.EventTypes), &kioskIds, &sub.CreatedAt)
+	sub.KioskIds = []int64(kioskIds)
+	if sub.EventTypes == nil {
+		sub.EventTypes = []string{}
+	}
+	if sub.KioskIds == nil {
+		sub.KioskIds = []int64{}
+	}
+	return sub, err
+}
+
+func scanDelivery(row scanner) (models.WebhookDelivery, error) {
+	var d models.WebhookDelivery
+	var payload []byte
+	err := row.Scan(&d.Id, &d.SubscriptionId, &d.EventId, &d.EventType, &payload, &d.Status, &d.Attempts,
+		&d.ResponseStatus, &d.LastError, &d.CreatedAt, &d.UpdatedAt)
+	d.Payload = payload
+	return d, err
+}
+
+// CreateWebhook stores sub and sets its id and creation time.
+func (p *postgresDB) CreateWebhook(ctx context.Context, sub *models.WebhookSubscription) (err error) {
+	ctx, span := startSpan(ctx, "CreateWebhook")
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		INSERT INTO webhook_subscriptions (url, secret, event_types, kiosk_ids)
+		VALUES($1,$2,$3,$4)
+		RETURNING id, created_at
+	`
+	err = p.db.QueryRowContext(ctx, query, sub.URL, sub.Secret, pq.Array(sub.EventTypes), pq.Array(sub.KioskIds)).
+		Scan(&sub.Id, &sub.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("error inserting webhook: %w", err)
+	}
+
+	return nil
+}
+
+func (p *postgresDB) GetWebhook(ctx context.Context, id int64) (_ *models.WebhookSubscription, err error) {
+	ctx, span := startSpan(ctx, "GetWebhook")
+	defer func() { tracing.End(span, err) }()
+
+	row := p.db.QueryRowContext(ctx, "SELECT "+webhookColumns+" FROM webhook_subscriptions WHERE id = $1", id)
+	sub, err := scanWebhook(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errWebhookNotFound(id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+
+	return &sub, nil
+}
+
+func (p *postgresDB) ListWebhooks(ctx context.Context) (_ []models.WebhookSubscription, err error) {
+	ctx, span := startSpan(ctx, "ListWebhooks")
+	defer func() { tracing.End(span, err) }()
+
+	rows, err := p.db.QueryContext(ctx, "SELECT "+webhookColumns+" FROM webhook_subscriptions ORDER BY id ASC")
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer rows.Close()
+
+	subs := []models.WebhookSubscription{}
+	for rows.Next() {
+		sub, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, sub)
+	}
+
+	return subs, rows.Err()
+}
+
+// DeleteWebhook removes a subscription and its delivery log.
+func (p *postgresDB) DeleteWebhook(ctx context.Context, id int64) (err error) {
+	ctx, span := startSpan(ctx, "DeleteWebhook")
+	defer func() { tracing.End(span, err) }()
+
+	result, err := p.db.ExecContext(ctx, "DELETE FROM webhook_subscriptions WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("error deleting webhook: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return errWebhookNotFound(id)
+	}
+
+	return nil
+}
+
+// CreateDelivery stores a new delivery and sets its id and times.
+func (p *postgresDB) CreateDelivery(ctx context.Context, d *models.WebhookDelivery) (err error) {
+	ctx, span := startSpan(ctx, "CreateDelivery")
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, payload, status)
+		VALUES($1,$2,$3,$4,$5)
+		RETURNING id, created_at, updated_at
+	`
+	err = p.db.QueryRowContext(ctx, query, d.SubscriptionId, d.EventId, d.EventType, []byte(d.Payload), d.Status).
+		Scan(&d.Id, &d.CreatedAt, &d.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("error inserting delivery: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateDelivery records the outcome of the latest attempt of d.
+func (p *postgresDB) UpdateDelivery(ctx context.Context, d *models.WebhookDelivery) (err error) {
+	ctx, span := startSpan(ctx, "UpdateDelivery")
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		UPDATE webhook_deliveries
+		SET status = $2, attempts = $3, response_status = $4, last_error = $5, updated_at = now()
+		WHERE id = $1
+		RETURNING updated_at
+	`
+	err = p.db.QueryRowContext(ctx, query, d.Id, d.Status, d.Attempts, d.ResponseStatus, d.LastError).
+		Scan(&d.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errDeliveryNotFound(d.Id)
+	}
+	if err != nil {
+		return fmt.Errorf("error updating delivery: %w", err)
+	}
+
+	return nil
+}
+
+func (p *postgresDB) GetDelivery(ctx context.Context, id int64) (_ *models.WebhookDelivery, err error) {
+	ctx, span := startSpan(ctx, "GetDelivery")
+	defer func() { tracing.End(span, err) }()
+
+	row := p.db.QueryRowContext(ctx, "SELECT "+deliveryColumns+" FROM webhook_deliveries WHERE id = $1", id)
+	d, err := scanDelivery(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errDeliveryNotFound(id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+
+	return &d, nil
+}
+
+// ListDeliveries returns up to limit deliveries of a subscription, newest
+// first, optionally restricted to one status. A non-zero beforeId returns
+// the deliveries older than that one, i.e. the next page.
+func (p *postgresDB) ListDeliveries(ctx context.Context, subscriptionId int64, status string, beforeId int64, limit int) (_ []models.WebhookDelivery, err error) {
+	ctx, span := startSpan(ctx, "ListDeliveries")
+	defer func() { tracing.End(span, err) }()
+
+	query := "SELECT " + deliveryColumns + ` FROM webhook_deliveries
+		WHERE subscription_id = $1 AND ($2 = '' OR status = $2) AND ($3 = 0 OR id < $3)
+		ORDER BY id DESC LIMIT $4`
+	rows, err := p.db.QueryContext(ctx, query, subscriptionId, status, beforeId, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, rows.Err()
+}
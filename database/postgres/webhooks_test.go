@@ -0,0 +1,89 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/macadrich/go-bike/database"
+	"github.com/macadrich/go-bike/database/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateWebhook(t *testing.T) {
+	db, mock := NewMock()
+	postgres := &postgresDB{db}
+	defer postgres.db.Close()
+
+	created := time.Date(2024, 5, 14, 6, 48, 0, 0, time.UTC)
+	sub := &models.WebhookSubscription{URL: "https://example.com/hook", Secret: "secret",
+		EventTypes: []string{models.EventBecameEmpty}, KioskIds: []int64{3005}}
+	mock.ExpectQuery("INSERT INTO webhook_subscriptions").
+		WithArgs(sub.URL, sub.Secret, pq.Array(sub.EventTypes), pq.Array(sub.KioskIds)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, created))
+
+	require.NoError(t, postgres.CreateWebhook(context.TODO(), sub))
+	assert.Equal(t, int64(1), sub.Id)
+	assert.Equal(t, created, sub.CreatedAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetWebhook(t *testing.T) {
+	db, mock := NewMock()
+	postgres := &postgresDB{db}
+	defer postgres.db.Close()
+
+	query := regexp.QuoteMeta("SELECT " + webhookColumns + " FROM webhook_subscriptions WHERE id = $1")
+	mock.ExpectQuery(query).WithArgs(int64(1)).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "url", "secret", "event_types", "kiosk_ids", "created_at"}).
+			AddRow(1, "https://example.com/hook", "secret", "{became_empty,became_full}", "{3005}", time.Now()))
+	mock.ExpectQuery(query).WithArgs(int64(2)).WillReturnError(sql.ErrNoRows)
+
+	sub, err := postgres.GetWebhook(context.TODO(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{models.EventBecameEmpty, models.EventBecameFull}, sub.EventTypes)
+	assert.Equal(t, []int64{3005}, sub.KioskIds)
+
+	_, err = postgres.GetWebhook(context.TODO(), 2)
+	assert.ErrorIs(t, err, database.ErrNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateDelivery(t *testing.T) {
+	db, mock := NewMock()
+	postgres := &postgresDB{db}
+	defer postgres.db.Close()
+
+	d := &models.WebhookDelivery{Id: 5, Status: models.DeliveryFailed, Attempts: 3, ResponseStatus: 502, LastError: "unexpected status code: 502"}
+	updated := time.Date(2024, 5, 14, 6, 50, 0, 0, time.UTC)
+	mock.ExpectQuery("UPDATE webhook_deliveries").
+		WithArgs(d.Id, d.Status, d.Attempts, d.ResponseStatus, d.LastError).
+		WillReturnRows(sqlmock.NewRows([]string{"updated_at"}).AddRow(updated))
+
+	require.NoError(t, postgres.UpdateDelivery(context.TODO(), d))
+	assert.Equal(t, updated, d.UpdatedAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListDeliveries(t *testing.T) {
+	db, mock := NewMock()
+	postgres := &postgresDB{db}
+	defer postgres.db.Close()
+
+	now := time.Now()
+	mock.ExpectQuery("FROM webhook_deliveries").WithArgs(int64(1), models.DeliveryFailed, int64(9), 100).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "subscription_id", "event_id", "event_type", "payload", "status", "attempts",
+			"response_status", "last_error", "created_at", "updated_at"}).
+			AddRow(5, 1, 10, models.EventBecameEmpty, []byte(`{"type":"became_empty"}`), models.DeliveryFailed, 3, 502, "boom", now, now))
+
+	deliveries, err := postgres.ListDeliveries(context.TODO(), 1, models.DeliveryFailed, 9, 100)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.JSONEq(t, `{"type":"became_empty"}`, string(deliveries[0].Payload))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
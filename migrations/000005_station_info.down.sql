@@ -0,0 +1,72 @@
+CREATE TABLE IF NOT EXISTS stations (
+    id SERIAL PRIMARY KEY,
+    at TIMESTAMP NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    kiosk_id INTEGER,
+    is_event_based BOOL NOT NULL,
+    is_virtual BOOL NOT NULL,
+    total_docks INTEGER,
+    trikes_available INTEGER,
+    docks_available INTEGER,
+    bikes_available INTEGER,
+    classic_bikes_available INTEGER,
+    smart_bikes_available INTEGER,
+    electric_bikes_available INTEGER,
+    reward_bikes_available INTEGER,
+    reward_docks_available INTEGER,
+    kiosk_type INTEGER,
+    latitude NUMERIC(10, 8) NOT NULL,
+    longitude NUMERIC(11, 8) NOT NULL,
+    kiosk_status VARCHAR(50) NOT NULL,
+    kiosk_public_status VARCHAR(50) NOT NULL,
+    kiosk_connection_status VARCHAR(50) NOT NULL,
+    address_street VARCHAR(100) NOT NULL,
+    address_city VARCHAR(100) NOT NULL,
+    address_state VARCHAR(100) NOT NULL,
+    address_zipcode VARCHAR(50) NOT NULL,
+    close_time VARCHAR(50) NOT NULL,
+    event_end VARCHAR(50) NOT NULL,
+    event_start VARCHAR(50) NOT NULL,
+    notes VARCHAR(255) NOT NULL,
+    open_time VARCHAR(50) NOT NULL,
+    public_text VARCHAR(255) NOT NULL,
+    timezone VARCHAR(50) NOT NULL,
+    coordinates NUMERIC[]
+);
+
+INSERT INTO stations (
+    at, name, kiosk_id, total_docks, is_event_based,
+    is_virtual, trikes_available, docks_available,
+    bikes_available, classic_bikes_available, smart_bikes_available,
+    electric_bikes_available, reward_bikes_available, reward_docks_available,
+    kiosk_type, latitude, longitude, kiosk_status,
+    kiosk_public_status, kiosk_connection_status, address_street,
+    address_city, address_state, address_zipcode, close_time,
+    event_end, event_start, notes, open_time, public_text, timezone
+)
+SELECT
+    s.at, i.name, s.kiosk_id, i.total_docks, i.is_event_based,
+    i.is_virtual, s.trikes_available, s.docks_available,
+    s.bikes_available, s.classic_bikes_available, s.smart_bikes_available,
+    s.electric_bikes_available, s.reward_bikes_available, s.reward_docks_available,
+    i.kiosk_type, i.latitude, i.longitude, s.kiosk_status,
+    s.kiosk_public_status, s.kiosk_connection_status, i.address_street,
+    i.address_city, i.address_state, i.address_zipcode, i.close_time,
+    i.event_end, i.event_start, i.notes, i.open_time, i.public_text, i.timezone
+FROM station_status s
+JOIN station_info i ON i.kiosk_id = s.kiosk_id
+    AND i.valid_from <= s.at AND (i.valid_to IS NULL OR s.at < i.valid_to)
+ORDER BY s.at, s.id;
+
+CREATE TABLE IF NOT EXISTS coordinates (
+    id SERIAL PRIMARY KEY,
+    kiosk_id INTEGER,
+    longitude NUMERIC(10,8) NOT NULL,
+    latitude NUMERIC(11,8) NOT NULL
+);
+
+ALTER TABLE geometry ADD CONSTRAINT geometry_kiosk_id_fkey FOREIGN KEY (kiosk_id) REFERENCES stations(id);
+
+DROP INDEX IF EXISTS bikes_kiosk_at_idx;
+DROP TABLE IF EXISTS station_status;
+DROP TABLE IF EXISTS station_info;
@@ -0,0 +1,121 @@
+CREATE TABLE IF NOT EXISTS station_info (
+    id SERIAL PRIMARY KEY,
+    kiosk_id INTEGER NOT NULL,
+    valid_from TIMESTAMP NOT NULL,
+    valid_to TIMESTAMP,
+    name VARCHAR(255) NOT NULL,
+    kiosk_type INTEGER,
+    is_event_based BOOL NOT NULL,
+    is_virtual BOOL NOT NULL,
+    total_docks INTEGER,
+    latitude NUMERIC(10, 8) NOT NULL,
+    longitude NUMERIC(11, 8) NOT NULL,
+    address_street VARCHAR(100) NOT NULL,
+    address_city VARCHAR(100) NOT NULL,
+    address_state VARCHAR(100) NOT NULL,
+    address_zipcode VARCHAR(50) NOT NULL,
+    close_time VARCHAR(50) NOT NULL,
+    event_end VARCHAR(50) NOT NULL,
+    event_start VARCHAR(50) NOT NULL,
+    notes VARCHAR(255) NOT NULL,
+    open_time VARCHAR(50) NOT NULL,
+    public_text VARCHAR(255) NOT NULL,
+    timezone VARCHAR(50) NOT NULL
+);
+
+-- At most one current version per kiosk.
+CREATE UNIQUE INDEX IF NOT EXISTS station_info_current_idx ON station_info (kiosk_id) WHERE valid_to IS NULL;
+CREATE INDEX IF NOT EXISTS station_info_kiosk_valid_from_idx ON station_info (kiosk_id, valid_from);
+
+CREATE TABLE IF NOT EXISTS station_status (
+    id SERIAL PRIMARY KEY,
+    at TIMESTAMP NOT NULL,
+    kiosk_id INTEGER NOT NULL,
+    trikes_available INTEGER,
+    docks_available INTEGER,
+    bikes_available INTEGER,
+    classic_bikes_available INTEGER,
+    smart_bikes_available INTEGER,
+    electric_bikes_available INTEGER,
+    reward_bikes_available INTEGER,
+    reward_docks_available INTEGER,
+    kiosk_status VARCHAR(50) NOT NULL,
+    kiosk_public_status VARCHAR(50) NOT NULL,
+    kiosk_connection_status VARCHAR(50) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS station_status_at_idx ON station_status (at);
+CREATE INDEX IF NOT EXISTS station_status_kiosk_at_idx ON station_status (kiosk_id, at);
+CREATE INDEX IF NOT EXISTS bikes_kiosk_at_idx ON bikes (kiosk_id, at);
+
+-- Every run of snapshots with identical static attributes becomes one
+-- version, valid until the next version of the same kiosk starts.
+INSERT INTO station_info (
+    kiosk_id, valid_from, valid_to, name, kiosk_type, is_event_based,
+    is_virtual, total_docks, latitude, longitude, address_street,
+    address_city, address_state, address_zipcode, close_time,
+    event_end, event_start, notes, open_time, public_text, timezone
+)
+SELECT
+    kiosk_id, valid_from, LEAD(valid_from) OVER (PARTITION BY kiosk_id ORDER BY valid_from),
+    name, kiosk_type, is_event_based, is_virtual, total_docks, latitude, longitude,
+    address_street, address_city, address_state, address_zipcode, close_time,
+    event_end, event_start, notes, open_time, public_text, timezone
+FROM (
+    SELECT DISTINCT ON (kiosk_id, version_no)
+        kiosk_id, at AS valid_from, name, kiosk_type, is_event_based, is_virtual,
+        total_docks, latitude, longitude, address_street, address_city,
+        address_state, address_zipcode, close_time, event_end, event_start,
+        notes, open_time, public_text, timezone
+    FROM (
+        SELECT flagged.*, SUM(is_change) OVER (PARTITION BY kiosk_id ORDER BY at, id) AS version_no
+        FROM (
+            SELECT s.*,
+                CASE WHEN LAG(id) OVER w IS NULL
+                    OR name IS DISTINCT FROM LAG(name) OVER w
+                    OR kiosk_type IS DISTINCT FROM LAG(kiosk_type) OVER w
+                    OR is_event_based IS DISTINCT FROM LAG(is_event_based) OVER w
+                    OR is_virtual IS DISTINCT FROM LAG(is_virtual) OVER w
+                    OR total_docks IS DISTINCT FROM LAG(total_docks) OVER w
+                    OR latitude IS DISTINCT FROM LAG(latitude) OVER w
+                    OR longitude IS DISTINCT FROM LAG(longitude) OVER w
+                    OR address_street IS DISTINCT FROM LAG(address_street) OVER w
+                    OR address_city IS DISTINCT FROM LAG(address_city) OVER w
+                    OR address_state IS DISTINCT FROM LAG(address_state) OVER w
+                    OR address_zipcode IS DISTINCT FROM LAG(address_zipcode) OVER w
+                    OR close_time IS DISTINCT FROM LAG(close_time) OVER w
+                    OR event_end IS DISTINCT FROM LAG(event_end) OVER w
+                    OR event_start IS DISTINCT FROM LAG(event_start) OVER w
+                    OR notes IS DISTINCT FROM LAG(notes) OVER w
+                    OR open_time IS DISTINCT FROM LAG(open_time) OVER w
+                    OR public_text IS DISTINCT FROM LAG(public_text) OVER w
+                    OR timezone IS DISTINCT FROM LAG(timezone) OVER w
+                THEN 1 ELSE 0 END AS is_change
+            FROM stations s
+            WHERE kiosk_id IS NOT NULL
+            WINDOW w AS (PARTITION BY kiosk_id ORDER BY at, id)
+        ) flagged
+    ) versioned
+    ORDER BY kiosk_id, version_no, at, id
+) versions;
+
+INSERT INTO station_status (
+    at, kiosk_id, trikes_available, docks_available, bikes_available,
+    classic_bikes_available, smart_bikes_available, electric_bikes_available,
+    reward_bikes_available, reward_docks_available, kiosk_status,
+    kiosk_public_status, kiosk_connection_status
+)
+SELECT
+    at, kiosk_id, trikes_available, docks_available, bikes_available,
+    classic_bikes_available, smart_bikes_available, electric_bikes_available,
+    reward_bikes_available, reward_docks_available, kiosk_status,
+    kiosk_public_status, kiosk_connection_status
+FROM stations
+WHERE kiosk_id IS NOT NULL
+ORDER BY at, id;
+
+-- Coordinates now live on station_info.
+DROP TABLE IF EXISTS coordinates;
+
+ALTER TABLE geometry DROP CONSTRAINT IF EXISTS geometry_kiosk_id_fkey;
+DROP TABLE IF EXISTS stations;
@@ -0,0 +1,23 @@
+DROP TABLE IF EXISTS geometry;
+CREATE TABLE IF NOT EXISTS geometry (
+    id SERIAL PRIMARY KEY,
+    kiosk_id INTEGER,
+    type VARCHAR(50) NOT NULL,
+    coordinates NUMERIC[]
+);
+
+ALTER TABLE station_info ALTER COLUMN open_time TYPE VARCHAR(50) USING COALESCE(open_time::TEXT, '');
+ALTER TABLE station_info ALTER COLUMN close_time TYPE VARCHAR(50) USING COALESCE(close_time::TEXT, '');
+ALTER TABLE station_info ALTER COLUMN event_start TYPE VARCHAR(50) USING COALESCE(to_char(event_start AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS"Z"'), '');
+ALTER TABLE station_info ALTER COLUMN event_end TYPE VARCHAR(50) USING COALESCE(to_char(event_end AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS"Z"'), '');
+
+ALTER TABLE station_info ALTER COLUMN open_time SET NOT NULL;
+ALTER TABLE station_info ALTER COLUMN close_time SET NOT NULL;
+ALTER TABLE station_info ALTER COLUMN event_start SET NOT NULL;
+ALTER TABLE station_info ALTER COLUMN event_end SET NOT NULL;
+
+ALTER TABLE station_info ALTER COLUMN valid_from TYPE TIMESTAMP USING valid_from AT TIME ZONE 'UTC';
+ALTER TABLE station_info ALTER COLUMN valid_to TYPE TIMESTAMP USING valid_to AT TIME ZONE 'UTC';
+
+ALTER TABLE bikes ALTER COLUMN at TYPE TIMESTAMP USING at AT TIME ZONE 'UTC';
+ALTER TABLE station_status ALTER COLUMN at TYPE TIMESTAMP USING at AT TIME ZONE 'UTC';
@@ -0,0 +1,58 @@
+-- Snapshot times were stored from UTC strings into zone-less columns.
+ALTER TABLE station_status ALTER COLUMN at TYPE TIMESTAMPTZ USING at AT TIME ZONE 'UTC';
+ALTER TABLE bikes ALTER COLUMN at TYPE TIMESTAMPTZ USING at AT TIME ZONE 'UTC';
+
+ALTER TABLE station_info ALTER COLUMN valid_from TYPE TIMESTAMPTZ USING valid_from AT TIME ZONE 'UTC';
+ALTER TABLE station_info ALTER COLUMN valid_to TYPE TIMESTAMPTZ USING valid_to AT TIME ZONE 'UTC';
+
+-- Missing values were stored as empty strings, and values the feed sent
+-- in another form were stored as is: both become NULL.
+CREATE FUNCTION pg_temp.legacy_time(value TEXT) RETURNS TIME AS $$
+BEGIN
+    RETURN NULLIF(value, '')::TIME;
+EXCEPTION WHEN others THEN
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Event times without an offset are local times of the station's time zone,
+-- or of America/New_York when it has none that Postgres knows.
+CREATE FUNCTION pg_temp.legacy_timestamp(value TEXT, zone TEXT) RETURNS TIMESTAMPTZ AS $$
+BEGIN
+    IF value ~ '\d{2}:\d{2}(:\d{2}(\.\d+)?)? ?(Z|[+-]\d{2}(:?\d{2})?)$' THEN
+        RETURN value::TIMESTAMPTZ;
+    END IF;
+    IF NOT EXISTS (SELECT 1 FROM pg_timezone_names WHERE name = zone) THEN
+        zone := 'America/New_York';
+    END IF;
+    RETURN NULLIF(value, '')::TIMESTAMP AT TIME ZONE zone;
+EXCEPTION WHEN others THEN
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+ALTER TABLE station_info ALTER COLUMN open_time DROP NOT NULL;
+ALTER TABLE station_info ALTER COLUMN close_time DROP NOT NULL;
+ALTER TABLE station_info ALTER COLUMN event_start DROP NOT NULL;
+ALTER TABLE station_info ALTER COLUMN event_end DROP NOT NULL;
+
+ALTER TABLE station_info ALTER COLUMN open_time TYPE TIME USING pg_temp.legacy_time(open_time);
+ALTER TABLE station_info ALTER COLUMN close_time TYPE TIME USING pg_temp.legacy_time(close_time);
+ALTER TABLE station_info ALTER COLUMN event_start TYPE TIMESTAMPTZ USING pg_temp.legacy_timestamp(event_start, timezone);
+ALTER TABLE station_info ALTER COLUMN event_end TYPE TIMESTAMPTZ USING pg_temp.legacy_timestamp(event_end, timezone);
+
+DROP FUNCTION pg_temp.legacy_time(TEXT);
+DROP FUNCTION pg_temp.legacy_timestamp(TEXT, TEXT);
+
+-- geometry was keyed by stations.id; it now holds one point per kiosk.
+DROP TABLE IF EXISTS geometry;
+CREATE TABLE IF NOT EXISTS geometry (
+    kiosk_id INTEGER PRIMARY KEY,
+    type VARCHAR(50) NOT NULL,
+    coordinates NUMERIC[] NOT NULL
+);
+
+INSERT INTO geometry (kiosk_id, type, coordinates)
+SELECT kiosk_id, 'Point', ARRAY[longitude, latitude]
+FROM station_info
+WHERE valid_to IS NULL;
@@ -0,0 +1,65 @@
+ALTER TABLE station_status RENAME TO station_status_partitioned;
+ALTER TABLE station_status_partitioned RENAME CONSTRAINT station_status_pkey TO station_status_partitioned_pkey;
+ALTER SEQUENCE station_status_id_seq RENAME TO station_status_partitioned_id_seq;
+DROP INDEX IF EXISTS station_status_at_idx;
+DROP INDEX IF EXISTS station_status_kiosk_at_idx;
+
+ALTER TABLE bikes RENAME TO bikes_partitioned;
+ALTER TABLE bikes_partitioned RENAME CONSTRAINT bikes_pkey TO bikes_partitioned_pkey;
+ALTER SEQUENCE bikes_id_seq RENAME TO bikes_partitioned_id_seq;
+DROP INDEX IF EXISTS bikes_kiosk_at_idx;
+
+CREATE TABLE station_status (
+    id SERIAL PRIMARY KEY,
+    at TIMESTAMPTZ NOT NULL,
+    kiosk_id INTEGER NOT NULL,
+    trikes_available INTEGER,
+    docks_available INTEGER,
+    bikes_available INTEGER,
+    classic_bikes_available INTEGER,
+    smart_bikes_available INTEGER,
+    electric_bikes_available INTEGER,
+    reward_bikes_available INTEGER,
+    reward_docks_available INTEGER,
+    kiosk_status VARCHAR(50) NOT NULL,
+    kiosk_public_status VARCHAR(50) NOT NULL,
+    kiosk_connection_status VARCHAR(50) NOT NULL
+);
+
+CREATE INDEX station_status_at_idx ON station_status (at);
+CREATE INDEX station_status_kiosk_at_idx ON station_status (kiosk_id, at);
+
+CREATE TABLE bikes (
+    id SERIAL PRIMARY KEY,
+    at TIMESTAMPTZ NOT NULL,
+    kiosk_id INTEGER,
+    dock_number INTEGER,
+    is_electric BOOL NOT NULL,
+    is_available BOOL NOT NULL,
+    battery INTEGER
+);
+
+CREATE INDEX bikes_kiosk_at_idx ON bikes (kiosk_id, at);
+
+INSERT INTO station_status (
+    id, at, kiosk_id, trikes_available, docks_available, bikes_available,
+    classic_bikes_available, smart_bikes_available, electric_bikes_available,
+    reward_bikes_available, reward_docks_available, kiosk_status,
+    kiosk_public_status, kiosk_connection_status
+)
+SELECT
+    id, at, kiosk_id, trikes_available, docks_available, bikes_available,
+    classic_bikes_available, smart_bikes_available, electric_bikes_available,
+    reward_bikes_available, reward_docks_available, kiosk_status,
+    kiosk_public_status, kiosk_connection_status
+FROM station_status_partitioned;
+
+INSERT INTO bikes (id, at, kiosk_id, dock_number, is_electric, is_available, battery)
+SELECT id, at, kiosk_id, dock_number, is_electric, is_available, battery
+FROM bikes_partitioned;
+
+SELECT setval(pg_get_serial_sequence('station_status', 'id'), COALESCE((SELECT MAX(id) FROM station_status), 0) + 1, false);
+SELECT setval(pg_get_serial_sequence('bikes', 'id'), COALESCE((SELECT MAX(id) FROM bikes), 0) + 1, false);
+
+DROP TABLE station_status_partitioned;
+DROP TABLE bikes_partitioned;
@@ -0,0 +1,100 @@
+-- station_status and bikes become range partitioned by month on at. The
+-- primary key of a partitioned table has to include the partition key.
+ALTER TABLE station_status RENAME TO station_status_unpartitioned;
+ALTER TABLE station_status_unpartitioned RENAME CONSTRAINT station_status_pkey TO station_status_unpartitioned_pkey;
+ALTER SEQUENCE station_status_id_seq RENAME TO station_status_unpartitioned_id_seq;
+DROP INDEX IF EXISTS station_status_at_idx;
+DROP INDEX IF EXISTS station_status_kiosk_at_idx;
+
+ALTER TABLE bikes RENAME TO bikes_unpartitioned;
+ALTER TABLE bikes_unpartitioned RENAME CONSTRAINT bikes_pkey TO bikes_unpartitioned_pkey;
+ALTER SEQUENCE bikes_id_seq RENAME TO bikes_unpartitioned_id_seq;
+DROP INDEX IF EXISTS bikes_kiosk_at_idx;
+
+CREATE TABLE station_status (
+    id BIGSERIAL,
+    at TIMESTAMPTZ NOT NULL,
+    kiosk_id INTEGER NOT NULL,
+    trikes_available INTEGER,
+    docks_available INTEGER,
+    bikes_available INTEGER,
+    classic_bikes_available INTEGER,
+    smart_bikes_available INTEGER,
+    electric_bikes_available INTEGER,
+    reward_bikes_available INTEGER,
+    reward_docks_available INTEGER,
+    kiosk_status VARCHAR(50) NOT NULL,
+    kiosk_public_status VARCHAR(50) NOT NULL,
+    kiosk_connection_status VARCHAR(50) NOT NULL,
+    PRIMARY KEY (id, at)
+) PARTITION BY RANGE (at);
+
+CREATE INDEX station_status_at_idx ON station_status (at);
+CREATE INDEX station_status_kiosk_at_idx ON station_status (kiosk_id, at);
+
+CREATE TABLE bikes (
+    id BIGSERIAL,
+    at TIMESTAMPTZ NOT NULL,
+    kiosk_id INTEGER,
+    dock_number INTEGER,
+    is_electric BOOL NOT NULL,
+    is_available BOOL NOT NULL,
+    battery INTEGER,
+    PRIMARY KEY (id, at)
+) PARTITION BY RANGE (at);
+
+CREATE INDEX bikes_kiosk_at_idx ON bikes (kiosk_id, at);
+
+-- One partition per UTC month, named <table>_pYYYY_MM, from the oldest
+-- snapshot through two months ahead. Later months are created by the
+-- service.
+DO $$
+DECLARE
+    parent TEXT;
+    first_at TIMESTAMPTZ;
+    month_start TIMESTAMPTZ;
+BEGIN
+    FOREACH parent IN ARRAY ARRAY['station_status', 'bikes'] LOOP
+        EXECUTE format('SELECT MIN(at) FROM %I', parent || '_unpartitioned') INTO first_at;
+
+        FOR month_start IN
+            SELECT generate_series(
+                date_trunc('month', COALESCE(first_at, now()) AT TIME ZONE 'UTC'),
+                date_trunc('month', now() AT TIME ZONE 'UTC') + INTERVAL '2 months',
+                INTERVAL '1 month'
+            ) AT TIME ZONE 'UTC'
+        LOOP
+            EXECUTE format(
+                'CREATE TABLE IF NOT EXISTS %I PARTITION OF %I FOR VALUES FROM (%L) TO (%L)',
+                parent || '_p' || to_char(month_start AT TIME ZONE 'UTC', 'YYYY_MM'),
+                parent,
+                month_start,
+                (month_start AT TIME ZONE 'UTC' + INTERVAL '1 month') AT TIME ZONE 'UTC'
+            );
+        END LOOP;
+    END LOOP;
+END
+$$;
+
+INSERT INTO station_status (
+    id, at, kiosk_id, trikes_available, docks_available, bikes_available,
+    classic_bikes_available, smart_bikes_available, electric_bikes_available,
+    reward_bikes_available, reward_docks_available, kiosk_status,
+    kiosk_public_status, kiosk_connection_status
+)
+SELECT
+    id, at, kiosk_id, trikes_available, docks_available, bikes_available,
+    classic_bikes_available, smart_bikes_available, electric_bikes_available,
+    reward_bikes_available, reward_docks_available, kiosk_status,
+    kiosk_public_status, kiosk_connection_status
+FROM station_status_unpartitioned;
+
+INSERT INTO bikes (id, at, kiosk_id, dock_number, is_electric, is_available, battery)
+SELECT id, at, kiosk_id, dock_number, is_electric, is_available, battery
+FROM bikes_unpartitioned;
+
+SELECT setval(pg_get_serial_sequence('station_status', 'id'), COALESCE((SELECT MAX(id) FROM station_status), 0) + 1, false);
+SELECT setval(pg_get_serial_sequence('bikes', 'id'), COALESCE((SELECT MAX(id) FROM bikes), 0) + 1, false);
+
+DROP TABLE station_status_unpartitioned;
+DROP TABLE bikes_unpartitioned;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS station_rollup_daily;
+DROP TABLE IF EXISTS station_rollup_hourly;
@@ -0,0 +1,74 @@
+-- Hourly and daily aggregates of station_status per kiosk, updated by every
+-- ingestion. Averages are bikes_sum / samples etc. The time between two
+-- samples of the same bucket counts as empty (no bikes) or full (no docks)
+-- when the earlier sample was, so last_* keep the latest sample.
+CREATE TABLE IF NOT EXISTS station_rollup_hourly (
+    kiosk_id INTEGER NOT NULL,
+    bucket TIMESTAMPTZ NOT NULL,
+    samples INTEGER NOT NULL,
+    bikes_min INTEGER NOT NULL,
+    bikes_max INTEGER NOT NULL,
+    bikes_sum BIGINT NOT NULL,
+    docks_min INTEGER NOT NULL,
+    docks_max INTEGER NOT NULL,
+    docks_sum BIGINT NOT NULL,
+    ebikes_min INTEGER NOT NULL,
+    ebikes_max INTEGER NOT NULL,
+    ebikes_sum BIGINT NOT NULL,
+    minutes_empty DOUBLE PRECISION NOT NULL,
+    minutes_full DOUBLE PRECISION NOT NULL,
+    last_at TIMESTAMPTZ NOT NULL,
+    last_bikes INTEGER NOT NULL,
+    last_docks INTEGER NOT NULL,
+    PRIMARY KEY (kiosk_id, bucket)
+);
+
+CREATE TABLE IF NOT EXISTS station_rollup_daily (LIKE station_rollup_hourly INCLUDING ALL);
+
+-- Backfill from the snapshots already stored. Days of existing data are
+-- cut at midnight in America/New_York, the default feed time zone.
+CREATE TEMPORARY TABLE rollup_samples AS
+SELECT
+    kiosk_id, at,
+    COALESCE(bikes_available, 0) AS bikes,
+    COALESCE(docks_available, 0) AS docks,
+    COALESCE(electric_bikes_available, 0) AS ebikes,
+    date_trunc('hour', at AT TIME ZONE 'UTC') AT TIME ZONE 'UTC' AS hour,
+    date_trunc('day', at AT TIME ZONE 'America/New_York') AT TIME ZONE 'America/New_York' AS day
+FROM station_status;
+
+INSERT INTO station_rollup_hourly
+SELECT
+    kiosk_id, hour, COUNT(*),
+    MIN(bikes), MAX(bikes), SUM(bikes),
+    MIN(docks), MAX(docks), SUM(docks),
+    MIN(ebikes), MAX(ebikes), SUM(ebikes),
+    COALESCE(SUM(EXTRACT(EPOCH FROM next_at - at) / 60) FILTER (WHERE bikes = 0), 0),
+    COALESCE(SUM(EXTRACT(EPOCH FROM next_at - at) / 60) FILTER (WHERE docks = 0), 0),
+    MAX(at),
+    (array_agg(bikes ORDER BY at DESC))[1],
+    (array_agg(docks ORDER BY at DESC))[1]
+FROM (
+    SELECT *, LEAD(at) OVER (PARTITION BY kiosk_id, hour ORDER BY at) AS next_at
+    FROM rollup_samples
+) s
+GROUP BY kiosk_id, hour;
+
+INSERT INTO station_rollup_daily
+SELECT
+    kiosk_id, day, COUNT(*),
+    MIN(bikes), MAX(bikes), SUM(bikes),
+    MIN(docks), MAX(docks), SUM(docks),
+    MIN(ebikes), MAX(ebikes), SUM(ebikes),
+    COALESCE(SUM(EXTRACT(EPOCH FROM next_at - at) / 60) FILTER (WHERE bikes = 0), 0),
+    COALESCE(SUM(EXTRACT(EPOCH FROM next_at - at) / 60) FILTER (WHERE docks = 0), 0),
+    MAX(at),
+    (array_agg(bikes ORDER BY at DESC))[1],
+    (array_agg(docks ORDER BY at DESC))[1]
+FROM (
+    SELECT *, LEAD(at) OVER (PARTITION BY kiosk_id, day ORDER BY at) AS next_at
+    FROM rollup_samples
+) s
+GROUP BY kiosk_id, day;
+
+DROP TABLE rollup_samples;
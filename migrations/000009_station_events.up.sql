@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS station_events (
+    id BIGSERIAL PRIMARY KEY,
+    kiosk_id INTEGER NOT NULL,
+    at TIMESTAMPTZ NOT NULL,
+    type VARCHAR(32) NOT NULL,
+    recovered VARCHAR(32) NOT NULL DEFAULT '',
+    bikes_available INTEGER,
+    docks_available INTEGER,
+    kiosk_connection_status VARCHAR(50) NOT NULL
+);
+
+-- Ingesting the same snapshot twice must not duplicate its events.
+CREATE UNIQUE INDEX IF NOT EXISTS station_events_unique_idx ON station_events (kiosk_id, at, type, recovered);
+CREATE INDEX IF NOT EXISTS station_events_at_idx ON station_events (at);
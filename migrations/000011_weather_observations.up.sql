@@ -0,0 +1,12 @@
+-- Weather reported by OpenWeather at each ingestion, keyed by the time of
+-- the observation, so that station history can be related to it.
+CREATE TABLE IF NOT EXISTS weather_observations (
+    at TIMESTAMPTZ PRIMARY KEY,
+    condition VARCHAR(50) NOT NULL,
+    description VARCHAR(255) NOT NULL,
+    temperature DOUBLE PRECISION NOT NULL,
+    feels_like DOUBLE PRECISION NOT NULL,
+    humidity INTEGER NOT NULL,
+    wind_speed DOUBLE PRECISION NOT NULL,
+    clouds INTEGER NOT NULL
+);
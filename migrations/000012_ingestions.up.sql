@@ -0,0 +1,14 @@
+-- One row per stored feed snapshot, keyed by its last_updated, so that a
+-- snapshot polled twice or imported again is only stored once.
+CREATE TABLE IF NOT EXISTS ingestions (
+    at TIMESTAMPTZ PRIMARY KEY,
+    source VARCHAR(20) NOT NULL,
+    stations INTEGER NOT NULL,
+    ingested_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+INSERT INTO ingestions (at, source, stations, ingested_at)
+SELECT at, 'live', COUNT(*), at
+FROM station_status
+GROUP BY at
+ON CONFLICT (at) DO NOTHING;
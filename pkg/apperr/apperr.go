@@ -0,0 +1,136 @@
+// Package apperr defines the errors the service reports to its clients.
+//
+// An *Error has a Kind, which decides how the API answers (not found is a
+// 404, an invalid argument a 400, and so on), and a Code that names the
+// exact failure for programs, e.g. "station_not_found". Its Message is
+// shown to clients; the wrapped cause is logged but never sent.
+//
+// The Err* sentinels match any error of their kind:
+//
+//	if errors.Is(err, apperr.ErrNotFound) { ... }
+package apperr
+
+import (
+	"errors"
+	"strings"
+)
+
+// Kind classifies an error by how a client should react to it.
+type Kind string
+
+const (
+	KindInvalidArgument Kind = "invalid_argument"
+	KindUnauthorized    Kind = "unauthorized"
+	KindNotFound        Kind = "not_found"
+	KindConflict        Kind = "conflict"
+	KindRateLimited     Kind = "rate_limited"
+	// KindUnavailable is a failing upstream, i.e. the bike or weather
+	// feed, or a service that is shutting down. Retrying later may help.
+	KindUnavailable Kind = "unavailable"
+	// KindInternal is everything else, e.g. a database failure.
+	KindInternal Kind = "internal"
+)
+
+// Error is a failure with a kind, a machine-readable code and a message
+// for clients.
+type Error struct {
+	Kind    Kind
+	Code    string
+	Message string
+	// Fields are the invalid fields of a request, when it has several or
+	// clients should point at them.
+	Fields []FieldError
+	// Err is the cause, for logs.
+	Err error
+}
+
+// FieldError is an invalid field of a request: a path or query parameter,
+// a header or a member of the body, as given by In.
+type FieldError struct {
+	Name   string `json:"name"`
+	In     string `json:"in" enums:"path,query,header,body"`
+	Reason string `json:"reason"`
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is matches sentinels, which have no code, by kind and other errors by
+// code.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	if !ok {
+		return false
+	}
+	if t.Code == "" {
+		return t.Kind == e.Kind
+	}
+	return t.Code == e.Code
+}
+
+// Sentinels matching every error of their kind.
+var (
+	ErrInvalidArgument = &Error{Kind: KindInvalidArgument, Message: "invalid argument"}
+	ErrUnauthorized    = &Error{Kind: KindUnauthorized, Message: "unauthorized"}
+	ErrNotFound        = &Error{Kind: KindNotFound, Message: "not found"}
+	ErrConflict        = &Error{Kind: KindConflict, Message: "conflict"}
+	ErrRateLimited     = &Error{Kind: KindRateLimited, Message: "rate limited"}
+	ErrUnavailable     = &Error{Kind: KindUnavailable, Message: "unavailable"}
+)
+
+// New returns an error of the given kind and code.
+func New(kind Kind, code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+// Wrap returns an error of the given kind and code caused by err.
+func Wrap(kind Kind, code, message string, err error) *Error {
+	return &Error{Kind: kind, Code: code, Message: message, Err: err}
+}
+
+// InvalidArgument reports a request parameter that is missing or wrong.
+func InvalidArgument(message string) *Error {
+	return New(KindInvalidArgument, "invalid_argument", message)
+}
+
+// InvalidFields reports the invalid fields of a request. Its message names
+// them all, e.g. "from is required; limit must be between 1 and 1000".
+func InvalidFields(fields ...FieldError) *Error {
+	messages := make([]string, len(fields))
+	for i, f := range fields {
+		messages[i] = f.Name + " " + f.Reason
+	}
+
+	e := InvalidArgument(strings.Join(messages, "; "))
+	e.Fields = fields
+	return e
+}
+
+// NotFound reports a missing resource, e.g. NotFound("station", ...)
+// has the code station_not_found.
+func NotFound(resource, message string) *Error {
+	return New(KindNotFound, resource+"_not_found", message)
+}
+
+// Upstream reports a failure of an external feed.
+func Upstream(message string, err error) *Error {
+	return Wrap(KindUnavailable, "upstream_unavailable", message, err)
+}
+
+// As returns the *Error in err's chain. Errors that are not one are
+// internal.
+func As(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return Wrap(KindInternal, "internal", "internal error", err)
+}
@@ -0,0 +1,45 @@
+package apperr
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIs(t *testing.T) {
+	err := fmt.Errorf("query: %w", NotFound("station", "station 3005 not found"))
+
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, err, NotFound("station", "any message"))
+	assert.NotErrorIs(t, err, NotFound("webhook", "webhook 1 not found"))
+	assert.NotErrorIs(t, err, ErrConflict)
+}
+
+func TestAs(t *testing.T) {
+	cause := errors.New("connection refused")
+
+	e := As(fmt.Errorf("fetch: %w", Upstream("bike feed unavailable", cause)))
+	assert.Equal(t, KindUnavailable, e.Kind)
+	assert.Equal(t, "upstream_unavailable", e.Code)
+	assert.ErrorIs(t, e, cause)
+	assert.Equal(t, "bike feed unavailable: connection refused", e.Error())
+
+	e = As(cause)
+	assert.Equal(t, KindInternal, e.Kind)
+	assert.Equal(t, "internal error", e.Message)
+	assert.ErrorIs(t, e, cause)
+}
+
+func TestInvalidFields(t *testing.T) {
+	e := InvalidFields(
+		FieldError{Name: "from", In: "query", Reason: "is required"},
+		FieldError{Name: "limit", In: "query", Reason: "must be between 1 and 1000"},
+	)
+
+	assert.ErrorIs(t, e, ErrInvalidArgument)
+	assert.Equal(t, "invalid_argument", e.Code)
+	assert.Equal(t, "from is required; limit must be between 1 and 1000", e.Message)
+	assert.Len(t, e.Fields, 2)
+}
@@ -0,0 +1,107 @@
+// Package archive walks saved feed files: the .json and .json.gz files of
+// a directory tree or of a .tar.gz (or .tgz) archive, or a single one.
+package archive
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Walk calls fn with the name and content of every feed file under path,
+// in lexical order for directories and in archive order for tarballs.
+// Gzipped files are decompressed. Walk stops at the first error fn returns.
+func Walk(path string, fn func(name string, r io.Reader) error) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	if info.IsDir() {
+		return walkDir(path, fn)
+	}
+	if isTarball(path) {
+		return walkTarball(path, fn)
+	}
+	if isFeed(path) {
+		return walkDir(path, fn)
+	}
+	return fmt.Errorf("%s is neither a directory, a .tar.gz archive nor a feed file", path)
+}
+
+func isTarball(name string) bool {
+	return strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tgz")
+}
+
+// isFeed reports whether name looks like a saved feed.
+func isFeed(name string) bool {
+	return strings.HasSuffix(name, ".json") || strings.HasSuffix(name, ".json.gz")
+}
+
+// open passes r to fn, decompressed when name is gzipped.
+func open(name string, r io.Reader, fn func(name string, r io.Reader) error) error {
+	if !strings.HasSuffix(name, ".gz") {
+		return fn(name, r)
+	}
+
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	defer gz.Close()
+
+	return fn(name, gz)
+}
+
+func walkDir(root string, fn func(name string, r io.Reader) error) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !isFeed(path) {
+			return err
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		return open(path, f, fn)
+	})
+}
+
+func walkTarball(path string, fn func(name string, r io.Reader) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+
+		if header.Typeflag != tar.TypeReg || !isFeed(header.Name) {
+			continue
+		}
+		if err := open(header.Name, tr, fn); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func gzipped(t *testing.T, content string) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, err := gz.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func collect(t *testing.T, path string) map[string]string {
+	files := make(map[string]string)
+	err := Walk(path, func(name string, r io.Reader) error {
+		content, err := io.ReadAll(r)
+		files[filepath.Base(name)] = string(content)
+		return err
+	})
+	require.NoError(t, err)
+	return files
+}
+
+func TestWalkDir(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "2024-05"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "2024-05", "a.json"), []byte(`{"a":1}`), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.json.gz"), gzipped(t, `{"b":2}`), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("skip"), 0o644))
+
+	assert.Equal(t, map[string]string{"a.json": `{"a":1}`, "b.json.gz": `{"b":2}`}, collect(t, dir))
+}
+
+func TestWalkTarball(t *testing.T) {
+	var tarball bytes.Buffer
+	tw := tar.NewWriter(&tarball)
+	for name, content := range map[string][]byte{
+		"feeds/a.json":    []byte(`{"a":1}`),
+		"feeds/b.json.gz": gzipped(t, `{"b":2}`),
+		"feeds/README":    []byte("skip"),
+	} {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write(content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+
+	path := filepath.Join(t.TempDir(), "feeds.tar.gz")
+	require.NoError(t, os.WriteFile(path, gzipped(t, tarball.String()), 0o644))
+
+	assert.Equal(t, map[string]string{"a.json": `{"a":1}`, "b.json.gz": `{"b":2}`}, collect(t, path))
+}
+
+func TestWalkFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "phl.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{}`), 0o644))
+	assert.Equal(t, map[string]string{"phl.json": `{}`}, collect(t, path))
+
+	other := filepath.Join(dir, "feeds.zip")
+	require.NoError(t, os.WriteFile(other, nil, 0o644))
+	assert.Error(t, Walk(other, func(string, io.Reader) error { return nil }))
+}
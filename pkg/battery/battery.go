@@ -0,0 +1,64 @@
+// Package battery summarises the charge of docked e-bikes.
+package battery
+
+import (
+	"math"
+	"slices"
+
+	"github.com/macadrich/go-bike/database/models"
+)
+
+// Distribution summarises the batteries of the e-bikes among bikes;
+// classic bikes are ignored. kioskId is copied to the result.
+func Distribution(kioskId int, bikes []models.Bike) models.BatteryDistribution {
+	d := models.BatteryDistribution{KioskId: kioskId}
+
+	var levels []int
+	for _, b := range bikes {
+		if b.IsElectric {
+			levels = append(levels, min(max(b.Battery, 0), 100))
+		}
+	}
+	if len(levels) == 0 {
+		return d
+	}
+	slices.Sort(levels)
+
+	sum := 0
+	for _, level := range levels {
+		sum += level
+		d.Buckets[min(level/10, len(d.Buckets)-1)]++
+	}
+
+	n := len(levels)
+	d.Count = n
+	d.Min = levels[0]
+	d.Max = levels[n-1]
+	d.Mean = math.Round(float64(sum)/float64(n)*10) / 10
+	if n%2 == 1 {
+		d.Median = float64(levels[n/2])
+	} else {
+		d.Median = float64(levels[n/2-1]+levels[n/2]) / 2
+	}
+
+	return d
+}
+
+// Summary returns the system-wide distribution of bikes and one per kiosk
+// with at least one e-bike, ordered by kiosk.
+func Summary(bikes []models.Bike) (system models.BatteryDistribution, stations []models.BatteryDistribution) {
+	byKiosk := make(map[int][]models.Bike)
+	for _, b := range bikes {
+		byKiosk[b.KioskId] = append(byKiosk[b.KioskId], b)
+	}
+
+	stations = []models.BatteryDistribution{}
+	for kioskId, docked := range byKiosk {
+		if d := Distribution(kioskId, docked); d.Count > 0 {
+			stations = append(stations, d)
+		}
+	}
+	slices.SortFunc(stations, func(a, b models.BatteryDistribution) int { return a.KioskId - b.KioskId })
+
+	return Distribution(0, bikes), stations
+}
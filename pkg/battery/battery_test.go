@@ -0,0 +1,41 @@
+package battery
+
+import (
+	"testing"
+
+	"github.com/macadrich/go-bike/database/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func ebike(kioskId, battery int) models.Bike {
+	return models.Bike{KioskId: kioskId, IsElectric: true, Battery: battery}
+}
+
+func TestDistribution(t *testing.T) {
+	bikes := []models.Bike{ebike(3005, 35), ebike(3005, 100), ebike(3005, 9), ebike(3005, 60), {KioskId: 3005}}
+
+	d := Distribution(3005, bikes)
+	assert.Equal(t, 3005, d.KioskId)
+	assert.Equal(t, 4, d.Count)
+	assert.Equal(t, 9, d.Min)
+	assert.Equal(t, 100, d.Max)
+	assert.Equal(t, 51.0, d.Mean)
+	assert.Equal(t, 47.5, d.Median)
+	assert.Equal(t, [10]int{1, 0, 0, 1, 0, 0, 1, 0, 0, 1}, d.Buckets)
+
+	assert.Equal(t, 35.0, Distribution(0, bikes[:3]).Median)
+	assert.Zero(t, Distribution(0, []models.Bike{{KioskId: 3005}}).Count)
+}
+
+func TestSummary(t *testing.T) {
+	bikes := []models.Bike{ebike(3006, 80), ebike(3005, 20), ebike(3005, 40), {KioskId: 3007}}
+
+	system, stations := Summary(bikes)
+	assert.Equal(t, 3, system.Count)
+	assert.Zero(t, system.KioskId)
+	require.Len(t, stations, 2)
+	assert.Equal(t, 3005, stations[0].KioskId)
+	assert.Equal(t, 30.0, stations[0].Mean)
+	assert.Equal(t, 3006, stations[1].KioskId)
+}
@@ -0,0 +1,99 @@
+// Package events derives station events from consecutive snapshots of a
+// kiosk.
+package events
+
+import (
+	"sort"
+	"time"
+
+	"github.com/macadrich/go-bike/database/models"
+)
+
+// ConnectionActive is the KioskConnectionStatus of a kiosk that is online.
+const ConnectionActive = "Active"
+
+// ConnectionMissing is the KioskConnectionStatus reported for a kiosk absent
+// from the feed, which counts as offline.
+const ConnectionMissing = "Missing"
+
+// Conditions a kiosk can enter and recover from, as named in the Recovered
+// field of a recovered event.
+const (
+	ConditionOffline = "offline"
+	ConditionEmpty   = "empty"
+	ConditionFull    = "full"
+)
+
+var conditions = []struct {
+	name  string
+	event string
+	holds func(*models.Stations) bool
+}{
+	{ConditionOffline, models.EventWentOffline, func(s *models.Stations) bool { return s.KioskConnectionStatus != ConnectionActive }},
+	{ConditionEmpty, models.EventBecameEmpty, func(s *models.Stations) bool { return s.BikesAvailable == 0 }},
+	{ConditionFull, models.EventBecameFull, func(s *models.Stations) bool { return s.DocksAvailable == 0 }},
+}
+
+// Detect compares the snapshot curr of a kiosk, taken at, with its previous
+// snapshot prev and returns an event for every condition that started or
+// ended in between. Nothing is reported without a previous snapshot.
+func Detect(prev, curr *models.Stations, at time.Time) []models.StationEvent {
+	if prev == nil {
+		return nil
+	}
+
+	var events []models.StationEvent
+	for _, c := range conditions {
+		was, is := c.holds(prev), c.holds(curr)
+		if was == is {
+			continue
+		}
+
+		event := models.StationEvent{
+			KioskId:               curr.KioskId,
+			At:                    at,
+			Type:                  c.event,
+			BikesAvailable:        curr.BikesAvailable,
+			DocksAvailable:        curr.DocksAvailable,
+			KioskConnectionStatus: curr.KioskConnectionStatus,
+		}
+		if was {
+			event.Type = models.EventRecovered
+			event.Recovered = c.name
+		}
+		events = append(events, event)
+	}
+
+	return events
+}
+
+// Previous returns the snapshot to compare a kiosk's new snapshot with:
+// its last snapshot prev or, when the kiosk was absent from the last
+// snapshot of the feed, taken at last, a missing one.
+func Previous(prev models.Stations, last time.Time) *models.Stations {
+	if prev.At.Before(last) {
+		prev.KioskConnectionStatus = ConnectionMissing
+	}
+	return &prev
+}
+
+// Missing returns a went_offline event, at at, for every kiosk that was
+// online in the last snapshot of the feed, taken at last, but is absent
+// from the new one. previous holds the last snapshot of every kiosk and
+// present the kiosks of the new snapshot.
+func Missing(previous map[int]models.Stations, present map[int]bool, last, at time.Time) []models.StationEvent {
+	var events []models.StationEvent
+	for kioskId, prev := range previous {
+		if present[kioskId] || !prev.At.Equal(last) || prev.KioskConnectionStatus != ConnectionActive {
+			continue
+		}
+		events = append(events, models.StationEvent{
+			KioskId:               kioskId,
+			At:                    at,
+			Type:                  models.EventWentOffline,
+			KioskConnectionStatus: ConnectionMissing,
+		})
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].KioskId < events[j].KioskId })
+	return events
+}
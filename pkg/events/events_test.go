@@ -0,0 +1,73 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"github.com/macadrich/go-bike/database/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetect(t *testing.T) {
+	at := time.Date(2024, 5, 14, 6, 48, 0, 0, time.UTC)
+	station := func(bikes, docks int, connection string) *models.Stations {
+		return &models.Stations{KioskId: 3005, BikesAvailable: bikes, DocksAvailable: docks, KioskConnectionStatus: connection}
+	}
+
+	tests := []struct {
+		name       string
+		prev, curr *models.Stations
+		want       []string
+	}{
+		{"first snapshot", nil, station(0, 10, "Active"), nil},
+		{"unchanged", station(3, 7, "Active"), station(4, 6, "Active"), nil},
+		{"became empty", station(1, 9, "Active"), station(0, 10, "Active"), []string{models.EventBecameEmpty}},
+		{"became full", station(9, 1, "Active"), station(10, 0, "Active"), []string{models.EventBecameFull}},
+		{"went offline", station(3, 7, "Active"), station(3, 7, "Unresponsive"), []string{models.EventWentOffline}},
+		{"recovered", station(0, 10, "Unresponsive"), station(2, 8, "Active"), []string{models.EventRecovered, models.EventRecovered}},
+		{"empty to full", station(0, 10, "Active"), station(10, 0, "Active"), []string{models.EventRecovered, models.EventBecameFull}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, event := range Detect(tt.prev, tt.curr, at) {
+				assert.Equal(t, 3005, event.KioskId)
+				assert.Equal(t, at, event.At)
+				got = append(got, event.Type)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	events := Detect(station(0, 10, "Unresponsive"), station(2, 8, "Active"), at)
+	assert.Equal(t, ConditionOffline, events[0].Recovered)
+	assert.Equal(t, ConditionEmpty, events[1].Recovered)
+}
+
+func TestMissing(t *testing.T) {
+	last := time.Date(2024, 5, 14, 6, 47, 0, 0, time.UTC)
+	at := last.Add(time.Minute)
+	previous := map[int]models.Stations{
+		3005: {KioskId: 3005, At: last, KioskConnectionStatus: ConnectionActive},
+		3006: {KioskId: 3006, At: last, KioskConnectionStatus: ConnectionActive},
+		3007: {KioskId: 3007, At: last, KioskConnectionStatus: "Unresponsive"},
+		3008: {KioskId: 3008, At: last.Add(-time.Hour), BikesAvailable: 5, DocksAvailable: 5, KioskConnectionStatus: ConnectionActive},
+	}
+
+	// 3006 dropped out of the feed; 3007 was already offline and 3008 went
+	// missing earlier.
+	events := Missing(previous, map[int]bool{3005: true}, last, at)
+	require.Len(t, events, 1)
+	assert.Equal(t, models.StationEvent{KioskId: 3006, At: at, Type: models.EventWentOffline, KioskConnectionStatus: ConnectionMissing}, events[0])
+
+	// 3008 is back, so it recovered.
+	events = Detect(Previous(previous[3008], last), &models.Stations{KioskId: 3008, BikesAvailable: 3, DocksAvailable: 7, KioskConnectionStatus: ConnectionActive}, at)
+	require.Len(t, events, 1)
+	assert.Equal(t, models.EventRecovered, events[0].Type)
+	assert.Equal(t, ConditionOffline, events[0].Recovered)
+
+	// 3005 was in the last snapshot and is compared as it was.
+	assert.Equal(t, ConnectionActive, Previous(previous[3005], last).KioskConnectionStatus)
+}
@@ -0,0 +1,150 @@
+// Package export writes tables of historical snapshots as CSV,
+// newline-delimited JSON or Parquet, one row at a time.
+//
+// A row holds one value per column, each either nil or of the Go type of
+// its column: string, int64, float64, bool or time.Time.
+package export
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Formats.
+const (
+	FormatCSV     = "csv"
+	FormatNDJSON  = "ndjson"
+	FormatParquet = "parquet"
+)
+
+// Datasets.
+const (
+	DatasetStations = "stations"
+	DatasetBikes    = "bikes"
+	DatasetWeather  = "weather"
+)
+
+// ErrUnknownFormat is returned by NewWriter for formats it cannot write.
+var ErrUnknownFormat = errors.New("unknown export format")
+
+// Type is the type of the values of a column.
+type Type int
+
+const (
+	String Type = iota
+	Int
+	Float
+	Bool
+	Time
+)
+
+// Column describes one column of a dataset.
+type Column struct {
+	Name string
+	Type Type
+}
+
+var datasets = map[string][]Column{
+	DatasetStations: {
+		{"at", Time}, {"kiosk_id", Int}, {"name", String}, {"kiosk_type", Int},
+		{"total_docks", Int}, {"docks_available", Int}, {"bikes_available", Int},
+		{"classic_bikes_available", Int}, {"smart_bikes_available", Int},
+		{"electric_bikes_available", Int}, {"trikes_available", Int},
+		{"reward_bikes_available", Int}, {"reward_docks_available", Int},
+		{"kiosk_status", String}, {"kiosk_public_status", String},
+		{"kiosk_connection_status", String}, {"is_event_based", Bool},
+		{"is_virtual", Bool}, {"latitude", Float}, {"longitude", Float},
+		{"address_street", String}, {"address_city", String},
+		{"address_state", String}, {"address_zipcode", String},
+	},
+	DatasetBikes: {
+		{"at", Time}, {"kiosk_id", Int}, {"dock_number", Int},
+		{"is_electric", Bool}, {"is_available", Bool}, {"battery", Int},
+	},
+	DatasetWeather: {
+		{"at", Time}, {"condition", String}, {"description", String},
+		{"temperature", Float}, {"feels_like", Float}, {"humidity", Int},
+		{"wind_speed", Float}, {"clouds", Int},
+	},
+}
+
+// Columns returns the columns of dataset and whether it exists.
+func Columns(dataset string) ([]Column, bool) {
+	columns, ok := datasets[dataset]
+	return columns, ok
+}
+
+// ValidFormat reports whether NewWriter supports format.
+func ValidFormat(format string) bool {
+	switch format {
+	case FormatCSV, FormatNDJSON, FormatParquet:
+		return true
+	}
+	return false
+}
+
+// ContentType is the media type of format.
+func ContentType(format string) string {
+	switch format {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatNDJSON:
+		return "application/x-ndjson"
+	default:
+		return "application/vnd.apache.parquet"
+	}
+}
+
+// Writer writes rows of a dataset. Close must be called once all rows are
+// written; it does not close the underlying writer.
+type Writer interface {
+	Write(row []any) error
+	Close() error
+}
+
+// NewWriter returns a Writer of format for rows of columns. Output is
+// buffered, so nothing reaches w before the first rows are written.
+func NewWriter(w io.Writer, format string, columns []Column) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w, columns)
+	case FormatNDJSON:
+		return newNDJSONWriter(w, columns), nil
+	case FormatParquet:
+		return newParquetWriter(w, columns), nil
+	}
+	return nil, fmt.Errorf("%w %q", ErrUnknownFormat, format)
+}
+
+// checkRow reports a row that does not match columns.
+func checkRow(columns []Column, row []any) error {
+	if len(row) != len(columns) {
+		return fmt.Errorf("export: got %d values for %d columns", len(row), len(columns))
+	}
+
+	for i, v := range row {
+		if v == nil {
+			continue
+		}
+		var ok bool
+		switch columns[i].Type {
+		case String:
+			_, ok = v.(string)
+		case Int:
+			_, ok = v.(int64)
+		case Float:
+			_, ok = v.(float64)
+		case Bool:
+			_, ok = v.(bool)
+		case Time:
+			_, ok = v.(time.Time)
+		}
+		if !ok {
+			return fmt.Errorf("export: column %s: unexpected %T", columns[i].Name, v)
+		}
+	}
+
+	return nil
+}
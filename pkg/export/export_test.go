@@ -0,0 +1,64 @@
+package export
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testColumns = []Column{
+	{"at", Time}, {"kiosk_id", Int}, {"name", String}, {"latitude", Float}, {"is_virtual", Bool},
+}
+
+var testRows = [][]any{
+	{time.Date(2024, 5, 14, 6, 48, 19, 0, time.UTC), int64(3005), "Welcome, Center", 39.94733, false},
+	{time.Date(2024, 5, 14, 6, 48, 19, 0, time.UTC), int64(3006), nil, nil, true},
+}
+
+func writeAll(t *testing.T, format string, columns []Column, rows [][]any) []byte {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, format, columns)
+	require.NoError(t, err)
+	for _, row := range rows {
+		require.NoError(t, w.Write(row))
+	}
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestCSV(t *testing.T) {
+	out := writeAll(t, FormatCSV, testColumns, testRows)
+	assert.Equal(t, "at,kiosk_id,name,latitude,is_virtual\n"+
+		"2024-05-14T06:48:19Z,3005,\"Welcome, Center\",39.94733,false\n"+
+		"2024-05-14T06:48:19Z,3006,,,true\n", string(out))
+}
+
+func TestNDJSON(t *testing.T) {
+	out := writeAll(t, FormatNDJSON, testColumns, testRows)
+	assert.Equal(t, `{"at":"2024-05-14T06:48:19Z","kiosk_id":3005,"name":"Welcome, Center","latitude":39.94733,"is_virtual":false}`+"\n"+
+		`{"at":"2024-05-14T06:48:19Z","kiosk_id":3006,"name":null,"latitude":null,"is_virtual":true}`+"\n", string(out))
+}
+
+func TestNewWriter(t *testing.T) {
+	_, err := NewWriter(&bytes.Buffer{}, "xlsx", testColumns)
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+
+	w, err := NewWriter(&bytes.Buffer{}, FormatCSV, testColumns)
+	require.NoError(t, err)
+	assert.Error(t, w.Write([]any{"2024-05-14", int64(3005), "x", 1.0, false}), "wrong type")
+	assert.Error(t, w.Write([]any{nil}), "wrong length")
+}
+
+func TestColumns(t *testing.T) {
+	for _, dataset := range []string{DatasetStations, DatasetBikes, DatasetWeather} {
+		columns, ok := Columns(dataset)
+		assert.True(t, ok, dataset)
+		assert.Equal(t, "at", columns[0].Name, dataset)
+	}
+
+	_, ok := Columns("trips")
+	assert.False(t, ok)
+}
@@ -0,0 +1,100 @@
+package export
+
+import (
+	"io"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// rowGroupRows is how many rows are buffered into each row group.
+const rowGroupRows = 10000
+
+// parquetWriter writes a Snappy compressed Parquet file of optional
+// columns. Rows are buffered until a row group is complete.
+type parquetWriter struct {
+	w       *parquet.Writer
+	columns []Column
+	row     parquet.Row
+}
+
+func newParquetWriter(w io.Writer, columns []Column) *parquetWriter {
+	schema := parquet.NewSchema("schema", parquetSchema(columns))
+	return &parquetWriter{
+		w: parquet.NewWriter(w, schema,
+			parquet.Compression(&parquet.Snappy),
+			parquet.MaxRowsPerRowGroup(rowGroupRows),
+			parquet.CreatedBy("go-bike", "", ""),
+		),
+		columns: columns,
+	}
+}
+
+func (p *parquetWriter) Write(row []any) error {
+	if err := checkRow(p.columns, row); err != nil {
+		return err
+	}
+
+	p.row = p.row[:0]
+	for i, v := range row {
+		var value parquet.Value
+		switch v := v.(type) {
+		case string:
+			value = parquet.ByteArrayValue([]byte(v))
+		case int64:
+			value = parquet.Int64Value(v)
+		case float64:
+			value = parquet.DoubleValue(v)
+		case bool:
+			value = parquet.BooleanValue(v)
+		case time.Time:
+			value = parquet.Int64Value(v.UnixMicro())
+		}
+		if v == nil {
+			p.row = append(p.row, value.Level(0, 0, i))
+		} else {
+			p.row = append(p.row, value.Level(0, 1, i))
+		}
+	}
+
+	_, err := p.w.WriteRows([]parquet.Row{p.row})
+	return err
+}
+
+// Close writes the remaining rows and the file footer.
+func (p *parquetWriter) Close() error {
+	return p.w.Close()
+}
+
+// parquetGroup is a group whose fields keep the order of the columns;
+// parquet.Group sorts them by name.
+type parquetGroup struct {
+	parquet.Group
+	fields []parquet.Field
+}
+
+func (g parquetGroup) Fields() []parquet.Field { return g.fields }
+
+func parquetSchema(columns []Column) parquet.Node {
+	group := parquetGroup{Group: parquet.Group{}}
+	for _, col := range columns {
+		node := parquet.Optional(parquetNode(col.Type))
+		group.Group[col.Name] = node
+		group.fields = append(group.fields, parquet.Group{col.Name: node}.Fields()[0])
+	}
+	return group
+}
+
+func parquetNode(t Type) parquet.Node {
+	switch t {
+	case Int:
+		return parquet.Int(64)
+	case Float:
+		return parquet.Leaf(parquet.DoubleType)
+	case Bool:
+		return parquet.Leaf(parquet.BooleanType)
+	case Time:
+		return parquet.Timestamp(parquet.Microsecond)
+	}
+	return parquet.String()
+}
@@ -0,0 +1,103 @@
+package export
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/format"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readParquet decodes a Parquet file of columns into its column names,
+// row groups and rows.
+func readParquet(t *testing.T, file []byte, columns []Column) ([]string, int, [][]any) {
+	f, err := parquet.OpenFile(bytes.NewReader(file), int64(len(file)))
+	require.NoError(t, err)
+
+	var names []string
+	for _, field := range f.Schema().Fields() {
+		names = append(names, field.Name())
+	}
+
+	r := parquet.NewReader(bytes.NewReader(file))
+	defer r.Close()
+
+	var rows [][]any
+	buf := make([]parquet.Row, 64)
+	for {
+		n, err := r.ReadRows(buf)
+		for _, row := range buf[:n] {
+			values := make([]any, len(columns))
+			for _, v := range row {
+				if v.IsNull() {
+					continue
+				}
+				var value any
+				switch columns[v.Column()].Type {
+				case String:
+					value = string(v.ByteArray())
+				case Int:
+					value = v.Int64()
+				case Float:
+					value = v.Double()
+				case Bool:
+					value = v.Boolean()
+				case Time:
+					value = time.UnixMicro(v.Int64()).UTC()
+				}
+				values[v.Column()] = value
+			}
+			rows = append(rows, values)
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, int64(len(rows)), f.NumRows())
+	return names, len(f.RowGroups()), rows
+}
+
+func TestParquet(t *testing.T) {
+	file := writeAll(t, FormatParquet, testColumns, testRows)
+
+	names, _, rows := readParquet(t, file, testColumns)
+	assert.Equal(t, []string{"at", "kiosk_id", "name", "latitude", "is_virtual"}, names)
+	assert.Equal(t, testRows, rows)
+
+	f, err := parquet.OpenFile(bytes.NewReader(file), int64(len(file)))
+	require.NoError(t, err)
+	schema := f.Metadata().Schema
+	require.NotNil(t, schema[1].LogicalType.Timestamp)
+	assert.Equal(t, format.TimestampType{IsAdjustedToUTC: true, Unit: format.TimeUnit{Micros: &format.MicroSeconds{}}}, *schema[1].LogicalType.Timestamp)
+	assert.NotNil(t, schema[3].LogicalType.UTF8)
+	assert.Equal(t, format.Optional, *schema[3].RepetitionType)
+}
+
+func TestParquetRowGroups(t *testing.T) {
+	columns := []Column{{"kiosk_id", Int}, {"is_available", Bool}}
+
+	var input [][]any
+	for i := 0; i < rowGroupRows+5; i++ {
+		input = append(input, []any{int64(i), i%3 == 0})
+	}
+
+	_, groups, rows := readParquet(t, writeAll(t, FormatParquet, columns, input), columns)
+	assert.Equal(t, 2, groups)
+	assert.Equal(t, input, rows)
+}
+
+func TestParquetEmpty(t *testing.T) {
+	file := writeAll(t, FormatParquet, testColumns, nil)
+
+	names, _, rows := readParquet(t, file, testColumns)
+	assert.Len(t, names, len(testColumns))
+	assert.Empty(t, rows)
+	assert.True(t, bytes.HasPrefix(file, []byte("PAR1")))
+}
@@ -0,0 +1,123 @@
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+)
+
+type csvWriter struct {
+	out     *csv.Writer
+	columns []Column
+	record  []string
+}
+
+func newCSVWriter(w io.Writer, columns []Column) (*csvWriter, error) {
+	c := &csvWriter{
+		out:     csv.NewWriter(w),
+		columns: columns,
+		record:  make([]string, len(columns)),
+	}
+
+	for i, col := range columns {
+		c.record[i] = col.Name
+	}
+	if err := c.out.Write(c.record); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Write writes row as a record; nulls are empty fields.
+func (c *csvWriter) Write(row []any) error {
+	if err := checkRow(c.columns, row); err != nil {
+		return err
+	}
+
+	for i, v := range row {
+		c.record[i] = formatText(v)
+	}
+	return c.out.Write(c.record)
+}
+
+func (c *csvWriter) Close() error {
+	c.out.Flush()
+	return c.out.Error()
+}
+
+// formatText formats a value for CSV.
+func formatText(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	}
+	return ""
+}
+
+type ndjsonWriter struct {
+	out     *bufio.Writer
+	columns []Column
+	keys    [][]byte
+	line    []byte
+}
+
+func newNDJSONWriter(w io.Writer, columns []Column) *ndjsonWriter {
+	n := &ndjsonWriter{
+		out:     bufio.NewWriter(w),
+		columns: columns,
+		keys:    make([][]byte, len(columns)),
+	}
+
+	for i, col := range columns {
+		n.keys[i], _ = json.Marshal(col.Name)
+	}
+
+	return n
+}
+
+// Write writes row as a JSON object on its own line, with the keys in
+// column order.
+func (n *ndjsonWriter) Write(row []any) error {
+	if err := checkRow(n.columns, row); err != nil {
+		return err
+	}
+
+	line := append(n.line[:0], '{')
+	for i, v := range row {
+		if i > 0 {
+			line = append(line, ',')
+		}
+		line = append(line, n.keys[i]...)
+		line = append(line, ':')
+
+		if t, ok := v.(time.Time); ok {
+			v = t.UTC()
+		}
+		value, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		line = append(line, value...)
+	}
+	line = append(line, '}', '\n')
+	n.line = line
+
+	_, err := n.out.Write(line)
+	return err
+}
+
+func (n *ndjsonWriter) Close() error {
+	return n.out.Flush()
+}
@@ -0,0 +1,175 @@
+// Package forecast predicts station availability from a seasonal baseline.
+//
+// A model holds, for every hour of the week, the mean and spread of the
+// bikes at a station over its history. A prediction starts from the latest
+// snapshot and decays its difference from the baseline exponentially, so
+// short horizons stay close to what is there now and long ones approach
+// the usual level for that hour.
+package forecast
+
+import (
+	"math"
+	"time"
+
+	"github.com/macadrich/go-bike/database/models"
+)
+
+// HoursPerWeek is the number of seasonal slots.
+const HoursPerWeek = 7 * 24
+
+// Confidence is the share of outcomes the predicted band should cover.
+const Confidence = 0.8
+
+// z is the standard normal quantile of the two-sided Confidence band.
+const z = 1.2816
+
+// decayTime is how quickly the current anomaly fades into the baseline.
+const decayTime = time.Hour
+
+// minSpread keeps bands open where history shows no variation at all.
+const minSpread = 1.0
+
+// Slot summarises the bikes at one hour of the week.
+type Slot struct {
+	Samples int
+	Mean    float64
+	Spread  float64
+}
+
+// Model is the seasonal baseline of one station.
+type Model struct {
+	loc   *time.Location
+	slots [HoursPerWeek]Slot
+}
+
+// HourOfWeek numbers the hours of the week in loc from Sunday 00:00.
+func HourOfWeek(t time.Time, loc *time.Location) int {
+	local := t.In(loc)
+	return int(local.Weekday())*24 + local.Hour()
+}
+
+// Train builds a model from hourly history points, read in loc.
+func Train(points []models.HistoryPoint, loc *time.Location) *Model {
+	m := &Model{loc: loc}
+
+	var sum, sumSq [HoursPerWeek]float64
+	for _, p := range points {
+		if p.Samples == 0 {
+			continue
+		}
+		h := HourOfWeek(p.At, loc)
+		m.slots[h].Samples++
+		sum[h] += p.BikesAvg
+		sumSq[h] += p.BikesAvg * p.BikesAvg
+	}
+
+	for h := range m.slots {
+		n := float64(m.slots[h].Samples)
+		if n == 0 {
+			continue
+		}
+		mean := sum[h] / n
+		m.slots[h].Mean = mean
+		if n > 1 {
+			m.slots[h].Spread = math.Sqrt(math.Max(sumSq[h]/n-mean*mean, 0) * n / (n - 1))
+		}
+	}
+
+	return m
+}
+
+// Slot returns the baseline of the hour holding t.
+func (m *Model) Slot(t time.Time) Slot {
+	return m.slots[HourOfWeek(t, m.loc)]
+}
+
+// Residual is the mean difference between the points that match and
+// their baseline, e.g. how many more bikes a station holds when it rains.
+// It returns the number of matching points too.
+func (m *Model) Residual(points []models.HistoryPoint, match func(at time.Time) bool) (float64, int) {
+	sum, n := 0.0, 0
+	for _, p := range points {
+		slot := m.Slot(p.At)
+		if p.Samples == 0 || slot.Samples == 0 || !match(p.At) {
+			continue
+		}
+		sum += p.BikesAvg - slot.Mean
+		n++
+	}
+	if n == 0 {
+		return 0, 0
+	}
+	return sum / float64(n), n
+}
+
+// Input is the state a prediction starts from. Adjust shifts the baseline,
+// e.g. by the Residual of the current weather.
+type Input struct {
+	At      time.Time
+	Bikes   int
+	Docks   int
+	Horizon time.Duration
+	Adjust  float64
+}
+
+// Predict forecasts the bikes and docks at in.At + in.Horizon. Without
+// history for either hour the station is expected to stay as it is.
+func (m *Model) Predict(in Input) models.StationForecast {
+	target := in.At.Add(in.Horizon)
+	capacity := float64(in.Bikes + in.Docks)
+	current := float64(in.Bikes)
+
+	now, later := m.Slot(in.At), m.Slot(target)
+
+	predicted, spread := current, 0.0
+	if now.Samples > 0 && later.Samples > 0 {
+		w := math.Exp(-in.Horizon.Hours() / decayTime.Hours())
+		anomaly := current - (now.Mean + in.Adjust)
+		predicted = later.Mean + in.Adjust + w*anomaly
+		spread = math.Max(later.Spread, minSpread) * math.Sqrt(1-w*w)
+	}
+	predicted = clamp(predicted, 0, capacity)
+
+	bikes := models.ForecastRange{
+		Predicted: round(predicted),
+		Low:       round(clamp(predicted-z*spread, 0, capacity)),
+		High:      round(clamp(predicted+z*spread, 0, capacity)),
+	}
+
+	return models.StationForecast{
+		At:      in.At,
+		Target:  target,
+		Horizon: in.Horizon.String(),
+		Bikes:   bikes,
+		Docks: models.ForecastRange{
+			Predicted: round(capacity - bikes.Predicted),
+			Low:       round(capacity - bikes.High),
+			High:      round(capacity - bikes.Low),
+		},
+		// At least one bike, or one free dock, once rounded.
+		ChanceOfBike: round(chanceAbove(predicted, spread, 0.5)),
+		ChanceOfDock: round(1 - chanceAbove(predicted, spread, capacity-0.5)),
+		Confidence:   Confidence,
+		Samples:      later.Samples,
+	}
+}
+
+// chanceAbove is the probability that a normal variable with the given
+// mean and spread exceeds x.
+func chanceAbove(mean, spread, x float64) float64 {
+	if spread == 0 {
+		if mean > x {
+			return 1
+		}
+		return 0
+	}
+	return 0.5 * math.Erfc((x-mean)/(spread*math.Sqrt2))
+}
+
+func clamp(v, lo, hi float64) float64 {
+	return math.Min(math.Max(v, lo), hi)
+}
+
+func round(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,104 @@
+package forecast
+
+import (
+	"testing"
+	"time"
+
+	"github.com/macadrich/go-bike/database/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// history has, for four weeks, 2 bikes at 8:00 and 10 (or 12 on rainy
+// weeks) at 9:00 every day.
+func history(start time.Time) []models.HistoryPoint {
+	var points []models.HistoryPoint
+	for d := 0; d < 28; d++ {
+		day := start.AddDate(0, 0, d)
+		nine := 10.0
+		if d%14 == 0 {
+			nine = 12
+		}
+		points = append(points,
+			models.HistoryPoint{At: day.Add(8 * time.Hour), Samples: 60, BikesAvg: 2},
+			models.HistoryPoint{At: day.Add(9 * time.Hour), Samples: 60, BikesAvg: nine},
+		)
+	}
+	return points
+}
+
+func TestHourOfWeek(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	// Monday 2024-05-13 08:30 in New York.
+	assert.Equal(t, 24+8, HourOfWeek(time.Date(2024, 5, 13, 12, 30, 0, 0, time.UTC), loc))
+	assert.Equal(t, 0, HourOfWeek(time.Date(2024, 5, 12, 0, 0, 0, 0, loc), loc))
+}
+
+func TestTrain(t *testing.T) {
+	start := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+	m := Train(history(start), time.UTC)
+
+	monday8 := m.Slot(time.Date(2024, 5, 13, 8, 15, 0, 0, time.UTC))
+	assert.Equal(t, 4, monday8.Samples)
+	assert.Equal(t, 2.0, monday8.Mean)
+	assert.Zero(t, monday8.Spread)
+
+	// Mondays at 9: 12, 10, 12, 10.
+	monday9 := m.Slot(time.Date(2024, 5, 13, 9, 0, 0, 0, time.UTC))
+	assert.Equal(t, 11.0, monday9.Mean)
+	assert.InDelta(t, 1.155, monday9.Spread, 0.001)
+
+	assert.Zero(t, m.Slot(time.Date(2024, 5, 13, 3, 0, 0, 0, time.UTC)).Samples)
+}
+
+func TestResidual(t *testing.T) {
+	start := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+	points := history(start)
+	m := Train(points, time.UTC)
+
+	rainy := func(at time.Time) bool { return at.Hour() == 9 && int(at.Sub(start).Hours()/24)%14 == 0 }
+	residual, n := m.Residual(points, rainy)
+	assert.Equal(t, 2, n)
+	assert.Greater(t, residual, 0.0)
+
+	_, n = m.Residual(points, func(time.Time) bool { return false })
+	assert.Zero(t, n)
+}
+
+func TestPredict(t *testing.T) {
+	start := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+	m := Train(history(start), time.UTC)
+	// Monday 8:00, two bikes above the usual two.
+	at := time.Date(2024, 5, 13, 8, 0, 0, 0, time.UTC)
+
+	now := m.Predict(Input{At: at, Bikes: 4, Docks: 11})
+	assert.Equal(t, 4.0, now.Bikes.Predicted)
+	assert.Equal(t, now.Bikes.Low, now.Bikes.High, "no uncertainty at horizon zero")
+	assert.Equal(t, 1.0, now.ChanceOfBike)
+
+	f := m.Predict(Input{At: at, Bikes: 4, Docks: 11, Horizon: 30 * time.Minute})
+	assert.Equal(t, at.Add(30*time.Minute), f.Target)
+	assert.Equal(t, "30m0s", f.Horizon)
+	// Still in the 8:00 slot: the anomaly has partly faded.
+	assert.Greater(t, f.Bikes.Predicted, 2.0)
+	assert.Less(t, f.Bikes.Predicted, 4.0)
+	assert.Less(t, f.Bikes.Low, f.Bikes.Predicted)
+	assert.Greater(t, f.Bikes.High, f.Bikes.Predicted)
+	assert.Equal(t, 15.0, f.Bikes.Predicted+f.Docks.Predicted)
+	assert.Equal(t, Confidence, f.Confidence)
+	assert.Equal(t, 4, f.Samples)
+
+	later := m.Predict(Input{At: at, Bikes: 4, Docks: 11, Horizon: time.Hour})
+	assert.Greater(t, later.Bikes.Predicted, 10.0, "towards the 9:00 baseline")
+
+	rain := m.Predict(Input{At: at, Bikes: 4, Docks: 11, Horizon: time.Hour, Adjust: 1})
+	assert.Greater(t, rain.Bikes.Predicted, later.Bikes.Predicted)
+
+	// Nothing known about 3:00: the station stays as it is.
+	night := m.Predict(Input{At: at.Add(-5 * time.Hour), Bikes: 0, Docks: 15, Horizon: 30 * time.Minute})
+	assert.Equal(t, 0.0, night.Bikes.Predicted)
+	assert.Zero(t, night.ChanceOfBike)
+	assert.Equal(t, 1.0, night.ChanceOfDock)
+}
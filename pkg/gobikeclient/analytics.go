@@ -0,0 +1,172 @@
+package gobikeclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/macadrich/go-bike/database/models"
+	"github.com/macadrich/go-bike/pkg/rebalance"
+)
+
+// TripFlowsQuery selects trip flows. A zero To means now, a zero Interval
+// one hour and a zero KioskId every kiosk. From and To are at most 31 days
+// apart.
+type TripFlowsQuery struct {
+	From, To time.Time
+	Interval time.Duration
+	KioskId  int
+}
+
+// TripFlows estimates the departures and arrivals per kiosk and interval.
+func (c *Client) TripFlows(ctx context.Context, q TripFlowsQuery) (*models.TripFlows, error) {
+	query := url.Values{}
+	setRange(query, q.From, q.To)
+	if q.Interval != 0 {
+		query.Set("interval", q.Interval.String())
+	}
+	if q.KioskId != 0 {
+		query.Set("kioskId", strconv.Itoa(q.KioskId))
+	}
+
+	var result models.TripFlows
+	if err := c.do(ctx, get("/api/v1/trips/flows", query), &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// TripODQuery selects an origin-destination matrix. A zero To means now and
+// a zero Limit the API default.
+type TripODQuery struct {
+	From, To time.Time
+	Limit    int
+}
+
+// TripOD estimates the trips between pairs of kiosks, busiest first.
+func (c *Client) TripOD(ctx context.Context, q TripODQuery) (*models.ODMatrix, error) {
+	query := url.Values{}
+	setRange(query, q.From, q.To)
+	if q.Limit != 0 {
+		query.Set("limit", strconv.Itoa(q.Limit))
+	}
+
+	var result models.ODMatrix
+	if err := c.do(ctx, get("/api/v1/trips/od", query), &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// EBikeBattery returns the distribution of e-bike batteries in the latest
+// snapshot, for every station or, with a kioskId, for one.
+func (c *Client) EBikeBattery(ctx context.Context, kioskId int) (*models.EBikeBattery, error) {
+	query := url.Values{}
+	if kioskId != 0 {
+		query.Set("kioskId", strconv.Itoa(kioskId))
+	}
+
+	var result models.EBikeBattery
+	if err := c.do(ctx, get("/api/v1/ebikes/battery", query), &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// LowBatteryBikes lists the e-bikes below threshold percent, or the
+// configured threshold when zero.
+func (c *Client) LowBatteryBikes(ctx context.Context, threshold int) (*models.LowBatteryReport, error) {
+	query := url.Values{}
+	if threshold != 0 {
+		query.Set("threshold", strconv.Itoa(threshold))
+	}
+
+	var result models.LowBatteryReport
+	if err := c.do(ctx, get("/api/v1/ebikes/low", query), &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// DockBatteryHistory returns what a dock held between from and to, at most
+// 31 days apart. A zero to means now.
+func (c *Client) DockBatteryHistory(ctx context.Context, kioskId, dockNumber int, from, to time.Time) (*models.DockBatteryHistory, error) {
+	query := url.Values{}
+	setRange(query, from, to)
+	path := "/api/v1/ebikes/stations/" + strconv.Itoa(kioskId) + "/docks/" + strconv.Itoa(dockNumber) + "/history"
+
+	var result models.DockBatteryHistory
+	if err := c.do(ctx, get(path, query), &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// RebalancingRequest asks for a rebalancing plan. Zero fields take the API
+// defaults.
+type RebalancingRequest struct {
+	Vans        int                `json:"vans,omitempty"`
+	VanCapacity int                `json:"vanCapacity,omitempty"`
+	MaxStops    int                `json:"maxStops,omitempty"`
+	TargetFill  *float64           `json:"targetFill,omitempty"`
+	Tolerance   *float64           `json:"tolerance,omitempty"`
+	Targets     string             `json:"targets,omitempty"`
+	Depot       *models.Coordinate `json:"depot,omitempty"`
+	KioskIds    []int              `json:"kioskIds,omitempty"`
+}
+
+// RebalancingPlan is the plan for the stations as of At.
+type RebalancingPlan struct {
+	At      time.Time `json:"at"`
+	Targets string    `json:"targets"`
+	rebalance.Plan
+}
+
+// RebalancingPlan plans van routes for the latest snapshot. Planning has
+// no side effects, so the request is retried like a read.
+func (c *Client) RebalancingPlan(ctx context.Context, req RebalancingRequest) (*RebalancingPlan, error) {
+	var result RebalancingPlan
+	err := c.do(ctx, request{method: http.MethodPost, path: "/api/v1/rebalancing/plan", body: req, idempotent: true}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ExportQuery selects an export. Empty Dataset and Format mean stations
+// and csv, a zero To means now and a zero KioskId every kiosk. From and To
+// are at most 366 days apart.
+type ExportQuery struct {
+	Dataset  string
+	Format   string
+	From, To time.Time
+	KioskId  int
+}
+
+// Export streams an export to w. An error after the download started
+// leaves w with a truncated file.
+func (c *Client) Export(ctx context.Context, q ExportQuery, w io.Writer) error {
+	query := url.Values{}
+	setRange(query, q.From, q.To)
+	if q.Dataset != "" {
+		query.Set("dataset", q.Dataset)
+	}
+	if q.Format != "" {
+		query.Set("format", q.Format)
+	}
+	if q.KioskId != 0 {
+		query.Set("kioskId", strconv.Itoa(q.KioskId))
+	}
+
+	resp, err := c.send(ctx, get("/api/v1/export", query))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
@@ -0,0 +1,196 @@
+// Package gobikeclient is the Go client of the go-bike HTTP API.
+//
+//	c, err := gobikeclient.New("http://localhost:8080", gobikeclient.WithToken(token))
+//	if err != nil {
+//		return err
+//	}
+//	stations, err := c.ListStations(ctx, time.Time{})
+//
+// Responses are the types of the models package, or of this package for
+// the v2 stations and snapshots, which rename some fields. Failed requests return an
+// *Error, which matches sentinels such as ErrNotFound with errors.Is.
+// Idempotent requests are retried on network errors, rate limiting and
+// unavailable servers, as configured by WithRetry. Listings that can be
+// long, events, ingestions and webhook deliveries, are read page by page with an
+// Iterator.
+package gobikeclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Client calls the go-bike API. It is safe for concurrent use.
+type Client struct {
+	baseURL    *url.URL
+	token      string
+	httpClient *http.Client
+	retry      RetryPolicy
+	userAgent  string
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithToken authenticates requests with a bearer token: the configured
+// authorization token or an API key.
+func WithToken(token string) Option {
+	return func(c *Client) { c.token = token }
+}
+
+// WithHTTPClient sends requests with hc instead of http.DefaultClient.
+// Streams and exports can last long, so hc should not set a Timeout;
+// bound requests with their context instead.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.httpClient = hc }
+}
+
+// WithRetry replaces DefaultRetryPolicy. RetryPolicy{} disables retries.
+func WithRetry(policy RetryPolicy) Option {
+	return func(c *Client) { c.retry = policy }
+}
+
+// WithUserAgent sets the User-Agent header of every request.
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) { c.userAgent = userAgent }
+}
+
+// New returns a client for the API served at baseURL, e.g.
+// http://localhost:8080.
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("gobikeclient: invalid base URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("gobikeclient: base URL %q must be http or https", baseURL)
+	}
+
+	c := &Client{
+		baseURL:    u,
+		httpClient: http.DefaultClient,
+		retry:      DefaultRetryPolicy,
+		userAgent:  "gobikeclient",
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c, nil
+}
+
+// request describes one API call.
+type request struct {
+	method string
+	path   string
+	query  url.Values
+	body   any
+	// idempotent requests are retried on any retryable failure, others
+	// only when the server did not process them.
+	idempotent bool
+	// noAuth is set for the probes served without authorization.
+	noAuth bool
+}
+
+func get(path string, query url.Values) request {
+	return request{method: http.MethodGet, path: path, query: query, idempotent: true}
+}
+
+// send performs req, with retries, and returns the successful response.
+// The caller closes its body.
+func (c *Client) send(ctx context.Context, req request) (*http.Response, error) {
+	var body []byte
+	if req.body != nil {
+		var err error
+		if body, err = json.Marshal(req.body); err != nil {
+			return nil, err
+		}
+	}
+
+	u := c.baseURL.JoinPath(req.path)
+	u.RawQuery = req.query.Encode()
+
+	for attempt := 1; ; attempt++ {
+		resp, err := c.attempt(ctx, req, u.String(), body)
+		if err == nil && resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+			return resp, nil
+		}
+		if err == nil {
+			err = readError(resp)
+			resp.Body.Close()
+		}
+
+		wait, retry := c.retry.next(attempt, req.idempotent, err)
+		if !retry || ctx.Err() != nil {
+			return nil, err
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		case <-timer.C:
+		}
+	}
+}
+
+func (c *Client) attempt(ctx context.Context, req request, u string, body []byte) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, u, reader)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Accept", "application/json")
+	httpReq.Header.Set("User-Agent", c.userAgent)
+	if body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" && !req.noAuth {
+		httpReq.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	return c.httpClient.Do(httpReq)
+}
+
+// do performs req and decodes the JSON response into out, when set.
+func (c *Client) do(ctx context.Context, req request, out any) error {
+	resp, err := c.send(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("gobikeclient: decoding response: %w", err)
+	}
+
+	return nil
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// setRange adds the from and, unless zero, to parameters to query.
+func setRange(query url.Values, from, to time.Time) {
+	query.Set("from", formatTime(from))
+	if !to.IsZero() {
+		query.Set("to", formatTime(to))
+	}
+}
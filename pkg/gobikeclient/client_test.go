@@ -0,0 +1,346 @@
+package gobikeclient_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/macadrich/go-bike/api"
+	"github.com/macadrich/go-bike/api/handlers"
+	"github.com/macadrich/go-bike/api/routers"
+	"github.com/macadrich/go-bike/config"
+	"github.com/macadrich/go-bike/database"
+	"github.com/macadrich/go-bike/database/models"
+	"github.com/macadrich/go-bike/pkg/apperr"
+	"github.com/macadrich/go-bike/pkg/gobikeclient"
+	"github.com/macadrich/go-bike/pkg/stream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const token = "secret"
+
+var at = time.Date(2024, 5, 14, 6, 48, 0, 0, time.UTC)
+
+// fakeDB serves a few stations, ingestions, events and webhook deliveries
+// from memory.
+type fakeDB struct {
+	database.Database
+	ingestions []models.Ingestion
+	events     []models.StationEvent
+	deliveries []models.WebhookDelivery
+}
+
+func (db *fakeDB) LatestSnapshot(ctx context.Context) (time.Time, error) {
+	return at, nil
+}
+
+func (db *fakeDB) NextIngestion(ctx context.Context, t time.Time) (time.Time, error) {
+	if t.After(at) {
+		return time.Time{}, nil
+	}
+	return at, nil
+}
+
+func (db *fakeDB) QuerySnapshot(ctx context.Context, t time.Time) ([]models.Stations, error) {
+	return []models.Stations{{KioskId: 3005, Name: "Welcome Park", At: t, KioskStatus: "FullService"}, {KioskId: 3006, At: t}}, nil
+}
+
+func (db *fakeDB) ListIngestions(ctx context.Context, before time.Time, limit int) ([]models.Ingestion, error) {
+	page := []models.Ingestion{}
+	for _, i := range db.ingestions {
+		if i.At.Before(before) && len(page) < limit {
+			page = append(page, i)
+		}
+	}
+	return page, nil
+}
+
+func (db *fakeDB) QueryAllStation(ctx context.Context, lastUpdate time.Time) ([]models.Stations, error) {
+	return []models.Stations{{KioskId: 3005, Name: "Welcome Park", At: at}, {KioskId: 3006, At: at}}, nil
+}
+
+func (db *fakeDB) QuerySpecificStation(ctx context.Context, kioskId int, lastUpdate time.Time) (*models.Stations, error) {
+	return &models.Stations{KioskId: kioskId, Name: "Welcome Park", At: at}, nil
+}
+
+func (db *fakeDB) StationHistory(ctx context.Context, kioskId int, from, to time.Time, resolution string) ([]models.HistoryPoint, error) {
+	return []models.HistoryPoint{{At: from, Samples: 12, BikesAvg: 4.5}}, nil
+}
+
+func (db *fakeDB) QueryEvents(ctx context.Context, since time.Time, kioskId int, afterId int64, limit int) ([]models.StationEvent, error) {
+	var page []models.StationEvent
+	for _, e := range db.events {
+		if e.At.Before(since) || (e.At.Equal(since) && e.Id <= afterId) {
+			continue
+		}
+		if len(page) == limit {
+			break
+		}
+		page = append(page, e)
+	}
+	return page, nil
+}
+
+func (db *fakeDB) GetWebhook(ctx context.Context, id int64) (*models.WebhookSubscription, error) {
+	if id != 1 {
+		return nil, apperr.NotFound("webhook", fmt.Sprintf("webhook %d not found", id))
+	}
+	return &models.WebhookSubscription{Id: 1}, nil
+}
+
+func (db *fakeDB) ListDeliveries(ctx context.Context, subscriptionId int64, status string, beforeId int64, limit int) ([]models.WebhookDelivery, error) {
+	page := []models.WebhookDelivery{}
+	for _, d := range db.deliveries {
+		if (beforeId == 0 || d.Id < beforeId) && len(page) < limit {
+			page = append(page, d)
+		}
+	}
+	return page, nil
+}
+
+func (db *fakeDB) UseAPIKey(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	return nil, database.ErrNotFound
+}
+
+// newServer serves the real router over a service backed by db.
+func newServer(t *testing.T, db database.Database) (*httptest.Server, api.IService) {
+	cfg := &config.Config{}
+	cfg.Authorization.Token = token
+	live := config.NewLive("", cfg)
+	svc := api.NewService(db, nil, live)
+
+	srv := httptest.NewServer(routers.NewRouter(handlers.NewHandlers(svc), live))
+	t.Cleanup(srv.Close)
+	return srv, svc
+}
+
+func newClient(t *testing.T, url string, opts ...gobikeclient.Option) *gobikeclient.Client {
+	c, err := gobikeclient.New(url, append([]gobikeclient.Option{gobikeclient.WithToken(token)}, opts...)...)
+	require.NoError(t, err)
+	return c
+}
+
+func TestStations(t *testing.T) {
+	srv, _ := newServer(t, &fakeDB{})
+	c := newClient(t, srv.URL)
+
+	stations, err := c.Stations(context.TODO(), at)
+	require.NoError(t, err)
+	assert.Len(t, stations.Stations, 2)
+
+	station, err := c.Station(context.TODO(), 3005, at)
+	require.NoError(t, err)
+	assert.Equal(t, "Welcome Park", station.Name)
+
+	history, err := c.StationHistory(context.TODO(), 3005, at.Add(-2*time.Hour), at, "hour")
+	require.NoError(t, err)
+	assert.Equal(t, models.ResolutionHour, history.Resolution)
+	require.Len(t, history.Points, 1)
+	assert.Equal(t, 4.5, history.Points[0].BikesAvg)
+}
+
+func TestSnapshotsV2(t *testing.T) {
+	srv, _ := newServer(t, &fakeDB{})
+	c := newClient(t, srv.URL)
+
+	snapshot, err := c.Snapshot(context.TODO(), time.Time{})
+	require.NoError(t, err)
+	assert.Equal(t, at, snapshot.At)
+	assert.Len(t, snapshot.Stations, 2)
+	assert.Nil(t, snapshot.Weather)
+
+	snapshot, err = c.Snapshot(context.TODO(), at.Add(-time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, at, snapshot.At)
+
+	_, err = c.Snapshot(context.TODO(), at.Add(time.Minute))
+	assert.ErrorIs(t, err, gobikeclient.ErrNotFound)
+
+	stations, err := c.ListStations(context.TODO(), time.Time{})
+	require.NoError(t, err)
+	require.Len(t, stations.Stations, 2)
+	assert.Equal(t, "FullService", stations.Stations[0].KioskStatus)
+
+	station, err := c.GetStation(context.TODO(), 3005, at)
+	require.NoError(t, err)
+	assert.Equal(t, "Welcome Park", station.Name)
+
+	_, err = c.GetStation(context.TODO(), 3999, time.Time{})
+	assert.ErrorIs(t, err, gobikeclient.ErrNotFound)
+}
+
+func TestIngestionsIterator(t *testing.T) {
+	db := &fakeDB{}
+	for i := 0; i < 5; i++ {
+		db.ingestions = append(db.ingestions, models.Ingestion{At: at.Add(-time.Duration(i) * time.Minute), Source: models.SourceLive})
+	}
+	srv, _ := newServer(t, db)
+	c := newClient(t, srv.URL)
+
+	ingestions, err := c.Ingestions(gobikeclient.IngestionsQuery{PageSize: 2}).All(context.TODO())
+	require.NoError(t, err)
+	assert.Equal(t, db.ingestions, ingestions)
+
+	ingestions, err = c.Ingestions(gobikeclient.IngestionsQuery{Before: at.Add(-2 * time.Minute)}).All(context.TODO())
+	require.NoError(t, err)
+	assert.Len(t, ingestions, 2)
+}
+
+func TestCreateIngestion(t *testing.T) {
+	unchanged := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/api/v2/ingestions", r.URL.Path)
+		if unchanged {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"at":"2024-05-14T06:48:00Z","source":"live","stations":2}`))
+	}))
+	defer srv.Close()
+	c := newClient(t, srv.URL)
+
+	ingestion, err := c.CreateIngestion(context.TODO())
+	require.NoError(t, err)
+	assert.Equal(t, models.Ingestion{At: at, Source: models.SourceLive, Stations: 2}, *ingestion)
+
+	unchanged = true
+	ingestion, err = c.CreateIngestion(context.TODO())
+	require.NoError(t, err)
+	assert.Nil(t, ingestion)
+}
+
+func TestErrors(t *testing.T) {
+	srv, _ := newServer(t, &fakeDB{})
+
+	_, err := newClient(t, srv.URL, gobikeclient.WithToken("wrong")).Stations(context.TODO(), at)
+	assert.ErrorIs(t, err, gobikeclient.ErrUnauthorized)
+
+	c := newClient(t, srv.URL)
+	_, err = c.StationHistory(context.TODO(), 3005, at, at.Add(-time.Hour), "")
+	assert.ErrorIs(t, err, gobikeclient.ErrBadRequest)
+	var apiErr *gobikeclient.Error
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, "to must be after from", apiErr.Message)
+	assert.Equal(t, "invalid_argument", apiErr.Code)
+	assert.Equal(t, []gobikeclient.InvalidParam{{Name: "to", In: "query", Reason: "must be after from"}}, apiErr.InvalidParams)
+
+	it := c.Deliveries(2, gobikeclient.DeliveriesQuery{})
+	assert.False(t, it.Next(context.TODO()))
+	assert.ErrorIs(t, it.Err(), gobikeclient.ErrNotFound)
+	require.ErrorAs(t, it.Err(), &apiErr)
+	assert.Equal(t, "webhook_not_found", apiErr.Code)
+
+	_, err = gobikeclient.New("localhost:8080")
+	assert.Error(t, err)
+}
+
+func TestEventsIterator(t *testing.T) {
+	db := &fakeDB{}
+	// Five events share a snapshot, so pages must resume by id.
+	for i := int64(1); i <= 7; i++ {
+		eventAt := at
+		if i > 5 {
+			eventAt = at.Add(time.Duration(i) * time.Minute)
+		}
+		db.events = append(db.events, models.StationEvent{Id: i, KioskId: 3000 + int(i), At: eventAt})
+	}
+	srv, _ := newServer(t, db)
+	c := newClient(t, srv.URL)
+
+	events, err := c.Events(gobikeclient.EventsQuery{Since: at, PageSize: 2}).All(context.TODO())
+	require.NoError(t, err)
+	var ids []int64
+	for _, e := range events {
+		ids = append(ids, e.Id)
+	}
+	assert.Equal(t, []int64{1, 2, 3, 4, 5, 6, 7}, ids)
+}
+
+func TestDeliveriesIterator(t *testing.T) {
+	db := &fakeDB{}
+	for id := int64(5); id >= 1; id-- {
+		db.deliveries = append(db.deliveries, models.WebhookDelivery{Id: id, SubscriptionId: 1})
+	}
+	srv, _ := newServer(t, db)
+	c := newClient(t, srv.URL)
+
+	deliveries, err := c.Deliveries(1, gobikeclient.DeliveriesQuery{PageSize: 2}).All(context.TODO())
+	require.NoError(t, err)
+	require.Len(t, deliveries, 5)
+	assert.True(t, slices.IsSortedFunc(deliveries, func(a, b models.WebhookDelivery) int {
+		return int(b.Id - a.Id)
+	}))
+}
+
+func TestStreamStations(t *testing.T) {
+	srv, svc := newServer(t, &fakeDB{})
+	c := newClient(t, srv.URL)
+
+	broker := svc.Stream()
+	broker.Publish([]models.StationUpdate{{KioskId: 3005, At: at, BikesAvailable: 3}})
+	sub, backlog, _ := broker.Subscribe(stream.Filter{}, 0, true)
+	broker.Unsubscribe(sub)
+	require.Len(t, backlog, 1)
+	broker.Publish([]models.StationUpdate{{KioskId: 3005, At: at, BikesAvailable: 4}})
+
+	stop := errors.New("stop")
+	var got []gobikeclient.StreamEvent
+	err := c.StreamStations(context.TODO(), gobikeclient.StreamQuery{LastEventID: backlog[0].ID}, func(e gobikeclient.StreamEvent) error {
+		got = append(got, e)
+		return stop
+	})
+	assert.ErrorIs(t, err, stop)
+	require.Len(t, got, 1)
+	assert.Equal(t, gobikeclient.StreamStation, got[0].Type)
+	assert.Equal(t, backlog[0].ID+1, got[0].ID)
+	assert.Equal(t, 4, got[0].Station.BikesAvailable)
+}
+
+func TestLive(t *testing.T) {
+	srv, _ := newServer(t, &fakeDB{})
+
+	report, err := newClient(t, srv.URL).Live(context.TODO())
+	require.NoError(t, err)
+	assert.True(t, report.Healthy())
+}
+
+func TestRetry(t *testing.T) {
+	var attempts int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 3 {
+			http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"kioskId":3005}`))
+	}))
+	defer srv.Close()
+
+	policy := gobikeclient.RetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	c := newClient(t, srv.URL, gobikeclient.WithRetry(policy))
+
+	station, err := c.Station(context.TODO(), 3005, at)
+	require.NoError(t, err)
+	assert.Equal(t, 3005, station.KioskId)
+	assert.Equal(t, 3, attempts)
+
+	// An ingestion may have run before the server failed.
+	attempts = 0
+	err = c.Ingest(context.TODO())
+	assert.ErrorIs(t, err, gobikeclient.ErrUnavailable)
+	assert.Equal(t, 1, attempts)
+
+	attempts = 0
+	c = newClient(t, srv.URL, gobikeclient.WithRetry(gobikeclient.RetryPolicy{}))
+	_, err = c.Station(context.TODO(), 3005, at)
+	assert.ErrorIs(t, err, gobikeclient.ErrUnavailable)
+	assert.Equal(t, 1, attempts)
+}
@@ -0,0 +1,100 @@
+package gobikeclient
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Errors matched by an *Error, by its status code, with errors.Is.
+var (
+	ErrBadRequest   = errors.New("gobikeclient: bad request")
+	ErrUnauthorized = errors.New("gobikeclient: unauthorized")
+	ErrNotFound     = errors.New("gobikeclient: not found")
+	ErrConflict     = errors.New("gobikeclient: conflict")
+	ErrRateLimited  = errors.New("gobikeclient: rate limited")
+	ErrUnavailable  = errors.New("gobikeclient: service unavailable")
+)
+
+// Error is returned for responses with an unsuccessful status code.
+type Error struct {
+	StatusCode int
+	// Code is the machine-readable error code given by the API, such as
+	// station_not_found. It is empty when the API gave none.
+	Code string
+	// Message is the explanation given by the API.
+	Message string
+	// InvalidParams lists the parameters the API rejected in a bad request.
+	InvalidParams []InvalidParam
+	// RetryAfter is how long the API asked to wait, if it did.
+	RetryAfter time.Duration
+}
+
+// InvalidParam is a parameter rejected by the API: its name, where it was
+// given (path, query, header or body) and why it is invalid.
+type InvalidParam struct {
+	Name   string `json:"name"`
+	In     string `json:"in"`
+	Reason string `json:"reason"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("go-bike API: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// Is reports whether target is the sentinel of e's status code.
+func (e *Error) Is(target error) bool {
+	switch e.StatusCode {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return target == ErrBadRequest
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return target == ErrUnauthorized
+	case http.StatusNotFound:
+		return target == ErrNotFound
+	case http.StatusConflict:
+		return target == ErrConflict
+	case http.StatusTooManyRequests:
+		return target == ErrRateLimited
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return target == ErrUnavailable
+	}
+	return false
+}
+
+// readError turns an unsuccessful response into an *Error. The API answers
+// with problem details; older servers and proxies may send {"message": ...}
+// or plain text.
+func readError(resp *http.Response) error {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+
+	var body struct {
+		Title         string         `json:"title"`
+		Detail        string         `json:"detail"`
+		Code          string         `json:"code"`
+		Message       string         `json:"message"`
+		InvalidParams []InvalidParam `json:"invalidParams"`
+	}
+	message := strings.TrimSpace(string(data))
+	if json.Unmarshal(data, &body) == nil {
+		switch {
+		case body.Detail != "":
+			message = body.Detail
+		case body.Message != "":
+			message = body.Message
+		case body.Title != "":
+			message = body.Title
+		}
+	}
+
+	apiErr := &Error{StatusCode: resp.StatusCode, Code: body.Code, Message: message, InvalidParams: body.InvalidParams}
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		apiErr.RetryAfter = time.Duration(seconds) * time.Second
+	}
+
+	return apiErr
+}
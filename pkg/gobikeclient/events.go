@@ -0,0 +1,53 @@
+package gobikeclient
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/macadrich/go-bike/database/models"
+)
+
+// EventsQuery selects station events. A zero KioskId means every kiosk.
+type EventsQuery struct {
+	Since   time.Time
+	KioskId int
+	// PageSize is the number of events fetched per request, at most 1000.
+	PageSize int
+}
+
+// eventsPage is the response of /events.
+type eventsPage struct {
+	Since  time.Time             `json:"since"`
+	Events []models.StationEvent `json:"events"`
+}
+
+// Events iterates over the events detected since q.Since, oldest first.
+func (c *Client) Events(q EventsQuery) *Iterator[models.StationEvent] {
+	limit := pageSize(q.PageSize)
+	since, afterId := q.Since, int64(0)
+
+	return newIterator(func(ctx context.Context) ([]models.StationEvent, bool, error) {
+		query := url.Values{
+			"since": {since.UTC().Format(time.RFC3339Nano)},
+			"limit": {strconv.Itoa(limit)},
+		}
+		if q.KioskId != 0 {
+			query.Set("kioskId", strconv.Itoa(q.KioskId))
+		}
+		if afterId != 0 {
+			query.Set("afterId", strconv.FormatInt(afterId, 10))
+		}
+
+		var page eventsPage
+		if err := c.do(ctx, get("/api/v1/events", query), &page); err != nil {
+			return nil, false, err
+		}
+		if n := len(page.Events); n > 0 {
+			since, afterId = page.Events[n-1].At, page.Events[n-1].Id
+		}
+
+		return page.Events, len(page.Events) == limit, nil
+	})
+}
@@ -0,0 +1,58 @@
+package gobikeclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/macadrich/go-bike/pkg/health"
+)
+
+// Live reports whether the server process is running.
+func (c *Client) Live(ctx context.Context) (*health.Report, error) {
+	var result health.Report
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/livez", idempotent: true, noAuth: true}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Ready returns the readiness of the server and its dependencies. A server
+// that is not ready is not an error: check the report with Healthy.
+func (c *Client) Ready(ctx context.Context) (*health.Report, error) {
+	var result health.Report
+	err := c.do(ctx, request{method: http.MethodGet, path: "/readyz", noAuth: true}, &result)
+
+	var apiErr *Error
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusServiceUnavailable {
+		if json.Unmarshal([]byte(apiErr.Message), &result) != nil {
+			return nil, fmt.Errorf("gobikeclient: decoding readiness: %w", err)
+		}
+		return &result, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ActiveConfig is the configuration in effect on the server. Runtime holds
+// its reloadable sections as served.
+type ActiveConfig struct {
+	Version  int64           `json:"version"`
+	LoadedAt time.Time       `json:"loadedAt"`
+	Runtime  json.RawMessage `json:"runtime"`
+}
+
+// ActiveConfig returns the version and reloadable sections of the
+// configuration in effect.
+func (c *Client) ActiveConfig(ctx context.Context) (*ActiveConfig, error) {
+	var result ActiveConfig
+	if err := c.do(ctx, get("/api/v1/admin/config", nil), &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
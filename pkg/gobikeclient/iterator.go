@@ -0,0 +1,74 @@
+package gobikeclient
+
+import "context"
+
+// defaultPageSize is the page size of iterators when none is given.
+const defaultPageSize = 500
+
+// maxPageSize is the largest page the API serves.
+const maxPageSize = 1000
+
+func pageSize(size int) int {
+	if size <= 0 {
+		return defaultPageSize
+	}
+	return min(size, maxPageSize)
+}
+
+// Iterator walks a listing fetched page by page:
+//
+//	it := c.Events(gobikeclient.EventsQuery{Since: since})
+//	for it.Next(ctx) {
+//		event := it.Value()
+//	}
+//	if err := it.Err(); err != nil {
+//		return err
+//	}
+type Iterator[T any] struct {
+	// fetch returns the next page and whether another one may follow.
+	fetch func(ctx context.Context) ([]T, bool, error)
+	page  []T
+	more  bool
+	value T
+	err   error
+}
+
+func newIterator[T any](fetch func(ctx context.Context) ([]T, bool, error)) *Iterator[T] {
+	return &Iterator[T]{fetch: fetch, more: true}
+}
+
+// Next advances to the next item, fetching a page when needed. It returns
+// false at the end of the listing or on error.
+func (it *Iterator[T]) Next(ctx context.Context) bool {
+	for len(it.page) == 0 {
+		if !it.more || it.err != nil {
+			return false
+		}
+		it.page, it.more, it.err = it.fetch(ctx)
+		if it.err != nil {
+			return false
+		}
+	}
+
+	it.value, it.page = it.page[0], it.page[1:]
+	return true
+}
+
+// Value returns the current item.
+func (it *Iterator[T]) Value() T {
+	return it.value
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *Iterator[T]) Err() error {
+	return it.err
+}
+
+// All collects the remaining items.
+func (it *Iterator[T]) All(ctx context.Context) ([]T, error) {
+	var items []T
+	for it.Next(ctx) {
+		items = append(items, it.Value())
+	}
+	return items, it.Err()
+}
@@ -0,0 +1,70 @@
+package gobikeclient
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy decides how failed requests are retried. Idempotent requests
+// are retried on network errors and 429, 502, 503 and 504 responses; the
+// others, e.g. triggering an ingestion, only on 429, which the API answers
+// before processing the request.
+type RetryPolicy struct {
+	// MaxAttempts is the number of tries, including the first. Zero or one
+	// disables retries.
+	MaxAttempts int
+	// MinBackoff is the wait before the first retry. It doubles with every
+	// retry, with jitter, up to MaxBackoff.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// DefaultRetryPolicy tries requests up to three times.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	MinBackoff:  200 * time.Millisecond,
+	MaxBackoff:  5 * time.Second,
+}
+
+// next returns how long to wait before retrying a request that failed
+// with err on the given attempt, and whether to retry at all.
+func (p RetryPolicy) next(attempt int, idempotent bool, err error) (time.Duration, bool) {
+	if attempt >= p.MaxAttempts || !retryable(err, idempotent) {
+		return 0, false
+	}
+
+	wait := p.MinBackoff << (attempt - 1)
+	if wait <= 0 || wait > p.MaxBackoff {
+		wait = p.MaxBackoff
+	}
+	// Full jitter over the upper half keeps clients from retrying in step.
+	if half := int64(wait / 2); half > 0 {
+		wait = time.Duration(half + rand.Int63n(half+1))
+	}
+
+	var apiErr *Error
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > wait {
+		wait = apiErr.RetryAfter
+	}
+
+	return wait, true
+}
+
+func retryable(err error, idempotent bool) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, ErrRateLimited) {
+		return true
+	}
+
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return idempotent && errors.Is(err, ErrUnavailable)
+	}
+
+	// The request may have reached the server before the connection broke.
+	return idempotent
+}
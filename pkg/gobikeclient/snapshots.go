@@ -0,0 +1,161 @@
+package gobikeclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/macadrich/go-bike/database/models"
+)
+
+// Station is a station of the v2 API: the feed fields of models.Stations
+// under corrected names. OpenTime and CloseTime are times of day such as
+// "06:00:00".
+type Station struct {
+	KioskId                int           `json:"kioskId"`
+	Id                     int           `json:"id"`
+	At                     time.Time     `json:"at"`
+	Name                   string        `json:"name"`
+	KioskType              int           `json:"kioskType"`
+	KioskStatus            string        `json:"kioskStatus"`
+	KioskPublicStatus      string        `json:"kioskPublicStatus"`
+	KioskConnectionStatus  string        `json:"kioskConnectionStatus"`
+	IsEventBased           bool          `json:"isEventBased"`
+	IsVirtual              bool          `json:"isVirtual"`
+	Latitude               float64       `json:"latitude"`
+	Longitude              float64       `json:"longitude"`
+	AddressStreet          string        `json:"addressStreet"`
+	AddressCity            string        `json:"addressCity"`
+	AddressState           string        `json:"addressState"`
+	AddressZipCode         string        `json:"addressZipCode"`
+	TimeZone               string        `json:"timeZone"`
+	OpenTime               *string       `json:"openTime"`
+	CloseTime              *string       `json:"closeTime"`
+	EventStart             *time.Time    `json:"eventStart"`
+	EventEnd               *time.Time    `json:"eventEnd"`
+	Notes                  string        `json:"notes"`
+	PublicText             string        `json:"publicText"`
+	TotalDocks             int           `json:"totalDocks"`
+	DocksAvailable         int           `json:"docksAvailable"`
+	BikesAvailable         int           `json:"bikesAvailable"`
+	ClassicBikesAvailable  int           `json:"classicBikesAvailable"`
+	SmartBikesAvailable    int           `json:"smartBikesAvailable"`
+	ElectricBikesAvailable int           `json:"electricBikesAvailable"`
+	TrikesAvailable        int           `json:"trikesAvailable"`
+	RewardBikesAvailable   int           `json:"rewardBikesAvailable"`
+	RewardDocksAvailable   int           `json:"rewardDocksAvailable"`
+	Bikes                  []models.Bike `json:"bikes"`
+}
+
+// StationList is the stations of the snapshot taken at At.
+type StationList struct {
+	At       time.Time `json:"at"`
+	Stations []Station `json:"stations"`
+}
+
+// Snapshot is a stored snapshot of the feed. Weather is nil when the
+// weather feature is off or nothing was observed near At.
+type Snapshot struct {
+	At       time.Time                  `json:"at"`
+	Stations []Station                  `json:"stations"`
+	Weather  *models.WeatherObservation `json:"weather"`
+}
+
+// atQuery is the at parameter of the v2 station reads, left out for the
+// latest snapshot.
+func atQuery(at time.Time) url.Values {
+	if at.IsZero() {
+		return nil
+	}
+	return url.Values{"at": {at.UTC().Format(time.RFC3339Nano)}}
+}
+
+// Snapshot returns the first snapshot taken at or after at, or the latest
+// one when at is zero.
+func (c *Client) Snapshot(ctx context.Context, at time.Time) (*Snapshot, error) {
+	path := "/api/v2/snapshots/latest"
+	if !at.IsZero() {
+		path = "/api/v2/snapshots/" + at.UTC().Format(time.RFC3339Nano)
+	}
+
+	var result Snapshot
+	if err := c.do(ctx, get(path, nil), &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ListStations returns the stations of the first snapshot taken at or
+// after at, or of the latest one when at is zero.
+func (c *Client) ListStations(ctx context.Context, at time.Time) (*StationList, error) {
+	var result StationList
+	if err := c.do(ctx, get("/api/v2/stations", atQuery(at)), &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetStation returns one kiosk in the first snapshot taken at or after at,
+// or in the latest one when at is zero.
+func (c *Client) GetStation(ctx context.Context, kioskId int, at time.Time) (*Station, error) {
+	var result Station
+	if err := c.do(ctx, get("/api/v2/stations/"+strconv.Itoa(kioskId), atQuery(at)), &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// CreateIngestion fetches the current feed and stores it, as the scheduler
+// does. It returns nil when the feed has not changed since the last
+// ingestion.
+func (c *Client) CreateIngestion(ctx context.Context) (*models.Ingestion, error) {
+	resp, err := c.send(ctx, request{method: http.MethodPost, path: "/api/v2/ingestions"})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNoContent {
+		return nil, nil
+	}
+	var result models.Ingestion
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("gobikeclient: decoding response: %w", err)
+	}
+	return &result, nil
+}
+
+// IngestionsQuery selects stored snapshots. A zero Before means now.
+type IngestionsQuery struct {
+	Before time.Time
+	// PageSize is the number of ingestions fetched per request, at most
+	// 1000.
+	PageSize int
+}
+
+// Ingestions iterates over the stored snapshots, newest first.
+func (c *Client) Ingestions(q IngestionsQuery) *Iterator[models.Ingestion] {
+	limit := pageSize(q.PageSize)
+	before := q.Before
+
+	return newIterator(func(ctx context.Context) ([]models.Ingestion, bool, error) {
+		query := url.Values{"limit": {strconv.Itoa(limit)}}
+		if !before.IsZero() {
+			query.Set("before", before.UTC().Format(time.RFC3339Nano))
+		}
+
+		var page []models.Ingestion
+		if err := c.do(ctx, get("/api/v2/ingestions", query), &page); err != nil {
+			return nil, false, err
+		}
+		if n := len(page); n > 0 {
+			before = page[n-1].At
+		}
+
+		return page, len(page) == limit, nil
+	})
+}
@@ -0,0 +1,89 @@
+package gobikeclient
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/macadrich/go-bike/database/models"
+)
+
+// Ingest fetches the current feed and stores it, as the scheduler does.
+//
+// Deprecated: Use CreateIngestion, which calls the v2 API.
+func (c *Client) Ingest(ctx context.Context) error {
+	return c.do(ctx, request{method: http.MethodPost, path: "/api/v1/indego-data-fetch-and-store-it-db"}, nil)
+}
+
+// Stations returns every station as of the snapshot at or before at.
+//
+// Deprecated: Use ListStations, which calls the v2 API.
+func (c *Client) Stations(ctx context.Context, at time.Time) (*models.StationsResponse, error) {
+	var result models.StationsResponse
+	if err := c.do(ctx, get("/api/v1/stations", url.Values{"at": {formatTime(at)}}), &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func stationPath(kioskId int, parts ...string) string {
+	path := "/api/v1/stations/" + strconv.Itoa(kioskId)
+	for _, part := range parts {
+		path += "/" + part
+	}
+	return path
+}
+
+// Station returns one kiosk as of the snapshot at or before at.
+//
+// Deprecated: Use GetStation, which calls the v2 API.
+func (c *Client) Station(ctx context.Context, kioskId int, at time.Time) (*models.Stations, error) {
+	var result models.Stations
+	if err := c.do(ctx, get(stationPath(kioskId), url.Values{"at": {formatTime(at)}}), &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// StationHistory returns the availability of a kiosk between from and to.
+// A zero to means now and an empty resolution means auto.
+func (c *Client) StationHistory(ctx context.Context, kioskId int, from, to time.Time, resolution string) (*models.StationHistory, error) {
+	query := url.Values{}
+	setRange(query, from, to)
+	if resolution != "" {
+		query.Set("resolution", resolution)
+	}
+
+	var result models.StationHistory
+	if err := c.do(ctx, get(stationPath(kioskId, "history"), query), &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ForecastOptions tune a forecast. A zero Horizon means 30 minutes.
+type ForecastOptions struct {
+	Horizon time.Duration
+	// Weather adjusts the forecast for the current weather.
+	Weather bool
+}
+
+// Forecast predicts the bikes and docks of a kiosk some time after its
+// latest snapshot.
+func (c *Client) Forecast(ctx context.Context, kioskId int, opts ForecastOptions) (*models.StationForecast, error) {
+	query := url.Values{}
+	if opts.Horizon != 0 {
+		query.Set("horizon", opts.Horizon.String())
+	}
+	if opts.Weather {
+		query.Set("weather", "true")
+	}
+
+	var result models.StationForecast
+	if err := c.do(ctx, get(stationPath(kioskId, "forecast"), query), &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
@@ -0,0 +1,105 @@
+package gobikeclient
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/macadrich/go-bike/database/models"
+)
+
+// Types of StreamEvent.
+const (
+	StreamStation = "station"
+	// StreamReset means updates were missed and could not be replayed, so
+	// the state should be reloaded with Stations.
+	StreamReset = "reset"
+)
+
+// StreamQuery selects the stations to follow. Empty KioskIds and BBox
+// match every station. LastEventID resumes a stream after the event with
+// that id.
+type StreamQuery struct {
+	KioskIds []int
+	// BBox is minLon,minLat,maxLon,maxLat.
+	BBox        string
+	LastEventID uint64
+}
+
+// StreamEvent is an event of the station stream.
+type StreamEvent struct {
+	Type    string
+	ID      uint64
+	Station *models.StationUpdate
+}
+
+// StreamStations follows station updates as they are ingested, calling fn
+// for each event until ctx is done, the server closes the stream or fn
+// returns an error. To resume after a disconnection, call it again with
+// the ID of the last event.
+func (c *Client) StreamStations(ctx context.Context, q StreamQuery, fn func(StreamEvent) error) error {
+	query := url.Values{}
+	for _, kioskId := range q.KioskIds {
+		query.Add("kioskId", strconv.Itoa(kioskId))
+	}
+	if q.BBox != "" {
+		query.Set("bbox", q.BBox)
+	}
+	if q.LastEventID != 0 {
+		query.Set("lastEventId", strconv.FormatUint(q.LastEventID, 10))
+	}
+
+	resp, err := c.send(ctx, get("/api/v1/stations/stream", query))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/event-stream") {
+		return fmt.Errorf("gobikeclient: unexpected stream content type %q", ct)
+	}
+
+	var event StreamEvent
+	var data strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64<<10), 1<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+
+		switch field {
+		case "":
+			// A blank line ends an event; a comment is a heartbeat.
+			if line != "" || event.Type == "" {
+				continue
+			}
+			if event.Type == StreamStation {
+				event.Station = new(models.StationUpdate)
+				if err := json.Unmarshal([]byte(data.String()), event.Station); err != nil {
+					return fmt.Errorf("gobikeclient: decoding stream event: %w", err)
+				}
+			}
+			if err := fn(event); err != nil {
+				return err
+			}
+			event = StreamEvent{}
+			data.Reset()
+		case "event":
+			event.Type = value
+		case "id":
+			event.ID, _ = strconv.ParseUint(value, 10, 64)
+		case "data":
+			data.WriteString(value)
+		}
+	}
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,93 @@
+package gobikeclient
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/macadrich/go-bike/database/models"
+)
+
+// WebhookRequest subscribes URL to station events. Empty EventTypes or
+// KioskIds match everything; Secret is generated when empty.
+type WebhookRequest struct {
+	URL        string   `json:"url"`
+	Secret     string   `json:"secret,omitempty"`
+	EventTypes []string `json:"eventTypes,omitempty"`
+	KioskIds   []int64  `json:"kioskIds,omitempty"`
+}
+
+// CreateWebhook creates a subscription. The result is the only one to hold
+// its signing secret.
+func (c *Client) CreateWebhook(ctx context.Context, req WebhookRequest) (*models.WebhookSubscription, error) {
+	var result models.WebhookSubscription
+	if err := c.do(ctx, request{method: http.MethodPost, path: "/api/v1/webhooks", body: req}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ListWebhooks returns every subscription, without secrets.
+func (c *Client) ListWebhooks(ctx context.Context) ([]models.WebhookSubscription, error) {
+	var result []models.WebhookSubscription
+	if err := c.do(ctx, get("/api/v1/webhooks", nil), &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// DeleteWebhook removes a subscription and its delivery log.
+func (c *Client) DeleteWebhook(ctx context.Context, id int64) error {
+	path := "/api/v1/webhooks/" + strconv.FormatInt(id, 10)
+	return c.do(ctx, request{method: http.MethodDelete, path: path, idempotent: true}, nil)
+}
+
+// DeliveriesQuery selects deliveries of a subscription. An empty Status
+// matches every delivery.
+type DeliveriesQuery struct {
+	Status string
+	// PageSize is the number of deliveries fetched per request, at most
+	// 1000.
+	PageSize int
+}
+
+// Deliveries iterates over the delivery log of a subscription, newest
+// first.
+func (c *Client) Deliveries(subscriptionId int64, q DeliveriesQuery) *Iterator[models.WebhookDelivery] {
+	limit := pageSize(q.PageSize)
+	path := "/api/v1/webhooks/" + strconv.FormatInt(subscriptionId, 10) + "/deliveries"
+	var beforeId int64
+
+	return newIterator(func(ctx context.Context) ([]models.WebhookDelivery, bool, error) {
+		query := url.Values{"limit": {strconv.Itoa(limit)}}
+		if q.Status != "" {
+			query.Set("status", q.Status)
+		}
+		if beforeId != 0 {
+			query.Set("beforeId", strconv.FormatInt(beforeId, 10))
+		}
+
+		var page []models.WebhookDelivery
+		if err := c.do(ctx, get(path, query), &page); err != nil {
+			return nil, false, err
+		}
+		if n := len(page); n > 0 {
+			beforeId = page[n-1].Id
+		}
+
+		return page, len(page) == limit, nil
+	})
+}
+
+// ReplayDelivery sends a failed delivery again. The API answers at once;
+// the outcome shows in the delivery log.
+func (c *Client) ReplayDelivery(ctx context.Context, id int64) (*models.WebhookDelivery, error) {
+	path := "/api/v1/webhooks/deliveries/" + strconv.FormatInt(id, 10) + "/replay"
+
+	var result models.WebhookDelivery
+	if err := c.do(ctx, request{method: http.MethodPost, path: path}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
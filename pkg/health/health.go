@@ -0,0 +1,88 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// CheckFunc reports a dependency as healthy by returning nil.
+type CheckFunc func(ctx context.Context) error
+
+type CheckResult struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+// Healthy reports whether every check in the report passed.
+func (r *Report) Healthy() bool {
+	return r.Status == StatusUp
+}
+
+type check struct {
+	name string
+	fn   CheckFunc
+}
+
+// Checker runs a set of named dependency checks concurrently, each bounded
+// by the same timeout.
+type Checker struct {
+	timeout time.Duration
+	checks  []check
+}
+
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout}
+}
+
+func (c *Checker) Register(name string, fn CheckFunc) {
+	c.checks = append(c.checks, check{name, fn})
+}
+
+func (c *Checker) Run(ctx context.Context) *Report {
+	report := &Report{
+		Status: StatusUp,
+		Checks: make(map[string]CheckResult, len(c.checks)),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, chk := range c.checks {
+		wg.Add(1)
+		go func(chk check) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(ctx, c.timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := chk.fn(ctx)
+			result := CheckResult{Status: StatusUp, Duration: time.Since(start).String()}
+			if err != nil {
+				result.Status = StatusDown
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[chk.name] = result
+			if err != nil {
+				report.Status = StatusDown
+			}
+		}(chk)
+	}
+	wg.Wait()
+
+	return report
+}
@@ -0,0 +1,44 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckerAllUp(t *testing.T) {
+	checker := NewChecker(time.Second)
+	checker.Register("database", func(ctx context.Context) error { return nil })
+	checker.Register("upstream", func(ctx context.Context) error { return nil })
+
+	report := checker.Run(context.TODO())
+	assert.True(t, report.Healthy())
+	assert.Len(t, report.Checks, 2)
+	assert.Equal(t, StatusUp, report.Checks["database"].Status)
+}
+
+func TestCheckerOneDown(t *testing.T) {
+	checker := NewChecker(time.Second)
+	checker.Register("database", func(ctx context.Context) error { return nil })
+	checker.Register("upstream", func(ctx context.Context) error { return errors.New("connection refused") })
+
+	report := checker.Run(context.TODO())
+	assert.False(t, report.Healthy())
+	assert.Equal(t, StatusDown, report.Checks["upstream"].Status)
+	assert.Equal(t, "connection refused", report.Checks["upstream"].Error)
+}
+
+func TestCheckerTimeout(t *testing.T) {
+	checker := NewChecker(10 * time.Millisecond)
+	checker.Register("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	report := checker.Run(context.TODO())
+	assert.False(t, report.Healthy())
+	assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks["slow"].Error)
+}
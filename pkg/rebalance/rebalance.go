@@ -0,0 +1,214 @@
+// Package rebalance plans van routes that move bikes from stations above
+// their target fill ratio to stations below it.
+//
+// The solver is greedy and deterministic: each van in turn repeatedly
+// drives to the stop that moves the most bikes per kilometre, picking up
+// from surplus stations while it has room and dropping off at deficit
+// stations while it carries bikes. Ties are broken by kiosk id, so equal
+// inputs always give the same plan.
+package rebalance
+
+import (
+	"math"
+	"slices"
+
+	"github.com/macadrich/go-bike/database/models"
+	"github.com/macadrich/go-bike/pkg/trips"
+)
+
+const (
+	ActionPickup  = "pickup"
+	ActionDropoff = "dropoff"
+)
+
+// detourKm is added to every leg so that a stop next door is not worth
+// infinitely more than one a block away.
+const detourKm = 0.5
+
+// Station is the state of one station to balance.
+type Station struct {
+	KioskId   int
+	Name      string
+	Location  models.Coordinate
+	Bikes     int
+	Capacity  int
+	Target    float64
+	Tolerance float64
+}
+
+// Imbalance is the number of bikes to remove (positive) or add (negative)
+// to bring s back to its target. Stations within Tolerance of their target,
+// as a share of capacity, are balanced already.
+func (s *Station) Imbalance() int {
+	if s.Capacity <= 0 {
+		return 0
+	}
+	target := int(math.Round(s.Target * float64(s.Capacity)))
+	diff := s.Bikes - target
+	if math.Abs(float64(diff)) <= s.Tolerance*float64(s.Capacity) {
+		return 0
+	}
+	return diff
+}
+
+// Options limits the vans.
+type Options struct {
+	Vans     int
+	Capacity int
+	MaxStops int
+	// Depot is where every van starts; when nil a van starts at its first
+	// stop.
+	Depot *models.Coordinate
+}
+
+type Stop struct {
+	KioskId    int     `json:"kioskId"`
+	Name       string  `json:"name"`
+	Action     string  `json:"action"`
+	Quantity   int     `json:"quantity"`
+	Load       int     `json:"load"`
+	DistanceKm float64 `json:"distanceKm"`
+}
+
+// Route is the stops of one van in order. EndLoad bikes are still in the
+// van after the last stop, when it ran out of stops or deficit stations.
+type Route struct {
+	Van        int     `json:"van"`
+	Stops      []Stop  `json:"stops"`
+	Moved      int     `json:"moved"`
+	EndLoad    int     `json:"endLoad"`
+	DistanceKm float64 `json:"distanceKm"`
+}
+
+// Plan is the routes of all vans. Surplus and Deficit are the bikes still
+// to remove and add after the plan.
+type Plan struct {
+	Routes     []Route `json:"routes"`
+	Moved      int     `json:"moved"`
+	DistanceKm float64 `json:"distanceKm"`
+	Surplus    int     `json:"surplus"`
+	Deficit    int     `json:"deficit"`
+}
+
+// Solve plans up to opts.Vans routes.
+func Solve(stations []Station, opts Options) Plan {
+	sorted := slices.Clone(stations)
+	slices.SortFunc(sorted, func(a, b Station) int { return a.KioskId - b.KioskId })
+
+	// remaining[i] is the imbalance of sorted[i] still to be fixed.
+	remaining := make([]int, len(sorted))
+	for i := range sorted {
+		remaining[i] = sorted[i].Imbalance()
+	}
+
+	plan := Plan{Routes: []Route{}}
+	for van := 1; van <= opts.Vans; van++ {
+		route := solveVan(sorted, remaining, opts)
+		if len(route.Stops) == 0 {
+			break
+		}
+		route.Van = van
+		plan.Routes = append(plan.Routes, route)
+		plan.Moved += route.Moved
+		plan.DistanceKm += route.DistanceKm
+	}
+	plan.DistanceKm = roundKm(plan.DistanceKm)
+
+	for _, r := range remaining {
+		if r > 0 {
+			plan.Surplus += r
+		} else {
+			plan.Deficit -= r
+		}
+	}
+
+	return plan
+}
+
+// solveVan plans one route, updating remaining as bikes are moved.
+func solveVan(stations []Station, remaining []int, opts Options) Route {
+	route := Route{Stops: []Stop{}}
+	load := 0
+	var at *models.Coordinate
+	if opts.Depot != nil {
+		depot := *opts.Depot
+		at = &depot
+	}
+
+	for len(route.Stops) < opts.MaxStops {
+		best, bestQty := -1, 0
+		bestScore, bestDist := 0.0, 0.0
+		for i := range stations {
+			qty := 0
+			switch {
+			case remaining[i] > 0:
+				qty = min(remaining[i], opts.Capacity-load)
+			case remaining[i] < 0:
+				qty = min(-remaining[i], load)
+			}
+			if qty <= 0 {
+				continue
+			}
+
+			dist := 0.0
+			if at != nil {
+				dist = trips.DistanceKm(*at, stations[i].Location)
+			}
+			score := float64(qty) / (dist + detourKm)
+			if score > bestScore {
+				best, bestQty, bestScore, bestDist = i, qty, score, dist
+			}
+		}
+		if best < 0 {
+			break
+		}
+
+		stop := Stop{
+			KioskId:    stations[best].KioskId,
+			Name:       stations[best].Name,
+			Quantity:   bestQty,
+			DistanceKm: roundKm(bestDist),
+		}
+		if remaining[best] > 0 {
+			stop.Action = ActionPickup
+			load += bestQty
+			remaining[best] -= bestQty
+		} else {
+			stop.Action = ActionDropoff
+			load -= bestQty
+			remaining[best] += bestQty
+			route.Moved += bestQty
+		}
+		stop.Load = load
+
+		route.Stops = append(route.Stops, stop)
+		location := stations[best].Location
+		at = &location
+	}
+
+	// Bikes picked up but not dropped off stay at their stations.
+	route.Stops = trimPickups(route.Stops, stations, remaining)
+	for _, stop := range route.Stops {
+		route.DistanceKm += stop.DistanceKm
+		route.EndLoad = stop.Load
+	}
+	route.DistanceKm = roundKm(route.DistanceKm)
+
+	return route
+}
+
+// trimPickups drops trailing pickups, which would only leave bikes in the
+// van, and gives their bikes back to remaining.
+func trimPickups(stops []Stop, stations []Station, remaining []int) []Stop {
+	for len(stops) > 0 && stops[len(stops)-1].Action == ActionPickup {
+		last := stops[len(stops)-1]
+		i, _ := slices.BinarySearchFunc(stations, last.KioskId, func(s Station, id int) int { return s.KioskId - id })
+		remaining[i] += last.Quantity
+		stops = stops[:len(stops)-1]
+	}
+	return stops
+}
+
+func roundKm(km float64) float64 {
+	return math.Round(km*1000) / 1000
+}
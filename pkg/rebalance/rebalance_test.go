@@ -0,0 +1,141 @@
+package rebalance
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/macadrich/go-bike/database/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// phlStations reads the stations of the phl.json feed fixture.
+func phlStations(t *testing.T) []Station {
+	data, err := os.ReadFile("../../phl.json")
+	require.NoError(t, err)
+
+	var feed struct {
+		Features []struct {
+			Properties struct {
+				KioskId           int     `json:"kioskId"`
+				Name              string  `json:"name"`
+				Latitude          float64 `json:"latitude"`
+				Longitude         float64 `json:"longitude"`
+				BikesAvailable    int     `json:"bikesAvailable"`
+				TotalDocks        int     `json:"totalDocks"`
+				KioskPublicStatus string  `json:"kioskPublicStatus"`
+			} `json:"properties"`
+		} `json:"features"`
+	}
+	require.NoError(t, json.Unmarshal(data, &feed))
+
+	var stations []Station
+	for _, f := range feed.Features {
+		p := f.Properties
+		if p.KioskPublicStatus != "Active" {
+			continue
+		}
+		stations = append(stations, Station{
+			KioskId:   p.KioskId,
+			Name:      p.Name,
+			Location:  models.Coordinate{Long: p.Longitude, Lat: p.Latitude},
+			Bikes:     p.BikesAvailable,
+			Capacity:  p.TotalDocks,
+			Target:    0.5,
+			Tolerance: 0.15,
+		})
+	}
+	require.NotEmpty(t, stations)
+	return stations
+}
+
+func TestImbalance(t *testing.T) {
+	tests := []struct {
+		station Station
+		want    int
+	}{
+		{Station{Bikes: 15, Capacity: 20, Target: 0.5, Tolerance: 0.1}, 5},
+		{Station{Bikes: 2, Capacity: 20, Target: 0.5, Tolerance: 0.1}, -8},
+		{Station{Bikes: 12, Capacity: 20, Target: 0.5, Tolerance: 0.1}, 0},
+		{Station{Bikes: 3, Capacity: 0, Target: 0.5}, 0},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, tt.station.Imbalance(), "%+v", tt.station)
+	}
+}
+
+func TestSolveSmall(t *testing.T) {
+	stations := []Station{
+		{KioskId: 3, Location: models.Coordinate{Long: -75.10, Lat: 39.95}, Bikes: 0, Capacity: 10, Target: 0.5},
+		{KioskId: 1, Location: models.Coordinate{Long: -75.16, Lat: 39.95}, Bikes: 10, Capacity: 10, Target: 0.5},
+		{KioskId: 2, Location: models.Coordinate{Long: -75.15, Lat: 39.95}, Bikes: 0, Capacity: 10, Target: 0.5},
+	}
+
+	plan := Solve(stations, Options{Vans: 1, Capacity: 20, MaxStops: 10})
+	require.Len(t, plan.Routes, 1)
+	stops := plan.Routes[0].Stops
+	require.Len(t, stops, 2)
+	assert.Equal(t, Stop{KioskId: 1, Action: ActionPickup, Quantity: 5, Load: 5}, stops[0])
+	// The nearer deficit station comes first.
+	assert.Equal(t, 2, stops[1].KioskId)
+	assert.Equal(t, ActionDropoff, stops[1].Action)
+	assert.Equal(t, 5, stops[1].Quantity)
+	assert.Equal(t, 0, stops[1].Load)
+	// Kiosk 1's surplus only covers one of the deficit stations.
+	assert.Equal(t, 5, plan.Moved)
+	assert.Equal(t, 5, plan.Deficit)
+	assert.Zero(t, plan.Surplus)
+}
+
+func TestSolvePHL(t *testing.T) {
+	stations := phlStations(t)
+	opts := Options{Vans: 3, Capacity: 20, MaxStops: 12, Depot: &models.Coordinate{Long: -75.1652, Lat: 39.9526}}
+
+	before := 0
+	for i := range stations {
+		if d := stations[i].Imbalance(); d < 0 {
+			before -= d
+		}
+	}
+
+	plan := Solve(stations, opts)
+	require.NotEmpty(t, plan.Routes)
+	assert.LessOrEqual(t, len(plan.Routes), opts.Vans)
+	assert.Equal(t, plan, Solve(stations, opts), "deterministic")
+
+	byKiosk := make(map[int]Station)
+	for _, s := range stations {
+		byKiosk[s.KioskId] = s
+	}
+
+	moved := 0
+	for _, route := range plan.Routes {
+		assert.LessOrEqual(t, len(route.Stops), opts.MaxStops)
+		assert.Equal(t, ActionDropoff, route.Stops[len(route.Stops)-1].Action)
+
+		load := 0
+		for _, stop := range route.Stops {
+			s := byKiosk[stop.KioskId]
+			imbalance := s.Imbalance()
+			if stop.Action == ActionPickup {
+				load += stop.Quantity
+				assert.Greater(t, imbalance, 0, "pickup at %d", stop.KioskId)
+				assert.LessOrEqual(t, stop.Quantity, imbalance)
+			} else {
+				load -= stop.Quantity
+				assert.Less(t, imbalance, 0, "dropoff at %d", stop.KioskId)
+				assert.LessOrEqual(t, stop.Quantity, -imbalance)
+			}
+			assert.Equal(t, load, stop.Load)
+			assert.GreaterOrEqual(t, load, 0)
+			assert.LessOrEqual(t, load, opts.Capacity)
+		}
+		assert.Equal(t, load, route.EndLoad)
+		moved += route.Moved
+	}
+	assert.Equal(t, moved, plan.Moved)
+	assert.Positive(t, plan.Moved)
+	assert.Equal(t, before-plan.Moved, plan.Deficit)
+}
@@ -0,0 +1,92 @@
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Job is one run of a scheduled task.
+type Job func(ctx context.Context) error
+
+// Scheduler runs a job periodically. Its interval and enabled state can be
+// changed while it is running; runs never overlap.
+type Scheduler struct {
+	name string
+	job  Job
+
+	mu       sync.Mutex
+	enabled  bool
+	interval time.Duration
+	reset    chan struct{}
+}
+
+func New(name string, job Job) *Scheduler {
+	return &Scheduler{
+		name:  name,
+		job:   job,
+		reset: make(chan struct{}, 1),
+	}
+}
+
+// Update changes the schedule. The next run is timed from the moment of
+// the update.
+func (s *Scheduler) Update(enabled bool, interval time.Duration) {
+	s.mu.Lock()
+	changed := s.enabled != enabled || s.interval != interval
+	s.enabled = enabled
+	s.interval = interval
+	s.mu.Unlock()
+
+	if !changed {
+		return
+	}
+
+	select {
+	case s.reset <- struct{}{}:
+	default:
+	}
+}
+
+// Run blocks until ctx is done. A run in progress when ctx is cancelled is
+// not interrupted, so that it can be drained by its owner.
+func (s *Scheduler) Run(ctx context.Context) {
+	for {
+		s.mu.Lock()
+		enabled, interval := s.enabled, s.interval
+		s.mu.Unlock()
+
+		var tick <-chan time.Time
+		var timer *time.Timer
+		if enabled && interval > 0 {
+			timer = time.NewTimer(interval)
+			tick = timer.C
+		}
+
+		select {
+		case <-ctx.Done():
+			stopTimer(timer)
+			return
+		case <-s.reset:
+			stopTimer(timer)
+		case <-tick:
+			s.runOnce(context.WithoutCancel(ctx))
+		}
+	}
+}
+
+func (s *Scheduler) runOnce(ctx context.Context) {
+	start := time.Now()
+	if err := s.job(ctx); err != nil {
+		slog.Error("scheduled job failed", "job", s.name, "error", err)
+		return
+	}
+	slog.Debug("scheduled job finished", "job", s.name, "duration", time.Since(start))
+}
+
+func stopTimer(timer *time.Timer) {
+	if timer != nil {
+		timer.Stop()
+	}
+}
@@ -0,0 +1,53 @@
+package scheduler
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchedulerRunsWhenEnabled(t *testing.T) {
+	var runs atomic.Int32
+	s := New("test", func(ctx context.Context) error {
+		runs.Add(1)
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx)
+
+	time.Sleep(30 * time.Millisecond)
+	assert.Zero(t, runs.Load())
+
+	s.Update(true, 5*time.Millisecond)
+	assert.Eventually(t, func() bool { return runs.Load() >= 3 }, time.Second, 5*time.Millisecond)
+
+	s.Update(false, 5*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	stopped := runs.Load()
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, stopped, runs.Load())
+}
+
+func TestSchedulerStopsOnCancel(t *testing.T) {
+	s := New("test", func(ctx context.Context) error { return nil })
+	s.Update(true, time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("scheduler did not stop")
+	}
+}
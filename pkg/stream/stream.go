@@ -0,0 +1,213 @@
+// Package stream fans out live station updates to subscribers and keeps a
+// bounded history so that reconnecting clients can resume.
+package stream
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/macadrich/go-bike/database/models"
+)
+
+// subscriberBuffer is how many messages a subscriber may fall behind before
+// it is disconnected; it can then resume from its last message id.
+const subscriberBuffer = 512
+
+// Message is a station update with its position in the stream.
+type Message struct {
+	ID     uint64               `json:"id"`
+	Update models.StationUpdate `json:"station"`
+}
+
+// BBox is a bounding box in degrees.
+type BBox struct {
+	MinLon, MinLat, MaxLon, MaxLat float64
+}
+
+// ParseBBox parses "minLon,minLat,maxLon,maxLat".
+func ParseBBox(s string) (BBox, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return BBox{}, errors.New("bbox must be minLon,minLat,maxLon,maxLat")
+	}
+
+	var v [4]float64
+	for i, part := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return BBox{}, errors.New("bbox must be minLon,minLat,maxLon,maxLat")
+		}
+		v[i] = f
+	}
+
+	b := BBox{v[0], v[1], v[2], v[3]}
+	if b.MinLon > b.MaxLon || b.MinLat > b.MaxLat {
+		return BBox{}, errors.New("bbox minimum must not exceed its maximum")
+	}
+	return b, nil
+}
+
+func (b *BBox) Contains(lon, lat float64) bool {
+	return lon >= b.MinLon && lon <= b.MaxLon && lat >= b.MinLat && lat <= b.MaxLat
+}
+
+// Filter selects the updates a subscriber receives. Zero values match
+// everything.
+type Filter struct {
+	KioskIds map[int]bool
+	BBox     *BBox
+}
+
+func (f *Filter) Match(u *models.StationUpdate) bool {
+	if len(f.KioskIds) > 0 && !f.KioskIds[u.KioskId] {
+		return false
+	}
+	if f.BBox != nil && !f.BBox.Contains(u.Longitude, u.Latitude) {
+		return false
+	}
+	return true
+}
+
+// Subscription receives the messages matching its filter on C. C is closed
+// when the subscriber falls too far behind or unsubscribes.
+type Subscription struct {
+	C      <-chan Message
+	c      chan Message
+	filter Filter
+}
+
+// Broker publishes the changes between consecutive snapshots.
+type Broker struct {
+	mu          sync.Mutex
+	nextID      uint64
+	history     []Message
+	size        int
+	latest      map[int]models.StationUpdate
+	subscribers map[*Subscription]struct{}
+	closed      bool
+}
+
+// NewBroker keeps the last size messages for resuming subscribers. Ids
+// start from the current time so that ids from before a restart are
+// recognised as stale.
+func NewBroker(size int) *Broker {
+	return &Broker{
+		nextID:      uint64(time.Now().UnixMicro()),
+		size:        size,
+		latest:      make(map[int]models.StationUpdate),
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+// changed reports whether u differs from the last update of its kiosk,
+// ignoring the snapshot time.
+func (b *Broker) changed(u models.StationUpdate) bool {
+	prev, ok := b.latest[u.KioskId]
+	if !ok {
+		return true
+	}
+	prev.At = u.At
+	return prev != u
+}
+
+// Publish sends the updates that differ from the previous state of their
+// kiosk and returns how many were sent.
+func (b *Broker) Publish(updates []models.StationUpdate) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	published := 0
+	for _, u := range updates {
+		if !b.changed(u) {
+			continue
+		}
+		b.latest[u.KioskId] = u
+
+		msg := Message{ID: b.nextID, Update: u}
+		b.nextID++
+		published++
+
+		b.history = append(b.history, msg)
+		if len(b.history) > b.size {
+			b.history = b.history[len(b.history)-b.size:]
+		}
+
+		for sub := range b.subscribers {
+			if !sub.filter.Match(&msg.Update) {
+				continue
+			}
+			select {
+			case sub.c <- msg:
+			default:
+				b.remove(sub)
+			}
+		}
+	}
+
+	return published
+}
+
+// Subscribe registers a subscriber. With resume, the stored messages after
+// lastID are returned as backlog; complete is false when some of them are
+// no longer stored, in which case the client should reload the full state.
+func (b *Broker) Subscribe(filter Filter, lastID uint64, resume bool) (sub *Subscription, backlog []Message, complete bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := make(chan Message, subscriberBuffer)
+	sub = &Subscription{C: c, c: c, filter: filter}
+	if b.closed {
+		close(c)
+		return sub, nil, true
+	}
+	b.subscribers[sub] = struct{}{}
+
+	if !resume {
+		return sub, nil, true
+	}
+
+	switch {
+	case lastID >= b.nextID:
+		complete = false
+	case lastID+1 == b.nextID:
+		complete = true
+	default:
+		complete = len(b.history) > 0 && b.history[0].ID <= lastID+1
+	}
+	for _, msg := range b.history {
+		if msg.ID > lastID && filter.Match(&msg.Update) {
+			backlog = append(backlog, msg)
+		}
+	}
+
+	return sub, backlog, complete
+}
+
+func (b *Broker) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.remove(sub)
+}
+
+// Close disconnects every subscriber, now and from then on, so that
+// streaming requests end when the server shuts down.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for sub := range b.subscribers {
+		b.remove(sub)
+	}
+}
+
+func (b *Broker) remove(sub *Subscription) {
+	if _, ok := b.subscribers[sub]; ok {
+		delete(b.subscribers, sub)
+		close(sub.c)
+	}
+}
@@ -0,0 +1,114 @@
+package stream
+
+import (
+	"testing"
+	"time"
+
+	"github.com/macadrich/go-bike/database/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func update(kioskId, bikes int, lon, lat float64) models.StationUpdate {
+	return models.StationUpdate{KioskId: kioskId, At: time.Now(), BikesAvailable: bikes, Longitude: lon, Latitude: lat}
+}
+
+func TestParseBBox(t *testing.T) {
+	b, err := ParseBBox("-75.2,39.9,-75.1,40.0")
+	require.NoError(t, err)
+	assert.True(t, b.Contains(-75.16, 39.95))
+	assert.False(t, b.Contains(-75.0, 39.95))
+
+	for _, invalid := range []string{"", "1,2,3", "a,b,c,d", "-75.1,39.9,-75.2,40.0"} {
+		_, err := ParseBBox(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestPublishSendsChangesOnly(t *testing.T) {
+	b := NewBroker(100)
+	sub, _, _ := b.Subscribe(Filter{}, 0, false)
+
+	assert.Equal(t, 2, b.Publish([]models.StationUpdate{update(3005, 3, 0, 0), update(3006, 4, 0, 0)}))
+	// Only the time of 3006 changed.
+	assert.Equal(t, 1, b.Publish([]models.StationUpdate{update(3005, 2, 0, 0), update(3006, 4, 0, 0)}))
+
+	var got []int
+	for i := 0; i < 3; i++ {
+		got = append(got, (<-sub.C).Update.BikesAvailable)
+	}
+	assert.Equal(t, []int{3, 4, 2}, got)
+}
+
+func TestSubscribeFilters(t *testing.T) {
+	b := NewBroker(100)
+	bbox := BBox{MinLon: -75.2, MinLat: 39.9, MaxLon: -75.1, MaxLat: 40.0}
+	byKiosk, _, _ := b.Subscribe(Filter{KioskIds: map[int]bool{3006: true}}, 0, false)
+	byBox, _, _ := b.Subscribe(Filter{BBox: &bbox}, 0, false)
+
+	b.Publish([]models.StationUpdate{update(3005, 1, -75.16, 39.95), update(3006, 1, -75.3, 39.95)})
+
+	assert.Equal(t, 3006, (<-byKiosk.C).Update.KioskId)
+	assert.Equal(t, 3005, (<-byBox.C).Update.KioskId)
+	assert.Len(t, byKiosk.C, 0)
+	assert.Len(t, byBox.C, 0)
+}
+
+func TestSubscribeResumes(t *testing.T) {
+	b := NewBroker(2)
+	sub, _, _ := b.Subscribe(Filter{}, 0, false)
+	b.Publish([]models.StationUpdate{update(3005, 1, 0, 0)})
+	first := <-sub.C
+	b.Unsubscribe(sub)
+
+	b.Publish([]models.StationUpdate{update(3005, 2, 0, 0)})
+
+	// Everything after first is still stored.
+	_, backlog, complete := b.Subscribe(Filter{}, first.ID, true)
+	assert.True(t, complete)
+	require.Len(t, backlog, 1)
+	assert.Equal(t, 2, backlog[0].Update.BikesAvailable)
+
+	// Up to date.
+	_, backlog, complete = b.Subscribe(Filter{}, backlog[0].ID, true)
+	assert.True(t, complete)
+	assert.Empty(t, backlog)
+
+	// Two more updates push the one after first out of the history.
+	b.Publish([]models.StationUpdate{update(3005, 3, 0, 0)})
+	b.Publish([]models.StationUpdate{update(3005, 4, 0, 0)})
+	_, backlog, complete = b.Subscribe(Filter{}, first.ID, true)
+	assert.False(t, complete)
+	assert.Len(t, backlog, 2)
+
+	// Ids this broker never issued cannot be resumed.
+	_, _, complete = b.Subscribe(Filter{}, 1<<62, true)
+	assert.False(t, complete)
+}
+
+func TestSlowSubscriberIsDisconnected(t *testing.T) {
+	b := NewBroker(10)
+	sub, _, _ := b.Subscribe(Filter{}, 0, false)
+
+	for i := 0; i <= subscriberBuffer; i++ {
+		b.Publish([]models.StationUpdate{update(3005, i+1, 0, 0)})
+	}
+
+	n := 0
+	for range sub.C {
+		n++
+	}
+	assert.Equal(t, subscriberBuffer, n)
+}
+
+func TestClose(t *testing.T) {
+	b := NewBroker(10)
+	before, _, _ := b.Subscribe(Filter{}, 0, false)
+	b.Close()
+	after, _, _ := b.Subscribe(Filter{}, 0, false)
+
+	_, open := <-before.C
+	assert.False(t, open)
+	_, open = <-after.C
+	assert.False(t, open)
+}
@@ -0,0 +1,198 @@
+// Package trips infers bike movements from consecutive dock snapshots.
+//
+// The feed does not identify bikes, only which docks hold one. A bike that
+// is gone from its dock, or replaced by one of the other kind, counts as a
+// departure; a bike in a dock that was empty, or held the other kind,
+// counts as an arrival. Trips that start and end between two snapshots at
+// the same dock are invisible, so the counts are lower bounds.
+package trips
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/macadrich/go-bike/database/models"
+)
+
+// Counter sums the departures and arrivals of consecutive snapshot pairs
+// per kiosk and interval, attributing each pair to the interval of its
+// later snapshot. Pairs further apart than maxGap, usually on either side
+// of an ingestion outage, are skipped; zero means no limit.
+type Counter struct {
+	interval, maxGap time.Duration
+	flows            map[flowKey]*models.StationFlow
+}
+
+type flowKey struct {
+	kioskId int
+	bucket  int64
+}
+
+// NewCounter returns a Counter with intervals of the given length.
+func NewCounter(interval, maxGap time.Duration) *Counter {
+	return &Counter{interval: interval, maxGap: maxGap, flows: make(map[flowKey]*models.StationFlow)}
+}
+
+// Add counts a snapshot pair.
+func (c *Counter) Add(move models.DockMove) {
+	if c.maxGap > 0 && move.At.Sub(move.PrevAt) > c.maxGap {
+		return
+	}
+	if move.Departures == 0 && move.Arrivals == 0 {
+		return
+	}
+
+	bucket := move.At.UTC().Truncate(c.interval)
+	k := flowKey{move.KioskId, bucket.Unix()}
+	flow, ok := c.flows[k]
+	if !ok {
+		flow = &models.StationFlow{KioskId: move.KioskId, Bucket: bucket}
+		c.flows[k] = flow
+	}
+	flow.Departures += move.Departures
+	flow.Arrivals += move.Arrivals
+}
+
+// Flows returns the counted flows ordered by interval and kiosk. Intervals
+// without movement are omitted.
+func (c *Counter) Flows() []models.StationFlow {
+	result := make([]models.StationFlow, 0, len(c.flows))
+	for _, flow := range c.flows {
+		result = append(result, *flow)
+	}
+	slices.SortFunc(result, func(a, b models.StationFlow) int {
+		if c := a.Bucket.Compare(b.Bucket); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.KioskId, b.KioskId)
+	})
+
+	return result
+}
+
+// DistanceKm is the great-circle distance between two points.
+func DistanceKm(a, b models.Coordinate) float64 {
+	const earthRadiusKm = 6371.0
+	rad := math.Pi / 180
+
+	dLat := (b.Lat - a.Lat) * rad
+	dLon := (b.Long - a.Long) * rad
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(a.Lat*rad)*math.Cos(b.Lat*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(h))
+}
+
+// ipfIterations bounds the balancing of EstimateOD, which usually
+// converges in a few dozen rounds.
+const ipfIterations = 200
+
+// EstimateOD distributes the departures of every kiosk over the arrivals
+// of the others with a doubly constrained gravity model: the trips from i
+// to j are proportional to the departures at i, the arrivals at j and
+// exp(-distance/meanTripKm), balanced so that every kiosk keeps its totals.
+// Arrivals are first scaled to the number of departures. Kiosks without
+// coordinates are left out, and so are round trips, which the snapshots
+// cannot show. Pairs are sorted by trips, largest first.
+func EstimateOD(departures, arrivals map[int]int, coords map[int]models.Coordinate, meanTripKm float64) []models.ODPair {
+	var kiosks []int
+	for kioskId := range coords {
+		if departures[kioskId] > 0 || arrivals[kioskId] > 0 {
+			kiosks = append(kiosks, kioskId)
+		}
+	}
+	slices.Sort(kiosks)
+
+	n := len(kiosks)
+	origins := make([]float64, n)
+	destinations := make([]float64, n)
+	var totalO, totalD float64
+	for i, kioskId := range kiosks {
+		origins[i] = float64(departures[kioskId])
+		destinations[i] = float64(arrivals[kioskId])
+		totalO += origins[i]
+		totalD += destinations[i]
+	}
+	if totalO == 0 || totalD == 0 {
+		return []models.ODPair{}
+	}
+	for j := range destinations {
+		destinations[j] *= totalO / totalD
+	}
+
+	distance := make([][]float64, n)
+	deterrence := make([][]float64, n)
+	for i := range kiosks {
+		distance[i] = make([]float64, n)
+		deterrence[i] = make([]float64, n)
+		for j := range kiosks {
+			if i == j {
+				continue
+			}
+			distance[i][j] = DistanceKm(coords[kiosks[i]], coords[kiosks[j]])
+			deterrence[i][j] = math.Exp(-distance[i][j] / meanTripKm)
+		}
+	}
+
+	// Balancing factors of the origins (a) and destinations (b).
+	a := make([]float64, n)
+	b := make([]float64, n)
+	for j := range b {
+		b[j] = 1
+	}
+	for iter := 0; iter < ipfIterations; iter++ {
+		for i := range a {
+			a[i] = balance(func(j int) float64 { return b[j] * destinations[j] * deterrence[i][j] }, n)
+		}
+		change := 0.0
+		for j := range b {
+			next := balance(func(i int) float64 { return a[i] * origins[i] * deterrence[i][j] }, n)
+			change = math.Max(change, math.Abs(next-b[j]))
+			b[j] = next
+		}
+		if change < 1e-9 {
+			break
+		}
+	}
+
+	pairs := []models.ODPair{}
+	for i := range kiosks {
+		for j := range kiosks {
+			trips := a[i] * origins[i] * b[j] * destinations[j] * deterrence[i][j]
+			if trips < 0.005 {
+				continue
+			}
+			pairs = append(pairs, models.ODPair{
+				Origin:      kiosks[i],
+				Destination: kiosks[j],
+				Trips:       math.Round(trips*100) / 100,
+				DistanceKm:  math.Round(distance[i][j]*1000) / 1000,
+			})
+		}
+	}
+	slices.SortFunc(pairs, func(x, y models.ODPair) int {
+		if x.Trips != y.Trips {
+			return cmp.Compare(y.Trips, x.Trips)
+		}
+		if x.Origin != y.Origin {
+			return cmp.Compare(x.Origin, y.Origin)
+		}
+		return cmp.Compare(x.Destination, y.Destination)
+	})
+
+	return pairs
+}
+
+// balance returns 1/sum(term(k)), or 0 when the sum is zero.
+func balance(term func(k int) float64, n int) float64 {
+	sum := 0.0
+	for k := 0; k < n; k++ {
+		sum += term(k)
+	}
+	if sum == 0 {
+		return 0
+	}
+	return 1 / sum
+}
@@ -0,0 +1,81 @@
+package trips
+
+import (
+	"testing"
+	"time"
+
+	"github.com/macadrich/go-bike/database/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCounter(t *testing.T) {
+	t0 := time.Date(2024, 5, 14, 6, 50, 0, 0, time.UTC)
+	moves := []models.DockMove{
+		{KioskId: 3006, PrevAt: t0, At: t0.Add(5 * time.Minute), Arrivals: 2},
+		{KioskId: 3005, PrevAt: t0, At: t0.Add(5 * time.Minute), Departures: 2},
+		{KioskId: 3005, PrevAt: t0.Add(5 * time.Minute), At: t0.Add(15 * time.Minute), Arrivals: 1},
+		{KioskId: 3005, PrevAt: t0.Add(15 * time.Minute), At: t0.Add(20 * time.Minute)},
+		// After an outage; not attributed.
+		{KioskId: 3006, PrevAt: t0.Add(5 * time.Minute), At: t0.Add(3 * time.Hour), Departures: 3},
+	}
+
+	counter := NewCounter(time.Hour, time.Hour)
+	for _, move := range moves {
+		counter.Add(move)
+	}
+	flows := counter.Flows()
+	require.Len(t, flows, 3)
+	assert.Equal(t, models.StationFlow{KioskId: 3005, Bucket: t0.Truncate(time.Hour), Departures: 2}, flows[0])
+	assert.Equal(t, models.StationFlow{KioskId: 3006, Bucket: t0.Truncate(time.Hour), Arrivals: 2}, flows[1])
+	assert.Equal(t, models.StationFlow{KioskId: 3005, Bucket: t0.Add(time.Hour).Truncate(time.Hour), Arrivals: 1}, flows[2])
+
+	// Without a gap limit the outage counts too.
+	counter = NewCounter(time.Hour, 0)
+	for _, move := range moves {
+		counter.Add(move)
+	}
+	assert.Len(t, counter.Flows(), 4)
+}
+
+func TestDistanceKm(t *testing.T) {
+	cityHall := models.Coordinate{Long: -75.16379, Lat: 39.95233}
+	welcomePark := models.Coordinate{Long: -75.14403, Lat: 39.94733}
+	assert.InDelta(t, 1.77, DistanceKm(cityHall, welcomePark), 0.02)
+	assert.Zero(t, DistanceKm(cityHall, cityHall))
+}
+
+func TestEstimateOD(t *testing.T) {
+	coords := map[int]models.Coordinate{
+		1: {Long: -75.160, Lat: 39.950},
+		2: {Long: -75.150, Lat: 39.950},
+		3: {Long: -75.100, Lat: 39.950},
+		4: {Long: -75.170, Lat: 39.960},
+	}
+	departures := map[int]int{1: 10, 2: 0, 3: 5, 5: 100}
+	arrivals := map[int]int{1: 0, 2: 10, 3: 5}
+
+	pairs := EstimateOD(departures, arrivals, coords, 2)
+	require.NotEmpty(t, pairs)
+
+	out := map[int]float64{}
+	in := map[int]float64{}
+	for _, p := range pairs {
+		assert.NotEqual(t, p.Origin, p.Destination)
+		assert.NotEqual(t, 4, p.Origin, "kiosk without movement")
+		assert.NotEqual(t, 5, p.Origin, "kiosk without coordinates")
+		out[p.Origin] += p.Trips
+		in[p.Destination] += p.Trips
+	}
+	assert.InDelta(t, 10, out[1], 0.1)
+	assert.InDelta(t, 5, out[3], 0.1)
+	assert.InDelta(t, 10, in[2], 0.1)
+	assert.InDelta(t, 5, in[3], 0.1)
+
+	// The nearer destination gets more of kiosk 1's departures.
+	assert.Equal(t, 1, pairs[0].Origin)
+	assert.Equal(t, 2, pairs[0].Destination)
+
+	assert.Equal(t, pairs, EstimateOD(departures, arrivals, coords, 2), "deterministic")
+	assert.Empty(t, EstimateOD(map[int]int{}, arrivals, coords, 2))
+}
@@ -1,10 +1,44 @@
 package utils
 
-import "time"
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
 
-func ValidateTimestamp(t string) bool {
-	layout := time.RFC3339
-	_, err := time.Parse(layout, t)
+var errTimestamp = errors.New("timestamp must be RFC 3339, Unix seconds, now, or a duration from now such as -1h")
 
-	return err == nil
+// ParseTimestamp parses a timestamp from a query parameter or flag. See
+// ParseTime.
+func ParseTimestamp(t string) (time.Time, error) {
+	return ParseTime(t, time.Now())
+}
+
+// ParseTime parses a timestamp given as RFC 3339 (2024-05-14T06:48:00Z),
+// Unix seconds (1715669280), or relative to now: "now" or a signed
+// duration such as -1h or +30m. Spaces are read as plus signs, which is
+// what an unencoded + in a query string decodes to, both in +30m and in an
+// offset such as 2024-05-14T06:48:00+02:00.
+func ParseTime(t string, now time.Time) (time.Time, error) {
+	t = strings.ReplaceAll(t, " ", "+")
+
+	switch {
+	case t == "now":
+		return now, nil
+	case strings.HasPrefix(t, "-") || strings.HasPrefix(t, "+"):
+		if d, err := time.ParseDuration(t); err == nil {
+			return now.Add(d), nil
+		}
+	}
+
+	if seconds, err := strconv.ParseInt(t, 10, 64); err == nil {
+		return time.Unix(seconds, 0).UTC(), nil
+	}
+
+	at, err := time.Parse(time.RFC3339, t)
+	if err != nil {
+		return time.Time{}, errTimestamp
+	}
+	return at, nil
 }
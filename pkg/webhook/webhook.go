@@ -0,0 +1,194 @@
+// Package webhook signs and delivers webhook requests.
+//
+// Every request carries the header
+//
+//	X-GoBike-Signature: t=<unix seconds>,v1=<hex HMAC-SHA256>
+//
+// where the HMAC is computed with the subscription secret over the
+// timestamp, a dot and the raw request body. Receivers should reject
+// requests whose timestamp is too old to prevent replays.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SignatureHeader = "X-GoBike-Signature"
+	EventHeader     = "X-GoBike-Event"
+	DeliveryHeader  = "X-GoBike-Delivery"
+)
+
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+func mac(secret string, timestamp int64, body []byte) []byte {
+	h := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(h, "%d.", timestamp)
+	h.Write(body)
+	return h.Sum(nil)
+}
+
+// Sign returns the SignatureHeader value for body sent at t.
+func Sign(secret string, t time.Time, body []byte) string {
+	timestamp := t.Unix()
+	return fmt.Sprintf("t=%d,v1=%s", timestamp, hex.EncodeToString(mac(secret, timestamp, body)))
+}
+
+// Verify checks a SignatureHeader value against body. Signatures older than
+// tolerance, relative to now, are rejected; a zero tolerance disables the
+// check.
+func Verify(secret, header string, body []byte, now time.Time, tolerance time.Duration) error {
+	var timestamp int64
+	var signatures [][]byte
+
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			t, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return ErrInvalidSignature
+			}
+			timestamp = t
+		case "v1":
+			if sig, err := hex.DecodeString(value); err == nil {
+				signatures = append(signatures, sig)
+			}
+		}
+	}
+
+	if timestamp == 0 || len(signatures) == 0 {
+		return ErrInvalidSignature
+	}
+	if tolerance > 0 && now.Sub(time.Unix(timestamp, 0)).Abs() > tolerance {
+		return fmt.Errorf("%w: timestamp outside tolerance", ErrInvalidSignature)
+	}
+
+	expected := mac(secret, timestamp, body)
+	for _, sig := range signatures {
+		if hmac.Equal(sig, expected) {
+			return nil
+		}
+	}
+
+	return ErrInvalidSignature
+}
+
+// Request is one webhook delivery.
+type Request struct {
+	URL        string
+	Secret     string
+	Event      string
+	DeliveryId int64
+	Body       []byte
+}
+
+// Attempt is the outcome of sending a Request once. StatusCode is zero when
+// no response was received.
+type Attempt struct {
+	Number     int
+	StatusCode int
+	Err        error
+}
+
+// Retryable reports whether a later attempt may succeed. Client errors other
+// than 408 and 429 are permanent.
+func (a Attempt) Retryable() bool {
+	switch {
+	case a.Err == nil:
+		return false
+	case a.StatusCode == 0, a.StatusCode >= 500:
+		return true
+	default:
+		return a.StatusCode == http.StatusRequestTimeout || a.StatusCode == http.StatusTooManyRequests
+	}
+}
+
+// Sender delivers requests, retrying failed attempts with exponential
+// backoff.
+type Sender struct {
+	Client         *http.Client
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Timeout bounds every attempt.
+	Timeout time.Duration
+}
+
+// Backoff returns the wait after the given failed attempt, starting at
+// InitialBackoff and doubling up to MaxBackoff.
+func (s *Sender) Backoff(attempt int) time.Duration {
+	backoff := s.InitialBackoff
+	for i := 1; i < attempt && backoff < s.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, s.MaxBackoff)
+}
+
+// Send makes a single attempt. Any 2xx response is a success.
+func (s *Sender) Send(ctx context.Context, req Request) (int, error) {
+	if s.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.Timeout)
+		defer cancel()
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, req.URL, bytes.NewReader(req.Body))
+	if err != nil {
+		return 0, fmt.Errorf("error request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set(SignatureHeader, Sign(req.Secret, time.Now(), req.Body))
+	httpReq.Header.Set(EventHeader, req.Event)
+	httpReq.Header.Set(DeliveryHeader, strconv.FormatInt(req.DeliveryId, 10))
+
+	resp, err := s.Client.Do(httpReq)
+	if err != nil {
+		return 0, fmt.Errorf("error sending request: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// Deliver sends req until it succeeds, fails permanently, MaxAttempts is
+// reached or ctx is done. onAttempt, if not nil, is called after every
+// attempt. The last attempt is returned.
+func (s *Sender) Deliver(ctx context.Context, req Request, onAttempt func(Attempt)) Attempt {
+	var attempt Attempt
+	for n := 1; ; n++ {
+		status, err := s.Send(ctx, req)
+		attempt = Attempt{Number: n, StatusCode: status, Err: err}
+		if onAttempt != nil {
+			onAttempt(attempt)
+		}
+
+		if !attempt.Retryable() || n >= s.MaxAttempts {
+			return attempt
+		}
+
+		timer := time.NewTimer(s.Backoff(n))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return attempt
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignVerify(t *testing.T) {
+	body := []byte(`{"type":"became_empty"}`)
+	now := time.Unix(1715669299, 0)
+	header := Sign("secret", now, body)
+
+	assert.NoError(t, Verify("secret", header, body, now.Add(time.Minute), 5*time.Minute))
+	assert.ErrorIs(t, Verify("other", header, body, now, 0), ErrInvalidSignature)
+	assert.ErrorIs(t, Verify("secret", header, []byte(`{}`), now, 0), ErrInvalidSignature)
+	assert.ErrorIs(t, Verify("secret", header, body, now.Add(time.Hour), 5*time.Minute), ErrInvalidSignature)
+	assert.ErrorIs(t, Verify("secret", "garbage", body, now, 0), ErrInvalidSignature)
+}
+
+func TestBackoff(t *testing.T) {
+	s := &Sender{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}
+
+	assert.Equal(t, time.Second, s.Backoff(1))
+	assert.Equal(t, 2*time.Second, s.Backoff(2))
+	assert.Equal(t, 4*time.Second, s.Backoff(3))
+	assert.Equal(t, 5*time.Second, s.Backoff(4))
+}
+
+func TestDeliverRetriesUntilSuccess(t *testing.T) {
+	body := []byte(`{"type":"became_full"}`)
+	var calls atomic.Int32
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ := io.ReadAll(r.Body)
+		if err := Verify("secret", r.Header.Get(SignatureHeader), got, time.Now(), time.Minute); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		assert.Equal(t, "became_full", r.Header.Get(EventHeader))
+		assert.Equal(t, "42", r.Header.Get(DeliveryHeader))
+
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	sender := &Sender{Client: receiver.Client(), MaxAttempts: 5, InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}
+	var attempts []Attempt
+	last := sender.Deliver(context.Background(), Request{
+		URL: receiver.URL, Secret: "secret", Event: "became_full", DeliveryId: 42, Body: body,
+	}, func(a Attempt) { attempts = append(attempts, a) })
+
+	require.NoError(t, last.Err)
+	assert.Equal(t, 3, last.Number)
+	assert.Equal(t, http.StatusNoContent, last.StatusCode)
+	assert.Len(t, attempts, 3)
+	assert.Equal(t, http.StatusBadGateway, attempts[0].StatusCode)
+}
+
+func TestDeliverStopsOnPermanentFailure(t *testing.T) {
+	var calls atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer receiver.Close()
+
+	sender := &Sender{Client: receiver.Client(), MaxAttempts: 5, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	last := sender.Deliver(context.Background(), Request{URL: receiver.URL, Secret: "secret"}, nil)
+
+	assert.Error(t, last.Err)
+	assert.Equal(t, http.StatusGone, last.StatusCode)
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestDeliverGivesUpAfterMaxAttempts(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer receiver.Close()
+
+	sender := &Sender{Client: receiver.Client(), MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	last := sender.Deliver(context.Background(), Request{URL: receiver.URL, Secret: "secret"}, nil)
+
+	assert.Error(t, last.Err)
+	assert.Equal(t, 3, last.Number)
+}
+
+func TestDeliverStopsWhenCancelled(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer receiver.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sender := &Sender{Client: receiver.Client(), MaxAttempts: 10, InitialBackoff: time.Hour, MaxBackoff: time.Hour}
+	last := sender.Deliver(ctx, Request{URL: receiver.URL, Secret: "secret"}, func(Attempt) { cancel() })
+
+	assert.Equal(t, 1, last.Number)
+	assert.False(t, errors.Is(last.Err, context.Canceled))
+}
@@ -12,14 +12,6 @@ cd "$script_dir"
 
 sleep 1
 
-if ! [ -x "$(command -v migrate)" ]; then
-    echo >&2 "Error: migrate is not installed."
-    echo >&2 "Use:"
-    echo >&2 "go get -u github.com/golang-migrate/migrate/v4/cmd/migrate"
-    echo >&2 "to install it."
-    exit 1
-fi
-
 DB_USER="${POSTGRES_USER:=user}"
 DB_PASSWORD="${POSTGRES_PASSWORD:=password}"
 DB_NAME="${POSTGRES_DB:=indegodb}"
@@ -34,9 +26,8 @@ done
 
 >&2 echo "Postgres is up and running on port ${DB_PORT} - running migration now!"
 
-DATABASE_URL=postgres://${DB_USER}:${DB_PASSWORD}@${DB_HOST}:${DB_PORT}/${DB_NAME}
+DATABASE_URL=postgres://${DB_USER}:${DB_PASSWORD}@${DB_HOST}:${DB_PORT}/${DB_NAME}?sslmode=disable
 export DATABASE_URL
-sqlx database create
-sqlx migrate run
+go run ./cmd migrate up
 
 >&2 echo "Postgres has been migrated, ready to go!"
\ No newline at end of file